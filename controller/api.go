@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/handlers"
@@ -36,11 +37,15 @@ import (
 
 type (
 	API struct {
-		fissionClient     *crd.FissionClient
+		fissionClient     crd.FissionClientInterface
 		kubernetesClient  *kubernetes.Clientset
 		storageServiceUrl string
 		builderManagerUrl string
 		workflowApiUrl    string
+		executorUrl       string
+		auth              *authenticator
+		revisions         revisionPolicy
+		auditLog          *auditLog
 	}
 
 	logDBConfig struct {
@@ -52,6 +57,9 @@ type (
 
 func MakeAPI() (*API, error) {
 	api, err := makeCRDBackedAPI()
+	if err != nil {
+		return nil, err
+	}
 
 	u := os.Getenv("STORAGE_SERVICE_URL")
 	if len(u) > 0 {
@@ -74,7 +82,61 @@ func MakeAPI() (*API, error) {
 		api.workflowApiUrl = "http://workflows-apiserver"
 	}
 
-	return api, err
+	u = os.Getenv("EXECUTOR_URL")
+	if len(u) > 0 {
+		api.executorUrl = strings.TrimSuffix(u, "/")
+	} else {
+		api.executorUrl = "http://executor"
+	}
+
+	api.revisions.limit = defaultRevisionLimit
+	if l := os.Getenv("FUNCTION_REVISION_LIMIT"); len(l) > 0 {
+		api.revisions.limit, err = strconv.Atoi(l)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid FUNCTION_REVISION_LIMIT '%v': %v", l, err)
+		}
+	}
+	if p := os.Getenv("PRUNE_REVISION_PACKAGES"); len(p) > 0 {
+		api.revisions.prunePackages, err = strconv.ParseBool(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid PRUNE_REVISION_PACKAGES '%v': %v", p, err)
+		}
+	}
+
+	auditLogSize := defaultAuditLogSize
+	if l := os.Getenv("AUDIT_LOG_SIZE"); len(l) > 0 {
+		auditLogSize, err = strconv.Atoi(l)
+		if err != nil || auditLogSize < 1 {
+			return nil, fmt.Errorf("Invalid AUDIT_LOG_SIZE '%v'", l)
+		}
+	}
+	auditSinks, err := makeAuditSinks(os.Getenv("AUDIT_LOG_SINKS"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid AUDIT_LOG_SINKS: %v", err)
+	}
+	api.auditLog = makeAuditLog(auditLogSize, auditSinks)
+
+	// Requests are authenticated with bearer tokens: service account
+	// and user tokens are checked with Kubernetes, and there may be
+	// static tokens in a secret.
+	if os.Getenv("AUTH_ENABLED") == "true" {
+		staticTokens := make(map[string]*userInfo)
+		secret := os.Getenv("AUTH_TOKEN_SECRET")
+		if len(secret) > 0 {
+			ns := os.Getenv("AUTH_TOKEN_SECRET_NAMESPACE")
+			if len(ns) == 0 {
+				ns = "fission"
+			}
+			staticTokens, err = loadStaticTokens(api.kubernetesClient, ns, secret)
+			if err != nil {
+				return nil, err
+			}
+		}
+		api.auth = makeAuthenticator(api.kubernetesClient, staticTokens)
+		log.Info("Authentication and authorization enabled")
+	}
+
+	return api, nil
 }
 
 func (api *API) respondWithSuccess(w http.ResponseWriter, resp []byte) {
@@ -139,54 +201,79 @@ func (api *API) Serve(port int) {
 	r.HandleFunc(`/v1/{rest:[a-zA-Z0-9=\-\/]+}`, api.ApiVersionMismatchHandler)
 	r.HandleFunc("/", api.HomeHandler)
 
-	r.HandleFunc("/v2/packages", api.PackageApiList).Methods("GET")
-	r.HandleFunc("/v2/packages", api.PackageApiCreate).Methods("POST")
-	r.HandleFunc("/v2/packages/{package}", api.PackageApiGet).Methods("GET")
-	r.HandleFunc("/v2/packages/{package}", api.PackageApiUpdate).Methods("PUT")
-	r.HandleFunc("/v2/packages/{package}", api.PackageApiDelete).Methods("DELETE")
-
-	r.HandleFunc("/v2/functions", api.FunctionApiList).Methods("GET")
-	r.HandleFunc("/v2/functions", api.FunctionApiCreate).Methods("POST")
-	r.HandleFunc("/v2/functions/{function}", api.FunctionApiGet).Methods("GET")
-	r.HandleFunc("/v2/functions/{function}", api.FunctionApiUpdate).Methods("PUT")
-	r.HandleFunc("/v2/functions/{function}", api.FunctionApiDelete).Methods("DELETE")
-
-	r.HandleFunc("/v2/triggers/http", api.HTTPTriggerApiList).Methods("GET")
-	r.HandleFunc("/v2/triggers/http", api.HTTPTriggerApiCreate).Methods("POST")
-	r.HandleFunc("/v2/triggers/http/{httpTrigger}", api.HTTPTriggerApiGet).Methods("GET")
-	r.HandleFunc("/v2/triggers/http/{httpTrigger}", api.HTTPTriggerApiUpdate).Methods("PUT")
-	r.HandleFunc("/v2/triggers/http/{httpTrigger}", api.HTTPTriggerApiDelete).Methods("DELETE")
-
-	r.HandleFunc("/v2/environments", api.EnvironmentApiList).Methods("GET")
-	r.HandleFunc("/v2/environments", api.EnvironmentApiCreate).Methods("POST")
-	r.HandleFunc("/v2/environments/{environment}", api.EnvironmentApiGet).Methods("GET")
-	r.HandleFunc("/v2/environments/{environment}", api.EnvironmentApiUpdate).Methods("PUT")
-	r.HandleFunc("/v2/environments/{environment}", api.EnvironmentApiDelete).Methods("DELETE")
-
-	r.HandleFunc("/v2/watches", api.WatchApiList).Methods("GET")
-	r.HandleFunc("/v2/watches", api.WatchApiCreate).Methods("POST")
-	r.HandleFunc("/v2/watches/{watch}", api.WatchApiGet).Methods("GET")
-	r.HandleFunc("/v2/watches/{watch}", api.WatchApiUpdate).Methods("PUT")
-	r.HandleFunc("/v2/watches/{watch}", api.WatchApiDelete).Methods("DELETE")
-
-	r.HandleFunc("/v2/triggers/time", api.TimeTriggerApiList).Methods("GET")
-	r.HandleFunc("/v2/triggers/time", api.TimeTriggerApiCreate).Methods("POST")
-	r.HandleFunc("/v2/triggers/time/{timeTrigger}", api.TimeTriggerApiGet).Methods("GET")
-	r.HandleFunc("/v2/triggers/time/{timeTrigger}", api.TimeTriggerApiUpdate).Methods("PUT")
-	r.HandleFunc("/v2/triggers/time/{timeTrigger}", api.TimeTriggerApiDelete).Methods("DELETE")
-
-	r.HandleFunc("/v2/triggers/messagequeue", api.MessageQueueTriggerApiList).Methods("GET")
-	r.HandleFunc("/v2/triggers/messagequeue", api.MessageQueueTriggerApiCreate).Methods("POST")
-	r.HandleFunc("/v2/triggers/messagequeue/{mqTrigger}", api.MessageQueueTriggerApiGet).Methods("GET")
-	r.HandleFunc("/v2/triggers/messagequeue/{mqTrigger}", api.MessageQueueTriggerApiUpdate).Methods("PUT")
-	r.HandleFunc("/v2/triggers/messagequeue/{mqTrigger}", api.MessageQueueTriggerApiDelete).Methods("DELETE")
-
-	r.HandleFunc("/v2/deleteTpr", api.Tpr2crdApi).Methods("DELETE")
-
-	r.HandleFunc("/proxy/{dbType}", api.FunctionLogsApiPost).Methods("POST")
-	r.HandleFunc("/proxy/storage/v1/archive", api.StorageServiceProxy)
-	r.HandleFunc("/proxy/logs/{function}", api.FunctionPodLogs).Methods("POST")
-	r.HandleFunc("/proxy/workflows-apiserver/{path:.*}", api.WorkflowApiserverProxy)
+	r.HandleFunc("/v2/packages", api.authorize("packages", "list", api.PackageApiList)).Methods("GET")
+	r.HandleFunc("/v2/packages", api.authorize("packages", "create", api.audit("packages", api.PackageApiCreate))).Methods("POST")
+	r.HandleFunc("/v2/packages/{package}", api.authorize("packages", "get", api.PackageApiGet)).Methods("GET")
+	r.HandleFunc("/v2/packages/{package}", api.authorize("packages", "update", api.audit("packages", api.PackageApiUpdate))).Methods("PUT")
+	r.HandleFunc("/v2/packages/{package}", api.authorize("packages", "delete", api.audit("packages", api.PackageApiDelete))).Methods("DELETE")
+
+	r.HandleFunc("/v2/functions", api.authorize("functions", "list", api.FunctionApiList)).Methods("GET")
+	r.HandleFunc("/v2/functions", api.authorize("functions", "create", api.audit("functions", api.FunctionApiCreate))).Methods("POST")
+	r.HandleFunc("/v2/functions/{function}", api.authorize("functions", "get", api.FunctionApiGet)).Methods("GET")
+	r.HandleFunc("/v2/functions/{function}", api.authorize("functions", "update", api.audit("functions", api.FunctionApiUpdate))).Methods("PUT")
+	r.HandleFunc("/v2/functions/{function}", api.authorize("functions", "delete", api.audit("functions", api.FunctionApiDelete))).Methods("DELETE")
+	r.HandleFunc("/v2/functions/{function}/events", api.authorize("functions", "get", api.FunctionEventsApiGet)).Methods("GET")
+	r.HandleFunc("/v2/functions/{function}/revisions", api.authorize("functionrevisions", "list", api.FunctionRevisionApiList)).Methods("GET")
+	r.HandleFunc("/v2/functions/{function}/revisions/{revision}", api.authorize("functionrevisions", "get", api.FunctionRevisionApiGet)).Methods("GET")
+	r.HandleFunc("/v2/functions/{function}/revisions/{revision}/rollback", api.authorize("functions", "update", api.audit("functions", api.FunctionRevisionApiRollback))).Methods("POST")
+
+	r.HandleFunc("/v2/triggers/http", api.authorize("httptriggers", "list", api.HTTPTriggerApiList)).Methods("GET")
+	r.HandleFunc("/v2/triggers/http", api.authorize("httptriggers", "create", api.audit("httptriggers", api.HTTPTriggerApiCreate))).Methods("POST")
+	r.HandleFunc("/v2/triggers/http/{httpTrigger}", api.authorize("httptriggers", "get", api.HTTPTriggerApiGet)).Methods("GET")
+	r.HandleFunc("/v2/triggers/http/{httpTrigger}", api.authorize("httptriggers", "update", api.audit("httptriggers", api.HTTPTriggerApiUpdate))).Methods("PUT")
+	r.HandleFunc("/v2/triggers/http/{httpTrigger}", api.authorize("httptriggers", "delete", api.audit("httptriggers", api.HTTPTriggerApiDelete))).Methods("DELETE")
+
+	r.HandleFunc("/v2/environments", api.authorize("environments", "list", api.EnvironmentApiList)).Methods("GET")
+	r.HandleFunc("/v2/environments", api.authorize("environments", "create", api.audit("environments", api.EnvironmentApiCreate))).Methods("POST")
+	r.HandleFunc("/v2/environments/{environment}", api.authorize("environments", "get", api.EnvironmentApiGet)).Methods("GET")
+	r.HandleFunc("/v2/environments/{environment}", api.authorize("environments", "update", api.audit("environments", api.EnvironmentApiUpdate))).Methods("PUT")
+	r.HandleFunc("/v2/environments/{environment}", api.authorize("environments", "delete", api.audit("environments", api.EnvironmentApiDelete))).Methods("DELETE")
+
+	r.HandleFunc("/v2/watches", api.authorize("kuberneteswatchtriggers", "list", api.WatchApiList)).Methods("GET")
+	r.HandleFunc("/v2/watches", api.authorize("kuberneteswatchtriggers", "create", api.audit("kuberneteswatchtriggers", api.WatchApiCreate))).Methods("POST")
+	r.HandleFunc("/v2/watches/{watch}", api.authorize("kuberneteswatchtriggers", "get", api.WatchApiGet)).Methods("GET")
+	r.HandleFunc("/v2/watches/{watch}", api.authorize("kuberneteswatchtriggers", "update", api.audit("kuberneteswatchtriggers", api.WatchApiUpdate))).Methods("PUT")
+	r.HandleFunc("/v2/watches/{watch}", api.authorize("kuberneteswatchtriggers", "delete", api.audit("kuberneteswatchtriggers", api.WatchApiDelete))).Methods("DELETE")
+
+	r.HandleFunc("/v2/triggers/time", api.authorize("timetriggers", "list", api.TimeTriggerApiList)).Methods("GET")
+	r.HandleFunc("/v2/triggers/time", api.authorize("timetriggers", "create", api.audit("timetriggers", api.TimeTriggerApiCreate))).Methods("POST")
+	r.HandleFunc("/v2/triggers/time/{timeTrigger}", api.authorize("timetriggers", "get", api.TimeTriggerApiGet)).Methods("GET")
+	r.HandleFunc("/v2/triggers/time/{timeTrigger}", api.authorize("timetriggers", "update", api.audit("timetriggers", api.TimeTriggerApiUpdate))).Methods("PUT")
+	r.HandleFunc("/v2/triggers/time/{timeTrigger}", api.authorize("timetriggers", "delete", api.audit("timetriggers", api.TimeTriggerApiDelete))).Methods("DELETE")
+
+	r.HandleFunc("/v2/triggers/messagequeue", api.authorize("messagequeuetriggers", "list", api.MessageQueueTriggerApiList)).Methods("GET")
+	r.HandleFunc("/v2/triggers/messagequeue", api.authorize("messagequeuetriggers", "create", api.audit("messagequeuetriggers", api.MessageQueueTriggerApiCreate))).Methods("POST")
+	r.HandleFunc("/v2/triggers/messagequeue/{mqTrigger}", api.authorize("messagequeuetriggers", "get", api.MessageQueueTriggerApiGet)).Methods("GET")
+	r.HandleFunc("/v2/triggers/messagequeue/{mqTrigger}", api.authorize("messagequeuetriggers", "update", api.audit("messagequeuetriggers", api.MessageQueueTriggerApiUpdate))).Methods("PUT")
+	r.HandleFunc("/v2/triggers/messagequeue/{mqTrigger}", api.authorize("messagequeuetriggers", "delete", api.audit("messagequeuetriggers", api.MessageQueueTriggerApiDelete))).Methods("DELETE")
+
+	// validation reads the environments and packages specs refer to
+	r.HandleFunc("/v2/validate", api.authorize("*", "get", api.ValidateApi)).Methods("POST")
+
+	// applying specs may create, update and delete objects of any kind;
+	// the bodies carry archives, so they aren't read to find the
+	// namespace, and objects in other namespaces are authorized by
+	// the handler
+	r.HandleFunc("/v2/apply", api.authorizeProxy("*", "*", api.ApplyApi)).Methods("POST")
+
+	// the audit log has changes to objects of every kind
+	r.HandleFunc("/v2/audit", api.authorize("*", "list", api.AuditApiList)).Methods("GET")
+
+	// backups have objects of every kind in all namespaces
+	r.HandleFunc("/v2/backup", api.authorizeAll("*", "list", api.BackupApi)).Methods("GET")
+	r.HandleFunc("/v2/restore", api.authorizeAll("*", "create", api.RestoreApi)).Methods("POST")
+
+	// converting TPRs to CRDs is for cluster admins only
+	r.HandleFunc("/v2/deleteTpr", api.authorizeAll("*", "delete", api.Tpr2crdApi)).Methods("DELETE")
+
+	// Reading logs is a subresource of functions, like pods/log;
+	// archives are part of packages. The bodies of proxied requests
+	// are passed on unread.
+	r.HandleFunc("/proxy/{dbType}", api.authorizeProxy("functions/log", "get", api.FunctionLogsApiPost)).Methods("POST")
+	r.HandleFunc("/proxy/storage/v1/archive", api.authorizeProxy("packages", "", api.StorageServiceProxy))
+	r.HandleFunc("/proxy/logs/{function}", api.authorizeProxy("functions/log", "get", api.FunctionPodLogs)).Methods("POST")
+	r.HandleFunc("/proxy/workflows-apiserver/{path:.*}", api.authorizeProxy("workflows", "", api.WorkflowApiserverProxy))
+	r.HandleFunc("/proxy/executor/{path:.*}", api.authorizeProxy("functions", "get", api.ExecutorProxy)).Methods("GET")
 
 	address := fmt.Sprintf(":%v", port)
 
@@ -73,7 +73,51 @@ func assertCronSpecFails(err error) {
 	assert(fe.Code == fission.ErrorInvalidArgument, "error must be a invalid argument error")
 }
 
+// requireCluster skips tests of the API server, which needs a cluster.
+func requireCluster(t *testing.T) {
+	if g.client == nil {
+		t.Skip("no kubernetes cluster")
+	}
+}
+
 func TestFunctionApi(t *testing.T) {
+	requireCluster(t)
+
+	testEnv := &crd.Environment{
+		Metadata: metav1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: metav1.NamespaceDefault,
+		},
+		Spec: fission.EnvironmentSpec{
+			Runtime: fission.Runtime{
+				Image: "fission/node-env",
+			},
+		},
+	}
+	em, err := g.client.EnvironmentCreate(testEnv)
+	panicIf(err)
+	defer g.client.EnvironmentDelete(em)
+
+	testPkg := &crd.Package{
+		Metadata: metav1.ObjectMeta{
+			Name:      "foo-pkg",
+			Namespace: metav1.NamespaceDefault,
+		},
+		Spec: fission.PackageSpec{
+			Environment: fission.EnvironmentReference{
+				Name:      "nodejs",
+				Namespace: metav1.NamespaceDefault,
+			},
+			Deployment: fission.Archive{
+				Type:    fission.ArchiveTypeLiteral,
+				Literal: []byte("module.exports = async function(context) {}"),
+			},
+		},
+	}
+	pm, err := g.client.PackageCreate(testPkg)
+	panicIf(err)
+	defer g.client.PackageDelete(pm)
+
 	testFunc := &crd.Function{
 		Metadata: metav1.ObjectMeta{
 			Name:      "foo",
@@ -84,11 +128,15 @@ func TestFunctionApi(t *testing.T) {
 				Name: "nodejs",
 			},
 			Package: fission.FunctionPackageRef{
+				PackageRef: fission.PackageRef{
+					Name:      "foo-pkg",
+					Namespace: metav1.NamespaceDefault,
+				},
 				FunctionName: "xxx",
 			},
 		},
 	}
-	_, err := g.client.FunctionGet(&metav1.ObjectMeta{
+	_, err = g.client.FunctionGet(&metav1.ObjectMeta{
 		Name:      testFunc.Metadata.Name,
 		Namespace: metav1.NamespaceDefault,
 	})
@@ -135,6 +183,8 @@ func TestFunctionApi(t *testing.T) {
 }
 
 func TestHTTPTriggerApi(t *testing.T) {
+	requireCluster(t)
+
 	testTrigger := &crd.HTTPTrigger{
 		Metadata: metav1.ObjectMeta{
 			Name:      "foo",
@@ -142,6 +192,7 @@ func TestHTTPTriggerApi(t *testing.T) {
 		},
 		Spec: fission.HTTPTriggerSpec{
 			RelativeURL: "/hello",
+			Method:      "GET",
 			FunctionReference: fission.FunctionReference{
 				Type: fission.FunctionReferenceTypeFunctionName,
 				Name: "foo",
@@ -186,6 +237,7 @@ func TestHTTPTriggerApi(t *testing.T) {
 }
 
 func TestEnvironmentApi(t *testing.T) {
+	requireCluster(t)
 
 	testEnv := &crd.Environment{
 		Metadata: metav1.ObjectMeta{
@@ -237,6 +289,8 @@ func TestEnvironmentApi(t *testing.T) {
 }
 
 func TestWatchApi(t *testing.T) {
+	requireCluster(t)
+
 	testWatch := &crd.KubernetesWatchTrigger{
 		Metadata: metav1.ObjectMeta{
 			Name:      "xxx",
@@ -281,6 +335,8 @@ func TestWatchApi(t *testing.T) {
 }
 
 func TestTimeTriggerApi(t *testing.T) {
+	requireCluster(t)
+
 	testTrigger := &crd.TimeTrigger{
 		Metadata: metav1.ObjectMeta{
 			Name:      "xxx",
@@ -330,8 +386,8 @@ func TestMain(m *testing.M) {
 	// skip test if no cluster available for testing
 	kubeconfig := os.Getenv("KUBECONFIG")
 	if len(kubeconfig) == 0 {
-		log.Println("Skipping test, no kubernetes cluster")
-		return
+		log.Println("Skipping API tests, no kubernetes cluster")
+		os.Exit(m.Run())
 	}
 
 	go Start(8888)
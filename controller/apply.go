@@ -0,0 +1,886 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	storageSvcClient "github.com/fission/fission/storagesvc/client"
+)
+
+// Packages in an apply request refer to archives uploaded along with
+// it as archive://<name>, where name is the name of the request's file
+// part.
+const archiveUrlPrefix = "archive://"
+
+// applyMaxMemory is how much of an apply request's archives is kept in
+// memory; the rest is spilled to temporary files.
+const applyMaxMemory = 32 << 20
+
+type (
+	// specObject is a Fission object of any kind.
+	specObject interface {
+		GetObjectMeta() metav1.Object
+	}
+
+	// applyKind has what applying specs needs to know about one kind
+	// of object.
+	applyKind struct {
+		kind     string // key of ApplyResult.Status
+		resource string
+		desired  []specObject
+
+		list   func(namespace string) ([]specObject, error)
+		get    func(m *metav1.ObjectMeta) (specObject, error)
+		create func(obj specObject) (specObject, error)
+		update func(obj specObject) (specObject, error)
+		delete func(m *metav1.ObjectMeta) error
+
+		// unchanged returns true if an existing object already
+		// matches a desired one. Without it, objects match if their
+		// specs do.
+		unchanged func(existing specObject, desired specObject) bool
+	}
+
+	// applier makes the objects of a deployment match a set of
+	// specs. If any change fails, the changes made before it are
+	// undone, so that the deployment is either fully applied or left
+	// as it was.
+	applier struct {
+		fissionClient     crd.FissionClientInterface
+		storageServiceUrl string
+		revisions         revisionPolicy
+		req               *crd.ApplyRequest
+		namespaces        []string
+		dryRun            bool
+
+		status    map[string]fission.ApplyStatus
+		packages  map[string]*crd.Package // namespace/name -> stored package
+		functions []*crd.Function         // created or updated
+		changes   []appliedChange
+		undo      []func() error
+	}
+
+	// appliedChange is a change to an object, for the audit log.
+	appliedChange struct {
+		verb     string
+		resource string
+		meta     *metav1.ObjectMeta
+		before   specObject
+		after    specObject
+	}
+)
+
+func objectMeta(obj specObject) *metav1.ObjectMeta {
+	return obj.GetObjectMeta().(*metav1.ObjectMeta)
+}
+
+// metaKey identifies an object of a kind across namespaces.
+func metaKey(m *metav1.ObjectMeta) string {
+	return m.Namespace + "/" + m.Name
+}
+
+// sameSpec returns true if two objects have the same spec.
+func sameSpec(a specObject, b specObject) bool {
+	var specA, specB struct {
+		Spec json.RawMessage `json:"spec"`
+	}
+	for _, o := range []struct {
+		obj  specObject
+		spec interface{}
+	}{{a, &specA}, {b, &specB}} {
+		buf, err := json.Marshal(o.obj)
+		if err != nil {
+			return false
+		}
+		if json.Unmarshal(buf, o.spec) != nil {
+			return false
+		}
+	}
+	return string(specA.Spec) == string(specB.Spec)
+}
+
+// samePackage returns true if an existing package doesn't need to be
+// updated: either its spec is the same, or it was built from the same
+// source in the same way, in which case the desired package has no
+// deployment archive yet.
+func samePackage(existing specObject, desired specObject) bool {
+	e, d := existing.(*crd.Package), desired.(*crd.Package)
+	if sameSpec(e, d) {
+		return true
+	}
+	return reflect.DeepEqual(e.Spec.Environment, d.Spec.Environment) &&
+		!reflect.DeepEqual(e.Spec.Source, fission.Archive{}) &&
+		reflect.DeepEqual(e.Spec.Source, d.Spec.Source) &&
+		e.Spec.BuildCommand == d.Spec.BuildCommand
+}
+
+// applyKinds returns the kinds of objects in res, in the order they
+// must be created in so that references between them are valid.
+func applyKinds(fc crd.FissionClientInterface, res *crd.Resources) []*applyKind {
+	envs := &applyKind{
+		kind:     "environment",
+		resource: "environments",
+		list: func(ns string) ([]specObject, error) {
+			l, err := fc.Environments(ns).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]specObject, len(l.Items))
+			for i := range l.Items {
+				objs[i] = &l.Items[i]
+			}
+			return objs, nil
+		},
+		get: func(m *metav1.ObjectMeta) (specObject, error) {
+			return fc.Environments(m.Namespace).Get(m.Name)
+		},
+		create: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.Environment)
+			return fc.Environments(o.Metadata.Namespace).Create(o)
+		},
+		update: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.Environment)
+			return fc.Environments(o.Metadata.Namespace).Update(o)
+		},
+		delete: func(m *metav1.ObjectMeta) error {
+			return fc.Environments(m.Namespace).Delete(m.Name, &metav1.DeleteOptions{})
+		},
+	}
+	for i := range res.Environments {
+		envs.desired = append(envs.desired, &res.Environments[i])
+	}
+
+	pkgs := &applyKind{
+		kind:     "package",
+		resource: "packages",
+		list: func(ns string) ([]specObject, error) {
+			l, err := fc.Packages(ns).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]specObject, len(l.Items))
+			for i := range l.Items {
+				objs[i] = &l.Items[i]
+			}
+			return objs, nil
+		},
+		get: func(m *metav1.ObjectMeta) (specObject, error) {
+			return fc.Packages(m.Namespace).Get(m.Name)
+		},
+		create: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.Package)
+			return fc.Packages(o.Metadata.Namespace).Create(o)
+		},
+		update: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.Package)
+			return fc.Packages(o.Metadata.Namespace).Update(o)
+		},
+		delete: func(m *metav1.ObjectMeta) error {
+			return fc.Packages(m.Namespace).Delete(m.Name, &metav1.DeleteOptions{})
+		},
+		unchanged: samePackage,
+	}
+	for i := range res.Packages {
+		pkgs.desired = append(pkgs.desired, &res.Packages[i])
+	}
+
+	fns := &applyKind{
+		kind:     "function",
+		resource: "functions",
+		list: func(ns string) ([]specObject, error) {
+			l, err := fc.Functions(ns).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]specObject, len(l.Items))
+			for i := range l.Items {
+				objs[i] = &l.Items[i]
+			}
+			return objs, nil
+		},
+		get: func(m *metav1.ObjectMeta) (specObject, error) {
+			return fc.Functions(m.Namespace).Get(m.Name)
+		},
+		create: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.Function)
+			return fc.Functions(o.Metadata.Namespace).Create(o)
+		},
+		update: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.Function)
+			return fc.Functions(o.Metadata.Namespace).Update(o)
+		},
+		delete: func(m *metav1.ObjectMeta) error {
+			return fc.Functions(m.Namespace).Delete(m.Name, &metav1.DeleteOptions{})
+		},
+	}
+	for i := range res.Functions {
+		fns.desired = append(fns.desired, &res.Functions[i])
+	}
+
+	httpTriggers := &applyKind{
+		kind:     "HTTPTrigger",
+		resource: "httptriggers",
+		list: func(ns string) ([]specObject, error) {
+			l, err := fc.HTTPTriggers(ns).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]specObject, len(l.Items))
+			for i := range l.Items {
+				objs[i] = &l.Items[i]
+			}
+			return objs, nil
+		},
+		get: func(m *metav1.ObjectMeta) (specObject, error) {
+			return fc.HTTPTriggers(m.Namespace).Get(m.Name)
+		},
+		create: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.HTTPTrigger)
+			return fc.HTTPTriggers(o.Metadata.Namespace).Create(o)
+		},
+		update: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.HTTPTrigger)
+			return fc.HTTPTriggers(o.Metadata.Namespace).Update(o)
+		},
+		delete: func(m *metav1.ObjectMeta) error {
+			return fc.HTTPTriggers(m.Namespace).Delete(m.Name, &metav1.DeleteOptions{})
+		},
+	}
+	for i := range res.HTTPTriggers {
+		httpTriggers.desired = append(httpTriggers.desired, &res.HTTPTriggers[i])
+	}
+
+	watches := &applyKind{
+		kind:     "KubernetesWatchTrigger",
+		resource: "kuberneteswatchtriggers",
+		list: func(ns string) ([]specObject, error) {
+			l, err := fc.KubernetesWatchTriggers(ns).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]specObject, len(l.Items))
+			for i := range l.Items {
+				objs[i] = &l.Items[i]
+			}
+			return objs, nil
+		},
+		get: func(m *metav1.ObjectMeta) (specObject, error) {
+			return fc.KubernetesWatchTriggers(m.Namespace).Get(m.Name)
+		},
+		create: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.KubernetesWatchTrigger)
+			return fc.KubernetesWatchTriggers(o.Metadata.Namespace).Create(o)
+		},
+		update: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.KubernetesWatchTrigger)
+			return fc.KubernetesWatchTriggers(o.Metadata.Namespace).Update(o)
+		},
+		delete: func(m *metav1.ObjectMeta) error {
+			return fc.KubernetesWatchTriggers(m.Namespace).Delete(m.Name, &metav1.DeleteOptions{})
+		},
+	}
+	for i := range res.KubernetesWatchTriggers {
+		watches.desired = append(watches.desired, &res.KubernetesWatchTriggers[i])
+	}
+
+	timeTriggers := &applyKind{
+		kind:     "TimeTrigger",
+		resource: "timetriggers",
+		list: func(ns string) ([]specObject, error) {
+			l, err := fc.TimeTriggers(ns).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]specObject, len(l.Items))
+			for i := range l.Items {
+				objs[i] = &l.Items[i]
+			}
+			return objs, nil
+		},
+		get: func(m *metav1.ObjectMeta) (specObject, error) {
+			return fc.TimeTriggers(m.Namespace).Get(m.Name)
+		},
+		create: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.TimeTrigger)
+			return fc.TimeTriggers(o.Metadata.Namespace).Create(o)
+		},
+		update: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.TimeTrigger)
+			return fc.TimeTriggers(o.Metadata.Namespace).Update(o)
+		},
+		delete: func(m *metav1.ObjectMeta) error {
+			return fc.TimeTriggers(m.Namespace).Delete(m.Name, &metav1.DeleteOptions{})
+		},
+	}
+	for i := range res.TimeTriggers {
+		timeTriggers.desired = append(timeTriggers.desired, &res.TimeTriggers[i])
+	}
+
+	mqTriggers := &applyKind{
+		kind:     "MessageQueueTrigger",
+		resource: "messagequeuetriggers",
+		list: func(ns string) ([]specObject, error) {
+			l, err := fc.MessageQueueTriggers(ns).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]specObject, len(l.Items))
+			for i := range l.Items {
+				objs[i] = &l.Items[i]
+			}
+			return objs, nil
+		},
+		get: func(m *metav1.ObjectMeta) (specObject, error) {
+			return fc.MessageQueueTriggers(m.Namespace).Get(m.Name)
+		},
+		create: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.MessageQueueTrigger)
+			return fc.MessageQueueTriggers(o.Metadata.Namespace).Create(o)
+		},
+		update: func(obj specObject) (specObject, error) {
+			o := obj.(*crd.MessageQueueTrigger)
+			return fc.MessageQueueTriggers(o.Metadata.Namespace).Update(o)
+		},
+		delete: func(m *metav1.ObjectMeta) error {
+			return fc.MessageQueueTriggers(m.Namespace).Delete(m.Name, &metav1.DeleteOptions{})
+		},
+	}
+	for i := range res.MessageQueueTriggers {
+		mqTriggers.desired = append(mqTriggers.desired, &res.MessageQueueTriggers[i])
+	}
+
+	return []*applyKind{envs, pkgs, fns, httpTriggers, watches, timeTriggers, mqTriggers}
+}
+
+// archiveRefs returns the archives of a package that refer to uploaded
+// archives, along with their field paths.
+func archiveRefs(pkg *crd.Package) map[string]*fission.Archive {
+	refs := make(map[string]*fission.Archive)
+	for path, ar := range map[string]*fission.Archive{
+		"spec.source":     &pkg.Spec.Source,
+		"spec.deployment": &pkg.Spec.Deployment,
+	} {
+		if strings.HasPrefix(ar.URL, archiveUrlPrefix) {
+			refs[path] = ar
+		}
+	}
+	return refs
+}
+
+// validate checks that the objects of an apply request are valid, and
+// that the archives they refer to were uploaded. It returns an ErrorInvalidArgument listing all the
+// problems it finds.
+func (ap *applier) validate(files map[string][]*multipart.FileHeader) error {
+	res := &ap.req.Resources
+	results, err := makeValidator(ap.fissionClient).validateResources(res)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range applyKinds(ap.fissionClient, res) {
+		for _, obj := range k.desired {
+			m := objectMeta(obj)
+			errs := make(fieldErrors, 0)
+			if pkg, ok := obj.(*crd.Package); ok {
+				for path, ar := range archiveRefs(pkg) {
+					name := strings.TrimPrefix(ar.URL, archiveUrlPrefix)
+					if len(files[name]) == 0 {
+						errs.add(path+".url", "archive %v wasn't uploaded", name)
+					}
+				}
+			}
+			if len(errs) > 0 {
+				results = append(results, fission.ValidationResult{
+					Kind:      reflect.TypeOf(obj).Elem().Name(),
+					Namespace: m.Namespace,
+					Name:      m.Name,
+					Errors:    errs,
+				})
+			}
+		}
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(results))
+	for i, vr := range results {
+		msgs[i] = fission.MakeValidationError(vr.Kind, vr.Name, vr.Errors).Message
+	}
+	return fission.MakeError(fission.ErrorInvalidArgument, strings.Join(msgs, "\n"))
+}
+
+// uploadArchive stores an uploaded archive in the storage service,
+// after checking it against the checksum the client sent, if any.
+func (ap *applier) uploadArchive(name string, fh *multipart.FileHeader, checksum fission.Checksum) (*fission.Archive, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// the storage service client uploads files
+	tmp, err := ioutil.TempFile("", "fission-apply-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), f)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if len(checksum.Sum) > 0 && checksum.Sum != sum {
+		return nil, fission.MakeError(fission.ErrorChecksumFail,
+			fmt.Sprintf("Archive %v has checksum %v, expected %v", name, sum, checksum.Sum))
+	}
+
+	ssClient := storageSvcClient.MakeClient(ap.storageServiceUrl)
+	id, err := ssClient.Upload(tmp.Name(), nil)
+	if err != nil {
+		return nil, err
+	}
+	ap.undo = append(ap.undo, func() error {
+		return ssClient.Delete(id)
+	})
+
+	return &fission.Archive{
+		Type: fission.ArchiveTypeUrl,
+		URL:  ssClient.GetUrl(id),
+		Checksum: fission.Checksum{
+			Type: fission.ChecksumTypeSHA256,
+			Sum:  sum,
+		},
+	}, nil
+}
+
+// uploadArchives uploads the archives packages refer to, and points
+// the packages at them.
+func (ap *applier) uploadArchives(files map[string][]*multipart.FileHeader) error {
+	uploaded := make(map[string]*fission.Archive)
+	res := &ap.req.Resources
+	for i := range res.Packages {
+		for _, ar := range archiveRefs(&res.Packages[i]) {
+			name := strings.TrimPrefix(ar.URL, archiveUrlPrefix)
+			up, ok := uploaded[name]
+			if !ok {
+				var err error
+				up, err = ap.uploadArchive(name, files[name][0], ar.Checksum)
+				if err != nil {
+					return err
+				}
+				uploaded[name] = up
+			}
+			*ar = *up
+		}
+	}
+	return nil
+}
+
+// setPackageVersions makes functions refer to the current versions of
+// the packages they use, so that caches of the functions are
+// invalidated when their packages change.
+func (ap *applier) setPackageVersions() {
+	fns := ap.req.Resources.Functions
+	for i := range fns {
+		ref := &fns[i].Spec.Package.PackageRef
+		pkg, ok := ap.packages[namespaceOr(ref.Namespace, fns[i].Metadata.Namespace)+"/"+ref.Name]
+		if ok {
+			ref.ResourceVersion = pkg.Metadata.ResourceVersion
+		}
+	}
+}
+
+// existing returns the objects of a kind in the namespaces of the
+// request that belong to the deployment, and the others, keyed by
+// metaKey.
+func (ap *applier) existing(k *applyKind) (map[string]specObject, map[string]bool, error) {
+	existing := make(map[string]specObject)
+	others := make(map[string]bool)
+	for _, ns := range ap.namespaces {
+		objs, err := k.list(ns)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, obj := range objs {
+			m := objectMeta(obj)
+			if m.Annotations[fission.DEPLOYMENT_UID_ANNOTATION] == ap.req.DeploymentUID {
+				existing[metaKey(m)] = obj
+			} else {
+				others[metaKey(m)] = true
+			}
+		}
+	}
+	return existing, others, nil
+}
+
+// applyObjects creates or updates the desired objects of a kind.
+// Objects of other deployments, or created some other way, are left
+// alone.
+func (ap *applier) applyObjects(k *applyKind, existing map[string]specObject, others map[string]bool) error {
+	status := ap.status[k.kind]
+	unchanged := k.unchanged
+	if unchanged == nil {
+		unchanged = sameSpec
+	}
+
+	for _, obj := range k.desired {
+		m := objectMeta(obj)
+		if m.Annotations == nil {
+			m.Annotations = make(map[string]string)
+		}
+		m.Annotations[fission.DEPLOYMENT_NAME_ANNOTATION] = ap.req.DeploymentName
+		m.Annotations[fission.DEPLOYMENT_UID_ANNOTATION] = ap.req.DeploymentUID
+
+		stored := obj
+		old, ok := existing[metaKey(m)]
+		if ok && unchanged(old, obj) {
+			stored = old
+		} else if ok {
+			oldMeta := objectMeta(old)
+			m.ResourceVersion = oldMeta.ResourceVersion
+			if !ap.dryRun {
+				var err error
+				stored, err = k.update(obj)
+				if err != nil {
+					return err
+				}
+				// Others, e.g. the builder manager updating a
+				// package's status, may have changed the object
+				// since.
+				ap.undo = append(ap.undo, func() error {
+					return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+						current, err := k.get(oldMeta)
+						if err != nil {
+							return err
+						}
+						oldMeta.ResourceVersion = objectMeta(current).ResourceVersion
+						_, err = k.update(old)
+						return err
+					})
+				})
+				ap.changes = append(ap.changes, appliedChange{"update", k.resource, objectMeta(stored), old, stored})
+			}
+			status.Updated = append(status.Updated, *objectMeta(stored))
+		} else if others[metaKey(m)] {
+			return k8serrors.NewAlreadyExists(groupResource(k.resource), m.Name)
+		} else {
+			if !ap.dryRun {
+				var err error
+				stored, err = k.create(obj)
+				if err != nil {
+					return err
+				}
+				created := objectMeta(stored)
+				ap.undo = append(ap.undo, func() error {
+					return k.delete(created)
+				})
+				ap.changes = append(ap.changes, appliedChange{"create", k.resource, created, nil, stored})
+			}
+			status.Created = append(status.Created, *objectMeta(stored))
+		}
+
+		switch o := stored.(type) {
+		case *crd.Package:
+			ap.packages[o.Metadata.Namespace+"/"+o.Metadata.Name] = o
+		case *crd.Function:
+			if stored != old {
+				ap.functions = append(ap.functions, o)
+			}
+		}
+	}
+
+	ap.status[k.kind] = status
+	return nil
+}
+
+// deleteObjects deletes the objects of a kind that belong to the
+// deployment but aren't desired any more.
+func (ap *applier) deleteObjects(k *applyKind, existing map[string]specObject) error {
+	desired := make(map[string]bool)
+	for _, obj := range k.desired {
+		desired[metaKey(objectMeta(obj))] = true
+	}
+
+	status := ap.status[k.kind]
+	for key, old := range existing {
+		if desired[key] {
+			continue
+		}
+		old := old
+		m := objectMeta(old)
+		if !ap.dryRun {
+			err := k.delete(m)
+			if err != nil {
+				return err
+			}
+			ap.undo = append(ap.undo, func() error {
+				m.ResourceVersion = ""
+				m.UID = ""
+				_, err := k.create(old)
+				return err
+			})
+			ap.changes = append(ap.changes, appliedChange{"delete", k.resource, m, old, nil})
+		}
+		status.Deleted = append(status.Deleted, *m)
+	}
+	ap.status[k.kind] = status
+	return nil
+}
+
+// rollback undoes the changes made so far, most recent first. Errors
+// are logged, so that as much as possible is undone, and the number of
+// changes that couldn't be undone is returned.
+func (ap *applier) rollback() int {
+	failed := 0
+	for i := len(ap.undo) - 1; i >= 0; i-- {
+		err := ap.undo[i]()
+		if err != nil {
+			log.Errorf("Error rolling back apply of deployment %v: %v", ap.req.DeploymentName, err)
+			failed++
+		}
+	}
+	ap.undo = nil
+	return failed
+}
+
+// apply applies the request's objects, or undoes all its changes if
+// any of them fail.
+func (ap *applier) apply(files map[string][]*multipart.FileHeader) (*fission.ApplyResult, error) {
+	kinds := applyKinds(ap.fissionClient, &ap.req.Resources)
+	existing := make([]map[string]specObject, len(kinds))
+	others := make([]map[string]bool, len(kinds))
+	for i, k := range kinds {
+		var err error
+		existing[i], others[i], err = ap.existing(k)
+		if err != nil {
+			return nil, err
+		}
+		ap.status[k.kind] = fission.ApplyStatus{}
+	}
+
+	err := ap.applyAll(kinds, existing, others, files)
+	if err != nil {
+		if failed := ap.rollback(); failed > 0 {
+			_, msg := fission.GetHTTPError(err)
+			return nil, fission.MakeError(fission.ErrorInternal,
+				fmt.Sprintf("%v; rollback is partial, %v changes couldn't be undone", msg, failed))
+		}
+		return nil, err
+	}
+	if ap.dryRun {
+		return ap.result(), nil
+	}
+
+	// The functions are there (or gone) either way, so revision
+	// errors are only logged.
+	for _, fn := range ap.functions {
+		_, err := recordRevision(ap.fissionClient, fn, ap.revisions, 0)
+		if err != nil {
+			log.Errorf("Error recording revision of function %v: %v", fn.Metadata.Name, err)
+		}
+	}
+	for _, m := range ap.status["function"].Deleted {
+		err := deleteRevisions(ap.fissionClient, m.Namespace, m.Name)
+		if err != nil {
+			log.Errorf("Error deleting revisions of function %v: %v", m.Name, err)
+		}
+	}
+	return ap.result(), nil
+}
+
+func (ap *applier) result() *fission.ApplyResult {
+	result := &fission.ApplyResult{
+		Status:   ap.status,
+		Packages: make([]metav1.ObjectMeta, 0, len(ap.packages)),
+	}
+	for _, pkg := range ap.packages {
+		result.Packages = append(result.Packages, pkg.Metadata)
+	}
+	return result
+}
+
+func (ap *applier) applyAll(kinds []*applyKind, existing []map[string]specObject, others []map[string]bool, files map[string][]*multipart.FileHeader) error {
+	if !ap.dryRun {
+		err := ap.uploadArchives(files)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, k := range kinds {
+		if k.resource == "functions" {
+			ap.setPackageVersions()
+		}
+		err := ap.applyObjects(k, existing[i], others[i])
+		if err != nil {
+			log.Errorf("Error applying %v: %v", k.resource, err)
+			return err
+		}
+	}
+
+	if !ap.req.Delete {
+		return nil
+	}
+	// objects are deleted before the ones they refer to
+	for i := len(kinds) - 1; i >= 0; i-- {
+		err := ap.deleteObjects(kinds[i], existing[i])
+		if err != nil {
+			log.Errorf("Error deleting %v: %v", kinds[i].resource, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// parseApplyRequest reads an apply request, which is either JSON or a
+// multipart form with the JSON in its "request" field and the archives
+// packages refer to as files.
+func parseApplyRequest(r *http.Request) (*crd.ApplyRequest, map[string][]*multipart.FileHeader, error) {
+	var body []byte
+	var files map[string][]*multipart.FileHeader
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err := r.ParseMultipartForm(applyMaxMemory)
+		if err != nil {
+			return nil, nil, fission.MakeError(fission.ErrorInvalidArgument, err.Error())
+		}
+		values := r.MultipartForm.Value["request"]
+		if len(values) == 0 {
+			return nil, nil, fission.MakeError(fission.ErrorInvalidArgument, "Apply request has no request field")
+		}
+		body = []byte(values[0])
+		files = r.MultipartForm.File
+	} else {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var req crd.ApplyRequest
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		return nil, nil, fission.MakeError(fission.ErrorInvalidArgument, err.Error())
+	}
+	if len(req.DeploymentUID) == 0 {
+		return nil, nil, fission.MakeError(fission.ErrorInvalidArgument, "Apply request needs a deploymentUID")
+	}
+	return &req, files, nil
+}
+
+// ApplyApi makes the objects of a deployment match a set of specs, in
+// a single request: it validates all of them first, uploads the
+// archives the request carries, creates, updates (and, if asked,
+// deletes) objects in dependency order, and undoes all of it if any
+// step fails. It responds with what changed.
+func (a *API) ApplyApi(w http.ResponseWriter, r *http.Request) {
+	req, files, err := parseApplyRequest(r)
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	// Objects without a namespace go in the namespace of the request.
+	// Specs may have objects in several namespaces; the request was
+	// only authorized for its own, so the others are authorized here.
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+	namespaces := []string{ns}
+	seen := map[string]bool{ns: true}
+	for _, k := range applyKinds(a.fissionClient, &req.Resources) {
+		for _, obj := range k.desired {
+			m := objectMeta(obj)
+			m.Namespace = namespaceOr(m.Namespace, ns)
+			if !seen[m.Namespace] {
+				seen[m.Namespace] = true
+				namespaces = append(namespaces, m.Namespace)
+			}
+		}
+	}
+	if a.auth != nil {
+		for _, objNs := range namespaces[1:] {
+			err = a.auth.authorize(requestUser(r), objNs, "*", "*")
+			if err != nil {
+				a.respondWithError(w, err)
+				return
+			}
+		}
+	}
+
+	ap := &applier{
+		fissionClient:     a.fissionClient,
+		storageServiceUrl: a.storageServiceUrl,
+		revisions:         a.revisions,
+		req:               req,
+		namespaces:        namespaces,
+		dryRun:            isDryRun(r),
+		status:            make(map[string]fission.ApplyStatus),
+		packages:          make(map[string]*crd.Package),
+	}
+
+	err = ap.validate(files)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	result, err := ap.apply(files)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	for _, c := range ap.changes {
+		var before, after interface{}
+		if c.before != nil {
+			before = c.before
+		}
+		if c.after != nil {
+			after = c.after
+		}
+		a.recordChange(r, c.verb, c.resource, c.meta.Namespace, c.meta.Name, before, after)
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	a.respondWithSuccess(w, resp)
+}
@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/controller/client"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+	"github.com/fission/fission/storagesvc"
+)
+
+func TestApply(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+
+	// a storage service that remembers what's uploaded
+	archives := make(map[string]bool)
+	uploads := 0
+	ss := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			uploads++
+			id := fmt.Sprintf("archive-%v", uploads)
+			archives[id] = true
+			json.NewEncoder(w).Encode(storagesvc.UploadResponse{ID: id})
+		case "DELETE":
+			delete(archives, r.URL.Query().Get("id"))
+		}
+	}))
+	defer ss.Close()
+
+	api := &API{fissionClient: fc, storageServiceUrl: ss.URL, revisions: revisionPolicy{limit: 2}}
+	r := mux.NewRouter()
+	r.HandleFunc("/v2/apply", api.ApplyApi).Methods("POST")
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	fclient := client.MakeClient(srv.URL)
+
+	contents := []byte("module.exports = function(context) {}")
+	archiveFile, err := ioutil.TempFile("", "fission-apply-test-")
+	if err != nil {
+		t.Fatalf("Error creating archive: %v", err)
+	}
+	defer os.Remove(archiveFile.Name())
+	archiveFile.Write(contents)
+	archiveFile.Close()
+	sum := sha256.Sum256(contents)
+
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: ns}
+	}
+	function := func(name string) crd.Function {
+		return crd.Function{
+			Metadata: meta(name),
+			Spec: fission.FunctionSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+				Package: fission.FunctionPackageRef{
+					PackageRef: fission.PackageRef{Name: "hello-pkg", Namespace: ns},
+				},
+			},
+		}
+	}
+	httpTrigger := func(name string) crd.HTTPTrigger {
+		return crd.HTTPTrigger{
+			Metadata: meta(name),
+			Spec: fission.HTTPTriggerSpec{
+				RelativeURL:       "/" + name,
+				Method:            "GET",
+				FunctionReference: fission.FunctionReference{Name: "hello"},
+			},
+		}
+	}
+	req := &crd.ApplyRequest{
+		DeploymentName: "test",
+		DeploymentUID:  "1234",
+		Resources: crd.Resources{
+			Environments: []crd.Environment{{
+				Metadata: meta("nodejs"),
+				Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+			}},
+			Packages: []crd.Package{{
+				Metadata: meta("hello-pkg"),
+				Spec: fission.PackageSpec{
+					Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+					Deployment: fission.Archive{
+						Type:     fission.ArchiveTypeUrl,
+						URL:      "archive://hello",
+						Checksum: fission.Checksum{Type: fission.ChecksumTypeSHA256, Sum: hex.EncodeToString(sum[:])},
+					},
+				},
+			}},
+			Functions:    []crd.Function{function("hello")},
+			HTTPTriggers: []crd.HTTPTrigger{httpTrigger("hello")},
+		},
+	}
+	expectNames := func(what string, ms []metav1.ObjectMeta, names ...string) {
+		got := make([]string, len(ms))
+		for i, m := range ms {
+			got[i] = m.Name
+		}
+		if strings.Join(got, ",") != strings.Join(names, ",") {
+			t.Errorf("Expected %v %v, got %v", what, names, got)
+		}
+	}
+
+	// archives that weren't uploaded are caught before anything changes
+	_, err = fclient.Apply(req, nil)
+	if fe, ok := err.(fission.Error); !ok || fe.Code != fission.ErrorInvalidArgument {
+		t.Fatalf("Expected apply without archives to be invalid, got %v", err)
+	}
+
+	// a dry run changes nothing
+	fclient.DryRun = true
+	result, err := fclient.Apply(req, map[string]string{"hello": archiveFile.Name()})
+	if err != nil {
+		t.Fatalf("Error in dry run: %v", err)
+	}
+	expectNames("created functions", result.Status["function"].Created, "hello")
+	if len(archives) != 0 {
+		t.Errorf("Expected dry run not to upload archives")
+	}
+	if fns, _ := fc.Functions(ns).List(metav1.ListOptions{}); len(fns.Items) != 0 {
+		t.Errorf("Expected dry run not to create functions")
+	}
+	fclient.DryRun = false
+
+	// first apply creates everything
+	result, err = fclient.Apply(req, map[string]string{"hello": archiveFile.Name()})
+	if err != nil {
+		t.Fatalf("Error applying: %v", err)
+	}
+	expectNames("created environments", result.Status["environment"].Created, "nodejs")
+	expectNames("created packages", result.Status["package"].Created, "hello-pkg")
+	expectNames("created functions", result.Status["function"].Created, "hello")
+	expectNames("created triggers", result.Status["HTTPTrigger"].Created, "hello")
+	expectNames("packages", result.Packages, "hello-pkg")
+
+	pkg, err := fc.Packages(ns).Get("hello-pkg")
+	if err != nil {
+		t.Fatalf("Error getting package: %v", err)
+	}
+	if !strings.HasPrefix(pkg.Spec.Deployment.URL, ss.URL) || len(archives) != 1 {
+		t.Errorf("Expected package to refer to uploaded archive, got %v", pkg.Spec.Deployment.URL)
+	}
+	if pkg.Metadata.Annotations[fission.DEPLOYMENT_UID_ANNOTATION] != "1234" {
+		t.Errorf("Expected package to be annotated with its deployment, got %v", pkg.Metadata.Annotations)
+	}
+	fn, err := fc.Functions(ns).Get("hello")
+	if err != nil {
+		t.Fatalf("Error getting function: %v", err)
+	}
+	if fn.Spec.Package.PackageRef.ResourceVersion != pkg.Metadata.ResourceVersion {
+		t.Errorf("Expected function to refer to package version %v, got %v",
+			pkg.Metadata.ResourceVersion, fn.Spec.Package.PackageRef.ResourceVersion)
+	}
+
+	// unchanged objects are kept, and ones that aren't wanted any
+	// more are deleted
+	req.Resources.Packages[0].Spec.Deployment = pkg.Spec.Deployment
+	req.Resources.HTTPTriggers = nil
+	req.Delete = true
+	result, err = fclient.Apply(req, nil)
+	if err != nil {
+		t.Fatalf("Error applying: %v", err)
+	}
+	for typ, as := range result.Status {
+		if len(as.Created)+len(as.Updated) > 0 {
+			t.Errorf("Expected no %v to be created or updated, got %#v", typ, as)
+		}
+	}
+	expectNames("deleted triggers", result.Status["HTTPTrigger"].Deleted, "hello")
+	if _, err := fc.HTTPTriggers(ns).Get("hello"); err == nil {
+		t.Errorf("Expected trigger to be deleted")
+	}
+
+	// a failure undoes everything: the new trigger's name is taken by
+	// a trigger outside the deployment
+	_, err = fc.HTTPTriggers(ns).Create(&crd.HTTPTrigger{
+		Metadata: meta("taken"),
+		Spec:     httpTrigger("taken").Spec,
+	})
+	if err != nil {
+		t.Fatalf("Error creating trigger: %v", err)
+	}
+	req.Resources.Packages[0].Spec.Deployment.URL = "archive://hello"
+	req.Resources.Functions = append(req.Resources.Functions, function("other"))
+	req.Resources.HTTPTriggers = []crd.HTTPTrigger{httpTrigger("taken")}
+	_, err = fclient.Apply(req, map[string]string{"hello": archiveFile.Name()})
+	if fe, ok := err.(fission.Error); !ok || fe.Code != fission.ErrorNameExists {
+		t.Fatalf("Expected apply to fail with a name conflict, got %v", err)
+	}
+	if _, err := fc.Functions(ns).Get("other"); err == nil {
+		t.Errorf("Expected created function to be deleted")
+	}
+	pkg2, err := fc.Packages(ns).Get("hello-pkg")
+	if err != nil {
+		t.Fatalf("Error getting package: %v", err)
+	}
+	if pkg2.Spec.Deployment.URL != pkg.Spec.Deployment.URL {
+		t.Errorf("Expected package to be restored, got %v", pkg2.Spec.Deployment.URL)
+	}
+	if len(archives) != 1 {
+		t.Errorf("Expected the archive uploaded by the failed apply to be deleted, got %v", archives)
+	}
+}
+
+func TestApplyNamespaces(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	api := &API{fissionClient: fc, auth: makeTestAuthenticator()}
+	r := mux.NewRouter()
+	r.HandleFunc("/v2/apply", api.authorizeProxy("*", "*", api.ApplyApi)).Methods("POST")
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	env := func(ns string) crd.Environment {
+		return crd.Environment{
+			Metadata: metav1.ObjectMeta{Name: "nodejs", Namespace: ns},
+			Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+		}
+	}
+	apply := func(token string, envs ...crd.Environment) error {
+		fclient := client.MakeClient(srv.URL)
+		fclient.Token = token
+		_, err := fclient.Apply(&crd.ApplyRequest{
+			DeploymentName: "test",
+			DeploymentUID:  "1234",
+			Resources:      crd.Resources{Environments: envs},
+		}, nil)
+		return err
+	}
+	exists := func(ns string) bool {
+		_, err := fc.Environments(ns).Get("nodejs")
+		return err == nil
+	}
+
+	// specs may have objects in several namespaces
+	err := apply("ci-token", env(metav1.NamespaceDefault), env("other"))
+	if err != nil {
+		t.Fatalf("Error applying: %v", err)
+	}
+	if !exists(metav1.NamespaceDefault) || !exists("other") {
+		t.Errorf("Expected environments in both namespaces")
+	}
+
+	// the client sends the namespace of single namespace specs
+	err = apply("carol-token", env("mine"))
+	if err != nil || !exists("mine") {
+		t.Errorf("Expected apply in namespace mine to succeed, got %v", err)
+	}
+
+	// each namespace is authorized, not just the request's
+	body, err := json.Marshal(&crd.ApplyRequest{
+		DeploymentName: "test",
+		DeploymentUID:  "5678",
+		Resources:      crd.Resources{Environments: []crd.Environment{env("mine"), env("theirs")}},
+	})
+	if err != nil {
+		t.Fatalf("Error encoding request: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/v2/apply?namespace=mine", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer carol-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || exists("theirs") {
+		t.Errorf("Expected apply in namespace theirs to be forbidden, got %v", w.Code)
+	}
+}
@@ -0,0 +1,458 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+)
+
+// The controller records every change made through its API as an
+// audit event. Events are written to the sinks configured with
+// AUDIT_LOG_SINKS, a comma separated list of
+//
+//	stdout         JSON lines on standard output
+//	file:<path>    JSON lines appended to a file
+//	http(s)://...  a webhook, which gets each event POSTed as JSON
+//
+// The most recent AUDIT_LOG_SIZE events are also kept in memory, to be
+// queried with /v2/audit. They don't persist: they're lost when the
+// controller restarts, and each controller replica has its own. The
+// sinks are the durable record.
+
+const (
+	defaultAuditLogSize = 1000
+
+	// Events wait in a queue to be written to the sinks, so that slow
+	// sinks don't hold up requests. Events that don't fit are dropped
+	// from the sinks (but still kept in memory); they're counted and
+	// logged, and /v2/audit reports the count.
+	auditQueueSize = 1000
+
+	// Longer values, such as literal archives, are summarized.
+	maxAuditValueLength = 256
+)
+
+// AuditDroppedHeader has the number of events dropped from the sinks
+// since the controller started.
+const AuditDroppedHeader = "X-Fission-Audit-Dropped"
+
+type (
+	auditSink interface {
+		write(e *fission.AuditEvent) error
+	}
+
+	// writerSink writes events as JSON lines.
+	writerSink struct {
+		w io.Writer
+	}
+
+	// webhookSink POSTs events to a URL.
+	webhookSink struct {
+		url        string
+		httpClient *http.Client
+	}
+
+	// auditLog records changes, writes them to its sinks and keeps the
+	// most recent ones.
+	auditLog struct {
+		lock   sync.Mutex
+		events []fission.AuditEvent
+		size   int
+		sinks  []auditSink
+		queue  chan *fission.AuditEvent
+
+		// events dropped from the sinks, because the queue was full
+		// or a sink failed
+		dropped int
+	}
+
+	// auditRecorder captures the status and body of a response.
+	auditRecorder struct {
+		http.ResponseWriter
+		status int
+		body   bytes.Buffer
+	}
+)
+
+// auditNameVars are the route variables that name the object a change
+// is about, by resource.
+var auditNameVars = map[string]string{
+	"environments":            "environment",
+	"packages":                "package",
+	"functions":               "function",
+	"httptriggers":            "httpTrigger",
+	"kuberneteswatchtriggers": "watch",
+	"timetriggers":            "timeTrigger",
+	"messagequeuetriggers":    "mqTrigger",
+}
+
+func (s *writerSink) write(e *fission.AuditEvent) error {
+	return json.NewEncoder(s.w).Encode(e)
+}
+
+func (s *webhookSink) write(e *fission.AuditEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook %v responded with %v", s.url, resp.Status)
+	}
+	return nil
+}
+
+// makeAuditSinks parses AUDIT_LOG_SINKS.
+func makeAuditSinks(spec string) ([]auditSink, error) {
+	sinks := make([]auditSink, 0)
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		switch {
+		case len(s) == 0:
+		case s == "stdout":
+			sinks = append(sinks, &writerSink{w: os.Stdout})
+		case strings.HasPrefix(s, "file:"):
+			f, err := os.OpenFile(strings.TrimPrefix(s, "file:"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, &writerSink{w: f})
+		case strings.HasPrefix(s, "http://"), strings.HasPrefix(s, "https://"):
+			sinks = append(sinks, &webhookSink{
+				url:        s,
+				httpClient: &http.Client{Timeout: 10 * time.Second},
+			})
+		default:
+			return nil, fmt.Errorf("unknown audit log sink '%v'", s)
+		}
+	}
+	return sinks, nil
+}
+
+func makeAuditLog(size int, sinks []auditSink) *auditLog {
+	l := &auditLog{
+		events: make([]fission.AuditEvent, 0),
+		size:   size,
+		sinks:  sinks,
+		queue:  make(chan *fission.AuditEvent, auditQueueSize),
+	}
+	go l.writeToSinks()
+	return l
+}
+
+func (l *auditLog) writeToSinks() {
+	for e := range l.queue {
+		for _, s := range l.sinks {
+			err := s.write(e)
+			if err != nil {
+				log.Errorf("Error writing audit event, %v events dropped so far: %v", l.drop(), err)
+			}
+		}
+	}
+}
+
+func (l *auditLog) record(e *fission.AuditEvent) {
+	l.lock.Lock()
+	l.events = append(l.events, *e)
+	if len(l.events) > l.size {
+		l.events = l.events[len(l.events)-l.size:]
+	}
+	l.lock.Unlock()
+
+	if len(l.sinks) == 0 {
+		return
+	}
+	select {
+	case l.queue <- e:
+	default:
+		log.Errorf("Audit log queue is full, dropping event for %v %v/%v, %v events dropped so far",
+			e.Resource, e.Namespace, e.Name, l.drop())
+	}
+}
+
+// drop counts an event that wasn't written to a sink, and returns the
+// number dropped so far.
+func (l *auditLog) drop() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.dropped++
+	return l.dropped
+}
+
+// droppedEvents returns the number of events dropped from the sinks.
+func (l *auditLog) droppedEvents() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.dropped
+}
+
+// list returns the kept events that match, oldest first.
+func (l *auditLog) list(match func(e *fission.AuditEvent) bool) []fission.AuditEvent {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	events := make([]fission.AuditEvent, 0)
+	for i := range l.events {
+		if match(&l.events[i]) {
+			events = append(events, l.events[i])
+		}
+	}
+	return events
+}
+
+func (rec *auditRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *auditRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// specFields flattens the spec of an object into its fields, keyed by
+// JSON path. Lists are single fields.
+func specFields(obj interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if obj == nil {
+		return fields
+	}
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return fields
+	}
+	var o struct {
+		Spec interface{} `json:"spec"`
+	}
+	if json.Unmarshal(buf, &o) != nil {
+		return fields
+	}
+
+	var flatten func(path string, v interface{})
+	flatten = func(path string, v interface{}) {
+		switch x := v.(type) {
+		case nil:
+		case map[string]interface{}:
+			for k, y := range x {
+				flatten(path+"."+k, y)
+			}
+		default:
+			fields[path] = v
+		}
+	}
+	flatten("spec", o.Spec)
+	return fields
+}
+
+func auditValue(v interface{}) interface{} {
+	if s, ok := v.(string); ok && len(s) > maxAuditValueLength {
+		return fmt.Sprintf("(%v bytes)", len(s))
+	}
+	return v
+}
+
+// specChanges returns the spec fields that differ between two versions
+// of an object, either of which may be nil.
+func specChanges(before interface{}, after interface{}) []fission.SpecChange {
+	oldFields, newFields := specFields(before), specFields(after)
+	paths := make([]string, 0, len(oldFields)+len(newFields))
+	for path := range oldFields {
+		paths = append(paths, path)
+	}
+	for path := range newFields {
+		if _, ok := oldFields[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	changes := make([]fission.SpecChange, 0)
+	for _, path := range paths {
+		o, n := oldFields[path], newFields[path]
+		if !reflect.DeepEqual(o, n) {
+			changes = append(changes, fission.SpecChange{
+				Field: path,
+				Old:   auditValue(o),
+				New:   auditValue(n),
+			})
+		}
+	}
+	return changes
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// recordChange records a change to an object made by a request.
+// Before is nil for creates, after for deletes.
+func (a *API) recordChange(r *http.Request, verb string, resource string, namespace string, name string, before interface{}, after interface{}) {
+	if a.auditLog == nil {
+		return
+	}
+	e := &fission.AuditEvent{
+		Time:      metav1.Now(),
+		ClientIP:  clientIP(r),
+		Verb:      verb,
+		Resource:  resource,
+		Namespace: namespace,
+		Name:      name,
+		Changes:   specChanges(before, after),
+	}
+	if user := requestUser(r); user != nil {
+		e.User = user.Username
+	}
+	a.auditLog.record(e)
+}
+
+// audit wraps an API handler that creates, updates or deletes an object
+// of a resource, so that successful changes are recorded. Requests
+// whose route names an object change it, the others create one; dry
+// runs change nothing and aren't recorded.
+func (a *API) audit(resource string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.auditLog == nil || isDryRun(r) {
+			handler(w, r)
+			return
+		}
+
+		verb := "create"
+		name := mux.Vars(r)[auditNameVars[resource]]
+		if len(name) > 0 {
+			verb = "update"
+			if r.Method == "DELETE" {
+				verb = "delete"
+			}
+		}
+		// the namespace the handler acts on
+		ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+		var before interface{}
+		if verb != "create" {
+			// if it's missing, the handler fails
+			before, _, _ = a.getStored(resource, ns, name)
+		}
+
+		rec := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(rec, r)
+		if rec.status >= 300 {
+			return
+		}
+
+		var after interface{}
+		if verb != "delete" {
+			// creates and updates respond with the object's metadata
+			var m metav1.ObjectMeta
+			if json.Unmarshal(rec.body.Bytes(), &m) == nil && len(m.Name) > 0 {
+				ns, name = namespaceOr(m.Namespace, ns), m.Name
+			}
+			after, _, _ = a.getStored(resource, ns, name)
+		}
+		a.recordChange(r, verb, resource, ns, name, before, after)
+	}
+}
+
+// AuditApiList responds with the recorded changes that match the query
+// parameters, oldest first. Only the changes kept in memory since the
+// controller started are there. The AuditDroppedHeader has the number
+// of events the sinks missed.
+//
+//	since, until   only changes in this time range (RFC 3339)
+//	resource       only changes to this resource, such as functions
+//	namespace      only changes in this namespace
+//	name           only changes to objects with this name
+//	user           only changes by this user
+//	verb           only creates, updates or deletes
+//	limit          only this many of the most recent matching changes
+func (a *API) AuditApiList(w http.ResponseWriter, r *http.Request) {
+	if a.auditLog == nil {
+		a.respondWithError(w, fission.MakeError(fission.ErrorNotImplmented, "The audit log is disabled"))
+		return
+	}
+
+	q := r.URL.Query()
+	var since, until time.Time
+	for param, t := range map[string]*time.Time{"since": &since, "until": &until} {
+		if v := q.Get(param); len(v) > 0 {
+			var err error
+			*t, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				a.respondWithError(w, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Invalid %v '%v'", param, v)))
+				return
+			}
+		}
+	}
+	limit := 0
+	if l := q.Get("limit"); len(l) > 0 {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			a.respondWithError(w, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Invalid limit '%v'", l)))
+			return
+		}
+	}
+
+	matches := func(param string, value string) bool {
+		v := q.Get(param)
+		return len(v) == 0 || v == value
+	}
+	events := a.auditLog.list(func(e *fission.AuditEvent) bool {
+		return (since.IsZero() || !e.Time.Time.Before(since)) &&
+			(until.IsZero() || e.Time.Time.Before(until)) &&
+			matches("resource", e.Resource) &&
+			matches("namespace", e.Namespace) &&
+			matches("name", e.Name) &&
+			matches("user", e.User) &&
+			matches("verb", e.Verb)
+	})
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+
+	resp, err := json.Marshal(events)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	w.Header().Set(AuditDroppedHeader, strconv.Itoa(a.auditLog.droppedEvents()))
+	a.respondWithSuccess(w, resp)
+}
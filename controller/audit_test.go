@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+)
+
+type chanSink chan *fission.AuditEvent
+
+func (s chanSink) write(e *fission.AuditEvent) error {
+	s <- e
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+	sink := make(chanSink, 10)
+	api := &API{fissionClient: fc, auditLog: makeAuditLog(10, []auditSink{sink})}
+
+	_, err := fc.Environments(ns).Create(&crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "nodejs", Namespace: ns},
+		Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating environment: %v", err)
+	}
+	for _, name := range []string{"hello-a", "hello-b"} {
+		_, err = fc.Packages(ns).Create(&crd.Package{
+			Metadata: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec: fission.PackageSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+				Deployment:  fission.Archive{Type: fission.ArchiveTypeUrl, URL: "http://example.com/" + name},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error creating package: %v", err)
+		}
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v2/functions", api.audit("functions", api.FunctionApiCreate)).Methods("POST")
+	r.HandleFunc("/v2/functions/{function}", api.audit("functions", api.FunctionApiUpdate)).Methods("PUT")
+	r.HandleFunc("/v2/functions/{function}", api.audit("functions", api.FunctionApiDelete)).Methods("DELETE")
+	r.HandleFunc("/v2/audit", api.AuditApiList).Methods("GET")
+
+	do := func(method string, url string, obj interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if obj != nil {
+			body, err = json.Marshal(obj)
+			if err != nil {
+				t.Fatalf("Error encoding request: %v", err)
+			}
+		}
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("Error in %v %v: %v %v", method, url, w.Code, w.Body.String())
+		}
+		return w
+	}
+	list := func(query string) []fission.AuditEvent {
+		w := do("GET", "/v2/audit"+query, nil)
+		var events []fission.AuditEvent
+		err := json.Unmarshal(w.Body.Bytes(), &events)
+		if err != nil {
+			t.Fatalf("Error decoding audit events: %v", err)
+		}
+		return events
+	}
+
+	fn := &crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+			Package: fission.FunctionPackageRef{
+				PackageRef: fission.PackageRef{Name: "hello-a", Namespace: ns},
+			},
+		},
+	}
+	do("POST", "/v2/functions", fn)
+	fn, err = fc.Functions(ns).Get("hello")
+	if err != nil {
+		t.Fatalf("Error getting function: %v", err)
+	}
+	fn.Spec.Package.PackageRef.Name = "hello-b"
+	do("PUT", "/v2/functions/hello", fn)
+	// dry runs aren't recorded
+	do("DELETE", "/v2/functions/hello?dryRun=true", nil)
+	do("DELETE", "/v2/functions/hello", nil)
+
+	events := list("")
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %#v", events)
+	}
+	for i, verb := range []string{"create", "update", "delete"} {
+		e := events[i]
+		if e.Verb != verb || e.Resource != "functions" || e.Namespace != ns || e.Name != "hello" {
+			t.Errorf("Expected %v of function %v/hello, got %#v", verb, ns, e)
+		}
+	}
+	changes := events[1].Changes
+	if len(changes) != 1 || changes[0].Field != "spec.package.packageref.name" ||
+		changes[0].Old != "hello-a" || changes[0].New != "hello-b" {
+		t.Errorf("Expected package reference change, got %#v", changes)
+	}
+	if len(events[2].Changes) == 0 || events[2].Changes[0].New != nil {
+		t.Errorf("Expected delete to remove all fields, got %#v", events[2].Changes)
+	}
+
+	if events := list("?verb=update"); len(events) != 1 || events[0].Verb != "update" {
+		t.Errorf("Expected only the update, got %#v", events)
+	}
+	if events := list("?limit=1"); len(events) != 1 || events[0].Verb != "delete" {
+		t.Errorf("Expected only the most recent event, got %#v", events)
+	}
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if events := list("?since=" + future); len(events) != 0 {
+		t.Errorf("Expected no events since %v, got %#v", future, events)
+	}
+	if events := list("?resource=packages"); len(events) != 0 {
+		t.Errorf("Expected no package events, got %#v", events)
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-sink:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected events to be written to the sink")
+		}
+	}
+}
+
+func TestAuditDroppedEvents(t *testing.T) {
+	// a sink that never finishes writing
+	sink := make(chanSink)
+	l := makeAuditLog(10, []auditSink{sink})
+
+	n := auditQueueSize + 10
+	for i := 0; i < n; i++ {
+		l.record(&fission.AuditEvent{Verb: "create", Resource: "functions", Name: "hello"})
+	}
+	// the writer may have taken one event off the queue
+	if dropped := l.droppedEvents(); dropped < n-auditQueueSize-1 {
+		t.Errorf("Expected at least %v dropped events, got %v", n-auditQueueSize-1, dropped)
+	}
+	if events := l.list(func(e *fission.AuditEvent) bool { return true }); len(events) != 10 {
+		t.Errorf("Expected dropped events to be kept in memory, got %v", len(events))
+	}
+}
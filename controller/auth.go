@@ -0,0 +1,366 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	authenticationv1 "k8s.io/client-go/pkg/apis/authentication/v1"
+	authorizationv1 "k8s.io/client-go/pkg/apis/authorization/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/cache"
+)
+
+type (
+	// userInfo is the user a request was authenticated as.
+	userInfo struct {
+		Username string
+		Groups   []string
+		Extra    map[string]authorizationv1.ExtraValue
+	}
+
+	// authenticator checks the bearer tokens of API requests, and asks
+	// Kubernetes whether their users may do what they're asking for.
+	// Access is controlled with RBAC roles on the Fission CRDs, so the
+	// same roles govern the Fission API and kubectl.
+	authenticator struct {
+		kubernetesClient kubernetes.Interface
+
+		// Static tokens are for users Kubernetes doesn't know about,
+		// e.g. CI systems outside the cluster.
+		staticTokens map[string]*userInfo // token -> user
+
+		// Tokens reviewed by Kubernetes are cached for a while, so
+		// that not every request makes a TokenReview.
+		reviewedTokens *cache.Cache // token -> *userInfo
+	}
+
+	contextKey int
+)
+
+const (
+	// userContextKey is the context key of the user a request was
+	// authenticated as.
+	userContextKey contextKey = iota
+
+	// namespaceContextKey is the context key of the namespace a
+	// request was authorized for.
+	namespaceContextKey
+)
+
+// Users of static tokens are in this group, in addition to the
+// authenticated users' group, so that RBAC roles can be bound to all
+// of them.
+const staticTokenGroup = "fission:static-token-users"
+
+func makeAuthenticator(kubernetesClient kubernetes.Interface, staticTokens map[string]*userInfo) *authenticator {
+	return &authenticator{
+		kubernetesClient: kubernetesClient,
+		staticTokens:     staticTokens,
+		reviewedTokens:   cache.MakeCache(time.Minute, 0),
+	}
+}
+
+// loadStaticTokens reads static tokens from a Secret, in which each key
+// is a user name and its value is the user's token.
+func loadStaticTokens(kubernetesClient kubernetes.Interface, namespace string, name string) (map[string]*userInfo, error) {
+	secret, err := kubernetesClient.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]*userInfo)
+	for username, token := range secret.Data {
+		t := strings.TrimSpace(string(token))
+		if len(t) == 0 {
+			continue
+		}
+		tokens[t] = &userInfo{
+			Username: username,
+			Groups:   []string{staticTokenGroup, "system:authenticated"},
+		}
+	}
+	log.Infof("Loaded %v static tokens from secret %v/%v", len(tokens), namespace, name)
+	return tokens, nil
+}
+
+// authenticate returns the user whose bearer token is on a request.
+func (a *authenticator) authenticate(r *http.Request) (*userInfo, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, fission.MakeError(fission.ErrorNotAuthenticated, "Authorization header with bearer token required")
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	if len(token) == 0 {
+		return nil, fission.MakeError(fission.ErrorNotAuthenticated, "Empty bearer token")
+	}
+
+	if user, ok := a.staticTokens[token]; ok {
+		return user, nil
+	}
+	if user, err := a.reviewedTokens.Get(token); err == nil {
+		return user.(*userInfo), nil
+	}
+
+	review, err := a.kubernetesClient.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		log.Infof("Token review failed: %v", review.Status.Error)
+		return nil, fission.MakeError(fission.ErrorNotAuthenticated, "Invalid bearer token")
+	}
+
+	user := &userInfo{
+		Username: review.Status.User.Username,
+		Groups:   review.Status.User.Groups,
+		Extra:    make(map[string]authorizationv1.ExtraValue),
+	}
+	for k, v := range review.Status.User.Extra {
+		user.Extra[k] = authorizationv1.ExtraValue(v)
+	}
+	// Tokens aren't logged or kept anywhere else, so keeping them as
+	// keys is fine. Concurrent reviews of the same token may both
+	// try to set it; either result will do.
+	a.reviewedTokens.Set(token, user)
+	return user, nil
+}
+
+// authorize returns an error unless user may use verb on a Fission
+// resource in namespace.
+func (a *authenticator) authorize(user *userInfo, namespace string, resource string, verb string) error {
+	attrs := &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      verb,
+		Group:     "fission.io",
+		Version:   "v1",
+		Resource:  resource,
+	}
+	if parts := strings.SplitN(resource, "/", 2); len(parts) == 2 {
+		attrs.Resource = parts[0]
+		attrs.Subresource = parts[1]
+	}
+
+	review, err := a.kubernetesClient.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attrs,
+			User:               user.Username,
+			Groups:             user.Groups,
+			Extra:              user.Extra,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !review.Status.Allowed {
+		msg := fmt.Sprintf("User %v may not %v %v", user.Username, verb, resource)
+		if len(namespace) > 0 {
+			msg += fmt.Sprintf(" in namespace %v", namespace)
+		}
+		return fission.MakeError(fission.ErrorNotAuthorized, msg)
+	}
+	return nil
+}
+
+// methodVerb maps an HTTP method to a Kubernetes API verb.
+func methodVerb(method string) string {
+	switch method {
+	case "POST":
+		return "create"
+	case "PUT":
+		return "update"
+	case "DELETE":
+		return "delete"
+	default:
+		return "get"
+	}
+}
+
+// requestNamespace returns the namespace a request is about: the
+// namespace query parameter, or else the namespace of the object in
+// the body of a create or update. The two must agree if both are
+// given. Lists and watches without a namespace are across all
+// namespaces, which needs cluster-wide access; anything else defaults
+// to the default namespace.
+func requestNamespace(r *http.Request, verb string) (string, error) {
+	// The query is parsed by hand, since parsing forms could use up
+	// the body of uploads.
+	ns := r.URL.Query().Get("namespace")
+
+	// Handlers decode bodies whatever their content type, so the body
+	// is checked whatever its content type too.
+	if r.Method == "POST" || r.Method == "PUT" {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return "", err
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		var obj struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		// Bodies that aren't objects are left to the handler to
+		// complain about.
+		if json.Unmarshal(body, &obj) == nil && len(obj.Metadata.Namespace) > 0 {
+			if len(ns) > 0 && ns != obj.Metadata.Namespace {
+				return "", namespaceMismatch(ns, obj.Metadata.Namespace)
+			}
+			return obj.Metadata.Namespace, nil
+		}
+	}
+
+	if len(ns) > 0 {
+		return ns, nil
+	}
+	if verb == "list" || verb == "watch" {
+		return metav1.NamespaceAll, nil
+	}
+	return metav1.NamespaceDefault, nil
+}
+
+// queryNamespace is requestNamespace for requests whose bodies aren't
+// Fission objects, such as those passed on to other services.
+func queryNamespace(r *http.Request, verb string) (string, error) {
+	if ns := r.URL.Query().Get("namespace"); len(ns) > 0 {
+		return ns, nil
+	}
+	if verb == "list" || verb == "watch" {
+		return metav1.NamespaceAll, nil
+	}
+	return metav1.NamespaceDefault, nil
+}
+
+func namespaceMismatch(requested string, object string) error {
+	return fission.MakeError(fission.ErrorInvalidArgument,
+		fmt.Sprintf("Object namespace %v doesn't match request namespace %v", object, requested))
+}
+
+// requestedNamespace returns the namespace a request was authorized
+// for. Handlers act on objects in this namespace, and nowhere else.
+// Without authorizeIn, e.g. in tests, it's the namespace query
+// parameter.
+func requestedNamespace(r *http.Request) string {
+	if ns, ok := r.Context().Value(namespaceContextKey).(string); ok {
+		return ns
+	}
+	return r.URL.Query().Get("namespace")
+}
+
+// objectNamespace puts the object of a create or update in the
+// namespace of the request, and returns an error if it names another
+// one.
+func objectNamespace(r *http.Request, m *metav1.ObjectMeta) error {
+	ns := requestedNamespace(r)
+	if len(m.Namespace) == 0 {
+		m.Namespace = namespaceOr(ns, metav1.NamespaceDefault)
+		return nil
+	}
+	if len(ns) > 0 && ns != m.Namespace {
+		return namespaceMismatch(ns, m.Namespace)
+	}
+	return nil
+}
+
+// authorize wraps an API handler so that it only handles requests
+// from users that may use verb on resource. Resource may name a
+// subresource, as in "functions/log". An empty verb is derived from
+// the request's method. Without authentication set up, all requests
+// are let through.
+func (api *API) authorize(resource string, verb string, handler http.HandlerFunc) http.HandlerFunc {
+	return api.authorizeIn(requestNamespace, resource, verb, handler)
+}
+
+// authorizeProxy is authorize for requests that are passed on to other
+// services, whose bodies aren't read.
+func (api *API) authorizeProxy(resource string, verb string, handler http.HandlerFunc) http.HandlerFunc {
+	return api.authorizeIn(queryNamespace, resource, verb, handler)
+}
+
+// authorizeAll is authorize for requests about objects in all
+// namespaces.
+func (api *API) authorizeAll(resource string, verb string, handler http.HandlerFunc) http.HandlerFunc {
+	allNamespaces := func(r *http.Request, verb string) (string, error) {
+		return metav1.NamespaceAll, nil
+	}
+	return api.authorizeIn(allNamespaces, resource, verb, handler)
+}
+
+// authorizeIn resolves the namespace of a request once, so that it's
+// authorized for the same namespace that the handler, which gets it
+// from requestedNamespace, acts on.
+func (api *API) authorizeIn(namespaceOf func(r *http.Request, verb string) (string, error),
+	resource string, verb string, handler http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := verb
+		if len(v) == 0 {
+			v = methodVerb(r.Method)
+		}
+		if v == "list" && wantsWatch(r) {
+			v = "watch"
+		}
+		ns, err := namespaceOf(r, v)
+		if err != nil {
+			api.respondWithError(w, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), namespaceContextKey, ns)
+
+		if api.auth == nil {
+			handler(w, r.WithContext(ctx))
+			return
+		}
+
+		user, err := api.auth.authenticate(r)
+		if err != nil {
+			api.respondWithError(w, err)
+			return
+		}
+		err = api.auth.authorize(user, ns, resource, v)
+		if err != nil {
+			log.Infof("Denied %v %v: %v", r.Method, r.URL.Path, err)
+			api.respondWithError(w, err)
+			return
+		}
+
+		// Don't pass the token on to services behind the proxies.
+		r.Header.Del("Authorization")
+		handler(w, r.WithContext(context.WithValue(ctx, userContextKey, user)))
+	}
+}
+
+// requestUser returns the user a request was authenticated as, or nil
+// if authentication is disabled.
+func requestUser(r *http.Request) *userInfo {
+	user, _ := r.Context().Value(userContextKey).(*userInfo)
+	return user
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sFake "k8s.io/client-go/kubernetes/fake"
+	authenticationv1 "k8s.io/client-go/pkg/apis/authentication/v1"
+	authorizationv1 "k8s.io/client-go/pkg/apis/authorization/v1"
+	k8sTesting "k8s.io/client-go/testing"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+)
+
+// makeTestAuthenticator makes an authenticator for which Kubernetes
+// knows the token "alice-token", and alice may only read functions in
+// the default namespace. Bob, with a static token, may do anything to
+// functions in the "mine" namespace, and carol anything in it.
+func makeTestAuthenticator() *authenticator {
+	client := k8sFake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8sTesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "alice-token" {
+			review.Status.Authenticated = true
+			review.Status.User.Username = "alice"
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8sTesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		switch review.Spec.User {
+		case "alice":
+			review.Status.Allowed = attrs.Resource == "functions" && attrs.Namespace == "default" && attrs.Verb == "get"
+		case "ci":
+			review.Status.Allowed = true
+		case "bob":
+			review.Status.Allowed = attrs.Resource == "functions" && attrs.Namespace == "mine"
+		case "carol":
+			review.Status.Allowed = attrs.Namespace == "mine"
+		}
+		return true, review, nil
+	})
+	return makeAuthenticator(client, map[string]*userInfo{
+		"ci-token":    {Username: "ci", Groups: []string{staticTokenGroup}},
+		"bob-token":   {Username: "bob", Groups: []string{staticTokenGroup}},
+		"carol-token": {Username: "carol", Groups: []string{staticTokenGroup}},
+	})
+}
+
+func TestAuthorize(t *testing.T) {
+	api := &API{auth: makeTestAuthenticator()}
+	server := httptest.NewServer(api.authorize("functions", "get", func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Authorization")) > 0 {
+			t.Errorf("Expected token not to be passed on to the handler")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		token     string
+		namespace string
+		status    int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"bad-token", "", http.StatusUnauthorized},
+		{"alice-token", "", http.StatusOK},
+		{"alice-token", "kube-system", http.StatusForbidden},
+		{"ci-token", "kube-system", http.StatusOK},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", server.URL+"?namespace="+test.namespace, nil)
+		if err != nil {
+			t.Fatalf("Error making request: %v", err)
+		}
+		if len(test.token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("Expected status %v for token %q in namespace %q, got %v",
+				test.status, test.token, test.namespace, resp.StatusCode)
+		}
+	}
+}
+
+func TestAuthorizeAll(t *testing.T) {
+	api := &API{auth: makeTestAuthenticator()}
+	server := httptest.NewServer(api.authorizeAll("*", "delete", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// rights in the requested namespace aren't enough
+	tests := []struct {
+		token  string
+		status int
+	}{
+		{"carol-token", http.StatusForbidden},
+		{"ci-token", http.StatusOK},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("DELETE", server.URL+"?namespace=mine", nil)
+		if err != nil {
+			t.Fatalf("Error making request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("Expected status %v for token %q, got %v", test.status, test.token, resp.StatusCode)
+		}
+	}
+}
+
+func TestAuthorizeRequestNamespace(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	api := &API{fissionClient: fc, auth: makeTestAuthenticator()}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v2/functions", api.authorize("functions", "create", api.FunctionApiCreate)).Methods("POST")
+	r.HandleFunc("/v2/functions/{function}", api.authorize("functions", "update", api.FunctionApiUpdate)).Methods("PUT")
+	r.HandleFunc("/v2/functions/{function}", api.authorize("functions", "delete", api.FunctionApiDelete)).Methods("DELETE")
+
+	function := func(ns string) *crd.Function {
+		return &crd.Function{
+			Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+			Spec: fission.FunctionSpec{
+				InvokeStrategy: fission.InvokeStrategy{
+					ExecutionStrategy: fission.ExecutionStrategy{ExecutorType: fission.ExecutorTypeContainer},
+				},
+				Container: &fission.FunctionContainer{Image: "hello"},
+			},
+		}
+	}
+	do := func(method string, url string, contentType string, obj interface{}) int {
+		var body []byte
+		if obj != nil {
+			var err error
+			body, err = json.Marshal(obj)
+			if err != nil {
+				t.Fatalf("Error encoding request: %v", err)
+			}
+		}
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer bob-token")
+		if len(contentType) > 0 {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	exists := func(ns string) bool {
+		_, err := fc.Functions(ns).Get("hello")
+		return err == nil
+	}
+
+	for _, contentType := range []string{"application/json", "text/plain", ""} {
+		// objects in other namespaces than the request's are refused,
+		// whatever the content type says
+		code := do("POST", "/v2/functions?namespace=mine", contentType, function("victim"))
+		if code != http.StatusBadRequest || exists("victim") {
+			t.Errorf("Expected create in another namespace to be refused with content type %q, got %v", contentType, code)
+		}
+		// without a namespace parameter, the object's namespace is
+		// authorized
+		code = do("POST", "/v2/functions", contentType, function("victim"))
+		if code != http.StatusForbidden || exists("victim") {
+			t.Errorf("Expected create in namespace victim to be forbidden with content type %q, got %v", contentType, code)
+		}
+	}
+
+	// objects without a namespace go into the request's
+	code := do("POST", "/v2/functions?namespace=mine", "text/plain", function(""))
+	if code != http.StatusCreated || !exists("mine") {
+		t.Fatalf("Expected function to be created in namespace mine, got %v", code)
+	}
+	_, err := fc.Functions("victim").Create(function("victim"))
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+
+	fn, err := fc.Functions("victim").Get("hello")
+	if err != nil {
+		t.Fatalf("Error getting function: %v", err)
+	}
+	fn.Spec.Container.Image = "changed"
+	code = do("PUT", "/v2/functions/hello?namespace=mine", "text/plain", fn)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected update in another namespace to be refused, got %v", code)
+	}
+	fn, err = fc.Functions("victim").Get("hello")
+	if err != nil || fn.Spec.Container.Image != "hello" {
+		t.Errorf("Expected function in namespace victim to be unchanged, got %#v, %v", fn, err)
+	}
+
+	code = do("DELETE", "/v2/functions/hello?namespace=mine", "", nil)
+	if code != http.StatusOK || exists("mine") {
+		t.Errorf("Expected function in namespace mine to be deleted, got %v", code)
+	}
+	if !exists("victim") {
+		t.Errorf("Expected function in namespace victim to be kept")
+	}
+	code = do("DELETE", "/v2/functions/hello?namespace=victim", "", nil)
+	if code != http.StatusForbidden || !exists("victim") {
+		t.Errorf("Expected delete in namespace victim to be forbidden, got %v", code)
+	}
+}
@@ -0,0 +1,559 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	storageSvcClient "github.com/fission/fission/storagesvc/client"
+)
+
+// A backup is a gzipped tarball with the objects of all namespaces in
+// backup.json, and the archives of the storage service that packages
+// and function revisions use in archives/<sha256 of the archive>.
+// Restoring it creates the objects that don't exist yet, uploading the
+// archives they use again; objects that exist are left as they are, so
+// restoring twice is harmless.
+const (
+	backupObjectsFile = "backup.json"
+	backupArchiveDir  = "archives/"
+)
+
+var backupArchiveName = regexp.MustCompile("^[0-9a-f]{64}$")
+
+type (
+	// backupWriter saves the archives of the objects being backed up
+	// to a directory.
+	backupWriter struct {
+		ssClient *storageSvcClient.Client
+		dir      string
+		saved    map[string]string // storage service ID -> sha256
+	}
+
+	// restorer creates the objects of a backup for a request.
+	restorer struct {
+		api      *API
+		req      *http.Request
+		ssClient *storageSvcClient.Client
+		dir      string
+		uploaded map[string]*fission.Archive // sha256 -> archive
+		result   *fission.ApplyResult
+	}
+)
+
+// storageArchiveId returns the storage service ID of an archive URL,
+// or "" if the archive isn't in the storage service.
+func storageArchiveId(archiveUrl string) string {
+	u, err := url.Parse(archiveUrl)
+	if err != nil || !strings.HasSuffix(u.Path, "/v1/archive") {
+		return ""
+	}
+	return u.Query().Get("id")
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// saveArchive downloads an archive from the storage service, checks it
+// against its checksum, and points the archive at the saved copy.
+// Literal archives and ones elsewhere are left alone.
+func (bw *backupWriter) saveArchive(ar *fission.Archive) error {
+	if ar.Type != fission.ArchiveTypeUrl {
+		return nil
+	}
+	id := storageArchiveId(ar.URL)
+	if len(id) == 0 {
+		return nil
+	}
+
+	sum, ok := bw.saved[id]
+	if !ok {
+		path := filepath.Join(bw.dir, fmt.Sprintf("download-%v", len(bw.saved)))
+		err := bw.ssClient.Download(id, path)
+		if err != nil {
+			return fmt.Errorf("error downloading archive %v: %v", id, err)
+		}
+		sum, err = fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(path, filepath.Join(bw.dir, sum))
+		if err != nil {
+			return err
+		}
+		bw.saved[id] = sum
+	}
+
+	if ar.Checksum.Type == fission.ChecksumTypeSHA256 && len(ar.Checksum.Sum) > 0 && ar.Checksum.Sum != sum {
+		return fission.MakeError(fission.ErrorChecksumFail,
+			fmt.Sprintf("Archive %v has checksum %v, expected %v", id, sum, ar.Checksum.Sum))
+	}
+	ar.URL = archiveUrlPrefix + sum
+	ar.Checksum = fission.Checksum{
+		Type: fission.ChecksumTypeSHA256,
+		Sum:  sum,
+	}
+	return nil
+}
+
+// addObject adds an object to the resources of its kind.
+func addObject(res *crd.Resources, obj specObject) {
+	switch o := obj.(type) {
+	case *crd.Environment:
+		res.Environments = append(res.Environments, *o)
+	case *crd.Package:
+		res.Packages = append(res.Packages, *o)
+	case *crd.Function:
+		res.Functions = append(res.Functions, *o)
+	case *crd.HTTPTrigger:
+		res.HTTPTriggers = append(res.HTTPTriggers, *o)
+	case *crd.KubernetesWatchTrigger:
+		res.KubernetesWatchTriggers = append(res.KubernetesWatchTriggers, *o)
+	case *crd.TimeTrigger:
+		res.TimeTriggers = append(res.TimeTriggers, *o)
+	case *crd.MessageQueueTrigger:
+		res.MessageQueueTriggers = append(res.MessageQueueTriggers, *o)
+	}
+}
+
+// collect gets the objects of all namespaces, and saves the archives
+// they use.
+func (bw *backupWriter) collect(fc crd.FissionClientInterface) (*crd.Backup, error) {
+	b := &crd.Backup{}
+	for _, k := range applyKinds(fc, &crd.Resources{}) {
+		objs, err := k.list(metav1.NamespaceAll)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			addObject(&b.Resources, obj)
+		}
+	}
+
+	quotas, err := fc.FunctionQuotas(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b.FunctionQuotas = quotas.Items
+
+	revs, err := fc.FunctionRevisions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b.FunctionRevisions = revs.Items
+
+	archives := make([]*fission.Archive, 0)
+	for i := range b.Resources.Packages {
+		spec := &b.Resources.Packages[i].Spec
+		archives = append(archives, &spec.Source, &spec.Deployment)
+	}
+	for i := range b.FunctionRevisions {
+		spec := &b.FunctionRevisions[i].Spec.Package
+		archives = append(archives, &spec.Source, &spec.Deployment)
+	}
+	for _, ar := range archives {
+		err := bw.saveArchive(ar)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+// write writes the backup tarball.
+func (bw *backupWriter) write(w io.Writer, b *crd.Backup) error {
+	objs, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err = writeTarFile(tw, backupObjectsFile, int64(len(objs)), bytes.NewReader(objs))
+	if err != nil {
+		return err
+	}
+	// archives uploaded more than once are saved once
+	written := make(map[string]bool)
+	for _, sum := range bw.saved {
+		if written[sum] {
+			continue
+		}
+		written[sum] = true
+		err = func() error {
+			f, err := os.Open(filepath.Join(bw.dir, sum))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			fi, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			return writeTarFile(tw, backupArchiveDir+sum, fi.Size(), f)
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// readBackup unpacks a backup tarball, keeping its archives in dir.
+// Archives whose contents don't match their names are rejected.
+func readBackup(r io.Reader, dir string) (*crd.Backup, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Backup isn't a gzipped tarball: %v", err))
+	}
+	tr := tar.NewReader(gr)
+
+	var b *crd.Backup
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Error reading backup: %v", err))
+		}
+
+		switch {
+		case hdr.Name == backupObjectsFile:
+			b = &crd.Backup{}
+			err = json.NewDecoder(tr).Decode(b)
+			if err != nil {
+				return nil, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Error decoding %v: %v", backupObjectsFile, err))
+			}
+
+		case strings.HasPrefix(hdr.Name, backupArchiveDir):
+			sum := strings.TrimPrefix(hdr.Name, backupArchiveDir)
+			if !backupArchiveName.MatchString(sum) {
+				return nil, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Unexpected archive %v in backup", hdr.Name))
+			}
+			f, err := os.Create(filepath.Join(dir, sum))
+			if err != nil {
+				return nil, err
+			}
+			h := sha256.New()
+			_, err = io.Copy(io.MultiWriter(f, h), tr)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			if hex.EncodeToString(h.Sum(nil)) != sum {
+				return nil, fission.MakeError(fission.ErrorChecksumFail,
+					fmt.Sprintf("Archive %v of backup doesn't match its checksum", sum))
+			}
+		}
+	}
+	if b == nil {
+		return nil, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Backup has no %v", backupObjectsFile))
+	}
+	return b, nil
+}
+
+// uploadArchive uploads a saved archive that an archive of a restored
+// object refers to, and points the archive at the upload.
+func (rs *restorer) uploadArchive(ar *fission.Archive) error {
+	if !strings.HasPrefix(ar.URL, archiveUrlPrefix) {
+		return nil
+	}
+	sum := strings.TrimPrefix(ar.URL, archiveUrlPrefix)
+	up, ok := rs.uploaded[sum]
+	if !ok {
+		path := filepath.Join(rs.dir, sum)
+		if !backupArchiveName.MatchString(sum) {
+			return fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Invalid archive reference %v", ar.URL))
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Archive %v isn't in the backup", sum))
+		}
+		id, err := rs.ssClient.Upload(path, nil)
+		if err != nil {
+			return fmt.Errorf("error uploading archive %v: %v", sum, err)
+		}
+		up = &fission.Archive{
+			Type: fission.ArchiveTypeUrl,
+			URL:  rs.ssClient.GetUrl(id),
+			Checksum: fission.Checksum{
+				Type: fission.ChecksumTypeSHA256,
+				Sum:  sum,
+			},
+		}
+		rs.uploaded[sum] = up
+	}
+	*ar = *up
+	return nil
+}
+
+// create creates an object, unless one with its name already exists.
+// prepare is called on new objects first. It returns the created
+// object, or nil if it already existed.
+func (rs *restorer) create(kind string, resource string, obj specObject,
+	prepare func() error, create func() (specObject, error)) (specObject, error) {
+
+	m := objectMeta(obj)
+	_, _, err := rs.api.getStored(resource, m.Namespace, m.Name)
+	if err == nil {
+		return nil, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	m.ResourceVersion = ""
+	m.UID = ""
+	m.SelfLink = ""
+	m.CreationTimestamp = metav1.Time{}
+	if prepare != nil {
+		err = prepare()
+		if err != nil {
+			return nil, err
+		}
+	}
+	created, err := create()
+	if k8serrors.IsAlreadyExists(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cm := objectMeta(created)
+	status := rs.result.Status[kind]
+	status.Created = append(status.Created, *cm)
+	rs.result.Status[kind] = status
+	rs.api.recordChange(rs.req, "create", resource, cm.Namespace, cm.Name, nil, created)
+	return created, nil
+}
+
+// restore creates the objects of a backup that don't exist yet. Objects
+// are created in dependency order; packages that were owned by their
+// functions are owned by the restored functions.
+func (rs *restorer) restore(b *crd.Backup) error {
+	fc := rs.api.fissionClient
+	owned := make(map[string]bool) // namespace/name of packages
+	for i := range b.Resources.Packages {
+		pkg := &b.Resources.Packages[i]
+		if len(pkg.Metadata.OwnerReferences) > 0 {
+			owned[pkg.Metadata.Namespace+"/"+pkg.Metadata.Name] = true
+		}
+	}
+
+	for _, k := range applyKinds(fc, &b.Resources) {
+		for _, obj := range k.desired {
+			m := objectMeta(obj)
+			var prepare func() error
+			switch o := obj.(type) {
+			case *crd.Package:
+				prepare = func() error {
+					// the restored functions own them again below
+					o.Metadata.OwnerReferences = nil
+					err := rs.uploadArchive(&o.Spec.Source)
+					if err != nil {
+						return err
+					}
+					return rs.uploadArchive(&o.Spec.Deployment)
+				}
+			case *crd.Function:
+				prepare = func() error {
+					ref := &o.Spec.Package.PackageRef
+					if len(ref.Name) == 0 {
+						return nil
+					}
+					pkg, err := fc.Packages(namespaceOr(ref.Namespace, o.Metadata.Namespace)).Get(ref.Name)
+					if err == nil {
+						ref.ResourceVersion = pkg.Metadata.ResourceVersion
+					}
+					return nil
+				}
+			}
+
+			created, err := rs.create(k.kind, k.resource, obj, prepare, func() (specObject, error) {
+				return k.create(obj)
+			})
+			if err != nil {
+				return fmt.Errorf("error restoring %v %v/%v: %v", k.kind, m.Namespace, m.Name, err)
+			}
+
+			fn, ok := created.(*crd.Function)
+			if !ok {
+				continue
+			}
+			ref := fn.Spec.Package.PackageRef
+			if owned[namespaceOr(ref.Namespace, fn.Metadata.Namespace)+"/"+ref.Name] {
+				_, err = rs.api.ownPackage(fn)
+				if err != nil {
+					log.Errorf("Error setting the owner of the package of function %v: %v", fn.Metadata.Name, err)
+				}
+			}
+		}
+	}
+
+	for i := range b.FunctionQuotas {
+		q := &b.FunctionQuotas[i]
+		_, err := rs.create("quota", "functionquotas", q, nil, func() (specObject, error) {
+			return fc.FunctionQuotas(q.Metadata.Namespace).Create(q)
+		})
+		if err != nil {
+			return fmt.Errorf("error restoring function quota %v/%v: %v", q.Metadata.Namespace, q.Metadata.Name, err)
+		}
+	}
+
+	for i := range b.FunctionRevisions {
+		rev := &b.FunctionRevisions[i]
+		_, err := rs.create("revision", "functionrevisions", rev, func() error {
+			err := rs.uploadArchive(&rev.Spec.Package.Source)
+			if err != nil {
+				return err
+			}
+			return rs.uploadArchive(&rev.Spec.Package.Deployment)
+		}, func() (specObject, error) {
+			return fc.FunctionRevisions(rev.Metadata.Namespace).Create(rev)
+		})
+		if err != nil {
+			return fmt.Errorf("error restoring function revision %v/%v: %v", rev.Metadata.Namespace, rev.Metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+// BackupApi responds with a backup of all Fission objects and the
+// archives in the storage service that they use. Archives are checked
+// against the checksums of the packages that use them.
+func (a *API) BackupApi(w http.ResponseWriter, r *http.Request) {
+	dir, err := ioutil.TempDir("", "fission-backup-")
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	bw := &backupWriter{
+		ssClient: storageSvcClient.MakeClient(a.storageServiceUrl),
+		dir:      dir,
+		saved:    make(map[string]string),
+	}
+	b, err := bw.collect(a.fissionClient)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", "attachment; filename=fission-backup.tar.gz")
+	err = bw.write(w, b)
+	if err != nil {
+		// too late to respond with an error; the client sees a
+		// truncated tarball
+		log.Errorf("Error writing backup: %v", err)
+	}
+}
+
+// RestoreApi restores a backup made by BackupApi, and responds with the
+// objects it created.
+func (a *API) RestoreApi(w http.ResponseWriter, r *http.Request) {
+	dir, err := ioutil.TempDir("", "fission-restore-")
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := readBackup(r.Body, dir)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	rs := &restorer{
+		api:      a,
+		req:      r,
+		ssClient: storageSvcClient.MakeClient(a.storageServiceUrl),
+		dir:      dir,
+		uploaded: make(map[string]*fission.Archive),
+		result: &fission.ApplyResult{
+			Status:   make(map[string]fission.ApplyStatus),
+			Packages: make([]metav1.ObjectMeta, 0),
+		},
+	}
+	err = rs.restore(b)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	for _, m := range rs.result.Status["package"].Created {
+		rs.result.Packages = append(rs.result.Packages, m)
+	}
+
+	resp, err := json.Marshal(rs.result)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	a.respondWithSuccess(w, resp)
+}
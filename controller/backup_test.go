@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/controller/client"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+	"github.com/fission/fission/storagesvc"
+)
+
+// fakeStorageService stores uploaded archives in memory.
+func fakeStorageService(t *testing.T, archives map[string][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		switch r.Method {
+		case "POST":
+			f, _, err := r.FormFile("uploadfile")
+			if err != nil {
+				t.Errorf("Error reading upload: %v", err)
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			contents, _ := ioutil.ReadAll(f)
+			id = fmt.Sprintf("archive-%v", len(archives)+1)
+			archives[id] = contents
+			json.NewEncoder(w).Encode(storagesvc.UploadResponse{ID: id})
+		case "GET":
+			contents, ok := archives[id]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(contents)
+		case "DELETE":
+			delete(archives, id)
+		}
+	}))
+}
+
+func TestBackupRestore(t *testing.T) {
+	ns := metav1.NamespaceDefault
+	archives := make(map[string][]byte)
+	ss := fakeStorageService(t, archives)
+	defer ss.Close()
+
+	// controllers of the old and the new cluster, with the same
+	// storage service
+	serve := func(fc crd.FissionClientInterface) *httptest.Server {
+		api := &API{fissionClient: fc, storageServiceUrl: ss.URL}
+		r := mux.NewRouter()
+		r.HandleFunc("/v2/backup", api.BackupApi).Methods("GET")
+		r.HandleFunc("/v2/restore", api.RestoreApi).Methods("POST")
+		return httptest.NewServer(r)
+	}
+	oldFc, newFc := crdFake.MakeFissionClient(), crdFake.MakeFissionClient()
+	oldSrv, newSrv := serve(oldFc), serve(newFc)
+	defer oldSrv.Close()
+	defer newSrv.Close()
+	oldClient, newClient := client.MakeClient(oldSrv.URL), client.MakeClient(newSrv.URL)
+
+	contents := []byte("module.exports = function(context) {}")
+	archives["old-id"] = contents
+	hash := sha256.Sum256(contents)
+	sum := hex.EncodeToString(hash[:])
+	archive := fission.Archive{
+		Type:     fission.ArchiveTypeUrl,
+		URL:      ss.URL + "/v1/archive?id=old-id",
+		Checksum: fission.Checksum{Type: fission.ChecksumTypeSHA256, Sum: sum},
+	}
+
+	_, err := oldFc.Environments(ns).Create(&crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "nodejs", Namespace: ns},
+		Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating environment: %v", err)
+	}
+	fn, err := oldFc.Functions(ns).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+			Package: fission.FunctionPackageRef{
+				PackageRef: fission.PackageRef{Name: "hello-pkg", Namespace: ns, ResourceVersion: "1"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+	_, err = oldFc.Packages(ns).Create(&crd.Package{
+		Metadata: metav1.ObjectMeta{
+			Name:            "hello-pkg",
+			Namespace:       ns,
+			Annotations:     map[string]string{fission.PACKAGE_FOR_FUNCTION_ANNOTATION: "hello"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Function", Name: "hello", UID: fn.Metadata.UID}},
+		},
+		Spec: fission.PackageSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+			Deployment:  archive,
+		},
+		Status: fission.PackageStatus{BuildStatus: fission.BuildStatusSucceeded},
+	})
+	if err != nil {
+		t.Fatalf("Error creating package: %v", err)
+	}
+	_, err = oldFc.FunctionRevisions(ns).Create(&crd.FunctionRevision{
+		Metadata: metav1.ObjectMeta{Name: "hello-1", Namespace: ns, Labels: map[string]string{"functionName": "hello"}},
+		Spec: fission.FunctionRevisionSpec{
+			FunctionName: "hello",
+			Revision:     1,
+			Function:     fn.Spec,
+			Package:      fission.PackageSpec{Deployment: archive},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating revision: %v", err)
+	}
+
+	var backup bytes.Buffer
+	err = oldClient.BackupCreate(&backup)
+	if err != nil {
+		t.Fatalf("Error creating backup: %v", err)
+	}
+
+	// the archive is gone along with the old cluster
+	delete(archives, "old-id")
+
+	result, err := newClient.BackupRestore(bytes.NewReader(backup.Bytes()))
+	if err != nil {
+		t.Fatalf("Error restoring backup: %v", err)
+	}
+	for kind, n := range map[string]int{"environment": 1, "package": 1, "function": 1, "revision": 1} {
+		if len(result.Status[kind].Created) != n {
+			t.Errorf("Expected %v %v to be created, got %#v", n, kind, result.Status[kind])
+		}
+	}
+	if len(archives) != 1 {
+		t.Errorf("Expected the archive to be uploaded once, got %v", len(archives))
+	}
+
+	pkg, err := newFc.Packages(ns).Get("hello-pkg")
+	if err != nil {
+		t.Fatalf("Error getting restored package: %v", err)
+	}
+	id := storageArchiveId(pkg.Spec.Deployment.URL)
+	if !bytes.Equal(archives[id], contents) || pkg.Spec.Deployment.Checksum.Sum != sum {
+		t.Errorf("Expected package to use the uploaded archive, got %#v", pkg.Spec.Deployment)
+	}
+	if pkg.Status.BuildStatus != fission.BuildStatusSucceeded {
+		t.Errorf("Expected package build status to be kept, got %v", pkg.Status.BuildStatus)
+	}
+	newFn, err := newFc.Functions(ns).Get("hello")
+	if err != nil {
+		t.Fatalf("Error getting restored function: %v", err)
+	}
+	owners := pkg.Metadata.OwnerReferences
+	if len(owners) != 1 || owners[0].UID != newFn.Metadata.UID {
+		t.Errorf("Expected package to be owned by the restored function, got %#v", owners)
+	}
+	if newFn.Spec.Package.PackageRef.ResourceVersion != pkg.Metadata.ResourceVersion {
+		t.Errorf("Expected function to refer to package version %v, got %v",
+			pkg.Metadata.ResourceVersion, newFn.Spec.Package.PackageRef.ResourceVersion)
+	}
+	rev, err := newFc.FunctionRevisions(ns).Get("hello-1")
+	if err != nil {
+		t.Fatalf("Error getting restored revision: %v", err)
+	}
+	if rev.Spec.Package.Deployment.URL != pkg.Spec.Deployment.URL {
+		t.Errorf("Expected revision to use the uploaded archive, got %v", rev.Spec.Package.Deployment.URL)
+	}
+
+	// restoring again changes nothing
+	result, err = newClient.BackupRestore(bytes.NewReader(backup.Bytes()))
+	if err != nil {
+		t.Fatalf("Error restoring backup again: %v", err)
+	}
+	for kind, as := range result.Status {
+		if len(as.Created) > 0 {
+			t.Errorf("Expected nothing to be created again, got %v %#v", kind, as.Created)
+		}
+	}
+	if len(archives) != 1 {
+		t.Errorf("Expected no more uploads, got %v archives", len(archives))
+	}
+
+	// archives that don't match their checksums aren't backed up
+	archives["old-id"] = []byte("something else")
+	err = oldClient.BackupCreate(ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Expected checksum error, got %v", err)
+	}
+}
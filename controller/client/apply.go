@@ -0,0 +1,135 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+// Apply has the controller make a deployment's objects match req, and
+// returns what changed. Archives maps the names packages refer to as
+// archive://<name> to local files, which are uploaded along with the
+// request. If anything fails, the controller undoes its changes.
+func (c *Client) Apply(req *crd.ApplyRequest, archives map[string]string) (*fission.ApplyResult, error) {
+	// archives may be large, so the request is streamed
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeApplyRequest(mw, req, archives))
+	}()
+
+	relativeUrl := "apply"
+	if ns := applyNamespace(&req.Resources); len(ns) > 0 {
+		relativeUrl += fmt.Sprintf("?namespace=%v", ns)
+	}
+	resp, err := c.httpClient().Post(c.url(relativeUrl), mw.FormDataContentType(), pr)
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var result fission.ApplyResult
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// applyNamespace returns the namespace of the objects of an apply
+// request, if they're all in one; the request is then authorized for
+// it. Otherwise the controller authorizes each namespace in turn.
+func applyNamespace(res *crd.Resources) string {
+	var metas []*metav1.ObjectMeta
+	for i := range res.Environments {
+		metas = append(metas, &res.Environments[i].Metadata)
+	}
+	for i := range res.Packages {
+		metas = append(metas, &res.Packages[i].Metadata)
+	}
+	for i := range res.Functions {
+		metas = append(metas, &res.Functions[i].Metadata)
+	}
+	for i := range res.HTTPTriggers {
+		metas = append(metas, &res.HTTPTriggers[i].Metadata)
+	}
+	for i := range res.KubernetesWatchTriggers {
+		metas = append(metas, &res.KubernetesWatchTriggers[i].Metadata)
+	}
+	for i := range res.TimeTriggers {
+		metas = append(metas, &res.TimeTriggers[i].Metadata)
+	}
+	for i := range res.MessageQueueTriggers {
+		metas = append(metas, &res.MessageQueueTriggers[i].Metadata)
+	}
+
+	ns := ""
+	for _, m := range metas {
+		if len(m.Namespace) == 0 {
+			continue
+		}
+		if len(ns) > 0 && ns != m.Namespace {
+			return ""
+		}
+		ns = m.Namespace
+	}
+	return ns
+}
+
+func writeApplyRequest(mw *multipart.Writer, req *crd.ApplyRequest, archives map[string]string) error {
+	reqbody, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	err = mw.WriteField("request", string(reqbody))
+	if err != nil {
+		return err
+	}
+
+	for name, path := range archives {
+		w, err := mw.CreateFormFile(name, filepath.Base(path))
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
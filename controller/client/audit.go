@@ -0,0 +1,94 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fission/fission"
+)
+
+// AuditListOptions filter the changes listed from the audit log. Empty
+// fields match everything; Limit keeps only the most recent changes.
+type AuditListOptions struct {
+	Since     time.Time
+	Until     time.Time
+	Resource  string
+	Namespace string
+	Name      string
+	User      string
+	Verb      string
+	Limit     int
+}
+
+func (opts *AuditListOptions) query() url.Values {
+	q := url.Values{}
+	if opts == nil {
+		return q
+	}
+	if !opts.Since.IsZero() {
+		q.Set("since", opts.Since.Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		q.Set("until", opts.Until.Format(time.RFC3339))
+	}
+	for param, v := range map[string]string{
+		"resource":  opts.Resource,
+		"namespace": opts.Namespace,
+		"name":      opts.Name,
+		"user":      opts.User,
+		"verb":      opts.Verb,
+	} {
+		if len(v) > 0 {
+			q.Set(param, v)
+		}
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	return q
+}
+
+// AuditList lists the changes recorded in the controller's audit log,
+// oldest first.
+func (c *Client) AuditList(opts *AuditListOptions) ([]fission.AuditEvent, error) {
+	relativeUrl := "audit"
+	if q := opts.query(); len(q) > 0 {
+		relativeUrl += "?" + q.Encode()
+	}
+
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]fission.AuditEvent, 0)
+	err = json.Unmarshal(body, &events)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/fission/fission"
+)
+
+// BackupCreate writes a backup of all Fission objects, along with the
+// archives they use, to w as a gzipped tarball.
+func (c *Client) BackupCreate(w io.Writer) error {
+	resp, err := c.httpClient().Get(c.url("backup"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fission.MakeErrorFromHTTP(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// BackupRestore restores a backup made by BackupCreate, creating the
+// objects that don't exist yet, and returns the objects it created.
+func (c *Client) BackupRestore(r io.Reader) (*fission.ApplyResult, error) {
+	resp, err := c.httpClient().Post(c.url("restore"), "application/gzip", r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var result fission.ApplyResult
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
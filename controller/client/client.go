@@ -18,17 +18,55 @@ package client
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	"github.com/fission/fission"
 )
 
 type (
 	Client struct {
 		Url string
+
+		// Token is sent as a bearer token on requests to the
+		// controller, if it's set.
+		Token string
+
+		// DryRun makes the controller validate creates, updates and
+		// deletes without carrying them out. It responds with the
+		// objects it would store.
+		DryRun bool
+
+		// environments and packages that dry runs would have
+		// created, as resource/namespace/name
+		dryRunCreated []string
+	}
+
+	// DeleteOptions change how objects are deleted.
+	DeleteOptions struct {
+		// Force deletes objects that others still refer to.
+		Force bool
+
+		// Cascade deletes objects along with the ones that depend
+		// on them, such as the triggers of a function.
+		Cascade bool
+	}
+
+	// controllerTransport adds a bearer token, and the dry run
+	// parameter, to requests to one host.
+	controllerTransport struct {
+		host          string
+		token         string
+		dryRun        bool
+		dryRunCreated []string
+		base          http.RoundTripper
 	}
 )
 
@@ -36,13 +74,69 @@ func MakeClient(serverUrl string) *Client {
 	return &Client{Url: strings.TrimSuffix(serverUrl, "/")}
 }
 
+// Transport wraps base so that requests to the controller, including
+// those through its proxies, carry the client's token. Requests to
+// other hosts are left alone, so that the token doesn't leak. In dry
+// run mode, changes through the controller API are marked as dry runs.
+func (c *Client) Transport(base http.RoundTripper) http.RoundTripper {
+	if len(c.Token) == 0 && !c.DryRun {
+		return base
+	}
+	host := ""
+	u, err := url.Parse(c.Url)
+	if err == nil {
+		host = u.Host
+	}
+	return &controllerTransport{
+		host:          host,
+		token:         c.Token,
+		dryRun:        c.DryRun,
+		dryRunCreated: c.dryRunCreated,
+		base:          base,
+	}
+}
+
+func (t *controllerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not change the request they're given.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	if len(t.token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	// Proxied services don't know about dry runs, so only API
+	// requests are marked.
+	if t.dryRun && r.Method != "GET" && strings.HasPrefix(r.URL.Path, "/v2/") {
+		u := *r.URL
+		q := u.Query()
+		q.Set("dryRun", "true")
+		u.RawQuery = q.Encode()
+		r.URL = &u
+		// objects created by earlier dry runs may be referred to
+		if len(t.dryRunCreated) > 0 {
+			r.Header.Set("X-Fission-Dry-Run-Created", strings.Join(t.dryRunCreated, ","))
+		}
+	}
+	return t.base.RoundTrip(r)
+}
+
+func (c *Client) httpClient() *http.Client {
+	return &http.Client{Transport: c.Transport(http.DefaultTransport)}
+}
+
 func (c *Client) delete(relativeUrl string) error {
 	req, err := http.NewRequest("DELETE", c.url(relativeUrl), nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return err
 	}
@@ -66,13 +160,107 @@ func (c *Client) put(relativeUrl string, contentType string, body []byte) (*http
 		return nil, err
 	}
 	req.Header.Set("Content-type", contentType)
-	return http.DefaultClient.Do(req)
+	return c.httpClient().Do(req)
+}
+
+// ListOptions filter and paginate lists. A list with a Limit returns
+// a continue token along with the objects if there are more of them;
+// passing it as Continue gets the next page. Watches start after
+// ResourceVersion, if it's set.
+type ListOptions struct {
+	Namespace       string
+	LabelSelector   string
+	FieldSelector   string
+	Limit           int
+	Continue        string
+	ResourceVersion string
+}
+
+func (opts *ListOptions) query() url.Values {
+	q := url.Values{}
+	if opts == nil {
+		return q
+	}
+	if len(opts.Namespace) > 0 {
+		q.Set("namespace", opts.Namespace)
+	}
+	if len(opts.LabelSelector) > 0 {
+		q.Set("labelSelector", opts.LabelSelector)
+	}
+	if len(opts.FieldSelector) > 0 {
+		q.Set("fieldSelector", opts.FieldSelector)
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if len(opts.Continue) > 0 {
+		q.Set("continue", opts.Continue)
+	}
+	if len(opts.ResourceVersion) > 0 {
+		q.Set("resourceVersion", opts.ResourceVersion)
+	}
+	return q
+}
+
+// list gets a list of objects into result, and returns the continue
+// token for the next page, if there is one.
+func (c *Client) list(relativeUrl string, query url.Values, result interface{}) (string, error) {
+	if len(query) > 0 {
+		relativeUrl += "?" + query.Encode()
+	}
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return "", err
+	}
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("X-Fission-Continue"), nil
 }
 
 func (c *Client) url(relativeUrl string) string {
 	return c.Url + "/v2/" + relativeUrl
 }
 
+// dryRunCreate records an object that a dry run would have created.
+func (c *Client) dryRunCreate(resource string, m *metav1.ObjectMeta) {
+	ns := m.Namespace
+	if len(ns) == 0 {
+		ns = metav1.NamespaceDefault
+	}
+	c.dryRunCreated = append(c.dryRunCreated, resource+"/"+ns+"/"+m.Name)
+}
+
+// objectMeta returns the metadata of a create or update response,
+// which is the stored object's metadata, or the whole object for dry
+// runs.
+func (c *Client) objectMeta(body []byte) (*metav1.ObjectMeta, error) {
+	if c.DryRun {
+		var obj struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		err := json.Unmarshal(body, &obj)
+		if err != nil {
+			return nil, err
+		}
+		return &obj.Metadata, nil
+	}
+
+	var m metav1.ObjectMeta
+	err := json.Unmarshal(body, &m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
 func (c *Client) handleResponse(resp *http.Response) ([]byte, error) {
 	if resp.StatusCode != 200 {
 		return nil, fission.MakeErrorFromHTTP(resp)
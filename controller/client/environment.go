@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -33,7 +32,7 @@ func (c *Client) EnvironmentCreate(env *crd.Environment) (*metav1.ObjectMeta, er
 		return nil, err
 	}
 
-	resp, err := http.Post(c.url("environments"), "application/json", bytes.NewReader(reqbody))
+	resp, err := c.httpClient().Post(c.url("environments"), "application/json", bytes.NewReader(reqbody))
 	if err != nil {
 		return nil, err
 	}
@@ -44,20 +43,21 @@ func (c *Client) EnvironmentCreate(env *crd.Environment) (*metav1.ObjectMeta, er
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
+	m, err := c.objectMeta(body)
 	if err != nil {
 		return nil, err
 	}
-
-	return &m, nil
+	if c.DryRun {
+		c.dryRunCreate("environments", m)
+	}
+	return m, nil
 }
 
 func (c *Client) EnvironmentGet(m *metav1.ObjectMeta) (*crd.Environment, error) {
 	relativeUrl := fmt.Sprintf("environments/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
 
-	resp, err := http.Get(c.url(relativeUrl))
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
 	if err != nil {
 		return nil, err
 	}
@@ -95,38 +95,36 @@ func (c *Client) EnvironmentUpdate(env *crd.Environment) (*metav1.ObjectMeta, er
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) EnvironmentDelete(m *metav1.ObjectMeta) error {
+	return c.EnvironmentDeleteWithOptions(m, nil)
+}
+
+// EnvironmentDeleteWithOptions deletes an environment; environments
+// that functions use are only deleted with opts.Force.
+func (c *Client) EnvironmentDeleteWithOptions(m *metav1.ObjectMeta, opts *DeleteOptions) error {
 	relativeUrl := fmt.Sprintf("environments/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
-
+	if opts != nil && opts.Force {
+		relativeUrl += "&force=true"
+	}
 	return c.delete(relativeUrl)
 }
 
 func (c *Client) EnvironmentList() ([]crd.Environment, error) {
-	resp, err := http.Get(c.url("environments"))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := c.handleResponse(resp)
-	if err != nil {
-		return nil, err
-	}
+	envs, _, err := c.EnvironmentListWithOptions(nil)
+	return envs, err
+}
 
+// EnvironmentListWithOptions returns the environments matching opts, and the
+// continue token for the next page, if there is one.
+func (c *Client) EnvironmentListWithOptions(opts *ListOptions) ([]crd.Environment, string, error) {
 	envs := make([]crd.Environment, 0)
-	err = json.Unmarshal(body, &envs)
+	next, err := c.list("environments", opts.query(), &envs)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	return envs, nil
+	return envs, next, nil
 }
@@ -0,0 +1,66 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	executorClient "github.com/fission/fission/executor/client"
+)
+
+// executorGet gets relativeUrl from the executor's introspection API,
+// through the controller's proxy.
+func (c *Client) executorGet(relativeUrl string, result interface{}) error {
+	resp, err := c.httpClient().Get(c.Url + "/proxy/executor/v2/" + relativeUrl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (c *Client) FunctionStatus(m *metav1.ObjectMeta) (*executorClient.FunctionStatus, error) {
+	relativeUrl := fmt.Sprintf("functions/%v/status", m.Name)
+	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
+
+	var status executorClient.FunctionStatus
+	err := c.executorGet(relativeUrl, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *Client) FuncSvcList() ([]executorClient.FuncSvcStatus, error) {
+	fsvcs := make([]executorClient.FuncSvcStatus, 0)
+	err := c.executorGet("funcsvcs", &fsvcs)
+	return fsvcs, err
+}
+
+func (c *Client) PoolList() ([]executorClient.PoolStatus, error) {
+	pools := make([]executorClient.PoolStatus, 0)
+	err := c.executorGet("pools", &pools)
+	return pools, err
+}
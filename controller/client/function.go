@@ -20,9 +20,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
 
 	"github.com/fission/fission/crd"
 )
@@ -34,7 +34,7 @@ func (c *Client) FunctionCreate(f *crd.Function) (*metav1.ObjectMeta, error) {
 		return nil, err
 	}
 
-	resp, err := http.Post(c.url("functions"), "application/json", bytes.NewReader(reqbody))
+	resp, err := c.httpClient().Post(c.url("functions"), "application/json", bytes.NewReader(reqbody))
 	if err != nil {
 		return nil, err
 	}
@@ -45,20 +45,14 @@ func (c *Client) FunctionCreate(f *crd.Function) (*metav1.ObjectMeta, error) {
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) FunctionGet(m *metav1.ObjectMeta) (*crd.Function, error) {
 	relativeUrl := fmt.Sprintf("functions/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
 
-	resp, err := http.Get(c.url(relativeUrl))
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +77,7 @@ func (c *Client) FunctionGetRawDeployment(m *metav1.ObjectMeta) ([]byte, error)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
 	relativeUrl += fmt.Sprintf("&deploymentraw=1")
 
-	resp, err := http.Get(c.url(relativeUrl))
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
 	if err != nil {
 		return nil, err
 	}
@@ -110,22 +104,47 @@ func (c *Client) FunctionUpdate(f *crd.Function) (*metav1.ObjectMeta, error) {
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) FunctionDelete(m *metav1.ObjectMeta) error {
+	return c.FunctionDeleteWithOptions(m, nil)
+}
+
+// FunctionDeleteWithOptions deletes a function; with opts.Cascade, its
+// triggers and the packages created for it are deleted too.
+func (c *Client) FunctionDeleteWithOptions(m *metav1.ObjectMeta, opts *DeleteOptions) error {
 	relativeUrl := fmt.Sprintf("functions/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
+	if opts != nil && opts.Cascade {
+		relativeUrl += "&cascade=true"
+	}
 	return c.delete(relativeUrl)
 }
 
 func (c *Client) FunctionList() ([]crd.Function, error) {
-	resp, err := http.Get(c.url("functions"))
+	funcs, _, err := c.FunctionListWithOptions(nil)
+	return funcs, err
+}
+
+// FunctionListWithOptions returns the functions matching opts, and the
+// continue token for the next page, if there is one.
+func (c *Client) FunctionListWithOptions(opts *ListOptions) ([]crd.Function, string, error) {
+	funcs := make([]crd.Function, 0)
+	next, err := c.list("functions", opts.query(), &funcs)
+	if err != nil {
+		return nil, "", err
+	}
+	return funcs, next, nil
+}
+
+// FunctionEvents returns the Kubernetes events recorded for a function
+// by the executor, oldest first.
+func (c *Client) FunctionEvents(m *metav1.ObjectMeta) ([]apiv1.Event, error) {
+	relativeUrl := fmt.Sprintf("functions/%v/events", m.Name)
+	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
+
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
 	if err != nil {
 		return nil, err
 	}
@@ -136,11 +155,78 @@ func (c *Client) FunctionList() ([]crd.Function, error) {
 		return nil, err
 	}
 
-	funcs := make([]crd.Function, 0)
-	err = json.Unmarshal(body, &funcs)
+	events := make([]apiv1.Event, 0)
+	err = json.Unmarshal(body, &events)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// FunctionRevisionList returns the revisions of a function, oldest
+// first.
+func (c *Client) FunctionRevisionList(m *metav1.ObjectMeta) ([]crd.FunctionRevision, error) {
+	relativeUrl := fmt.Sprintf("functions/%v/revisions", m.Name)
+	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
+
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	revs := make([]crd.FunctionRevision, 0)
+	err = json.Unmarshal(body, &revs)
+	if err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+func (c *Client) FunctionRevisionGet(m *metav1.ObjectMeta, revision int) (*crd.FunctionRevision, error) {
+	relativeUrl := fmt.Sprintf("functions/%v/revisions/%v", m.Name, revision)
+	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
+
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var rev crd.FunctionRevision
+	err = json.Unmarshal(body, &rev)
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// FunctionRollback rolls a function and its package back to a
+// revision. The rollback is recorded as a new revision.
+func (c *Client) FunctionRollback(m *metav1.ObjectMeta, revision int) (*metav1.ObjectMeta, error) {
+	relativeUrl := fmt.Sprintf("functions/%v/revisions/%v/rollback", m.Name, revision)
+	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
+
+	resp, err := c.httpClient().Post(c.url(relativeUrl), "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
 	if err != nil {
 		return nil, err
 	}
 
-	return funcs, nil
+	return c.objectMeta(body)
 }
@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -33,7 +32,7 @@ func (c *Client) HTTPTriggerCreate(t *crd.HTTPTrigger) (*metav1.ObjectMeta, erro
 		return nil, err
 	}
 
-	resp, err := http.Post(c.url("triggers/http"), "application/json", bytes.NewReader(reqbody))
+	resp, err := c.httpClient().Post(c.url("triggers/http"), "application/json", bytes.NewReader(reqbody))
 	if err != nil {
 		return nil, err
 	}
@@ -44,20 +43,14 @@ func (c *Client) HTTPTriggerCreate(t *crd.HTTPTrigger) (*metav1.ObjectMeta, erro
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) HTTPTriggerGet(m *metav1.ObjectMeta) (*crd.HTTPTrigger, error) {
 	relativeUrl := fmt.Sprintf("triggers/http/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
 
-	resp, err := http.Get(c.url(relativeUrl))
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
 	if err != nil {
 		return nil, err
 	}
@@ -95,12 +88,7 @@ func (c *Client) HTTPTriggerUpdate(t *crd.HTTPTrigger) (*metav1.ObjectMeta, erro
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) HTTPTriggerDelete(m *metav1.ObjectMeta) error {
@@ -110,22 +98,17 @@ func (c *Client) HTTPTriggerDelete(m *metav1.ObjectMeta) error {
 }
 
 func (c *Client) HTTPTriggerList() ([]crd.HTTPTrigger, error) {
-	resp, err := http.Get(c.url("triggers/http"))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := c.handleResponse(resp)
-	if err != nil {
-		return nil, err
-	}
+	triggers, _, err := c.HTTPTriggerListWithOptions(nil)
+	return triggers, err
+}
 
+// HTTPTriggerListWithOptions returns the HTTP triggers matching opts, and the
+// continue token for the next page, if there is one.
+func (c *Client) HTTPTriggerListWithOptions(opts *ListOptions) ([]crd.HTTPTrigger, string, error) {
 	triggers := make([]crd.HTTPTrigger, 0)
-	err = json.Unmarshal(body, &triggers)
+	next, err := c.list("triggers/http", opts.query(), &triggers)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	return triggers, nil
+	return triggers, next, nil
 }
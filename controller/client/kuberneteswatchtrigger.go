@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -34,7 +33,7 @@ func (c *Client) WatchCreate(w *crd.KubernetesWatchTrigger) (*metav1.ObjectMeta,
 		return nil, err
 	}
 
-	resp, err := http.Post(c.url("watches"), "application/json", bytes.NewReader(reqbody))
+	resp, err := c.httpClient().Post(c.url("watches"), "application/json", bytes.NewReader(reqbody))
 	if err != nil {
 		return nil, err
 	}
@@ -45,20 +44,14 @@ func (c *Client) WatchCreate(w *crd.KubernetesWatchTrigger) (*metav1.ObjectMeta,
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) WatchGet(m *metav1.ObjectMeta) (*crd.KubernetesWatchTrigger, error) {
 	relativeUrl := fmt.Sprintf("watches/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
 
-	resp, err := http.Get(c.url(relativeUrl))
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
 	if err != nil {
 		return nil, err
 	}
@@ -90,22 +83,17 @@ func (c *Client) WatchDelete(m *metav1.ObjectMeta) error {
 }
 
 func (c *Client) WatchList() ([]crd.KubernetesWatchTrigger, error) {
-	resp, err := http.Get(c.url("watches"))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := c.handleResponse(resp)
-	if err != nil {
-		return nil, err
-	}
+	watches, _, err := c.WatchListWithOptions(nil)
+	return watches, err
+}
 
+// WatchListWithOptions returns the Kubernetes watch triggers matching opts, and the
+// continue token for the next page, if there is one.
+func (c *Client) WatchListWithOptions(opts *ListOptions) ([]crd.KubernetesWatchTrigger, string, error) {
 	watches := make([]crd.KubernetesWatchTrigger, 0)
-	err = json.Unmarshal(body, &watches)
+	next, err := c.list("watches", opts.query(), &watches)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	return watches, err
+	return watches, next, nil
 }
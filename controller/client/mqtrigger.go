@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -33,7 +32,7 @@ func (c *Client) MessageQueueTriggerCreate(t *crd.MessageQueueTrigger) (*metav1.
 		return nil, err
 	}
 
-	resp, err := http.Post(c.url("triggers/messagequeue"), "application/json", bytes.NewReader(reqbody))
+	resp, err := c.httpClient().Post(c.url("triggers/messagequeue"), "application/json", bytes.NewReader(reqbody))
 	if err != nil {
 		return nil, err
 	}
@@ -44,20 +43,14 @@ func (c *Client) MessageQueueTriggerCreate(t *crd.MessageQueueTrigger) (*metav1.
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) MessageQueueTriggerGet(m *metav1.ObjectMeta) (*crd.MessageQueueTrigger, error) {
 	relativeUrl := fmt.Sprintf("triggers/messagequeue/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
 
-	resp, err := http.Get(c.url(relativeUrl))
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
 	if err != nil {
 		return nil, err
 	}
@@ -95,12 +88,7 @@ func (c *Client) MessageQueueTriggerUpdate(mqTrigger *crd.MessageQueueTrigger) (
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) MessageQueueTriggerDelete(m *metav1.ObjectMeta) error {
@@ -110,28 +98,24 @@ func (c *Client) MessageQueueTriggerDelete(m *metav1.ObjectMeta) error {
 }
 
 func (c *Client) MessageQueueTriggerList(mqType string) ([]crd.MessageQueueTrigger, error) {
-	relativeUrl := "triggers/messagequeue"
+	triggers, _, err := c.MessageQueueTriggerListWithOptions(mqType, nil)
+	return triggers, err
+}
+
+// MessageQueueTriggerListWithOptions returns the message queue
+// triggers matching opts, and the continue token for the next page, if
+// there is one.
+func (c *Client) MessageQueueTriggerListWithOptions(mqType string, opts *ListOptions) ([]crd.MessageQueueTrigger, string, error) {
+	query := opts.query()
 	if len(mqType) > 0 {
 		// TODO remove this, replace with field selector
-		relativeUrl += fmt.Sprintf("?mqtype=%v", mqType)
-	}
-
-	resp, err := http.Get(c.url(relativeUrl))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := c.handleResponse(resp)
-	if err != nil {
-		return nil, err
+		query.Set("mqtype", mqType)
 	}
 
 	triggers := make([]crd.MessageQueueTrigger, 0)
-	err = json.Unmarshal(body, &triggers)
+	next, err := c.list("triggers/messagequeue", query, &triggers)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	return triggers, nil
+	return triggers, next, nil
 }
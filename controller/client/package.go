@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -34,7 +33,7 @@ func (c *Client) PackageCreate(f *crd.Package) (*metav1.ObjectMeta, error) {
 		return nil, err
 	}
 
-	resp, err := http.Post(c.url("packages"), "application/json", bytes.NewReader(reqbody))
+	resp, err := c.httpClient().Post(c.url("packages"), "application/json", bytes.NewReader(reqbody))
 	if err != nil {
 		return nil, err
 	}
@@ -45,20 +44,21 @@ func (c *Client) PackageCreate(f *crd.Package) (*metav1.ObjectMeta, error) {
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
+	m, err := c.objectMeta(body)
 	if err != nil {
 		return nil, err
 	}
-
-	return &m, nil
+	if c.DryRun {
+		c.dryRunCreate("packages", m)
+	}
+	return m, nil
 }
 
 func (c *Client) PackageGet(m *metav1.ObjectMeta) (*crd.Package, error) {
 	relativeUrl := fmt.Sprintf("packages/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
 
-	resp, err := http.Get(c.url(relativeUrl))
+	resp, err := c.httpClient().Get(c.url(relativeUrl))
 	if err != nil {
 		return nil, err
 	}
@@ -96,37 +96,36 @@ func (c *Client) PackageUpdate(f *crd.Package) (*metav1.ObjectMeta, error) {
 		return nil, err
 	}
 
-	var m metav1.ObjectMeta
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		return nil, err
-	}
-	return &m, nil
+	return c.objectMeta(body)
 }
 
 func (c *Client) PackageDelete(m *metav1.ObjectMeta) error {
+	return c.PackageDeleteWithOptions(m, nil)
+}
+
+// PackageDeleteWithOptions deletes a package; packages that functions
+// use are only deleted with opts.Force.
+func (c *Client) PackageDeleteWithOptions(m *metav1.ObjectMeta, opts *DeleteOptions) error {
 	relativeUrl := fmt.Sprintf("packages/%v", m.Name)
 	relativeUrl += fmt.Sprintf("?namespace=%v", m.Namespace)
+	if opts != nil && opts.Force {
+		relativeUrl += "&force=true"
+	}
 	return c.delete(relativeUrl)
 }
 
 func (c *Client) PackageList() ([]crd.Package, error) {
-	resp, err := http.Get(c.url("packages"))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := c.handleResponse(resp)
-	if err != nil {
-		return nil, err
-	}
+	pkgs, _, err := c.PackageListWithOptions(nil)
+	return pkgs, err
+}
 
-	funcs := make([]crd.Package, 0)
-	err = json.Unmarshal(body, &funcs)
+// PackageListWithOptions returns the packages matching opts, and the
+// continue token for the next page, if there is one.
+func (c *Client) PackageListWithOptions(opts *ListOptions) ([]crd.Package, string, error) {
+	pkgs := make([]crd.Package, 0)
+	next, err := c.list("packages", opts.query(), &pkgs)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	return funcs, nil
+	return pkgs, next, nil
 }
@@ -0,0 +1,52 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+// Validate has the controller validate resources without creating
+// them, and returns the problems with the invalid ones.
+func (c *Client) Validate(res *crd.Resources) ([]fission.ValidationResult, error) {
+	reqbody, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Post(c.url("validate"), "application/json", bytes.NewReader(reqbody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := c.handleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]fission.ValidationResult, 0)
+	err = json.Unmarshal(body, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+type (
+	// Watcher reads the events of a watch on the controller. A watch
+	// ends when the controller closes it, or after an error; callers
+	// then watch again from the ResourceVersion of the last object
+	// they got.
+	Watcher struct {
+		resp    *http.Response
+		decoder *json.Decoder
+	}
+
+	watchEvent struct {
+		Type   watch.EventType `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}
+
+	EnvironmentWatcher            struct{ *Watcher }
+	FunctionWatcher               struct{ *Watcher }
+	HTTPTriggerWatcher            struct{ *Watcher }
+	KubernetesWatchTriggerWatcher struct{ *Watcher }
+	MessageQueueTriggerWatcher    struct{ *Watcher }
+	PackageWatcher                struct{ *Watcher }
+	TimeTriggerWatcher            struct{ *Watcher }
+)
+
+// watch starts watching the objects a list would return.
+func (c *Client) watch(relativeUrl string, query url.Values) (*Watcher, error) {
+	query.Set("watch", "true")
+	resp, err := c.httpClient().Get(c.url(relativeUrl + "?" + query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		return nil, fission.MakeErrorFromHTTP(resp)
+	}
+	return &Watcher{
+		resp:    resp,
+		decoder: json.NewDecoder(resp.Body),
+	}, nil
+}
+
+// Stop ends the watch.
+func (w *Watcher) Stop() {
+	w.resp.Body.Close()
+}
+
+// next decodes the object of the next event into obj. It returns
+// io.EOF once the watch has ended.
+func (w *Watcher) next(obj interface{}) (watch.EventType, error) {
+	var event watchEvent
+	err := w.decoder.Decode(&event)
+	if err != nil {
+		return "", err
+	}
+	if event.Type == watch.Error {
+		var status metav1.Status
+		err = json.Unmarshal(event.Object, &status)
+		if err != nil {
+			return "", err
+		}
+		return "", errors.New(status.Message)
+	}
+	err = json.Unmarshal(event.Object, obj)
+	if err != nil {
+		return "", err
+	}
+	return event.Type, nil
+}
+
+func (w *EnvironmentWatcher) Next() (watch.EventType, *crd.Environment, error) {
+	var env crd.Environment
+	t, err := w.next(&env)
+	if err != nil {
+		return "", nil, err
+	}
+	return t, &env, nil
+}
+
+func (w *FunctionWatcher) Next() (watch.EventType, *crd.Function, error) {
+	var fn crd.Function
+	t, err := w.next(&fn)
+	if err != nil {
+		return "", nil, err
+	}
+	return t, &fn, nil
+}
+
+func (w *HTTPTriggerWatcher) Next() (watch.EventType, *crd.HTTPTrigger, error) {
+	var ht crd.HTTPTrigger
+	t, err := w.next(&ht)
+	if err != nil {
+		return "", nil, err
+	}
+	return t, &ht, nil
+}
+
+func (w *KubernetesWatchTriggerWatcher) Next() (watch.EventType, *crd.KubernetesWatchTrigger, error) {
+	var ws crd.KubernetesWatchTrigger
+	t, err := w.next(&ws)
+	if err != nil {
+		return "", nil, err
+	}
+	return t, &ws, nil
+}
+
+func (w *MessageQueueTriggerWatcher) Next() (watch.EventType, *crd.MessageQueueTrigger, error) {
+	var mqt crd.MessageQueueTrigger
+	t, err := w.next(&mqt)
+	if err != nil {
+		return "", nil, err
+	}
+	return t, &mqt, nil
+}
+
+func (w *PackageWatcher) Next() (watch.EventType, *crd.Package, error) {
+	var pkg crd.Package
+	t, err := w.next(&pkg)
+	if err != nil {
+		return "", nil, err
+	}
+	return t, &pkg, nil
+}
+
+func (w *TimeTriggerWatcher) Next() (watch.EventType, *crd.TimeTrigger, error) {
+	var tt crd.TimeTrigger
+	t, err := w.next(&tt)
+	if err != nil {
+		return "", nil, err
+	}
+	return t, &tt, nil
+}
+
+// The Watch* methods watch the objects matching opts. Limit and
+// Continue don't apply to watches. Without a ResourceVersion, the
+// watch starts with an ADDED event for each existing object.
+
+func (c *Client) EnvironmentWatch(opts *ListOptions) (*EnvironmentWatcher, error) {
+	w, err := c.watch("environments", opts.query())
+	if err != nil {
+		return nil, err
+	}
+	return &EnvironmentWatcher{w}, nil
+}
+
+func (c *Client) FunctionWatch(opts *ListOptions) (*FunctionWatcher, error) {
+	w, err := c.watch("functions", opts.query())
+	if err != nil {
+		return nil, err
+	}
+	return &FunctionWatcher{w}, nil
+}
+
+func (c *Client) HTTPTriggerWatch(opts *ListOptions) (*HTTPTriggerWatcher, error) {
+	w, err := c.watch("triggers/http", opts.query())
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPTriggerWatcher{w}, nil
+}
+
+func (c *Client) WatchWatch(opts *ListOptions) (*KubernetesWatchTriggerWatcher, error) {
+	w, err := c.watch("watches", opts.query())
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesWatchTriggerWatcher{w}, nil
+}
+
+func (c *Client) MessageQueueTriggerWatch(opts *ListOptions) (*MessageQueueTriggerWatcher, error) {
+	w, err := c.watch("triggers/messagequeue", opts.query())
+	if err != nil {
+		return nil, err
+	}
+	return &MessageQueueTriggerWatcher{w}, nil
+}
+
+func (c *Client) PackageWatch(opts *ListOptions) (*PackageWatcher, error) {
+	w, err := c.watch("packages", opts.query())
+	if err != nil {
+		return nil, err
+	}
+	return &PackageWatcher{w}, nil
+}
+
+func (c *Client) TimeTriggerWatch(opts *ListOptions) (*TimeTriggerWatcher, error) {
+	w, err := c.watch("triggers/time", opts.query())
+	if err != nil {
+		return nil, err
+	}
+	return &TimeTriggerWatcher{w}, nil
+}
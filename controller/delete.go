@@ -0,0 +1,251 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+// Packages created for a function are owned by it. Deleting a function
+// with cascade=true also deletes the triggers that invoke it and the
+// packages it owns (unless other functions use them); otherwise they're
+// orphaned, and left for "fission package delete --orphan".
+
+// isCascade returns true if a delete request should also delete the
+// objects that depend on the object.
+func isCascade(r *http.Request) bool {
+	cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade"))
+	return cascade
+}
+
+// ownPackage makes the package a function was just created with owned by
+// the function, if the package was created for it. The function refers
+// to a package version, which changes along with the owner, so it's
+// updated too and returned.
+func (a *API) ownPackage(fn *crd.Function) (*crd.Function, error) {
+	ns := namespaceOr(fn.Metadata.Namespace, metav1.NamespaceDefault)
+	ref := fn.Spec.Package.PackageRef
+	// owners must be in the same namespace
+	if len(ref.Name) == 0 || namespaceOr(ref.Namespace, ns) != ns {
+		return fn, nil
+	}
+
+	pkg, err := a.fissionClient.Packages(ns).Get(ref.Name)
+	if err != nil {
+		return fn, err
+	}
+	if pkg.Metadata.Annotations[fission.PACKAGE_FOR_FUNCTION_ANNOTATION] != fn.Metadata.Name ||
+		len(pkg.Metadata.OwnerReferences) > 0 {
+		return fn, nil
+	}
+
+	pkg.Metadata.OwnerReferences = []metav1.OwnerReference{
+		{
+			APIVersion: "fission.io/v1",
+			Kind:       "Function",
+			Name:       fn.Metadata.Name,
+			UID:        fn.Metadata.UID,
+		},
+	}
+	pkg, err = a.fissionClient.Packages(ns).Update(pkg)
+	if err != nil {
+		return fn, err
+	}
+
+	if len(ref.ResourceVersion) == 0 {
+		return fn, nil
+	}
+	fn.Spec.Package.PackageRef.ResourceVersion = pkg.Metadata.ResourceVersion
+	return a.fissionClient.Functions(ns).Update(fn)
+}
+
+// triggersOf returns the triggers that invoke a function, as lists of
+// names by resource.
+func (a *API) triggersOf(namespace string, fnName string) (map[string][]string, error) {
+	fc := a.fissionClient
+	triggers := make(map[string][]string)
+
+	hts, err := fc.HTTPTriggers(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range hts.Items {
+		if t.Spec.FunctionReference.Name == fnName {
+			triggers["httptriggers"] = append(triggers["httptriggers"], t.Metadata.Name)
+		}
+	}
+
+	ws, err := fc.KubernetesWatchTriggers(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range ws.Items {
+		if t.Spec.FunctionReference.Name == fnName {
+			triggers["kuberneteswatchtriggers"] = append(triggers["kuberneteswatchtriggers"], t.Metadata.Name)
+		}
+	}
+
+	tts, err := fc.TimeTriggers(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tts.Items {
+		if t.Spec.FunctionReference.Name == fnName {
+			triggers["timetriggers"] = append(triggers["timetriggers"], t.Metadata.Name)
+		}
+	}
+
+	mqts, err := fc.MessageQueueTriggers(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range mqts.Items {
+		if t.Spec.FunctionReference.Name == fnName {
+			triggers["messagequeuetriggers"] = append(triggers["messagequeuetriggers"], t.Metadata.Name)
+		}
+	}
+
+	return triggers, nil
+}
+
+// deleteDependent deletes an object that depends on the object a request
+// deletes, and records the change. Objects that are already gone are
+// fine.
+func (a *API) deleteDependent(r *http.Request, resource string, namespace string, name string) error {
+	before, _, err := a.getStored(resource, namespace, name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	fc := a.fissionClient
+	opts := &metav1.DeleteOptions{}
+	switch resource {
+	case "packages":
+		err = fc.Packages(namespace).Delete(name, opts)
+	case "httptriggers":
+		err = fc.HTTPTriggers(namespace).Delete(name, opts)
+	case "kuberneteswatchtriggers":
+		err = fc.KubernetesWatchTriggers(namespace).Delete(name, opts)
+	case "timetriggers":
+		err = fc.TimeTriggers(namespace).Delete(name, opts)
+	case "messagequeuetriggers":
+		err = fc.MessageQueueTriggers(namespace).Delete(name, opts)
+	default:
+		err = fmt.Errorf("unknown resource %v", resource)
+	}
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	a.recordChange(r, "delete", resource, namespace, name, before, nil)
+	return nil
+}
+
+// deleteFunctionCascade deletes a function along with its triggers and
+// the packages it owns. Triggers go first, so that nothing invokes the
+// function while it's being deleted.
+func (a *API) deleteFunctionCascade(r *http.Request, namespace string, name string) error {
+	fn, err := a.fissionClient.Functions(namespace).Get(name)
+	if err != nil {
+		return err
+	}
+
+	triggers, err := a.triggersOf(namespace, name)
+	if err != nil {
+		return err
+	}
+	for resource, names := range triggers {
+		for _, t := range names {
+			err = a.deleteDependent(r, resource, namespace, t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// The garbage collector would delete all the packages the function
+	// owns, including those other functions use, so they're orphaned
+	// and deleted here instead.
+	orphan := metav1.DeletePropagationOrphan
+	err = a.fissionClient.Functions(namespace).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &orphan})
+	if err != nil {
+		return err
+	}
+
+	pkgs, err := a.fissionClient.Packages(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs.Items {
+		owned := false
+		for _, owner := range pkg.Metadata.OwnerReferences {
+			if owner.Kind == "Function" && owner.UID == fn.Metadata.UID {
+				owned = true
+			}
+		}
+		if !owned {
+			continue
+		}
+		// keep packages that other functions have started using
+		err = a.checkPackageUnused(namespace, pkg.Metadata.Name)
+		if err != nil {
+			log.Infof("Keeping package %v of deleted function %v: %v", pkg.Metadata.Name, name, err)
+			continue
+		}
+		err = a.deleteDependent(r, "packages", namespace, pkg.Metadata.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkEnvironmentUnused returns an error if functions use an
+// environment.
+func (a *API) checkEnvironmentUnused(namespace string, name string) error {
+	fns, err := a.fissionClient.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	users := make([]string, 0)
+	for _, fn := range fns.Items {
+		ref := fn.Spec.Environment
+		if ref.Name == name && namespaceOr(ref.Namespace, fn.Metadata.Namespace) == namespace {
+			users = append(users, fn.Metadata.Name)
+		}
+	}
+	if len(users) > 0 {
+		return fission.MakeError(fission.ErrorInvalidArgument,
+			fmt.Sprintf("Environment %v is used by functions %v; delete them first, or force the deletion", name, users))
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+)
+
+func TestCascadeDelete(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+	api := &API{fissionClient: fc}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v2/functions", api.FunctionApiCreate).Methods("POST")
+	r.HandleFunc("/v2/functions/{function}", api.FunctionApiDelete).Methods("DELETE")
+	r.HandleFunc("/v2/environments/{environment}", api.EnvironmentApiDelete).Methods("DELETE")
+
+	do := func(method string, url string, obj interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if obj != nil {
+			var err error
+			body, err = json.Marshal(obj)
+			if err != nil {
+				t.Fatalf("Error encoding request: %v", err)
+			}
+		}
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	_, err := fc.Environments(ns).Create(&crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "nodejs", Namespace: ns},
+		Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating environment: %v", err)
+	}
+
+	// creates a function with a package made for it, and an HTTP trigger
+	create := func(name string) {
+		pkg, err := fc.Packages(ns).Create(&crd.Package{
+			Metadata: metav1.ObjectMeta{
+				Name:        name + "-pkg",
+				Namespace:   ns,
+				Annotations: map[string]string{fission.PACKAGE_FOR_FUNCTION_ANNOTATION: name},
+			},
+			Spec: fission.PackageSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+				Deployment:  fission.Archive{Type: fission.ArchiveTypeUrl, URL: "http://example.com/" + name},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error creating package: %v", err)
+		}
+		w := do("POST", "/v2/functions", &crd.Function{
+			Metadata: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec: fission.FunctionSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+				Package: fission.FunctionPackageRef{
+					PackageRef: fission.PackageRef{
+						Name:            pkg.Metadata.Name,
+						Namespace:       ns,
+						ResourceVersion: pkg.Metadata.ResourceVersion,
+					},
+				},
+			},
+		})
+		if w.Code != 201 {
+			t.Fatalf("Error creating function %v: %v %v", name, w.Code, w.Body.String())
+		}
+		_, err = fc.HTTPTriggers(ns).Create(&crd.HTTPTrigger{
+			Metadata: metav1.ObjectMeta{Name: name + "-trigger", Namespace: ns},
+			Spec: fission.HTTPTriggerSpec{
+				RelativeURL:       "/" + name,
+				Method:            "GET",
+				FunctionReference: fission.FunctionReference{Type: fission.FunctionReferenceTypeFunctionName, Name: name},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error creating trigger: %v", err)
+		}
+	}
+
+	create("hello")
+	fn, err := fc.Functions(ns).Get("hello")
+	if err != nil {
+		t.Fatalf("Error getting function: %v", err)
+	}
+	pkg, err := fc.Packages(ns).Get("hello-pkg")
+	if err != nil {
+		t.Fatalf("Error getting package: %v", err)
+	}
+	owners := pkg.Metadata.OwnerReferences
+	if len(owners) != 1 || owners[0].Kind != "Function" || owners[0].UID != fn.Metadata.UID {
+		t.Errorf("Expected package to be owned by function %v, got %#v", fn.Metadata.UID, owners)
+	}
+	if fn.Spec.Package.PackageRef.ResourceVersion != pkg.Metadata.ResourceVersion {
+		t.Errorf("Expected function to refer to package version %v, got %v",
+			pkg.Metadata.ResourceVersion, fn.Spec.Package.PackageRef.ResourceVersion)
+	}
+
+	w := do("DELETE", "/v2/environments/nodejs", nil)
+	if w.Code != 400 {
+		t.Errorf("Expected deleting a used environment to fail, got %v", w.Code)
+	}
+
+	// without cascade, the trigger and package stay
+	w = do("DELETE", "/v2/functions/hello", nil)
+	if w.Code != 200 {
+		t.Fatalf("Error deleting function: %v %v", w.Code, w.Body.String())
+	}
+	if _, err := fc.Packages(ns).Get("hello-pkg"); err != nil {
+		t.Errorf("Expected package to be kept: %v", err)
+	}
+	if _, err := fc.HTTPTriggers(ns).Get("hello-trigger"); err != nil {
+		t.Errorf("Expected trigger to be kept: %v", err)
+	}
+
+	create("bye")
+	// dry runs change nothing
+	w = do("DELETE", "/v2/functions/bye?cascade=true&dryRun=true", nil)
+	if w.Code != 200 {
+		t.Fatalf("Error in dry run: %v %v", w.Code, w.Body.String())
+	}
+	if _, err := fc.HTTPTriggers(ns).Get("bye-trigger"); err != nil {
+		t.Errorf("Expected dry run to keep trigger: %v", err)
+	}
+
+	// packages that other functions use are kept
+	create("shared")
+	shared, err := fc.Functions(ns).Get("shared")
+	if err != nil {
+		t.Fatalf("Error getting function: %v", err)
+	}
+	_, err = fc.Functions(ns).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "user", Namespace: ns},
+		Spec:     shared.Spec,
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+	w = do("DELETE", "/v2/functions/shared?cascade=true", nil)
+	if w.Code != 200 {
+		t.Fatalf("Error deleting function: %v %v", w.Code, w.Body.String())
+	}
+	if _, err := fc.Packages(ns).Get("shared-pkg"); err != nil {
+		t.Errorf("Expected package used by another function to be kept: %v", err)
+	}
+	if _, err := fc.HTTPTriggers(ns).Get("shared-trigger"); err == nil {
+		t.Errorf("Expected trigger of function to be deleted")
+	}
+	w = do("DELETE", "/v2/functions/user?cascade=true", nil)
+	if w.Code != 200 {
+		t.Fatalf("Error deleting function: %v %v", w.Code, w.Body.String())
+	}
+
+	w = do("DELETE", "/v2/functions/bye?cascade=true", nil)
+	if w.Code != 200 {
+		t.Fatalf("Error deleting function: %v %v", w.Code, w.Body.String())
+	}
+	if _, err := fc.Functions(ns).Get("bye"); err == nil {
+		t.Errorf("Expected function to be deleted")
+	}
+	if _, err := fc.Packages(ns).Get("bye-pkg"); err == nil {
+		t.Errorf("Expected package of function to be deleted")
+	}
+	if _, err := fc.HTTPTriggers(ns).Get("bye-trigger"); err == nil {
+		t.Errorf("Expected trigger of function to be deleted")
+	}
+	// other functions' objects are left alone
+	if _, err := fc.HTTPTriggers(ns).Get("hello-trigger"); err != nil {
+		t.Errorf("Expected trigger of another function to be kept: %v", err)
+	}
+
+	w = do("DELETE", "/v2/environments/nodejs", nil)
+	if w.Code != 200 {
+		t.Errorf("Expected deleting an unused environment to succeed, got %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestForceEnvironmentDelete(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+	api := &API{fissionClient: fc}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v2/environments/{environment}", api.EnvironmentApiDelete).Methods("DELETE")
+
+	_, err := fc.Environments(ns).Create(&crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "nodejs", Namespace: ns},
+		Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating environment: %v", err)
+	}
+	_, err = fc.Functions(ns).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+
+	for _, c := range []struct {
+		url  string
+		code int
+	}{
+		{"/v2/environments/nodejs", 400},
+		{"/v2/environments/nodejs?force=true&dryRun=true", 200},
+		{"/v2/environments/nodejs?force=true", 200},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("DELETE", c.url, nil))
+		if w.Code != c.code {
+			t.Errorf("Expected %v from DELETE %v, got %v %v", c.code, c.url, w.Code, w.Body.String())
+		}
+	}
+	if _, err := fc.Environments(ns).Get("nodejs"); err == nil {
+		t.Errorf("Expected environment to be deleted")
+	}
+}
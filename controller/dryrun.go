@@ -0,0 +1,215 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fission/fission"
+)
+
+// Requests with dryRun=true go through the same validation and
+// conflict checks as real ones, and then respond with the object
+// that would have been stored (or deleted) instead of changing
+// anything. Errors are the ones the real request would get.
+
+// DryRunCreatedHeader lists the environments and packages that earlier
+// dry runs of a client would have created, as comma separated
+// resource/namespace/name, so that objects referring to them are valid
+// in later dry runs of the same changes.
+const DryRunCreatedHeader = "X-Fission-Dry-Run-Created"
+
+func isDryRun(r *http.Request) bool {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	return dryRun
+}
+
+// isForced returns true if a delete request should go ahead even if
+// other objects refer to the object.
+func isForced(r *http.Request) bool {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	return force
+}
+
+func (v *validator) addDryRunCreated(r *http.Request) {
+	for _, created := range strings.Split(r.Header.Get(DryRunCreatedHeader), ",") {
+		parts := strings.Split(strings.TrimSpace(created), "/")
+		if len(parts) != 3 {
+			continue
+		}
+		key := parts[1] + "/" + parts[2]
+		switch parts[0] {
+		case "environments":
+			v.environments[key] = true
+		case "packages":
+			v.packages[key] = true
+		}
+	}
+}
+
+func groupResource(resource string) schema.GroupResource {
+	return schema.GroupResource{Group: "fission.io", Resource: resource}
+}
+
+// getStored gets an object of a resource, such as "functions", and
+// returns it along with its metadata.
+func (a *API) getStored(resource string, namespace string, name string) (interface{}, *metav1.ObjectMeta, error) {
+	fc := a.fissionClient
+	switch resource {
+	case "environments":
+		o, err := fc.Environments(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	case "packages":
+		o, err := fc.Packages(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	case "functions":
+		o, err := fc.Functions(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	case "httptriggers":
+		o, err := fc.HTTPTriggers(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	case "kuberneteswatchtriggers":
+		o, err := fc.KubernetesWatchTriggers(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	case "timetriggers":
+		o, err := fc.TimeTriggers(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	case "messagequeuetriggers":
+		o, err := fc.MessageQueueTriggers(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	case "functionquotas":
+		o, err := fc.FunctionQuotas(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	case "functionrevisions":
+		o, err := fc.FunctionRevisions(namespace).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, &o.Metadata, nil
+	}
+	return nil, nil, fmt.Errorf("unknown resource %v", resource)
+}
+
+func (a *API) respondWithDryRun(w http.ResponseWriter, status int, obj interface{}) {
+	resp, err := json.Marshal(obj)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	// headers can't be set once the status is written
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(resp)
+	if err != nil {
+		log.Printf("Error writing dry run response: %v", err)
+	}
+}
+
+// dryRunCreate responds with a validated object that's about to be
+// created, unless an object with its name already exists.
+func (a *API) dryRunCreate(w http.ResponseWriter, resource string, m *metav1.ObjectMeta, obj interface{}) {
+	_, _, err := a.getStored(resource, namespaceOr(m.Namespace, ""), m.Name)
+	if err == nil {
+		a.respondWithError(w, k8serrors.NewAlreadyExists(groupResource(resource), m.Name))
+		return
+	}
+	if !k8serrors.IsNotFound(err) {
+		a.respondWithError(w, err)
+		return
+	}
+	a.respondWithDryRun(w, http.StatusCreated, obj)
+}
+
+// dryRunUpdate responds with a validated object that's about to be
+// updated, unless it doesn't exist or has changed since the version
+// the update is based on.
+func (a *API) dryRunUpdate(w http.ResponseWriter, resource string, m *metav1.ObjectMeta, obj interface{}) {
+	_, stored, err := a.getStored(resource, namespaceOr(m.Namespace, ""), m.Name)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	if len(m.ResourceVersion) > 0 && m.ResourceVersion != stored.ResourceVersion {
+		a.respondWithError(w, k8serrors.NewConflict(groupResource(resource), m.Name,
+			fmt.Errorf("the object has been modified; apply changes to the latest version")))
+		return
+	}
+	a.respondWithDryRun(w, http.StatusOK, obj)
+}
+
+// dryRunDelete responds with an object that's about to be deleted.
+func (a *API) dryRunDelete(w http.ResponseWriter, resource string, namespace string, name string) {
+	obj, _, err := a.getStored(resource, namespace, name)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	a.respondWithDryRun(w, http.StatusOK, obj)
+}
+
+// checkPackageUnused returns an error if functions use a package.
+func (a *API) checkPackageUnused(namespace string, name string) error {
+	fns, err := a.fissionClient.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	users := make([]string, 0)
+	for _, fn := range fns.Items {
+		ref := fn.Spec.Package.PackageRef
+		if ref.Name == name && namespaceOr(ref.Namespace, fn.Metadata.Namespace) == namespace {
+			users = append(users, fn.Metadata.Name)
+		}
+	}
+	if len(users) > 0 {
+		return fission.MakeError(fission.ErrorInvalidArgument,
+			fmt.Sprintf("Package %v is used by functions %v; delete them first, or force the deletion", name, users))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+)
+
+func TestDryRun(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+	api := &API{fissionClient: fc}
+
+	_, err := fc.Environments(ns).Create(&crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "nodejs", Namespace: ns},
+		Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating environment: %v", err)
+	}
+	_, err = fc.Packages(ns).Create(&crd.Package{
+		Metadata: metav1.ObjectMeta{Name: "hello-pkg", Namespace: ns},
+		Spec: fission.PackageSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+			Deployment:  fission.Archive{Type: fission.ArchiveTypeUrl, URL: "http://example.com/hello.zip"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating package: %v", err)
+	}
+	fn, err := fc.Functions(ns).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+			Package: fission.FunctionPackageRef{
+				PackageRef: fission.PackageRef{Name: "hello-pkg", Namespace: ns},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v2/environments", api.EnvironmentApiCreate).Methods("POST")
+	r.HandleFunc("/v2/packages", api.PackageApiCreate).Methods("POST")
+	r.HandleFunc("/v2/packages/{package}", api.PackageApiDelete).Methods("DELETE")
+	r.HandleFunc("/v2/functions/{function}", api.FunctionApiUpdate).Methods("PUT")
+
+	do := func(method string, url string, obj interface{}, header http.Header) *httptest.ResponseRecorder {
+		var body []byte
+		if obj != nil {
+			body, err = json.Marshal(obj)
+			if err != nil {
+				t.Fatalf("Error encoding request: %v", err)
+			}
+		}
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectStatus := func(what string, w *httptest.ResponseRecorder, status int) {
+		if w.Code != status {
+			t.Errorf("Expected status %v for %v, got %v: %v", status, what, w.Code, w.Body.String())
+		}
+	}
+
+	// creates respond with the object, and don't store it
+	env := &crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "python", Namespace: ns},
+		Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/python-env"}},
+	}
+	w := do("POST", "/v2/environments?dryRun=true", env, nil)
+	expectStatus("dry run create", w, http.StatusCreated)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected dry run create to respond with JSON, got content type %q", ct)
+	}
+	var got crd.Environment
+	err = json.Unmarshal(w.Body.Bytes(), &got)
+	if err != nil || got.Spec.Runtime.Image != env.Spec.Runtime.Image {
+		t.Errorf("Expected dry run create to respond with the environment, got %v", w.Body.String())
+	}
+	if _, err := fc.Environments(ns).Get("python"); err == nil {
+		t.Errorf("Expected dry run not to create the environment")
+	}
+
+	env.Metadata.Name = "nodejs"
+	w = do("POST", "/v2/environments?dryRun=true", env, nil)
+	expectStatus("dry run create of an existing name", w, http.StatusConflict)
+
+	// objects may refer to the ones earlier dry runs would create
+	pkg := &crd.Package{
+		Metadata: metav1.ObjectMeta{Name: "hello-py", Namespace: ns},
+		Spec: fission.PackageSpec{
+			Environment: fission.EnvironmentReference{Name: "python", Namespace: ns},
+			Deployment:  fission.Archive{Type: fission.ArchiveTypeUrl, URL: "http://example.com/hello.zip"},
+		},
+	}
+	w = do("POST", "/v2/packages?dryRun=true", pkg, nil)
+	expectStatus("dry run create of an invalid package", w, http.StatusBadRequest)
+	w = do("POST", "/v2/packages?dryRun=true", pkg, http.Header{DryRunCreatedHeader: {"environments/default/python"}})
+	expectStatus("dry run create after a dry run create", w, http.StatusCreated)
+
+	// updates of stale versions conflict
+	stale := *fn
+	stale.Metadata.ResourceVersion = "stale"
+	w = do("PUT", "/v2/functions/hello?dryRun=true", &stale, nil)
+	expectStatus("dry run update of a stale version", w, http.StatusConflict)
+	w = do("PUT", "/v2/functions/hello?dryRun=true", fn, nil)
+	expectStatus("dry run update", w, http.StatusOK)
+
+	// packages in use are only deleted by force
+	w = do("DELETE", "/v2/packages/hello-pkg?dryRun=true", nil, nil)
+	expectStatus("dry run delete of a package in use", w, http.StatusBadRequest)
+	w = do("DELETE", "/v2/packages/hello-pkg?dryRun=true&force=true", nil, nil)
+	expectStatus("forced dry run delete", w, http.StatusOK)
+	if _, err := fc.Packages(ns).Get("hello-pkg"); err != nil {
+		t.Errorf("Expected dry run not to delete the package: %v", err)
+	}
+}
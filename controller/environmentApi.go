@@ -30,19 +30,29 @@ import (
 )
 
 func (a *API) EnvironmentApiList(w http.ResponseWriter, r *http.Request) {
-	envs, err := a.fissionClient.Environments(metav1.NamespaceAll).List(metav1.ListOptions{})
+	opts, err := parseListOptions(r)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-
-	resp, err := json.Marshal(envs.Items)
+	if wantsWatch(r) {
+		wi, err := a.fissionClient.Environments(opts.namespace).Watch(watchOptions(r, opts))
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		a.respondWithWatch(w, r, wi)
+		return
+	}
+	envs, err := a.fissionClient.Environments(opts.namespace).List(opts.ListOptions)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
-	a.respondWithSuccess(w, resp)
+	items := envs.Items
+	page, next := opts.page(items, func(i int) *metav1.ObjectMeta { return &items[i].Metadata })
+	a.respondWithList(w, page, next)
 }
 
 func (a *API) EnvironmentApiCreate(w http.ResponseWriter, r *http.Request) {
@@ -60,12 +70,23 @@ func (a *API) EnvironmentApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = validateResourceName(env.Metadata.Name)
+	err = objectNamespace(r, &env.Metadata)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
+	err = a.validate(r, &env)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	if isDryRun(r) {
+		a.dryRunCreate(w, "environments", &env.Metadata, &env)
+		return
+	}
+
 	enew, err := a.fissionClient.Environments(env.Metadata.Namespace).Create(&env)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -85,10 +106,7 @@ func (a *API) EnvironmentApiCreate(w http.ResponseWriter, r *http.Request) {
 func (a *API) EnvironmentApiGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["environment"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
-	}
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
 
 	env, err := a.fissionClient.Environments(ns).Get(name)
 	if err != nil {
@@ -128,6 +146,23 @@ func (a *API) EnvironmentApiUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = objectNamespace(r, &env.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &env)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	if isDryRun(r) {
+		a.dryRunUpdate(w, "environments", &env.Metadata, &env)
+		return
+	}
+
 	enew, err := a.fissionClient.Environments(env.Metadata.Namespace).Update(&env)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -146,9 +181,19 @@ func (a *API) EnvironmentApiUpdate(w http.ResponseWriter, r *http.Request) {
 func (a *API) EnvironmentApiDelete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["environment"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	if !isForced(r) {
+		err := a.checkEnvironmentUnused(ns, name)
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+	}
+
+	if isDryRun(r) {
+		a.dryRunDelete(w, "environments", ns, name)
+		return
 	}
 
 	err := a.fissionClient.Environments(ns).Delete(name, &metav1.DeleteOptions{})
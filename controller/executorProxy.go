@@ -0,0 +1,50 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/gorilla/mux"
+)
+
+// ExecutorProxy forwards read-only requests to the executor's
+// introspection API, which isn't reachable from outside the cluster.
+func (api *API) ExecutorProxy(w http.ResponseWriter, r *http.Request) {
+	u := api.executorUrl
+	executorUrl, err := url.Parse(u)
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing url %v: %v", u, err)
+		http.Error(w, msg, 500)
+		return
+	}
+
+	vars := mux.Vars(r)
+	path := fmt.Sprintf("/%s", vars["path"])
+	director := func(req *http.Request) {
+		req.URL.Scheme = executorUrl.Scheme
+		req.URL.Host = executorUrl.Host
+		req.URL.Path = path
+	}
+	proxy := &httputil.ReverseProxy{
+		Director: director,
+	}
+	proxy.ServeHTTP(w, r)
+}
@@ -29,7 +29,7 @@ import (
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/pkg/api"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/pkg/api/v1"
 	restclient "k8s.io/client-go/rest"
 
@@ -38,19 +38,29 @@ import (
 )
 
 func (a *API) FunctionApiList(w http.ResponseWriter, r *http.Request) {
-	funcs, err := a.fissionClient.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	opts, err := parseListOptions(r)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-
-	resp, err := json.Marshal(funcs.Items)
+	if wantsWatch(r) {
+		wi, err := a.fissionClient.Functions(opts.namespace).Watch(watchOptions(r, opts))
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		a.respondWithWatch(w, r, wi)
+		return
+	}
+	funcs, err := a.fissionClient.Functions(opts.namespace).List(opts.ListOptions)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
-	a.respondWithSuccess(w, resp)
+	items := funcs.Items
+	page, next := opts.page(items, func(i int) *metav1.ObjectMeta { return &items[i].Metadata })
+	a.respondWithList(w, page, next)
 }
 
 func (a *API) FunctionApiCreate(w http.ResponseWriter, r *http.Request) {
@@ -67,18 +77,45 @@ func (a *API) FunctionApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = validateResourceName(f.Metadata.Name)
+	err = objectNamespace(r, &f.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &f)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
+	if isDryRun(r) {
+		a.dryRunCreate(w, "functions", &f.Metadata, &f)
+		return
+	}
+
 	fnew, err := a.fissionClient.Functions(f.Metadata.Namespace).Create(&f)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
+	// Without an owner, the package is just left behind when the function
+	// is deleted, so this is only logged too.
+	owned, err := a.ownPackage(fnew)
+	if err != nil {
+		log.Errorf("Error setting the owner of the package of function %v: %v", fnew.Metadata.Name, err)
+	} else {
+		fnew = owned
+	}
+
+	// The function is there either way, so a missing revision is
+	// only logged.
+	_, err = recordRevision(a.fissionClient, fnew, a.revisions, 0)
+	if err != nil {
+		log.Errorf("Error recording revision of function %v: %v", fnew.Metadata.Name, err)
+	}
+
 	resp, err := json.Marshal(fnew.Metadata)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -92,10 +129,7 @@ func (a *API) FunctionApiCreate(w http.ResponseWriter, r *http.Request) {
 func (a *API) FunctionApiGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["function"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
-	}
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
 
 	f, err := a.fissionClient.Functions(ns).Get(name)
 	if err != nil {
@@ -134,12 +168,34 @@ func (a *API) FunctionApiUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = objectNamespace(r, &f.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &f)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	if isDryRun(r) {
+		a.dryRunUpdate(w, "functions", &f.Metadata, &f)
+		return
+	}
+
 	fnew, err := a.fissionClient.Functions(f.Metadata.Namespace).Update(&f)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
+	_, err = recordRevision(a.fissionClient, fnew, a.revisions, 0)
+	if err != nil {
+		log.Errorf("Error recording revision of function %v: %v", fnew.Metadata.Name, err)
+	}
+
 	resp, err := json.Marshal(fnew.Metadata)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -151,17 +207,31 @@ func (a *API) FunctionApiUpdate(w http.ResponseWriter, r *http.Request) {
 func (a *API) FunctionApiDelete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["function"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	if isDryRun(r) {
+		a.dryRunDelete(w, "functions", ns, name)
+		return
 	}
 
-	err := a.fissionClient.Functions(ns).Delete(name, &metav1.DeleteOptions{})
+	var err error
+	if isCascade(r) {
+		err = a.deleteFunctionCascade(r, ns, name)
+	} else {
+		// keep the packages the function owns
+		orphan := metav1.DeletePropagationOrphan
+		err = a.fissionClient.Functions(ns).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &orphan})
+	}
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
+	err = deleteRevisions(a.fissionClient, ns, name)
+	if err != nil {
+		log.Errorf("Error deleting revisions of function %v: %v", name, err)
+	}
+
 	a.respondWithSuccess(w, []byte(""))
 }
 
@@ -199,13 +269,10 @@ func (a *API) FunctionLogsApiPost(w http.ResponseWriter, r *http.Request) {
 func (a *API) FunctionPodLogs(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	fnName := vars["function"]
-	ns := vars["namespace"]
+	// function pods all run in the fission-function namespace
+	ns := "fission-function"
 
-	if len(ns) == 0 {
-		ns = "fission-function"
-	}
-
-	f, err := a.fissionClient.Functions(api.NamespaceDefault).Get(fnName)
+	f, err := a.fissionClient.Functions(namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)).Get(fnName)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
@@ -256,3 +323,33 @@ func (a *API) FunctionPodLogs(w http.ResponseWriter, r *http.Request) {
 	}
 	return
 }
+
+// FunctionEventsApiGet returns the Kubernetes events recorded for a
+// function by the executor, oldest first.
+func (a *API) FunctionEventsApiGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["function"]
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	selector := fields.Set{
+		"involvedObject.kind": "Function",
+		"involvedObject.name": name,
+	}.AsSelector().String()
+	events, err := a.kubernetesClient.CoreV1().Events(ns).List(metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LastTimestamp.Time.Before(items[j].LastTimestamp.Time)
+	})
+
+	resp, err := json.Marshal(items)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	a.respondWithSuccess(w, resp)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+)
+
+// revisionVars returns the function namespace and name of a revision
+// request, and the revision number if the URL has one.
+func revisionVars(r *http.Request) (string, string, int, error) {
+	vars := mux.Vars(r)
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	revision := 0
+	if rv, ok := vars["revision"]; ok {
+		var err error
+		revision, err = strconv.Atoi(rv)
+		if err != nil || revision < 1 {
+			return "", "", 0, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Invalid revision '%v'", rv))
+		}
+	}
+	return ns, vars["function"], revision, nil
+}
+
+func (a *API) FunctionRevisionApiList(w http.ResponseWriter, r *http.Request) {
+	ns, fnName, _, err := revisionVars(r)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	revs, err := listRevisions(a.fissionClient, ns, fnName)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	resp, err := json.Marshal(revs)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	a.respondWithSuccess(w, resp)
+}
+
+func (a *API) FunctionRevisionApiGet(w http.ResponseWriter, r *http.Request) {
+	ns, fnName, revision, err := revisionVars(r)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	rev, err := a.fissionClient.FunctionRevisions(ns).Get(revisionName(fnName, revision))
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	resp, err := json.Marshal(rev)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	a.respondWithSuccess(w, resp)
+}
+
+// FunctionRevisionApiRollback rolls a function back to a revision, and
+// responds with the function's new metadata, or the whole function for
+// dry runs.
+func (a *API) FunctionRevisionApiRollback(w http.ResponseWriter, r *http.Request) {
+	ns, fnName, revision, err := revisionVars(r)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	if isDryRun(r) {
+		// the function as it would be after the rollback; its
+		// package would be restored too
+		rev, err := getRevision(a.fissionClient, ns, fnName, revision)
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		fn, err := a.fissionClient.Functions(ns).Get(fnName)
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		fn.Spec = rev.Spec.Function
+		a.respondWithDryRun(w, http.StatusOK, fn)
+		return
+	}
+
+	fn, err := rollback(a.fissionClient, ns, fnName, revision, a.revisions)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	resp, err := json.Marshal(fn.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	a.respondWithSuccess(w, resp)
+}
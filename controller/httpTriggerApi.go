@@ -30,19 +30,29 @@ import (
 )
 
 func (a *API) HTTPTriggerApiList(w http.ResponseWriter, r *http.Request) {
-	triggers, err := a.fissionClient.HTTPTriggers(metav1.NamespaceAll).List(metav1.ListOptions{})
+	opts, err := parseListOptions(r)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-
-	resp, err := json.Marshal(triggers.Items)
+	if wantsWatch(r) {
+		wi, err := a.fissionClient.HTTPTriggers(opts.namespace).Watch(watchOptions(r, opts))
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		a.respondWithWatch(w, r, wi)
+		return
+	}
+	triggers, err := a.fissionClient.HTTPTriggers(opts.namespace).List(opts.ListOptions)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
-	a.respondWithSuccess(w, resp)
+	items := triggers.Items
+	page, next := opts.page(items, func(i int) *metav1.ObjectMeta { return &items[i].Metadata })
+	a.respondWithList(w, page, next)
 }
 
 func (a *API) checkHTTPTriggerDuplicates(t *crd.HTTPTrigger) error {
@@ -74,7 +84,13 @@ func (a *API) HTTPTriggerApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = validateResourceName(t.Metadata.Name)
+	err = objectNamespace(r, &t.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &t)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
@@ -87,6 +103,11 @@ func (a *API) HTTPTriggerApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		a.dryRunCreate(w, "httptriggers", &t.Metadata, &t)
+		return
+	}
+
 	tnew, err := a.fissionClient.HTTPTriggers(t.Metadata.Namespace).Create(&t)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -106,10 +127,7 @@ func (a *API) HTTPTriggerApiCreate(w http.ResponseWriter, r *http.Request) {
 func (a *API) HTTPTriggerApiGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["httpTrigger"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
-	}
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
 
 	t, err := a.fissionClient.HTTPTriggers(ns).Get(name)
 	if err != nil {
@@ -149,12 +167,29 @@ func (a *API) HTTPTriggerApiUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = objectNamespace(r, &t.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &t)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
 	err = a.checkHTTPTriggerDuplicates(&t)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
+	if isDryRun(r) {
+		a.dryRunUpdate(w, "httptriggers", &t.Metadata, &t)
+		return
+	}
+
 	tnew, err := a.fissionClient.HTTPTriggers(t.Metadata.Namespace).Update(&t)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -172,9 +207,11 @@ func (a *API) HTTPTriggerApiUpdate(w http.ResponseWriter, r *http.Request) {
 func (a *API) HTTPTriggerApiDelete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["httpTrigger"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	if isDryRun(r) {
+		a.dryRunDelete(w, "httptriggers", ns, name)
+		return
 	}
 
 	err := a.fissionClient.HTTPTriggers(ns).Delete(name, &metav1.DeleteOptions{})
@@ -0,0 +1,125 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+)
+
+// ContinueHeader is set on list responses that stop at the limit of
+// the request. Its value is passed as the continue parameter to get
+// the next page. It's a header rather than part of the body, so that
+// list responses are still plain arrays of objects.
+const ContinueHeader = "X-Fission-Continue"
+
+// listOptions are the query parameters of list requests:
+//
+//	namespace      only list objects in this namespace (default: all)
+//	labelSelector  only list objects with matching labels
+//	fieldSelector  only list objects with matching fields
+//	               (metadata.name and metadata.namespace for CRDs)
+//	limit          return at most this many objects
+//	continue       continue a list from where the last page ended
+//
+// Selectors are passed on to the CRD client. The API server doesn't
+// paginate CRDs, so the controller does that itself.
+type listOptions struct {
+	metav1.ListOptions
+	namespace string
+	limit     int
+	after     string // namespace/name of the last object of the previous page
+}
+
+func parseListOptions(r *http.Request) (*listOptions, error) {
+	q := r.URL.Query()
+	opts := &listOptions{
+		ListOptions: metav1.ListOptions{
+			LabelSelector: q.Get("labelSelector"),
+			FieldSelector: q.Get("fieldSelector"),
+		},
+		namespace: q.Get("namespace"),
+	}
+
+	if l := q.Get("limit"); len(l) > 0 {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			return nil, fission.MakeError(fission.ErrorInvalidArgument, fmt.Sprintf("Invalid limit '%v'", l))
+		}
+		opts.limit = limit
+	}
+
+	if c := q.Get("continue"); len(c) > 0 {
+		after, err := base64.RawURLEncoding.DecodeString(c)
+		if err != nil {
+			return nil, fission.MakeError(fission.ErrorInvalidArgument, "Invalid continue token")
+		}
+		opts.after = string(after)
+	}
+	return opts, nil
+}
+
+// page sorts items, a slice of Fission objects, by namespace and name,
+// and returns the page of them that was asked for, along with the
+// continue token for the next page, if there is one. meta returns the
+// metadata of the i-th item.
+func (opts *listOptions) page(items interface{}, meta func(i int) *metav1.ObjectMeta) (interface{}, string) {
+	key := func(i int) string {
+		m := meta(i)
+		return m.Namespace + "/" + m.Name
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return key(i) < key(j)
+	})
+
+	v := reflect.ValueOf(items)
+	start := 0
+	if len(opts.after) > 0 {
+		start = sort.Search(v.Len(), func(i int) bool {
+			return key(i) > opts.after
+		})
+	}
+	end := v.Len()
+	next := ""
+	if opts.limit > 0 && start+opts.limit < end {
+		end = start + opts.limit
+		next = base64.RawURLEncoding.EncodeToString([]byte(key(end - 1)))
+	}
+	return v.Slice(start, end).Interface(), next
+}
+
+// respondWithList responds with a page of a list, as returned by page.
+func (a *API) respondWithList(w http.ResponseWriter, items interface{}, next string) {
+	resp, err := json.Marshal(items)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+	if len(next) > 0 {
+		w.Header().Set(ContinueHeader, next)
+	}
+	a.respondWithSuccess(w, resp)
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission/crd"
+)
+
+func TestListPage(t *testing.T) {
+	fns := []crd.Function{
+		{Metadata: metav1.ObjectMeta{Namespace: "default", Name: "c"}},
+		{Metadata: metav1.ObjectMeta{Namespace: "default", Name: "a"}},
+		{Metadata: metav1.ObjectMeta{Namespace: "other", Name: "a"}},
+		{Metadata: metav1.ObjectMeta{Namespace: "default", Name: "b"}},
+		{Metadata: metav1.ObjectMeta{Namespace: "default", Name: "d"}},
+	}
+	expected := []string{"default/a", "default/b", "default/c", "default/d", "other/a"}
+
+	var listed []string
+	next := ""
+	for pages := 0; pages == 0 || len(next) > 0; pages++ {
+		if pages > len(fns) {
+			t.Fatalf("Too many pages")
+		}
+		r, err := http.NewRequest("GET", "/v2/functions?limit=2&continue="+next, nil)
+		if err != nil {
+			t.Fatalf("Error making request: %v", err)
+		}
+		opts, err := parseListOptions(r)
+		if err != nil {
+			t.Fatalf("Error parsing list options: %v", err)
+		}
+
+		var page interface{}
+		page, next = opts.page(fns, func(i int) *metav1.ObjectMeta { return &fns[i].Metadata })
+		items := page.([]crd.Function)
+		if len(items) > 2 {
+			t.Errorf("Expected at most 2 functions per page, got %v", len(items))
+		}
+		for _, fn := range items {
+			listed = append(listed, fn.Metadata.Namespace+"/"+fn.Metadata.Name)
+		}
+	}
+
+	if len(listed) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, listed)
+	}
+	for i := range expected {
+		if listed[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, listed)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/v2/functions?limit=-1", nil)
+	_, err := parseListOptions(r)
+	if err == nil {
+		t.Errorf("Expected negative limit to be refused")
+	}
+}
@@ -30,17 +30,29 @@ import (
 
 func (a *API) MessageQueueTriggerApiList(w http.ResponseWriter, r *http.Request) {
 	//mqType := r.FormValue("mqtype") // ignored for now
-	triggers, err := a.fissionClient.MessageQueueTriggers(metav1.NamespaceAll).List(metav1.ListOptions{})
+	opts, err := parseListOptions(r)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-	resp, err := json.Marshal(triggers.Items)
+	if wantsWatch(r) {
+		wi, err := a.fissionClient.MessageQueueTriggers(opts.namespace).Watch(watchOptions(r, opts))
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		a.respondWithWatch(w, r, wi)
+		return
+	}
+	triggers, err := a.fissionClient.MessageQueueTriggers(opts.namespace).List(opts.ListOptions)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-	a.respondWithSuccess(w, resp)
+
+	items := triggers.Items
+	page, next := opts.page(items, func(i int) *metav1.ObjectMeta { return &items[i].Metadata })
+	a.respondWithList(w, page, next)
 }
 
 func (a *API) MessageQueueTriggerApiCreate(w http.ResponseWriter, r *http.Request) {
@@ -57,12 +69,23 @@ func (a *API) MessageQueueTriggerApiCreate(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = validateResourceName(mqTrigger.Metadata.Name)
+	err = objectNamespace(r, &mqTrigger.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &mqTrigger)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
+	if isDryRun(r) {
+		a.dryRunCreate(w, "messagequeuetriggers", &mqTrigger.Metadata, &mqTrigger)
+		return
+	}
+
 	tnew, err := a.fissionClient.MessageQueueTriggers(mqTrigger.Metadata.Namespace).Create(&mqTrigger)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -81,10 +104,7 @@ func (a *API) MessageQueueTriggerApiCreate(w http.ResponseWriter, r *http.Reques
 func (a *API) MessageQueueTriggerApiGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["mqTrigger"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
-	}
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
 
 	mqTrigger, err := a.fissionClient.MessageQueueTriggers(ns).Get(name)
 	if err != nil {
@@ -122,6 +142,23 @@ func (a *API) MessageQueueTriggerApiUpdate(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	err = objectNamespace(r, &mqTrigger.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &mqTrigger)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	if isDryRun(r) {
+		a.dryRunUpdate(w, "messagequeuetriggers", &mqTrigger.Metadata, &mqTrigger)
+		return
+	}
+
 	tnew, err := a.fissionClient.MessageQueueTriggers(mqTrigger.Metadata.Namespace).Update(&mqTrigger)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -139,9 +176,11 @@ func (a *API) MessageQueueTriggerApiUpdate(w http.ResponseWriter, r *http.Reques
 func (a *API) MessageQueueTriggerApiDelete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["mqTrigger"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	if isDryRun(r) {
+		a.dryRunDelete(w, "messagequeuetriggers", ns, name)
+		return
 	}
 
 	err := a.fissionClient.MessageQueueTriggers(ns).Delete(name, &metav1.DeleteOptions{})
@@ -29,19 +29,29 @@ import (
 )
 
 func (a *API) PackageApiList(w http.ResponseWriter, r *http.Request) {
-	funcs, err := a.fissionClient.Packages(metav1.NamespaceAll).List(metav1.ListOptions{})
+	opts, err := parseListOptions(r)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-
-	resp, err := json.Marshal(funcs.Items)
+	if wantsWatch(r) {
+		wi, err := a.fissionClient.Packages(opts.namespace).Watch(watchOptions(r, opts))
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		a.respondWithWatch(w, r, wi)
+		return
+	}
+	funcs, err := a.fissionClient.Packages(opts.namespace).List(opts.ListOptions)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
-	a.respondWithSuccess(w, resp)
+	items := funcs.Items
+	page, next := opts.page(items, func(i int) *metav1.ObjectMeta { return &items[i].Metadata })
+	a.respondWithList(w, page, next)
 }
 
 func (a *API) PackageApiCreate(w http.ResponseWriter, r *http.Request) {
@@ -58,21 +68,20 @@ func (a *API) PackageApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = validateResourceName(f.Metadata.Name)
+	err = objectNamespace(r, &f.Metadata)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
-	// Ensure size limits
-	if len(f.Spec.Source.Literal) > 256*1024 {
-		err := fission.MakeError(fission.ErrorInvalidArgument, "Package literal larger than 256K")
+	err = a.validate(r, &f)
+	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-	if len(f.Spec.Deployment.Literal) > 256*1024 {
-		err := fission.MakeError(fission.ErrorInvalidArgument, "Package literal larger than 256K")
-		a.respondWithError(w, err)
+
+	if isDryRun(r) {
+		a.dryRunCreate(w, "packages", &f.Metadata, &f)
 		return
 	}
 
@@ -95,10 +104,7 @@ func (a *API) PackageApiCreate(w http.ResponseWriter, r *http.Request) {
 func (a *API) PackageApiGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["package"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
-	}
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
 	raw := r.FormValue("raw") // just the deployment pkg
 
 	f, err := a.fissionClient.Packages(ns).Get(name)
@@ -143,6 +149,23 @@ func (a *API) PackageApiUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = objectNamespace(r, &f.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &f)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	if isDryRun(r) {
+		a.dryRunUpdate(w, "packages", &f.Metadata, &f)
+		return
+	}
+
 	fnew, err := a.fissionClient.Packages(f.Metadata.Namespace).Update(&f)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -160,9 +183,19 @@ func (a *API) PackageApiUpdate(w http.ResponseWriter, r *http.Request) {
 func (a *API) PackageApiDelete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["package"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	if !isForced(r) {
+		err := a.checkPackageUnused(ns, name)
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+	}
+
+	if isDryRun(r) {
+		a.dryRunDelete(w, "packages", ns, name)
+		return
 	}
 
 	err := a.fissionClient.Packages(ns).Delete(name, &metav1.DeleteOptions{})
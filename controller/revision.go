@@ -0,0 +1,338 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+// Functions keep this many revisions unless FUNCTION_REVISION_LIMIT
+// says otherwise.
+const defaultRevisionLimit = 10
+
+// maxRevisionAttempts is how many revision numbers recordRevision
+// tries when concurrent updates of a function take the ones it picked.
+const maxRevisionAttempts = 5
+
+// revisionPolicy is how much history of functions is kept.
+type revisionPolicy struct {
+	// limit is the number of revisions kept of each function; zero
+	// keeps them all.
+	limit int
+	// prunePackages deletes the packages of pruned revisions that
+	// nothing else uses. Off unless PRUNE_REVISION_PACKAGES is set.
+	prunePackages bool
+}
+
+// revisionName returns the name of a function revision object.
+func revisionName(fnName string, revision int) string {
+	return fmt.Sprintf("%v-%v", fnName, revision)
+}
+
+// listRevisions returns the revisions of a function, oldest first.
+func listRevisions(fc crd.FissionClientInterface, namespace string, fnName string) ([]crd.FunctionRevision, error) {
+	l, err := fc.FunctionRevisions(namespace).List(metav1.ListOptions{
+		LabelSelector: labels.Set{"functionName": fnName}.AsSelector().String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	revs := l.Items
+	sort.Slice(revs, func(i, j int) bool {
+		return revs[i].Spec.Revision < revs[j].Spec.Revision
+	})
+	return revs, nil
+}
+
+// sameJSON returns true if a and b encode to the same JSON. Specs that
+// have been through the API server can have empty fields where the
+// originals had nil ones, which DeepEqual would count as changes.
+func sameJSON(a interface{}, b interface{}) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ja) == string(jb)
+}
+
+// recordRevision records fn as its next revision, along with its
+// package, and then prunes revisions beyond the policy's limit.
+// Nothing is recorded if neither has changed since the last revision.
+// rollbackOf is the revision a rollback restored, or zero.
+func recordRevision(fc crd.FissionClientInterface, fn *crd.Function, policy revisionPolicy, rollbackOf int) (*crd.FunctionRevision, error) {
+	ns := fn.Metadata.Namespace
+	if len(ns) == 0 {
+		ns = metav1.NamespaceDefault
+	}
+
+	spec := fission.FunctionRevisionSpec{
+		FunctionName: fn.Metadata.Name,
+		Function:     fn.Spec,
+		RollbackOf:   rollbackOf,
+	}
+	ref := fn.Spec.Package.PackageRef
+	if len(ref.Name) > 0 {
+		pkgNs := ref.Namespace
+		if len(pkgNs) == 0 {
+			pkgNs = ns
+		}
+		pkg, err := fc.Packages(pkgNs).Get(ref.Name)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		// A function may be created before its package; its next
+		// revision will have the package.
+		if err == nil {
+			spec.Package = pkg.Spec
+			spec.PackageStatus = pkg.Status
+		}
+	}
+
+	// Concurrent updates of the function may take the revision
+	// number picked here first; the next one is tried then.
+	var rev *crd.FunctionRevision
+	var revs []crd.FunctionRevision
+	var err error
+	for attempt := 1; ; attempt++ {
+		revs, err = listRevisions(fc, ns, fn.Metadata.Name)
+		if err != nil {
+			return nil, err
+		}
+		next := 1
+		if len(revs) > 0 {
+			last := revs[len(revs)-1]
+			if rollbackOf == 0 && sameJSON(last.Spec.Function, spec.Function) && sameJSON(last.Spec.Package, spec.Package) {
+				return &last, nil
+			}
+			next = last.Spec.Revision + 1
+		}
+		if next <= spec.Revision {
+			// the list doesn't have the revision that was taken yet
+			next = spec.Revision + 1
+		}
+		spec.Revision = next
+
+		rev, err = fc.FunctionRevisions(ns).Create(&crd.FunctionRevision{
+			Metadata: metav1.ObjectMeta{
+				Name:      revisionName(fn.Metadata.Name, spec.Revision),
+				Namespace: ns,
+				Labels: map[string]string{
+					"functionName": fn.Metadata.Name,
+				},
+			},
+			Spec: spec,
+		})
+		if k8serrors.IsAlreadyExists(err) && attempt < maxRevisionAttempts {
+			log.Infof("Revision %v of function %v was taken by a concurrent update, trying the next one",
+				spec.Revision, fn.Metadata.Name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	revs = append(revs, *rev)
+	if policy.limit > 0 && len(revs) > policy.limit {
+		err = pruneRevisions(fc, revs[:len(revs)-policy.limit], policy.prunePackages)
+		if err != nil {
+			// the revision is recorded; pruning is tried again
+			// next time
+			log.Errorf("Error pruning revisions of function %v: %v", fn.Metadata.Name, err)
+		}
+	}
+	return rev, nil
+}
+
+// pruneRevisions deletes revisions. With prunePackages, it then deletes
+// the packages they referenced that neither a function nor any other
+// revision still references. Those are packages orphaned by function
+// updates; the packages of revisions that are kept are never deleted,
+// so that the function can be rolled back to them.
+func pruneRevisions(fc crd.FissionClientInterface, revs []crd.FunctionRevision, prunePackages bool) error {
+	candidates := make(map[string]fission.PackageRef)
+	for _, rev := range revs {
+		log.Infof("Deleting revision %v of function %v/%v", rev.Spec.Revision, rev.Metadata.Namespace, rev.Spec.FunctionName)
+		err := fc.FunctionRevisions(rev.Metadata.Namespace).Delete(rev.Metadata.Name, &metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		ref := rev.Spec.Function.Package.PackageRef
+		if len(ref.Name) == 0 {
+			continue
+		}
+		if len(ref.Namespace) == 0 {
+			ref.Namespace = rev.Metadata.Namespace
+		}
+		candidates[ref.Namespace+"/"+ref.Name] = ref
+	}
+	if !prunePackages || len(candidates) == 0 {
+		return nil
+	}
+
+	refKey := func(ref fission.PackageRef, ns string) string {
+		if len(ref.Namespace) > 0 {
+			ns = ref.Namespace
+		}
+		return ns + "/" + ref.Name
+	}
+	fns, err := fc.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, fn := range fns.Items {
+		delete(candidates, refKey(fn.Spec.Package.PackageRef, fn.Metadata.Namespace))
+	}
+	remaining, err := fc.FunctionRevisions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, rev := range remaining.Items {
+		delete(candidates, refKey(rev.Spec.Function.Package.PackageRef, rev.Metadata.Namespace))
+	}
+
+	for _, ref := range candidates {
+		log.Infof("Deleting package %v/%v, which is no longer used by any function or revision", ref.Namespace, ref.Name)
+		err = fc.Packages(ref.Namespace).Delete(ref.Name, &metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRevisions deletes the history of a function that's been
+// deleted. Its packages are left alone, like the function's current
+// package.
+func deleteRevisions(fc crd.FissionClientInterface, namespace string, fnName string) error {
+	revs, err := listRevisions(fc, namespace, fnName)
+	if err != nil {
+		return err
+	}
+	for _, rev := range revs {
+		err = fc.FunctionRevisions(namespace).Delete(rev.Metadata.Name, &metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// getRevision gets a revision of a function.
+func getRevision(fc crd.FissionClientInterface, namespace string, fnName string, revision int) (*crd.FunctionRevision, error) {
+	rev, err := fc.FunctionRevisions(namespace).Get(revisionName(fnName, revision))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, fission.MakeError(fission.ErrorNotFound,
+				fmt.Sprintf("Function %v has no revision %v", fnName, revision))
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+// rollbackPackageName returns the name of a package made from the
+// package snapshot of a function revision.
+func rollbackPackageName(fnName string, revision int) string {
+	return fmt.Sprintf("%v-%v-%v", fnName, revision, strings.ToLower(uuid.NewV4().String()[:8]))
+}
+
+// rollback restores a function and its package to a revision, and
+// records that as a new revision, so that history is never rewritten.
+// Packages may be shared by functions, so one that has changed since
+// the revision is left alone; the function gets a new package made
+// from the revision's snapshot instead.
+func rollback(fc crd.FissionClientInterface, namespace string, fnName string, revision int, policy revisionPolicy) (*crd.Function, error) {
+	rev, err := getRevision(fc, namespace, fnName, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, err := fc.Functions(namespace).Get(fnName)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := rev.Spec.Function.Package.PackageRef
+	if len(ref.Name) > 0 {
+		pkgNs := ref.Namespace
+		if len(pkgNs) == 0 {
+			pkgNs = namespace
+		}
+
+		// Builds that hadn't finished when the revision was recorded
+		// are started over.
+		status := rev.Spec.PackageStatus
+		if status.BuildStatus == fission.BuildStatusRunning {
+			status = fission.PackageStatus{BuildStatus: fission.BuildStatusPending}
+		}
+
+		pkg, err := fc.Packages(pkgNs).Get(ref.Name)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err != nil || !sameJSON(pkg.Spec, rev.Spec.Package) {
+			name := ref.Name
+			if err == nil {
+				name = rollbackPackageName(fnName, revision)
+			}
+			pkg, err = fc.Packages(pkgNs).Create(&crd.Package{
+				Metadata: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: pkgNs,
+				},
+				Spec:   rev.Spec.Package,
+				Status: status,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		ref.Name = pkg.Metadata.Name
+		ref.ResourceVersion = pkg.Metadata.ResourceVersion
+	}
+
+	fn.Spec = rev.Spec.Function
+	fn.Spec.Package.PackageRef = ref
+	fnew, err := fc.Functions(namespace).Update(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = recordRevision(fc, fnew, policy, revision)
+	if err != nil {
+		log.Errorf("Error recording rollback of function %v to revision %v: %v", fnName, revision, err)
+	}
+	return fnew, nil
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+)
+
+func TestRevisionHistory(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+	policy := revisionPolicy{limit: 2, prunePackages: true}
+
+	makePackage := func(name string, url string) *crd.Package {
+		pkg, err := fc.Packages(ns).Create(&crd.Package{
+			Metadata: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec: fission.PackageSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+				Deployment:  fission.Archive{Type: fission.ArchiveTypeUrl, URL: url},
+			},
+			Status: fission.PackageStatus{BuildStatus: fission.BuildStatusSucceeded},
+		})
+		if err != nil {
+			t.Fatalf("Error creating package %v: %v", name, err)
+		}
+		return pkg
+	}
+	usePackage := func(fn *crd.Function, pkg *crd.Package) {
+		fn.Spec.Package.PackageRef = fission.PackageRef{
+			Namespace:       pkg.Metadata.Namespace,
+			Name:            pkg.Metadata.Name,
+			ResourceVersion: pkg.Metadata.ResourceVersion,
+		}
+	}
+	expectRevision := func(rev *crd.FunctionRevision, err error, revision int) {
+		if err != nil {
+			t.Fatalf("Error recording revision: %v", err)
+		}
+		if rev.Spec.Revision != revision {
+			t.Fatalf("Expected revision %v, got %v", revision, rev.Spec.Revision)
+		}
+	}
+
+	// first revision
+	pkgA := makePackage("hello-a", "http://example.com/a.zip")
+	fn := &crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+		},
+	}
+	usePackage(fn, pkgA)
+	fn, err := fc.Functions(ns).Create(fn)
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+	rev, err := recordRevision(fc, fn, policy, 0)
+	expectRevision(rev, err, 1)
+	if rev.Spec.Package.Deployment.URL != pkgA.Spec.Deployment.URL {
+		t.Errorf("Expected revision to have package spec, got %#v", rev.Spec.Package)
+	}
+
+	// unchanged functions don't get new revisions
+	rev, err = recordRevision(fc, fn, policy, 0)
+	expectRevision(rev, err, 1)
+
+	// new packages do
+	pkgB := makePackage("hello-b", "http://example.com/b.zip")
+	usePackage(fn, pkgB)
+	fn, err = fc.Functions(ns).Update(fn)
+	if err != nil {
+		t.Fatalf("Error updating function: %v", err)
+	}
+	rev, err = recordRevision(fc, fn, policy, 0)
+	expectRevision(rev, err, 2)
+
+	// the third revision prunes the first, and the package only it used
+	pkgC := makePackage("hello-c", "http://example.com/c.zip")
+	usePackage(fn, pkgC)
+	fn, err = fc.Functions(ns).Update(fn)
+	if err != nil {
+		t.Fatalf("Error updating function: %v", err)
+	}
+	rev, err = recordRevision(fc, fn, policy, 0)
+	expectRevision(rev, err, 3)
+
+	revs, err := listRevisions(fc, ns, "hello")
+	if err != nil {
+		t.Fatalf("Error listing revisions: %v", err)
+	}
+	if len(revs) != 2 || revs[0].Spec.Revision != 2 || revs[1].Spec.Revision != 3 {
+		t.Fatalf("Expected revisions 2 and 3, got %v", revs)
+	}
+	if _, err := fc.Packages(ns).Get("hello-a"); err == nil {
+		t.Errorf("Expected package of pruned revision to be deleted")
+	}
+	if _, err := fc.Packages(ns).Get("hello-b"); err != nil {
+		t.Errorf("Expected package of kept revision to be kept: %v", err)
+	}
+
+	// packages changed in place may be used by other functions, so
+	// rollbacks restore them as new packages
+	pkgB, err = fc.Packages(ns).Get("hello-b")
+	if err != nil {
+		t.Fatalf("Error getting package: %v", err)
+	}
+	pkgB.Spec.Deployment.URL = "http://example.com/b2.zip"
+	_, err = fc.Packages(ns).Update(pkgB)
+	if err != nil {
+		t.Fatalf("Error updating package: %v", err)
+	}
+
+	fn, err = rollback(fc, ns, "hello", 2, policy)
+	if err != nil {
+		t.Fatalf("Error rolling back: %v", err)
+	}
+	restoredName := fn.Spec.Package.PackageRef.Name
+	if restoredName == "hello-b" {
+		t.Errorf("Expected function to use a new package, got %v", restoredName)
+	}
+	restored, err := fc.Packages(ns).Get(restoredName)
+	if err != nil {
+		t.Fatalf("Error getting restored package: %v", err)
+	}
+	if restored.Spec.Deployment.URL != "http://example.com/b.zip" {
+		t.Errorf("Expected package to be restored, got deployment %v", restored.Spec.Deployment.URL)
+	}
+	if fn.Spec.Package.PackageRef.ResourceVersion != restored.Metadata.ResourceVersion {
+		t.Errorf("Expected function to reference the restored package's resource version")
+	}
+	pkgB, err = fc.Packages(ns).Get("hello-b")
+	if err != nil {
+		t.Fatalf("Error getting package: %v", err)
+	}
+	if pkgB.Spec.Deployment.URL != "http://example.com/b2.zip" {
+		t.Errorf("Expected the changed package to be left alone, got deployment %v", pkgB.Spec.Deployment.URL)
+	}
+
+	revs, err = listRevisions(fc, ns, "hello")
+	if err != nil {
+		t.Fatalf("Error listing revisions: %v", err)
+	}
+	last := revs[len(revs)-1]
+	if last.Spec.Revision != 4 || last.Spec.RollbackOf != 2 {
+		t.Errorf("Expected rollback to be recorded as revision 4, got %#v", last.Spec)
+	}
+
+	_, err = rollback(fc, ns, "hello", 1, policy)
+	if err == nil {
+		t.Errorf("Expected rollback to a pruned revision to fail")
+	}
+
+	err = deleteRevisions(fc, ns, "hello")
+	if err != nil {
+		t.Fatalf("Error deleting revisions: %v", err)
+	}
+	revs, err = listRevisions(fc, ns, "hello")
+	if err != nil || len(revs) != 0 {
+		t.Errorf("Expected no revisions, got %v, %v", revs, err)
+	}
+}
+
+func TestRevisionPackagesKept(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+	policy := revisionPolicy{limit: 1}
+
+	fn, err := fc.Functions(ns).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			Package: fission.FunctionPackageRef{
+				PackageRef: fission.PackageRef{Name: "hello-a", Namespace: ns},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+	_, err = fc.Packages(ns).Create(&crd.Package{
+		Metadata: metav1.ObjectMeta{Name: "hello-a", Namespace: ns},
+	})
+	if err != nil {
+		t.Fatalf("Error creating package: %v", err)
+	}
+	_, err = recordRevision(fc, fn, policy, 0)
+	if err != nil {
+		t.Fatalf("Error recording revision: %v", err)
+	}
+
+	fn.Spec.Package.PackageRef.Name = "hello-b"
+	fn, err = fc.Functions(ns).Update(fn)
+	if err != nil {
+		t.Fatalf("Error updating function: %v", err)
+	}
+	_, err = recordRevision(fc, fn, policy, 0)
+	if err != nil {
+		t.Fatalf("Error recording revision: %v", err)
+	}
+
+	// without prunePackages, only the revision is pruned
+	revs, err := listRevisions(fc, ns, "hello")
+	if err != nil || len(revs) != 1 {
+		t.Fatalf("Expected 1 revision, got %v, %v", revs, err)
+	}
+	if _, err := fc.Packages(ns).Get("hello-a"); err != nil {
+		t.Errorf("Expected package of pruned revision to be kept: %v", err)
+	}
+}
+
+func TestConcurrentRevisions(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+
+	fn, err := fc.Functions(ns).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+
+	// another update took revision 1 without this one seeing it
+	_, err = fc.FunctionRevisions(ns).Create(&crd.FunctionRevision{
+		Metadata: metav1.ObjectMeta{Name: revisionName("hello", 1), Namespace: ns},
+		Spec:     fission.FunctionRevisionSpec{FunctionName: "hello", Revision: 1},
+	})
+	if err != nil {
+		t.Fatalf("Error creating revision: %v", err)
+	}
+
+	rev, err := recordRevision(fc, fn, revisionPolicy{}, 0)
+	if err != nil {
+		t.Fatalf("Expected revision to be recorded with the next number, got %v", err)
+	}
+	if rev.Spec.Revision != 2 {
+		t.Errorf("Expected revision 2, got %v", rev.Spec.Revision)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// watchEvent is one change streamed to a watch request, in the same
+// form as Kubernetes watch events.
+type watchEvent struct {
+	Type   watch.EventType `json:"type"`
+	Object runtime.Object  `json:"object"`
+}
+
+// wantsWatch returns true for list requests with ?watch=true. Such
+// requests stream changes to the listed objects instead: ADDED,
+// MODIFIED and DELETED events, starting after the resourceVersion
+// parameter, or with an ADDED event for each existing object if there's
+// none.
+//
+// Events are newline-delimited JSON objects, or server-sent events if
+// the request accepts text/event-stream. Server-sent events carry the
+// object's resource version as their ID, so that EventSource clients
+// resume where they left off when they reconnect.
+func wantsWatch(r *http.Request) bool {
+	return r.URL.Query().Get("watch") == "true"
+}
+
+// watchOptions returns the list options of a watch request.
+func watchOptions(r *http.Request, opts *listOptions) metav1.ListOptions {
+	o := opts.ListOptions
+	o.Watch = true
+	o.ResourceVersion = r.URL.Query().Get("resourceVersion")
+	if len(o.ResourceVersion) == 0 {
+		o.ResourceVersion = r.Header.Get("Last-Event-ID")
+	}
+	return o
+}
+
+// respondWithWatch streams the events of a CRD watch until the client
+// goes away or the watch ends; clients then watch again from the last
+// resource version they got.
+func (a *API) respondWithWatch(w http.ResponseWriter, r *http.Request, wi watch.Interface) {
+	defer wi.Stop()
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Warn("Response writer doesn't support flushing, watch events will be delayed")
+	}
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-wi.ResultChan():
+			if !ok {
+				return
+			}
+			b, err := json.Marshal(&watchEvent{Type: event.Type, Object: event.Object})
+			if err != nil {
+				log.Errorf("Error encoding watch event: %v", err)
+				return
+			}
+
+			if sse {
+				fmt.Fprintf(w, "event: %v\n", event.Type)
+				if m, ok := event.Object.(metav1.ObjectMetaAccessor); ok {
+					fmt.Fprintf(w, "id: %v\n", m.GetObjectMeta().GetResourceVersion())
+				}
+				fmt.Fprintf(w, "data: %s\n\n", b)
+			} else {
+				fmt.Fprintf(w, "%s\n", b)
+			}
+			flush()
+
+			// The watch can't go on after an error, such as a
+			// resource version that's too old.
+			if event.Type == watch.Error {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/fission/fission/crd"
+)
+
+func TestRespondWithWatch(t *testing.T) {
+	fn := func(name string, rv string) *crd.Function {
+		return &crd.Function{
+			Metadata: metav1.ObjectMeta{Namespace: "default", Name: name, ResourceVersion: rv},
+		}
+	}
+	send := func(fw *watch.FakeWatcher) {
+		fw.Add(fn("a", "1"))
+		fw.Modify(fn("a", "2"))
+		fw.Delete(fn("a", "3"))
+		fw.Stop()
+	}
+	api := &API{}
+
+	// newline-delimited JSON
+	fw := watch.NewFake()
+	go send(fw)
+	r := httptest.NewRequest("GET", "/v2/functions?watch=true", nil)
+	if !wantsWatch(r) {
+		t.Fatalf("Expected watch=true to be a watch request")
+	}
+	w := httptest.NewRecorder()
+	api.respondWithWatch(w, r, fw)
+
+	expected := []watch.EventType{watch.Added, watch.Modified, watch.Deleted}
+	var types []watch.EventType
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var event struct {
+			Type   watch.EventType `json:"type"`
+			Object crd.Function    `json:"object"`
+		}
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			t.Fatalf("Error decoding event %q: %v", scanner.Text(), err)
+		}
+		if event.Object.Metadata.Name != "a" {
+			t.Errorf("Expected function a, got %v", event.Object.Metadata.Name)
+		}
+		types = append(types, event.Type)
+	}
+	if len(types) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("Expected events %v, got %v", expected, types)
+		}
+	}
+
+	// server-sent events
+	fw = watch.NewFake()
+	go send(fw)
+	r = httptest.NewRequest("GET", "/v2/functions?watch=true", nil)
+	r.Header.Set("Accept", "text/event-stream")
+	w = httptest.NewRecorder()
+	api.respondWithWatch(w, r, fw)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected content type text/event-stream, got %v", ct)
+	}
+	body := w.Body.String()
+	for i, et := range expected {
+		if !strings.Contains(body, "event: "+string(et)+"\n") {
+			t.Errorf("Expected %v event in %q", et, body)
+		}
+		if !strings.Contains(body, "id: "+strconv.Itoa(i+1)+"\n") {
+			t.Errorf("Expected event id %v in %q", i+1, body)
+		}
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"github.com/robfig/cron"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fission/fission"
@@ -30,19 +29,29 @@ import (
 )
 
 func (a *API) TimeTriggerApiList(w http.ResponseWriter, r *http.Request) {
-	triggers, err := a.fissionClient.TimeTriggers(metav1.NamespaceAll).List(metav1.ListOptions{})
+	opts, err := parseListOptions(r)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-
-	resp, err := json.Marshal(triggers.Items)
+	if wantsWatch(r) {
+		wi, err := a.fissionClient.TimeTriggers(opts.namespace).Watch(watchOptions(r, opts))
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		a.respondWithWatch(w, r, wi)
+		return
+	}
+	triggers, err := a.fissionClient.TimeTriggers(opts.namespace).List(opts.ListOptions)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
-	a.respondWithSuccess(w, resp)
+	items := triggers.Items
+	page, next := opts.page(items, func(i int) *metav1.ObjectMeta { return &items[i].Metadata })
+	a.respondWithList(w, page, next)
 }
 
 func (a *API) TimeTriggerApiCreate(w http.ResponseWriter, r *http.Request) {
@@ -59,20 +68,23 @@ func (a *API) TimeTriggerApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = validateResourceName(t.Metadata.Name)
+	err = objectNamespace(r, &t.Metadata)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
-	// validate
-	_, err = cron.Parse(t.Spec.Cron)
+	err = a.validate(r, &t)
 	if err != nil {
-		err = fission.MakeError(fission.ErrorInvalidArgument, "TimeTrigger cron spec is not valid")
 		a.respondWithError(w, err)
 		return
 	}
 
+	if isDryRun(r) {
+		a.dryRunCreate(w, "timetriggers", &t.Metadata, &t)
+		return
+	}
+
 	tnew, err := a.fissionClient.TimeTriggers(t.Metadata.Namespace).Create(&t)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -92,10 +104,7 @@ func (a *API) TimeTriggerApiCreate(w http.ResponseWriter, r *http.Request) {
 func (a *API) TimeTriggerApiGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["timeTrigger"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
-	}
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
 
 	t, err := a.fissionClient.TimeTriggers(ns).Get(name)
 	if err != nil {
@@ -135,13 +144,23 @@ func (a *API) TimeTriggerApiUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cron.Parse(t.Spec.Cron)
+	err = objectNamespace(r, &t.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &t)
 	if err != nil {
-		err = fission.MakeError(fission.ErrorInvalidArgument, "TimeTrigger cron spec is not valid")
 		a.respondWithError(w, err)
 		return
 	}
 
+	if isDryRun(r) {
+		a.dryRunUpdate(w, "timetriggers", &t.Metadata, &t)
+		return
+	}
+
 	tnew, err := a.fissionClient.TimeTriggers(t.Metadata.Namespace).Update(&t)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -159,9 +178,11 @@ func (a *API) TimeTriggerApiUpdate(w http.ResponseWriter, r *http.Request) {
 func (a *API) TimeTriggerApiDelete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["timeTrigger"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	if isDryRun(r) {
+		a.dryRunDelete(w, "timetriggers", ns, name)
+		return
 	}
 
 	err := a.fissionClient.TimeTriggers(ns).Delete(name, &metav1.DeleteOptions{})
@@ -47,9 +47,16 @@ func (a *API) Tpr2crdApi(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// dry runs respond with the TPRs that would be deleted
+	dryRun := isDryRun(r)
+	deleted := make([]string, 0)
 	for _, tpr := range tprList.Items {
 		for _, tprName := range fissionTprs {
 			if tpr.Name == tprName {
+				if dryRun {
+					deleted = append(deleted, tpr.Name)
+					break
+				}
 				err := kubeClient.ThirdPartyResources().Delete(tpr.Name, &metav1.DeleteOptions{})
 				if err != nil {
 					a.respondWithError(w, err)
@@ -60,5 +67,9 @@ func (a *API) Tpr2crdApi(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if dryRun {
+		a.respondWithDryRun(w, http.StatusOK, deleted)
+		return
+	}
 	a.respondWithSuccess(w, nil)
 }
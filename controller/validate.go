@@ -0,0 +1,598 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/robfig/cron"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/pkg/api/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/mqtrigger/messageQueue"
+)
+
+type (
+	// validator checks Fission objects before they're stored, so that
+	// mistakes are reported by the API rather than found when
+	// functions are invoked. Field paths in errors use the JSON names
+	// of fields.
+	validator struct {
+		fissionClient crd.FissionClientInterface
+
+		// Objects that are validated together may refer to each
+		// other before they exist.
+		environments map[string]bool // namespace/name
+		packages     map[string]bool // namespace/name
+	}
+
+	fieldErrors []fission.FieldError
+)
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+func makeValidator(fissionClient crd.FissionClientInterface) *validator {
+	return &validator{
+		fissionClient: fissionClient,
+		environments:  make(map[string]bool),
+		packages:      make(map[string]bool),
+	}
+}
+
+func (errs *fieldErrors) add(field string, format string, args ...interface{}) {
+	*errs = append(*errs, fission.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// addMsgs adds the messages of the Kubernetes validation functions.
+func (errs *fieldErrors) addMsgs(field string, msgs []string) {
+	for _, msg := range msgs {
+		errs.add(field, "%v", msg)
+	}
+}
+
+func namespaceOr(ns string, dflt string) string {
+	if len(ns) > 0 {
+		return ns
+	}
+	if len(dflt) > 0 {
+		return dflt
+	}
+	return metav1.NamespaceDefault
+}
+
+// addResources lets the objects validated by v refer to the
+// environments and packages in res.
+func (v *validator) addResources(res *crd.Resources) {
+	for _, env := range res.Environments {
+		v.environments[namespaceOr(env.Metadata.Namespace, "")+"/"+env.Metadata.Name] = true
+	}
+	for _, pkg := range res.Packages {
+		v.packages[namespaceOr(pkg.Metadata.Namespace, "")+"/"+pkg.Metadata.Name] = true
+	}
+}
+
+func (v *validator) environmentExists(namespace string, name string) (bool, error) {
+	if v.environments[namespace+"/"+name] {
+		return true, nil
+	}
+	_, err := v.fissionClient.Environments(namespace).Get(name)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (v *validator) packageExists(namespace string, name string) (bool, error) {
+	if v.packages[namespace+"/"+name] {
+		return true, nil
+	}
+	_, err := v.fissionClient.Packages(namespace).Get(name)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func validateMetadata(errs *fieldErrors, m *metav1.ObjectMeta) {
+	if len(m.Name) == 0 {
+		errs.add("metadata.name", "required")
+	} else {
+		errs.addMsgs("metadata.name", validation.IsDNS1123Subdomain(m.Name))
+	}
+	if len(m.Namespace) > 0 {
+		errs.addMsgs("metadata.namespace", validation.IsDNS1123Label(m.Namespace))
+	}
+}
+
+func validateFunctionReference(errs *fieldErrors, path string, ref *fission.FunctionReference) {
+	switch ref.Type {
+	case "", fission.FunctionReferenceTypeFunctionName:
+	default:
+		errs.add(path+".type", "unsupported function reference type %q", ref.Type)
+	}
+	if len(ref.Name) == 0 {
+		errs.add(path+".name", "required")
+	} else {
+		errs.addMsgs(path+".name", validation.IsDNS1123Subdomain(ref.Name))
+	}
+}
+
+func validatePort(errs *fieldErrors, path string, port int32) {
+	// zero means the default port
+	if port != 0 {
+		errs.addMsgs(path, validation.IsValidPortNum(int(port)))
+	}
+}
+
+func validateResourceRequirements(errs *fieldErrors, path string, r *v1.ResourceRequirements) {
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		req, hasReq := r.Requests[name]
+		limit, hasLimit := r.Limits[name]
+		if hasReq && req.Sign() < 0 {
+			errs.add(fmt.Sprintf("%v.requests.%v", path, name), "must not be negative")
+		}
+		if hasReq && hasLimit && req.Cmp(limit) > 0 {
+			errs.add(fmt.Sprintf("%v.requests.%v", path, name), "%v is more than the limit, %v", req.String(), limit.String())
+		}
+	}
+}
+
+func validateArchive(errs *fieldErrors, path string, a *fission.Archive) {
+	switch a.Type {
+	case "":
+		if len(a.URL) > 0 || len(a.Literal) > 0 {
+			errs.add(path+".type", "required")
+		}
+	case fission.ArchiveTypeLiteral:
+		if int64(len(a.Literal)) > fission.ArchiveLiteralSizeLimit {
+			errs.add(path+".literal", "must be at most %v bytes, upload larger archives instead", fission.ArchiveLiteralSizeLimit)
+		}
+	case fission.ArchiveTypeUrl:
+		if len(a.URL) == 0 {
+			errs.add(path+".url", "required")
+		} else if u, err := url.Parse(a.URL); err != nil || !u.IsAbs() {
+			errs.add(path+".url", "must be an absolute URL")
+		}
+	default:
+		errs.add(path+".type", "unsupported archive type %q", a.Type)
+	}
+	switch a.Checksum.Type {
+	case "", fission.ChecksumTypeSHA256:
+	default:
+		errs.add(path+".checksum.type", "unsupported checksum type %q", a.Checksum.Type)
+	}
+}
+
+func (v *validator) validateEnvironment(env *crd.Environment) (fieldErrors, error) {
+	errs := make(fieldErrors, 0)
+	validateMetadata(&errs, &env.Metadata)
+
+	spec := &env.Spec
+	if spec.Version < 0 || spec.Version > 3 {
+		errs.add("spec.version", "unsupported environment interface version %v", spec.Version)
+	}
+	if len(spec.Runtime.Image) == 0 {
+		errs.add("spec.runtime.image", "required")
+	}
+	validatePort(&errs, "spec.runtime.loadendpointport", spec.Runtime.LoadEndpointPort)
+	validatePort(&errs, "spec.runtime.functionendpointport", spec.Runtime.FunctionEndpointPort)
+	if len(spec.Runtime.LoadEndpointPath) > 0 && !strings.HasPrefix(spec.Runtime.LoadEndpointPath, "/") {
+		errs.add("spec.runtime.loadendpointpath", "must start with /")
+	}
+	if len(spec.Builder.Command) > 0 && len(spec.Builder.Image) == 0 {
+		errs.add("spec.builder.image", "required for a build command")
+	}
+	switch spec.AllowedFunctionsPerContainer {
+	case "", fission.AllowedFunctionsPerContainerSingle, fission.AllowedFunctionsPerContainerInfinite:
+	default:
+		errs.add("spec.allowedFunctionsPerContainer", "unsupported value %q, use %v or %v", spec.AllowedFunctionsPerContainer,
+			fission.AllowedFunctionsPerContainerSingle, fission.AllowedFunctionsPerContainerInfinite)
+	}
+	if spec.Poolsize < 0 {
+		errs.add("spec.poolsize", "must not be negative")
+	}
+	validateResourceRequirements(&errs, "spec.resources", &spec.Resources)
+	errs = append(errs, spec.PodTemplate.FieldErrors("spec.podtemplate")...)
+
+	return errs, nil
+}
+
+func (v *validator) validatePackage(pkg *crd.Package) (fieldErrors, error) {
+	errs := make(fieldErrors, 0)
+	validateMetadata(&errs, &pkg.Metadata)
+
+	spec := &pkg.Spec
+	if len(spec.Environment.Name) == 0 {
+		errs.add("spec.environment.name", "required")
+	} else {
+		ns := namespaceOr(spec.Environment.Namespace, pkg.Metadata.Namespace)
+		ok, err := v.environmentExists(ns, spec.Environment.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			errs.add("spec.environment.name", "environment %v/%v doesn't exist", ns, spec.Environment.Name)
+		}
+	}
+	if len(spec.Source.Type) == 0 && len(spec.Deployment.Type) == 0 {
+		errs.add("spec", "needs a source or deployment archive")
+	}
+	validateArchive(&errs, "spec.source", &spec.Source)
+	validateArchive(&errs, "spec.deployment", &spec.Deployment)
+
+	switch pkg.Status.BuildStatus {
+	case "", fission.BuildStatusPending, fission.BuildStatusRunning, fission.BuildStatusSucceeded,
+		fission.BuildStatusFailed, fission.BuildStatusNone:
+	default:
+		errs.add("status.buildstatus", "unsupported build status %q", pkg.Status.BuildStatus)
+	}
+
+	return errs, nil
+}
+
+// checkMaxScale checks the MaxScale of a function run in a deployment
+// against the quotas of its namespace, so that it's rejected when it's
+// stored rather than when it's first called.
+func (v *validator) checkMaxScale(errs *fieldErrors, path string, fn *crd.Function) error {
+	maxScale := fn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale
+	ns := namespaceOr(fn.Metadata.Namespace, "")
+	quotas, err := v.fissionClient.FunctionQuotas(ns).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, q := range quotas.Items {
+		if q.Spec.MaxScale > 0 && maxScale > q.Spec.MaxScale {
+			errs.add(path, "%v is above %v, the limit of quota %v", maxScale, q.Spec.MaxScale, q.Metadata.Name)
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateFunction(fn *crd.Function) (fieldErrors, error) {
+	errs := make(fieldErrors, 0)
+	validateMetadata(&errs, &fn.Metadata)
+
+	spec := &fn.Spec
+	es := &spec.InvokeStrategy.ExecutionStrategy
+	switch spec.InvokeStrategy.StrategyType {
+	case "", fission.StrategyTypeExecution:
+	default:
+		errs.add("spec.InvokeStrategy.StrategyType", "unsupported strategy type %q", spec.InvokeStrategy.StrategyType)
+	}
+
+	container := false
+	switch es.ExecutorType {
+	case "", fission.ExecutorTypePoolmgr, fission.ExecutorTypeNewdeploy, fission.ExecutorTypeJob:
+	case fission.ExecutorTypeContainer:
+		container = true
+	default:
+		errs.add("spec.InvokeStrategy.ExecutionStrategy.ExecutorType", "unsupported executor type %q, use %v", es.ExecutorType,
+			strings.Join([]string{fission.ExecutorTypePoolmgr, fission.ExecutorTypeNewdeploy, fission.ExecutorTypeContainer, fission.ExecutorTypeJob}, ", "))
+	}
+
+	esPath := "spec.InvokeStrategy.ExecutionStrategy"
+	if es.MinScale < 0 {
+		errs.add(esPath+".MinScale", "must not be negative")
+	}
+	if es.MaxScale < 0 {
+		errs.add(esPath+".MaxScale", "must not be negative")
+	}
+	if es.MaxScale > 0 && es.MinScale > es.MaxScale {
+		errs.add(esPath+".MinScale", "must not be more than MaxScale (%v)", es.MaxScale)
+	}
+	// utilization is relative to the CPU request, so targets above
+	// 100 percent are fine
+	if es.TargetCPUPercent < 0 {
+		errs.add(esPath+".TargetCPUPercent", "must not be negative")
+	}
+	if es.TargetRequestsPerSecond < 0 {
+		errs.add(esPath+".TargetRequestsPerSecond", "must not be negative")
+	}
+	if es.TargetInFlightRequests < 0 {
+		errs.add(esPath+".TargetInFlightRequests", "must not be negative")
+	}
+	if es.MaxConcurrency < 0 {
+		errs.add(esPath+".MaxConcurrency", "must not be negative")
+	}
+	if spec.FunctionTimeout < 0 {
+		errs.add("spec.functionTimeout", "must not be negative")
+	}
+	switch es.ExecutorType {
+	case fission.ExecutorTypeNewdeploy, fission.ExecutorTypeContainer:
+		err := v.checkMaxScale(&errs, esPath+".MaxScale", fn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if container {
+		if spec.Container == nil || len(spec.Container.Image) == 0 {
+			errs.add("spec.container.image", "required for executor type %v", fission.ExecutorTypeContainer)
+		} else {
+			validatePort(&errs, "spec.container.port", spec.Container.Port)
+		}
+	} else {
+		// Everything but containers runs in an environment, from a
+		// package.
+		if len(spec.Environment.Name) == 0 {
+			errs.add("spec.environment.name", "required")
+		} else {
+			ns := namespaceOr(spec.Environment.Namespace, fn.Metadata.Namespace)
+			ok, err := v.environmentExists(ns, spec.Environment.Name)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				errs.add("spec.environment.name", "environment %v/%v doesn't exist", ns, spec.Environment.Name)
+			}
+		}
+
+		ref := &spec.Package.PackageRef
+		if len(ref.Name) == 0 {
+			errs.add("spec.package.packageref.name", "required")
+		} else {
+			ns := namespaceOr(ref.Namespace, fn.Metadata.Namespace)
+			ok, err := v.packageExists(ns, ref.Name)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				errs.add("spec.package.packageref.name", "package %v/%v doesn't exist", ns, ref.Name)
+			}
+		}
+	}
+
+	for i, s := range spec.Secrets {
+		errs.addMsgs(fmt.Sprintf("spec.secrets[%v].name", i), validation.IsDNS1123Subdomain(s.Name))
+	}
+	for i, cm := range spec.ConfigMaps {
+		errs.addMsgs(fmt.Sprintf("spec.configmaps[%v].name", i), validation.IsDNS1123Subdomain(cm.Name))
+	}
+	validateResourceRequirements(&errs, "spec.resources", &spec.Resources)
+	errs = append(errs, spec.PodTemplate.FieldErrors("spec.podtemplate")...)
+
+	return errs, nil
+}
+
+func (v *validator) validateHTTPTrigger(t *crd.HTTPTrigger) (fieldErrors, error) {
+	errs := make(fieldErrors, 0)
+	validateMetadata(&errs, &t.Metadata)
+
+	spec := &t.Spec
+	// The router adds routes for triggers with mux, so their URLs and
+	// hosts are mux patterns.
+	route := mux.NewRouter().NewRoute()
+	if len(spec.RelativeURL) == 0 {
+		errs.add("spec.relativeurl", "required")
+	} else if !strings.HasPrefix(spec.RelativeURL, "/") {
+		errs.add("spec.relativeurl", "must start with /")
+	} else if err := route.Path(spec.RelativeURL).GetError(); err != nil {
+		errs.add("spec.relativeurl", "invalid URL pattern: %v", err)
+	}
+	if len(spec.Host) > 0 {
+		if err := mux.NewRouter().NewRoute().Host(spec.Host).GetError(); err != nil {
+			errs.add("spec.host", "invalid host pattern: %v", err)
+		}
+	}
+
+	method := false
+	for _, m := range httpMethods {
+		if spec.Method == m {
+			method = true
+		}
+	}
+	if !method {
+		errs.add("spec.method", "unsupported method %q, use one of %v", spec.Method, strings.Join(httpMethods, ", "))
+	}
+
+	validateFunctionReference(&errs, "spec.functionref", &spec.FunctionReference)
+	return errs, nil
+}
+
+func (v *validator) validateKubernetesWatchTrigger(w *crd.KubernetesWatchTrigger) (fieldErrors, error) {
+	errs := make(fieldErrors, 0)
+	validateMetadata(&errs, &w.Metadata)
+
+	spec := &w.Spec
+	if len(spec.Namespace) > 0 {
+		errs.addMsgs("spec.namespace", validation.IsDNS1123Label(spec.Namespace))
+	}
+	// the object types kubewatcher can watch
+	switch strings.ToUpper(spec.Type) {
+	case "POD", "SERVICE", "REPLICATIONCONTROLLER", "JOB":
+	default:
+		errs.add("spec.type", "unsupported object type %q, use pod, service, replicationcontroller or job", spec.Type)
+	}
+	for k, val := range spec.LabelSelector {
+		errs.addMsgs(fmt.Sprintf("spec.labelselector[%v]", k), validation.IsQualifiedName(k))
+		errs.addMsgs(fmt.Sprintf("spec.labelselector[%v]", k), validation.IsValidLabelValue(val))
+	}
+
+	validateFunctionReference(&errs, "spec.functionref", &spec.FunctionReference)
+	return errs, nil
+}
+
+func (v *validator) validateTimeTrigger(t *crd.TimeTrigger) (fieldErrors, error) {
+	errs := make(fieldErrors, 0)
+	validateMetadata(&errs, &t.Metadata)
+
+	if len(t.Spec.Cron) == 0 {
+		errs.add("spec.cron", "required")
+	} else if _, err := cron.Parse(t.Spec.Cron); err != nil {
+		errs.add("spec.cron", "invalid cron spec: %v", err)
+	}
+
+	validateFunctionReference(&errs, "spec.functionref", &t.Spec.FunctionReference)
+	return errs, nil
+}
+
+func (v *validator) validateMessageQueueTrigger(t *crd.MessageQueueTrigger) (fieldErrors, error) {
+	errs := make(fieldErrors, 0)
+	validateMetadata(&errs, &t.Metadata)
+
+	spec := &t.Spec
+	switch spec.MessageQueueType {
+	case messageQueue.NATS, messageQueue.ASQ:
+		if len(spec.Topic) == 0 {
+			errs.add("spec.topic", "required")
+		} else if !messageQueue.IsTopicValid(spec.MessageQueueType, spec.Topic) {
+			errs.add("spec.topic", "invalid topic for message queue type %v", spec.MessageQueueType)
+		}
+		if len(spec.ResponseTopic) > 0 && !messageQueue.IsTopicValid(spec.MessageQueueType, spec.ResponseTopic) {
+			errs.add("spec.respTopic", "invalid topic for message queue type %v", spec.MessageQueueType)
+		}
+	default:
+		errs.add("spec.messageQueueType", "unsupported message queue type %q, use %v or %v",
+			spec.MessageQueueType, messageQueue.NATS, messageQueue.ASQ)
+	}
+
+	validateFunctionReference(&errs, "spec.functionref", &spec.FunctionReference)
+	return errs, nil
+}
+
+// validate validates an object about to be created or updated by a
+// request.
+func (a *API) validate(r *http.Request, obj interface{}) error {
+	v := makeValidator(a.fissionClient)
+	if isDryRun(r) {
+		v.addDryRunCreated(r)
+	}
+	return v.validate(obj)
+}
+
+// validate validates a Fission object, and returns an
+// ErrorInvalidArgument with the problems it finds.
+func (v *validator) validate(obj interface{}) error {
+	var kind string
+	var m *metav1.ObjectMeta
+	var errs fieldErrors
+	var err error
+
+	switch o := obj.(type) {
+	case *crd.Environment:
+		kind, m = "Environment", &o.Metadata
+		errs, err = v.validateEnvironment(o)
+	case *crd.Package:
+		kind, m = "Package", &o.Metadata
+		errs, err = v.validatePackage(o)
+	case *crd.Function:
+		kind, m = "Function", &o.Metadata
+		errs, err = v.validateFunction(o)
+	case *crd.HTTPTrigger:
+		kind, m = "HTTPTrigger", &o.Metadata
+		errs, err = v.validateHTTPTrigger(o)
+	case *crd.KubernetesWatchTrigger:
+		kind, m = "KubernetesWatchTrigger", &o.Metadata
+		errs, err = v.validateKubernetesWatchTrigger(o)
+	case *crd.TimeTrigger:
+		kind, m = "TimeTrigger", &o.Metadata
+		errs, err = v.validateTimeTrigger(o)
+	case *crd.MessageQueueTrigger:
+		kind, m = "MessageQueueTrigger", &o.Metadata
+		errs, err = v.validateMessageQueueTrigger(o)
+	default:
+		return fmt.Errorf("can't validate %T", obj)
+	}
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fission.MakeValidationError(kind, m.Name, errs)
+	}
+	return nil
+}
+
+// validateResources validates a set of objects that may refer to each
+// other, and returns the problems with each invalid one.
+func (v *validator) validateResources(res *crd.Resources) ([]fission.ValidationResult, error) {
+	v.addResources(res)
+
+	results := make([]fission.ValidationResult, 0)
+	add := func(kind string, m *metav1.ObjectMeta, errs fieldErrors, err error) error {
+		if err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			results = append(results, fission.ValidationResult{
+				Kind:      kind,
+				Namespace: namespaceOr(m.Namespace, ""),
+				Name:      m.Name,
+				Errors:    errs,
+			})
+		}
+		return nil
+	}
+
+	for i := range res.Environments {
+		o := &res.Environments[i]
+		errs, err := v.validateEnvironment(o)
+		if err = add("Environment", &o.Metadata, errs, err); err != nil {
+			return nil, err
+		}
+	}
+	for i := range res.Packages {
+		o := &res.Packages[i]
+		errs, err := v.validatePackage(o)
+		if err = add("Package", &o.Metadata, errs, err); err != nil {
+			return nil, err
+		}
+	}
+	for i := range res.Functions {
+		o := &res.Functions[i]
+		errs, err := v.validateFunction(o)
+		if err = add("Function", &o.Metadata, errs, err); err != nil {
+			return nil, err
+		}
+	}
+	for i := range res.HTTPTriggers {
+		o := &res.HTTPTriggers[i]
+		errs, err := v.validateHTTPTrigger(o)
+		if err = add("HTTPTrigger", &o.Metadata, errs, err); err != nil {
+			return nil, err
+		}
+	}
+	for i := range res.KubernetesWatchTriggers {
+		o := &res.KubernetesWatchTriggers[i]
+		errs, err := v.validateKubernetesWatchTrigger(o)
+		if err = add("KubernetesWatchTrigger", &o.Metadata, errs, err); err != nil {
+			return nil, err
+		}
+	}
+	for i := range res.TimeTriggers {
+		o := &res.TimeTriggers[i]
+		errs, err := v.validateTimeTrigger(o)
+		if err = add("TimeTrigger", &o.Metadata, errs, err); err != nil {
+			return nil, err
+		}
+	}
+	for i := range res.MessageQueueTriggers {
+		o := &res.MessageQueueTriggers[i]
+		errs, err := v.validateMessageQueueTrigger(o)
+		if err = add("MessageQueueTrigger", &o.Metadata, errs, err); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+)
+
+// expectFieldErrors checks that a validation error names each of
+// fields, in any order.
+func expectFieldErrors(t *testing.T, what string, err error, fields ...string) {
+	if len(fields) == 0 {
+		if err != nil {
+			t.Errorf("Expected %v to be valid, got %v", what, err)
+		}
+		return
+	}
+	if err == nil {
+		t.Errorf("Expected %v to be invalid", what)
+		return
+	}
+	fe, ok := err.(fission.Error)
+	if !ok || fe.Code != fission.ErrorInvalidArgument {
+		t.Errorf("Expected an invalid argument error for %v, got %v", what, err)
+		return
+	}
+	for _, field := range fields {
+		if !strings.Contains(fe.Message, field+": ") {
+			t.Errorf("Expected error for %v to name %v, got %v", what, field, fe.Message)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+
+	_, err := fc.Environments(ns).Create(&crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "nodejs", Namespace: ns},
+		Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating environment: %v", err)
+	}
+	_, err = fc.Packages(ns).Create(&crd.Package{
+		Metadata: metav1.ObjectMeta{Name: "hello-pkg", Namespace: ns},
+		Spec: fission.PackageSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+			Deployment:  fission.Archive{Type: fission.ArchiveTypeUrl, URL: "http://example.com/hello.zip"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating package: %v", err)
+	}
+	v := makeValidator(fc)
+
+	env := &crd.Environment{
+		Metadata: metav1.ObjectMeta{Name: "Python_3", Namespace: ns},
+		Spec: fission.EnvironmentSpec{
+			Version:                      4,
+			AllowedFunctionsPerContainer: "some",
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("200m")},
+				Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+			},
+		},
+	}
+	expectFieldErrors(t, "environment", v.validate(env),
+		"metadata.name", "spec.version", "spec.runtime.image",
+		"spec.allowedFunctionsPerContainer", "spec.resources.requests.cpu")
+
+	pkg := &crd.Package{
+		Metadata: metav1.ObjectMeta{Name: "hello-pkg-2", Namespace: ns},
+		Spec: fission.PackageSpec{
+			Environment: fission.EnvironmentReference{Name: "python"},
+			Source:      fission.Archive{Type: "tarball"},
+		},
+	}
+	expectFieldErrors(t, "package", v.validate(pkg), "spec.environment.name", "spec.source.type")
+
+	fn := &crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+			Package: fission.FunctionPackageRef{
+				PackageRef: fission.PackageRef{Name: "hello-pkg", Namespace: ns},
+			},
+		},
+	}
+	expectFieldErrors(t, "function", v.validate(fn))
+
+	fn.Spec.Package.PackageRef.Name = "missing"
+	fn.Spec.InvokeStrategy.ExecutionStrategy = fission.ExecutionStrategy{
+		ExecutorType:     "lambda",
+		MinScale:         3,
+		MaxScale:         1,
+		TargetCPUPercent: -1,
+	}
+	expectFieldErrors(t, "function", v.validate(fn),
+		"spec.package.packageref.name",
+		"spec.InvokeStrategy.ExecutionStrategy.ExecutorType",
+		"spec.InvokeStrategy.ExecutionStrategy.MinScale",
+		"spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent")
+
+	// container functions need an image rather than a package
+	container := &crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello-container", Namespace: ns},
+		Spec: fission.FunctionSpec{
+			InvokeStrategy: fission.InvokeStrategy{
+				ExecutionStrategy: fission.ExecutionStrategy{ExecutorType: fission.ExecutorTypeContainer},
+			},
+		},
+	}
+	expectFieldErrors(t, "container function", v.validate(container), "spec.container.image")
+	container.Spec.Container = &fission.FunctionContainer{Image: "example/hello"}
+	expectFieldErrors(t, "container function", v.validate(container))
+
+	// deployments can't scale beyond the namespace's quotas
+	_, err = fc.FunctionQuotas(ns).Create(&crd.FunctionQuota{
+		Metadata: metav1.ObjectMeta{Name: "team", Namespace: ns},
+		Spec:     fission.FunctionQuotaSpec{MaxScale: 2},
+	})
+	if err != nil {
+		t.Fatalf("Error creating quota: %v", err)
+	}
+	container.Spec.InvokeStrategy.ExecutionStrategy.MaxScale = 5
+	expectFieldErrors(t, "container function above quota", v.validate(container),
+		"spec.InvokeStrategy.ExecutionStrategy.MaxScale")
+	container.Spec.InvokeStrategy.ExecutionStrategy.MaxScale = 2
+	expectFieldErrors(t, "container function within quota", v.validate(container))
+
+	ht := &crd.HTTPTrigger{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.HTTPTriggerSpec{
+			RelativeURL:       "/hello/{name",
+			Method:            "FETCH",
+			FunctionReference: fission.FunctionReference{Type: fission.FunctionReferenceTypeFunctionName},
+		},
+	}
+	expectFieldErrors(t, "HTTP trigger", v.validate(ht), "spec.relativeurl", "spec.method", "spec.functionref.name")
+	ht.Spec.RelativeURL = "/hello/{name}"
+	ht.Spec.Method = "GET"
+	ht.Spec.FunctionReference.Name = "hello"
+	expectFieldErrors(t, "HTTP trigger", v.validate(ht))
+
+	// names are DNS subdomains, so they may have dots
+	ht.Metadata.Name = "hello.v1"
+	ht.Spec.FunctionReference.Name = "hello.v1"
+	expectFieldErrors(t, "HTTP trigger", v.validate(ht))
+
+	tt := &crd.TimeTrigger{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.TimeTriggerSpec{
+			Cron:              "every minute",
+			FunctionReference: fission.FunctionReference{Type: fission.FunctionReferenceTypeFunctionName, Name: "hello"},
+		},
+	}
+	expectFieldErrors(t, "time trigger", v.validate(tt), "spec.cron")
+
+	mqt := &crd.MessageQueueTrigger{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.MessageQueueTriggerSpec{
+			MessageQueueType:  "kafka",
+			Topic:             "hello",
+			FunctionReference: fission.FunctionReference{Type: fission.FunctionReferenceTypeFunctionName, Name: "hello"},
+		},
+	}
+	expectFieldErrors(t, "message queue trigger", v.validate(mqt), "spec.messageQueueType")
+
+	kw := &crd.KubernetesWatchTrigger{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+		Spec: fission.KubernetesWatchTriggerSpec{
+			Namespace:         "default",
+			Type:              "deployment",
+			FunctionReference: fission.FunctionReference{Type: fission.FunctionReferenceTypeFunctionName, Name: "hello"},
+		},
+	}
+	expectFieldErrors(t, "watch", v.validate(kw), "spec.type")
+}
+
+func TestValidateResources(t *testing.T) {
+	fc := crdFake.MakeFissionClient()
+	ns := metav1.NamespaceDefault
+
+	// objects may refer to others in the same set
+	res := &crd.Resources{
+		Environments: []crd.Environment{{
+			Metadata: metav1.ObjectMeta{Name: "nodejs", Namespace: ns},
+			Spec:     fission.EnvironmentSpec{Runtime: fission.Runtime{Image: "fission/node-env"}},
+		}},
+		Packages: []crd.Package{{
+			Metadata: metav1.ObjectMeta{Name: "hello-pkg", Namespace: ns},
+			Spec: fission.PackageSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+				Deployment:  fission.Archive{Type: fission.ArchiveTypeUrl, URL: "archive://hello-zip"},
+			},
+		}},
+		Functions: []crd.Function{{
+			Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+			Spec: fission.FunctionSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: ns},
+				Package: fission.FunctionPackageRef{
+					PackageRef: fission.PackageRef{Name: "hello-pkg", Namespace: ns},
+				},
+			},
+		}},
+		TimeTriggers: []crd.TimeTrigger{{
+			Metadata: metav1.ObjectMeta{Name: "hello", Namespace: ns},
+			Spec: fission.TimeTriggerSpec{
+				Cron:              "@hourly",
+				FunctionReference: fission.FunctionReference{Type: fission.FunctionReferenceTypeFunctionName, Name: "hello"},
+			},
+		}},
+	}
+	results, err := makeValidator(fc).validateResources(res)
+	if err != nil {
+		t.Fatalf("Error validating resources: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected resources to be valid, got %v", results)
+	}
+
+	res.TimeTriggers[0].Spec.Cron = "hourly"
+	results, err = makeValidator(fc).validateResources(res)
+	if err != nil {
+		t.Fatalf("Error validating resources: %v", err)
+	}
+	if len(results) != 1 || results[0].Kind != "TimeTrigger" || results[0].Name != "hello" ||
+		len(results[0].Errors) != 1 || results[0].Errors[0].Field != "spec.cron" {
+		t.Errorf("Expected the time trigger's cron spec to be invalid, got %v", results)
+	}
+}
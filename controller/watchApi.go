@@ -29,19 +29,29 @@ import (
 )
 
 func (a *API) WatchApiList(w http.ResponseWriter, r *http.Request) {
-	watches, err := a.fissionClient.KubernetesWatchTriggers(metav1.NamespaceAll).List(metav1.ListOptions{})
+	opts, err := parseListOptions(r)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
-
-	resp, err := json.Marshal(watches.Items)
+	if wantsWatch(r) {
+		wi, err := a.fissionClient.KubernetesWatchTriggers(opts.namespace).Watch(watchOptions(r, opts))
+		if err != nil {
+			a.respondWithError(w, err)
+			return
+		}
+		a.respondWithWatch(w, r, wi)
+		return
+	}
+	watches, err := a.fissionClient.KubernetesWatchTriggers(opts.namespace).List(opts.ListOptions)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
 	}
 
-	a.respondWithSuccess(w, resp)
+	items := watches.Items
+	page, next := opts.page(items, func(i int) *metav1.ObjectMeta { return &items[i].Metadata })
+	a.respondWithList(w, page, next)
 }
 
 func (a *API) WatchApiCreate(w http.ResponseWriter, r *http.Request) {
@@ -58,7 +68,13 @@ func (a *API) WatchApiCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = validateResourceName(watch.Metadata.Name)
+	err = objectNamespace(r, &watch.Metadata)
+	if err != nil {
+		a.respondWithError(w, err)
+		return
+	}
+
+	err = a.validate(r, &watch)
 	if err != nil {
 		a.respondWithError(w, err)
 		return
@@ -66,6 +82,11 @@ func (a *API) WatchApiCreate(w http.ResponseWriter, r *http.Request) {
 
 	// TODO check for duplicate watches
 
+	if isDryRun(r) {
+		a.dryRunCreate(w, "kuberneteswatchtriggers", &watch.Metadata, &watch)
+		return
+	}
+
 	wnew, err := a.fissionClient.KubernetesWatchTriggers(watch.Metadata.Namespace).Create(&watch)
 	if err != nil {
 		a.respondWithError(w, err)
@@ -85,10 +106,7 @@ func (a *API) WatchApiCreate(w http.ResponseWriter, r *http.Request) {
 func (a *API) WatchApiGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["watch"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
-	}
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
 
 	watch, err := a.fissionClient.KubernetesWatchTriggers(ns).Get(name)
 	if err != nil {
@@ -113,9 +131,11 @@ func (a *API) WatchApiUpdate(w http.ResponseWriter, r *http.Request) {
 func (a *API) WatchApiDelete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["watch"]
-	ns := vars["namespace"]
-	if len(ns) == 0 {
-		ns = metav1.NamespaceDefault
+	ns := namespaceOr(requestedNamespace(r), metav1.NamespaceDefault)
+
+	if isDryRun(r) {
+		a.dryRunDelete(w, "kuberneteswatchtriggers", ns, name)
+		return
 	}
 
 	err := a.fissionClient.KubernetesWatchTriggers(ns).Delete(name, &metav1.DeleteOptions{})
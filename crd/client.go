@@ -37,6 +37,20 @@ type (
 	FissionClient struct {
 		crdClient *rest.RESTClient
 	}
+
+	// FissionClientInterface has the typed clients of FissionClient, so
+	// that components can be tested with crd/fake instead of a cluster.
+	FissionClientInterface interface {
+		Functions(ns string) FunctionInterface
+		Environments(ns string) EnvironmentInterface
+		HTTPTriggers(ns string) HTTPTriggerInterface
+		KubernetesWatchTriggers(ns string) KubernetesWatchTriggerInterface
+		TimeTriggers(ns string) TimeTriggerInterface
+		MessageQueueTriggers(ns string) MessageQueueTriggerInterface
+		Packages(ns string) PackageInterface
+		FunctionQuotas(ns string) FunctionQuotaInterface
+		FunctionRevisions(ns string) FunctionRevisionInterface
+	}
 )
 
 // Get a kubernetes client using the kubeconfig file at the
@@ -153,6 +167,20 @@ func configureClient(config *rest.Config) {
 				&metav1.ListOptions{},
 				&metav1.DeleteOptions{},
 			)
+			scheme.AddKnownTypes(
+				groupversion,
+				&FunctionQuota{},
+				&FunctionQuotaList{},
+				&metav1.ListOptions{},
+				&metav1.DeleteOptions{},
+			)
+			scheme.AddKnownTypes(
+				groupversion,
+				&FunctionRevision{},
+				&FunctionRevisionList{},
+				&metav1.ListOptions{},
+				&metav1.DeleteOptions{},
+			)
 			return nil
 		})
 	schemeBuilder.AddToScheme(scheme.Scheme)
@@ -211,6 +239,12 @@ func (fc *FissionClient) MessageQueueTriggers(ns string) MessageQueueTriggerInte
 func (fc *FissionClient) Packages(ns string) PackageInterface {
 	return MakePackageInterface(fc.crdClient, ns)
 }
+func (fc *FissionClient) FunctionQuotas(ns string) FunctionQuotaInterface {
+	return MakeFunctionQuotaInterface(fc.crdClient, ns)
+}
+func (fc *FissionClient) FunctionRevisions(ns string) FunctionRevisionInterface {
+	return MakeFunctionRevisionInterface(fc.crdClient, ns)
+}
 
 func (fc *FissionClient) WaitForCRDs() {
 	waitForCRDs(fc.crdClient)
@@ -159,12 +159,48 @@ func EnsureFissionCRDs(clientset *apiextensionsclient.Clientset) error {
 				},
 			},
 		},
+		// Function quotas: limits on the function capacity of a namespace
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "functionquotas.fission.io",
+			},
+			Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
+				Group:   crdGroupName,
+				Version: crdVersion,
+				Scope:   apiextensionsv1beta1.NamespaceScoped,
+				Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
+					Kind:     "FunctionQuota",
+					Plural:   "functionquotas",
+					Singular: "functionquota",
+				},
+			},
+		},
+		// Function revisions: snapshots of functions, for rollbacks
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "functionrevisions.fission.io",
+			},
+			Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
+				Group:   crdGroupName,
+				Version: crdVersion,
+				Scope:   apiextensionsv1beta1.NamespaceScoped,
+				Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
+					Kind:     "FunctionRevision",
+					Plural:   "functionrevisions",
+					Singular: "functionrevision",
+				},
+			},
+		},
 	}
 	for _, crd := range crds {
 		err := ensureCRD(clientset, &crd)
 		if err != nil {
 			return err
 		}
+		err = ensureCRDSchema(clientset, crd.ObjectMeta.Name)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -414,6 +414,114 @@ func kubernetesWatchTriggerTests(crdClient *rest.RESTClient) {
 
 }
 
+func functionQuotaTests(crdClient *rest.RESTClient) {
+	// sample function quota object
+	quota := &FunctionQuota{
+		Metadata: metav1.ObjectMeta{
+			Name:      "hello",
+			Namespace: metav1.NamespaceDefault,
+		},
+		Spec: fission.FunctionQuotaSpec{
+			MaxPods:  10,
+			MaxScale: 3,
+		},
+	}
+
+	// Test function quota CRUD
+	qi := MakeFunctionQuotaInterface(crdClient, metav1.NamespaceDefault)
+
+	// cleanup from old crashed tests, ignore errors
+	qi.Delete(quota.Metadata.Name, nil)
+
+	// create
+	q, err := qi.Create(quota)
+	panicIf(err)
+	if q.Metadata.Name != quota.Metadata.Name {
+		log.Panicf("Bad result from create: %v", q)
+	}
+
+	// read
+	q, err = qi.Get(quota.Metadata.Name)
+	panicIf(err)
+	if q.Spec.MaxPods != quota.Spec.MaxPods {
+		log.Panicf("Bad result from Get: %#v", q)
+	}
+
+	// update
+	quota.Metadata.ResourceVersion = q.Metadata.ResourceVersion
+	quota.Spec.MaxPods = 20
+	q, err = qi.Update(quota)
+	panicIf(err)
+
+	// list
+	ql, err := qi.List(metav1.ListOptions{})
+	panicIf(err)
+	if len(ql.Items) != 1 {
+		log.Panicf("wrong count from function quota list: %v", len(ql.Items))
+	}
+	if ql.Items[0].Spec.MaxPods != q.Spec.MaxPods {
+		log.Panicf("bad object from list: %v", ql.Items[0])
+	}
+
+	// delete
+	err = qi.Delete(q.Metadata.Name, nil)
+	panicIf(err)
+}
+
+func functionRevisionTests(crdClient *rest.RESTClient) {
+	// sample function revision object
+	revision := &FunctionRevision{
+		Metadata: metav1.ObjectMeta{
+			Name:      "hello-1",
+			Namespace: metav1.NamespaceDefault,
+			Labels:    map[string]string{"functionName": "hello"},
+		},
+		Spec: fission.FunctionRevisionSpec{
+			FunctionName: "hello",
+			Revision:     1,
+			Function: fission.FunctionSpec{
+				Environment: fission.EnvironmentReference{
+					Name: "xxx",
+				},
+			},
+		},
+	}
+
+	// Test function revision CRUD
+	ri := MakeFunctionRevisionInterface(crdClient, metav1.NamespaceDefault)
+
+	// cleanup from old crashed tests, ignore errors
+	ri.Delete(revision.Metadata.Name, nil)
+
+	// create
+	r, err := ri.Create(revision)
+	panicIf(err)
+	if r.Metadata.Name != revision.Metadata.Name {
+		log.Panicf("Bad result from create: %v", r)
+	}
+
+	// read
+	r, err = ri.Get(revision.Metadata.Name)
+	panicIf(err)
+	if r.Spec.Revision != revision.Spec.Revision {
+		log.Panicf("Bad result from Get: %#v", r)
+	}
+
+	// list by function
+	rl, err := ri.List(metav1.ListOptions{LabelSelector: "functionName=hello"})
+	panicIf(err)
+	if len(rl.Items) != 1 {
+		log.Panicf("wrong count from function revision list: %v", len(rl.Items))
+	}
+	if rl.Items[0].Spec.FunctionName != revision.Spec.FunctionName {
+		log.Panicf("bad object from list: %v", rl.Items[0])
+	}
+
+	// delete
+	err = ri.Delete(r.Metadata.Name, nil)
+	panicIf(err)
+}
+
 func TestCrd(t *testing.T) {
 	// skip test if no cluster available for testing
 	kubeconfig := os.Getenv("KUBECONFIG")
@@ -442,4 +550,6 @@ func TestCrd(t *testing.T) {
 	environmentTests(crdClient)
 	httpTriggerTests(crdClient)
 	kubernetesWatchTriggerTests(crdClient)
+	functionQuotaTests(crdClient)
+	functionRevisionTests(crdClient)
 }
@@ -0,0 +1,97 @@
+package fake
+
+import (
+	"testing"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission/crd"
+)
+
+var _ crd.FissionClientInterface = &FissionClient{}
+
+func TestFunctionClient(t *testing.T) {
+	fc := MakeFissionClient()
+	fi := fc.Functions(metav1.NamespaceDefault)
+
+	fn := &crd.Function{
+		Metadata: metav1.ObjectMeta{
+			Name:   "hello",
+			Labels: map[string]string{"app": "a"},
+		},
+	}
+	created, err := fi.Create(fn)
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+	if len(created.Metadata.UID) == 0 || len(created.Metadata.ResourceVersion) == 0 {
+		t.Errorf("Expected uid and resource version to be set, got %v", created.Metadata)
+	}
+	if created.Metadata.Namespace != metav1.NamespaceDefault {
+		t.Errorf("Expected namespace %v, got %v", metav1.NamespaceDefault, created.Metadata.Namespace)
+	}
+	if len(fn.Metadata.UID) != 0 {
+		t.Errorf("Create changed its argument")
+	}
+
+	_, err = fi.Create(fn)
+	if !k8s_err.IsAlreadyExists(err) {
+		t.Errorf("Expected already exists error, got %v", err)
+	}
+
+	got, err := fi.Get("hello")
+	if err != nil {
+		t.Fatalf("Error getting function: %v", err)
+	}
+	got.Spec.Package.FunctionName = "main"
+	updated, err := fi.Update(got)
+	if err != nil {
+		t.Fatalf("Error updating function: %v", err)
+	}
+	if updated.Metadata.ResourceVersion == got.Metadata.ResourceVersion {
+		t.Errorf("Expected update to change the resource version")
+	}
+	if updated.Metadata.UID != created.Metadata.UID {
+		t.Errorf("Expected update to keep uid %v, got %v", created.Metadata.UID, updated.Metadata.UID)
+	}
+
+	// got has an old resource version now
+	_, err = fi.Update(got)
+	if !k8s_err.IsConflict(err) {
+		t.Errorf("Expected conflict error, got %v", err)
+	}
+
+	_, err = fc.Functions("other").Create(&crd.Function{Metadata: metav1.ObjectMeta{Name: "other"}})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+	for _, test := range []struct {
+		ns       string
+		selector string
+		count    int
+	}{
+		{metav1.NamespaceDefault, "", 1},
+		{metav1.NamespaceAll, "", 2},
+		{metav1.NamespaceAll, "app=a", 1},
+		{metav1.NamespaceAll, "app=b", 0},
+	} {
+		fl, err := fc.Functions(test.ns).List(metav1.ListOptions{LabelSelector: test.selector})
+		if err != nil {
+			t.Fatalf("Error listing functions: %v", err)
+		}
+		if len(fl.Items) != test.count {
+			t.Errorf("Expected %v functions in %q with selector %q, got %v",
+				test.count, test.ns, test.selector, len(fl.Items))
+		}
+	}
+
+	err = fi.Delete("hello", nil)
+	if err != nil {
+		t.Fatalf("Error deleting function: %v", err)
+	}
+	_, err = fi.Get("hello")
+	if !k8s_err.IsNotFound(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
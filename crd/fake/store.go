@@ -0,0 +1,265 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type (
+	// object is implemented by all Fission CRD types.
+	object interface {
+		GetObjectMeta() metav1.Object
+	}
+
+	// store keeps objects as JSON, so that callers can't change stored
+	// objects through the pointers they pass in or get back.
+	store struct {
+		lock            sync.Mutex
+		resourceVersion int
+		uid             int
+		objects         map[string]map[string][]byte // resource -> namespace/name -> object
+	}
+)
+
+func makeStore() *store {
+	return &store{
+		objects: make(map[string]map[string][]byte),
+	}
+}
+
+func storeKey(namespace string, name string) string {
+	return namespace + "/" + name
+}
+
+func groupResource(resource string) schema.GroupResource {
+	return schema.GroupResource{Group: "fission.io", Resource: resource}
+}
+
+// copyObject deep copies in to out through JSON.
+func copyObject(in interface{}, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func (s *store) nextResourceVersion() string {
+	s.resourceVersion++
+	return strconv.Itoa(s.resourceVersion)
+}
+
+// create stores a copy of obj in out, filling in the server set
+// metadata, and returns an error if the object exists.
+func (s *store) create(resource string, namespace string, obj object, out object) error {
+	err := copyObject(obj, out)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m := out.GetObjectMeta()
+	if len(m.GetNamespace()) == 0 {
+		m.SetNamespace(namespace)
+	}
+	key := storeKey(m.GetNamespace(), m.GetName())
+	if _, ok := s.objects[resource][key]; ok {
+		return k8s_err.NewAlreadyExists(groupResource(resource), m.GetName())
+	}
+
+	s.uid++
+	m.SetUID(types.UID(fmt.Sprintf("fake-uid-%v", s.uid)))
+	m.SetResourceVersion(s.nextResourceVersion())
+	m.SetCreationTimestamp(metav1.Now())
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.objects[resource]; !ok {
+		s.objects[resource] = make(map[string][]byte)
+	}
+	s.objects[resource][key] = b
+	return nil
+}
+
+// get copies the stored object into out.
+func (s *store) get(resource string, namespace string, name string, out object) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.objects[resource][storeKey(namespace, name)]
+	if !ok {
+		return k8s_err.NewNotFound(groupResource(resource), name)
+	}
+	return json.Unmarshal(b, out)
+}
+
+// update replaces a stored object with a copy of obj, which it also
+// copies into out with a new resource version. Like the API server, it
+// refuses updates based on an old resource version.
+func (s *store) update(resource string, namespace string, obj object, out object) error {
+	err := copyObject(obj, out)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m := out.GetObjectMeta()
+	if len(m.GetNamespace()) == 0 {
+		m.SetNamespace(namespace)
+	}
+	key := storeKey(m.GetNamespace(), m.GetName())
+	b, ok := s.objects[resource][key]
+	if !ok {
+		return k8s_err.NewNotFound(groupResource(resource), m.GetName())
+	}
+
+	var stored metav1.ObjectMeta
+	err = json.Unmarshal(b, &struct {
+		Metadata *metav1.ObjectMeta `json:"metadata"`
+	}{&stored})
+	if err != nil {
+		return err
+	}
+	if len(m.GetResourceVersion()) > 0 && m.GetResourceVersion() != stored.ResourceVersion {
+		return k8s_err.NewConflict(groupResource(resource), m.GetName(),
+			fmt.Errorf("resource version %v is not the latest, %v", m.GetResourceVersion(), stored.ResourceVersion))
+	}
+	m.SetUID(stored.UID)
+	m.SetCreationTimestamp(stored.CreationTimestamp)
+	m.SetResourceVersion(s.nextResourceVersion())
+
+	b, err = json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	s.objects[resource][key] = b
+	return nil
+}
+
+// delete deletes a stored object, and the objects it owns, unless the
+// propagation policy of options orphans them.
+func (s *store) delete(resource string, namespace string, name string, options *metav1.DeleteOptions) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := storeKey(namespace, name)
+	b, ok := s.objects[resource][key]
+	if !ok {
+		return k8s_err.NewNotFound(groupResource(resource), name)
+	}
+	delete(s.objects[resource], key)
+
+	if options != nil && options.PropagationPolicy != nil &&
+		*options.PropagationPolicy == metav1.DeletePropagationOrphan {
+		return nil
+	}
+	m, err := objectMeta(b)
+	if err != nil {
+		return err
+	}
+	s.deleteDependents(namespace, m.UID)
+	return nil
+}
+
+// deleteDependents deletes the objects of a namespace owned by uid, and
+// the objects they own.
+func (s *store) deleteDependents(namespace string, uid types.UID) {
+	for _, objects := range s.objects {
+		for key, b := range objects {
+			m, err := objectMeta(b)
+			if err != nil || m.Namespace != namespace {
+				continue
+			}
+			for _, owner := range m.OwnerReferences {
+				if owner.UID == uid {
+					delete(objects, key)
+					s.deleteDependents(namespace, m.UID)
+					break
+				}
+			}
+		}
+	}
+}
+
+func objectMeta(b []byte) (*metav1.ObjectMeta, error) {
+	var m metav1.ObjectMeta
+	err := json.Unmarshal(b, &struct {
+		Metadata *metav1.ObjectMeta `json:"metadata"`
+	}{&m})
+	return &m, err
+}
+
+// list calls add with each stored object of a namespace, or of all
+// namespaces if namespace is empty, in namespace/name order.
+func (s *store) list(resource string, namespace string, add func(b []byte) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := make([]string, 0, len(s.objects[resource]))
+	for key := range s.objects[resource] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		b := s.objects[resource][key]
+		var m metav1.ObjectMeta
+		err := json.Unmarshal(b, &struct {
+			Metadata *metav1.ObjectMeta `json:"metadata"`
+		}{&m})
+		if err != nil {
+			return err
+		}
+		if len(namespace) > 0 && m.Namespace != namespace {
+			continue
+		}
+		err = add(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches returns true if obj matches the label selector of opts.
+func matches(opts metav1.ListOptions, obj object) (bool, error) {
+	if len(opts.LabelSelector) == 0 {
+		return true, nil
+	}
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(obj.GetObjectMeta().GetLabels())), nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+type (
+	FunctionRevisionInterface interface {
+		Create(*FunctionRevision) (*FunctionRevision, error)
+		Get(name string) (*FunctionRevision, error)
+		Update(*FunctionRevision) (*FunctionRevision, error)
+		Delete(name string, options *metav1.DeleteOptions) error
+		List(opts metav1.ListOptions) (*FunctionRevisionList, error)
+		Watch(opts metav1.ListOptions) (watch.Interface, error)
+	}
+
+	functionRevisionClient struct {
+		client    *rest.RESTClient
+		namespace string
+	}
+)
+
+func MakeFunctionRevisionInterface(crdClient *rest.RESTClient, namespace string) FunctionRevisionInterface {
+	return &functionRevisionClient{
+		client:    crdClient,
+		namespace: namespace,
+	}
+}
+
+func (fc *functionRevisionClient) Create(f *FunctionRevision) (*FunctionRevision, error) {
+	var result FunctionRevision
+	err := fc.client.Post().
+		Resource("functionrevisions").
+		Namespace(fc.namespace).
+		Body(f).
+		Do().Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (fc *functionRevisionClient) Get(name string) (*FunctionRevision, error) {
+	var result FunctionRevision
+	err := fc.client.Get().
+		Resource("functionrevisions").
+		Namespace(fc.namespace).
+		Name(name).
+		Do().Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (fc *functionRevisionClient) Update(f *FunctionRevision) (*FunctionRevision, error) {
+	var result FunctionRevision
+	err := fc.client.Put().
+		Resource("functionrevisions").
+		Namespace(fc.namespace).
+		Name(f.Metadata.Name).
+		Body(f).
+		Do().Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (fc *functionRevisionClient) Delete(name string, opts *metav1.DeleteOptions) error {
+	return fc.client.Delete().
+		Namespace(fc.namespace).
+		Resource("functionrevisions").
+		Name(name).
+		Body(opts).
+		Do().
+		Error()
+}
+
+func (fc *functionRevisionClient) List(opts metav1.ListOptions) (*FunctionRevisionList, error) {
+	var result FunctionRevisionList
+	err := fc.client.Get().
+		Namespace(fc.namespace).
+		Resource("functionrevisions").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (fc *functionRevisionClient) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	return fc.client.Get().
+		Prefix("watch").
+		Namespace(fc.namespace).
+		Resource("functionrevisions").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fission/fission"
+)
+
+// The CRDs carry OpenAPI v3 schemas, so that the API server rejects
+// objects with fields of the wrong type, missing required fields, or
+// values out of range, whichever client creates them. The schemas are
+// generated from the Go types, with the constraints below. Kubernetes
+// types, such as pod specs and metadata, are left to Kubernetes to
+// validate.
+//
+// API servers that don't validate custom resources ignore the schemas.
+
+// schemaProps is the part of an OpenAPI v3 schema that Fission uses.
+type schemaProps struct {
+	Type       string                  `json:"type,omitempty"`
+	Format     string                  `json:"format,omitempty"`
+	Properties map[string]*schemaProps `json:"properties,omitempty"`
+	Required   []string                `json:"required,omitempty"`
+	Items      *schemaProps            `json:"items,omitempty"`
+	Enum       []interface{}           `json:"enum,omitempty"`
+	Minimum    *float64                `json:"minimum,omitempty"`
+	Maximum    *float64                `json:"maximum,omitempty"`
+	MinLength  *int64                  `json:"minLength,omitempty"`
+	Nullable   bool                    `json:"nullable,omitempty"`
+}
+
+// bounds constrains a number, or the length of a string.
+type bounds struct {
+	min       *float64
+	max       *float64
+	minLength *int64
+}
+
+func atLeast(min float64) bounds {
+	return bounds{min: &min}
+}
+
+func between(min float64, max float64) bounds {
+	return bounds{min: &min, max: &max}
+}
+
+func nonEmpty() bounds {
+	var one int64 = 1
+	return bounds{minLength: &one}
+}
+
+// Ports are optional; zero means the default port.
+var portBounds = between(0, 65535)
+
+var (
+	// schemaEnums are the values of string types. Empty strings mean
+	// the default.
+	schemaEnums = map[reflect.Type][]string{
+		reflect.TypeOf(fission.ArchiveType("")):  {"", string(fission.ArchiveTypeLiteral), string(fission.ArchiveTypeUrl)},
+		reflect.TypeOf(fission.ChecksumType("")): {"", string(fission.ChecksumTypeSHA256)},
+		reflect.TypeOf(fission.BuildStatus("")): {"", fission.BuildStatusPending, fission.BuildStatusRunning,
+			fission.BuildStatusSucceeded, fission.BuildStatusFailed, fission.BuildStatusNone},
+		reflect.TypeOf(fission.ExecutorType("")): {"", fission.ExecutorTypePoolmgr, fission.ExecutorTypeNewdeploy,
+			fission.ExecutorTypeContainer, fission.ExecutorTypeJob},
+		reflect.TypeOf(fission.StrategyType("")): {"", fission.StrategyTypeExecution},
+		reflect.TypeOf(fission.AllowedFunctionsPerContainer("")): {"", fission.AllowedFunctionsPerContainerSingle,
+			fission.AllowedFunctionsPerContainerInfinite},
+		reflect.TypeOf(fission.FunctionReferenceType("")): {"", fission.FunctionReferenceTypeFunctionName},
+	}
+
+	// schemaRequired are the fields of types that must be there.
+	schemaRequired = map[reflect.Type][]string{
+		reflect.TypeOf(fission.Runtime{}):                    {"image"},
+		reflect.TypeOf(fission.PackageSpec{}):                {"environment"},
+		reflect.TypeOf(fission.PackageRef{}):                 {"name"},
+		reflect.TypeOf(fission.SecretReference{}):            {"name"},
+		reflect.TypeOf(fission.ConfigMapReference{}):         {"name"},
+		reflect.TypeOf(fission.FunctionContainer{}):          {"image"},
+		reflect.TypeOf(fission.FunctionReference{}):          {"name"},
+		reflect.TypeOf(fission.HTTPTriggerSpec{}):            {"functionref"},
+		reflect.TypeOf(fission.KubernetesWatchTriggerSpec{}): {"functionref"},
+		reflect.TypeOf(fission.MessageQueueTriggerSpec{}):    {"functionref", "messageQueueType", "topic"},
+		reflect.TypeOf(fission.TimeTriggerSpec{}):            {"cron", "functionref"},
+		reflect.TypeOf(fission.FunctionRevisionSpec{}):       {"functionName", "revision", "function"},
+	}
+
+	// schemaBounds constrain fields of types, by JSON name.
+	schemaBounds = map[reflect.Type]map[string]bounds{
+		reflect.TypeOf(fission.Runtime{}): {
+			"image":                nonEmpty(),
+			"loadendpointport":     portBounds,
+			"functionendpointport": portBounds,
+		},
+		reflect.TypeOf(fission.EnvironmentSpec{}): {
+			"version":  between(0, 3),
+			"poolsize": atLeast(0),
+		},
+		reflect.TypeOf(fission.FunctionContainer{}): {
+			"image": nonEmpty(),
+			"port":  portBounds,
+		},
+		reflect.TypeOf(fission.ExecutionStrategy{}): {
+			"MinScale":                atLeast(0),
+			"MaxScale":                atLeast(0),
+			"TargetCPUPercent":        atLeast(0),
+			"TargetRequestsPerSecond": atLeast(0),
+			"TargetInFlightRequests":  atLeast(0),
+			"MaxConcurrency":          atLeast(0),
+		},
+		reflect.TypeOf(fission.FunctionSpec{}): {
+			"functionTimeout": atLeast(0),
+		},
+		reflect.TypeOf(fission.FunctionReference{}): {
+			"name": nonEmpty(),
+		},
+		reflect.TypeOf(fission.TimeTriggerSpec{}): {
+			"cron": nonEmpty(),
+		},
+		reflect.TypeOf(fission.FunctionQuotaSpec{}): {
+			"maxpods":  atLeast(0),
+			"maxscale": atLeast(0),
+		},
+		reflect.TypeOf(fission.FunctionRevisionSpec{}): {
+			"revision":   atLeast(1),
+			"rollbackOf": atLeast(0),
+		},
+	}
+
+	// crdTypes are the types of the objects of the Fission CRDs, by
+	// CRD name.
+	crdTypes = map[string]reflect.Type{
+		"functions.fission.io":               reflect.TypeOf(Function{}),
+		"environments.fission.io":            reflect.TypeOf(Environment{}),
+		"httptriggers.fission.io":            reflect.TypeOf(HTTPTrigger{}),
+		"kuberneteswatchtriggers.fission.io": reflect.TypeOf(KubernetesWatchTrigger{}),
+		"timetriggers.fission.io":            reflect.TypeOf(TimeTrigger{}),
+		"messagequeuetriggers.fission.io":    reflect.TypeOf(MessageQueueTrigger{}),
+		"packages.fission.io":                reflect.TypeOf(Package{}),
+		"functionquotas.fission.io":          reflect.TypeOf(FunctionQuota{}),
+		"functionrevisions.fission.io":       reflect.TypeOf(FunctionRevision{}),
+	}
+)
+
+// isKubernetesType returns true for types defined by Kubernetes.
+func isKubernetesType(t reflect.Type) bool {
+	return strings.HasPrefix(t.PkgPath(), "k8s.io/")
+}
+
+// typeSchema returns the schema of the JSON encoding of a Go type.
+func typeSchema(t reflect.Type) *schemaProps {
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := typeSchema(t.Elem())
+		s.Nullable = true
+		return s
+
+	case reflect.Struct:
+		if isKubernetesType(t) {
+			return &schemaProps{Type: "object"}
+		}
+		s := &schemaProps{
+			Type:       "object",
+			Properties: make(map[string]*schemaProps),
+			Required:   schemaRequired[t],
+		}
+		addFields(s, t)
+		return s
+
+	case reflect.Map:
+		return &schemaProps{Type: "object", Nullable: true}
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schemaProps{Type: "string", Format: "byte"}
+		}
+		return &schemaProps{Type: "array", Items: typeSchema(t.Elem()), Nullable: true}
+
+	case reflect.String:
+		s := &schemaProps{Type: "string"}
+		for _, v := range schemaEnums[t] {
+			s.Enum = append(s.Enum, v)
+		}
+		return s
+
+	case reflect.Bool:
+		return &schemaProps{Type: "boolean"}
+
+	case reflect.Int32, reflect.Uint32, reflect.Int16, reflect.Uint16, reflect.Int8, reflect.Uint8:
+		return &schemaProps{Type: "integer", Format: "int32"}
+
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &schemaProps{Type: "integer", Format: "int64"}
+
+	case reflect.Float32, reflect.Float64:
+		return &schemaProps{Type: "number"}
+	}
+	// Fission types don't have other kinds of fields
+	return &schemaProps{Type: "object"}
+}
+
+// addFields adds the properties of a struct's fields to its schema,
+// following the rules of encoding/json.
+func addFields(s *schemaProps, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" || (len(f.PkgPath) > 0 && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && len(name) == 0 && f.Type.Kind() == reflect.Struct {
+			// embedded, as in metav1.TypeMeta
+			addFields(s, f.Type)
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		prop := typeSchema(f.Type)
+		if b, ok := schemaBounds[t][name]; ok {
+			prop.Minimum, prop.Maximum, prop.MinLength = b.min, b.max, b.minLength
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// objectSchema returns the schema of the objects of a CRD.
+func objectSchema(t reflect.Type) *schemaProps {
+	s := typeSchema(t)
+	s.Required = append(s.Required, "spec")
+	return s
+}
+
+// ensureCRDSchema sets the validation schema of a Fission CRD, replacing
+// any older one.
+func ensureCRDSchema(clientset *apiextensionsclient.Clientset, name string) error {
+	t, ok := crdTypes[name]
+	if !ok {
+		return nil
+	}
+	// The CRD types of this version of the client don't have the
+	// validation field yet, so it's patched in.
+	patch, err := json.Marshal([]map[string]interface{}{{
+		"op":   "add",
+		"path": "/spec/validation",
+		"value": map[string]interface{}{
+			"openAPIV3Schema": objectSchema(t),
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	return clientset.ApiextensionsV1beta1().RESTClient().
+		Patch(types.JSONPatchType).
+		Resource("customresourcedefinitions").
+		Name(name).
+		Body(patch).
+		Do().
+		Error()
+}
@@ -0,0 +1,350 @@
+package crd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/fission/fission"
+)
+
+// validateSchema checks a decoded JSON value against the parts of
+// OpenAPI v3 that schemaProps has, and returns the errors.
+func validateSchema(s *schemaProps, path string, v interface{}) []string {
+	if v == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []string{fmt.Sprintf("%v: null", path)}
+	}
+
+	var errs []string
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%v: expected object, got %v", path, v)}
+		}
+		for _, r := range s.Required {
+			if _, ok := obj[r]; !ok {
+				errs = append(errs, fmt.Sprintf("%v.%v: required", path, r))
+			}
+		}
+		for k, fv := range obj {
+			if ps, ok := s.Properties[k]; ok {
+				errs = append(errs, validateSchema(ps, path+"."+k, fv)...)
+			}
+		}
+		return errs
+
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%v: expected array, got %v", path, v)}
+		}
+		for i, iv := range arr {
+			errs = append(errs, validateSchema(s.Items, fmt.Sprintf("%v[%v]", path, i), iv)...)
+		}
+		return errs
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%v: expected string, got %v", path, v)}
+		}
+		if s.MinLength != nil && int64(len(str)) < *s.MinLength {
+			errs = append(errs, fmt.Sprintf("%v: shorter than %v", path, *s.MinLength))
+		}
+		if len(s.Enum) > 0 {
+			found := false
+			for _, e := range s.Enum {
+				if e == str {
+					found = true
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Sprintf("%v: %q not in %v", path, str, s.Enum))
+			}
+		}
+		return errs
+
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok || (s.Type == "integer" && n != float64(int64(n))) {
+			return []string{fmt.Sprintf("%v: expected %v, got %v", path, s.Type, v)}
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			errs = append(errs, fmt.Sprintf("%v: %v less than %v", path, n, *s.Minimum))
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			errs = append(errs, fmt.Sprintf("%v: %v more than %v", path, n, *s.Maximum))
+		}
+		return errs
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{fmt.Sprintf("%v: expected boolean, got %v", path, v)}
+		}
+		return nil
+	}
+	return []string{fmt.Sprintf("%v: unknown type %q", path, s.Type)}
+}
+
+// checkTyped checks that every node of a schema has a type, as
+// structural schemas must.
+func checkTyped(t *testing.T, s *schemaProps, path string) {
+	if len(s.Type) == 0 {
+		t.Errorf("%v: schema has no type", path)
+	}
+	for k, ps := range s.Properties {
+		checkTyped(t, ps, path+"."+k)
+	}
+	if s.Items != nil {
+		checkTyped(t, s.Items, path+"[]")
+	}
+}
+
+func toJSONValue(t *testing.T, obj interface{}) interface{} {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Error marshaling %#v: %v", obj, err)
+	}
+	var v interface{}
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		t.Fatalf("Error unmarshaling %v: %v", string(b), err)
+	}
+	return v
+}
+
+func TestCRDSchemas(t *testing.T) {
+	for name, typ := range crdTypes {
+		checkTyped(t, objectSchema(typ), name)
+
+		// the schema must be serializable for the patch
+		_, err := json.Marshal(objectSchema(typ))
+		if err != nil {
+			t.Errorf("Error marshaling schema of %v: %v", name, err)
+		}
+	}
+
+	meta := metav1.ObjectMeta{Name: "hello", Namespace: metav1.NamespaceDefault}
+	fnRef := fission.FunctionReference{Type: fission.FunctionReferenceTypeFunctionName, Name: "hello"}
+	fnSpec := fission.FunctionSpec{
+		Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: meta.Namespace},
+		Package: fission.FunctionPackageRef{
+			PackageRef: fission.PackageRef{Name: "hello-pkg", Namespace: meta.Namespace},
+		},
+		InvokeStrategy: fission.InvokeStrategy{
+			StrategyType: fission.StrategyTypeExecution,
+			ExecutionStrategy: fission.ExecutionStrategy{
+				ExecutorType:     fission.ExecutorTypePoolmgr,
+				MaxScale:         1,
+				TargetCPUPercent: 80,
+			},
+		},
+	}
+
+	// objects as the CLI creates them
+	valid := map[string]interface{}{
+		"environments.fission.io": &Environment{
+			Metadata: meta,
+			Spec: fission.EnvironmentSpec{
+				Version:  2,
+				Runtime:  fission.Runtime{Image: "fission/node-env"},
+				Poolsize: 3,
+			},
+		},
+		"packages.fission.io": &Package{
+			Metadata: meta,
+			Spec: fission.PackageSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: meta.Namespace},
+				Deployment: fission.Archive{
+					Type:    fission.ArchiveTypeLiteral,
+					Literal: []byte("module.exports = function(context) {}"),
+				},
+			},
+			Status: fission.PackageStatus{BuildStatus: fission.BuildStatusNone},
+		},
+		"functions.fission.io": &Function{Metadata: meta, Spec: fnSpec},
+		"functions.fission.io/container": &Function{
+			Metadata: meta,
+			Spec: fission.FunctionSpec{
+				InvokeStrategy: fission.InvokeStrategy{
+					ExecutionStrategy: fission.ExecutionStrategy{ExecutorType: fission.ExecutorTypeContainer},
+				},
+				Container: &fission.FunctionContainer{Image: "hello", Port: 8080},
+			},
+		},
+		"httptriggers.fission.io": &HTTPTrigger{
+			Metadata: meta,
+			Spec:     fission.HTTPTriggerSpec{RelativeURL: "/hello", Method: "GET", FunctionReference: fnRef},
+		},
+		"kuberneteswatchtriggers.fission.io": &KubernetesWatchTrigger{
+			Metadata: meta,
+			Spec:     fission.KubernetesWatchTriggerSpec{Namespace: "default", Type: "pod", FunctionReference: fnRef},
+		},
+		"timetriggers.fission.io": &TimeTrigger{
+			Metadata: meta,
+			Spec:     fission.TimeTriggerSpec{Cron: "@every 1m", FunctionReference: fnRef},
+		},
+		"messagequeuetriggers.fission.io": &MessageQueueTrigger{
+			Metadata: meta,
+			Spec: fission.MessageQueueTriggerSpec{
+				FunctionReference: fnRef,
+				MessageQueueType:  "nats-streaming",
+				Topic:             "hello",
+			},
+		},
+		"functionquotas.fission.io": &FunctionQuota{
+			Metadata: meta,
+			Spec:     fission.FunctionQuotaSpec{MaxPods: 10},
+		},
+		"functionrevisions.fission.io": &FunctionRevision{
+			Metadata: meta,
+			Spec:     fission.FunctionRevisionSpec{FunctionName: "hello", Revision: 1, Function: fnSpec},
+		},
+	}
+	for name, obj := range valid {
+		typ := reflect.TypeOf(obj).Elem()
+		errs := validateSchema(objectSchema(typ), name, toJSONValue(t, obj))
+		if len(errs) > 0 {
+			t.Errorf("Expected %v to be valid, got %v", name, errs)
+		}
+	}
+
+	// objects as users might write them in specs
+	invalid := []struct {
+		name string
+		typ  reflect.Type
+		obj  string
+	}{
+		{"unknown executor", reflect.TypeOf(Function{}),
+			`{"metadata": {}, "spec": {"InvokeStrategy": {"ExecutionStrategy": {"ExecutorType": "foo"}}}}`},
+		{"negative scale", reflect.TypeOf(Function{}),
+			`{"metadata": {}, "spec": {"InvokeStrategy": {"ExecutionStrategy": {"MinScale": -1}}}}`},
+		{"cpu percent", reflect.TypeOf(Function{}),
+			`{"metadata": {}, "spec": {"InvokeStrategy": {"ExecutionStrategy": {"TargetCPUPercent": -1}}}}`},
+		{"container port", reflect.TypeOf(Function{}),
+			`{"metadata": {}, "spec": {"container": {"image": "hello", "port": 70000}}}`},
+		{"missing spec", reflect.TypeOf(Function{}),
+			`{"metadata": {}}`},
+		{"archive type", reflect.TypeOf(Package{}),
+			`{"metadata": {}, "spec": {"environment": {"name": "nodejs"}, "deployment": {"type": "ftp"}}}`},
+		{"build status", reflect.TypeOf(Package{}),
+			`{"metadata": {}, "spec": {"environment": {"name": "nodejs"}}, "status": {"buildstatus": "done"}}`},
+		{"missing image", reflect.TypeOf(Environment{}),
+			`{"metadata": {}, "spec": {"version": 1, "runtime": {}}}`},
+		{"pool size", reflect.TypeOf(Environment{}),
+			`{"metadata": {}, "spec": {"version": 2, "runtime": {"image": "node"}, "poolsize": "three"}}`},
+		{"env version", reflect.TypeOf(Environment{}),
+			`{"metadata": {}, "spec": {"version": 4, "runtime": {"image": "node"}}}`},
+		{"missing cron", reflect.TypeOf(TimeTrigger{}),
+			`{"metadata": {}, "spec": {"functionref": {"name": "hello"}}}`},
+		{"missing function name", reflect.TypeOf(HTTPTrigger{}),
+			`{"metadata": {}, "spec": {"relativeurl": "/hello", "functionref": {"type": "name"}}}`},
+		{"revision", reflect.TypeOf(FunctionRevision{}),
+			`{"metadata": {}, "spec": {"functionName": "hello", "revision": 0, "function": {}}}`},
+	}
+	for _, test := range invalid {
+		var v interface{}
+		err := json.Unmarshal([]byte(test.obj), &v)
+		if err != nil {
+			t.Fatalf("Error unmarshaling %v: %v", test.name, err)
+		}
+		errs := validateSchema(objectSchema(test.typ), test.name, v)
+		if len(errs) == 0 {
+			t.Errorf("Expected %v to be rejected", test.name)
+		}
+	}
+}
+
+// requireCluster skips the test unless KUBECONFIG points at a cluster,
+// and returns a client for the Fission CRDs on it, with their schemas
+// installed.
+func requireCluster(t *testing.T) *rest.RESTClient {
+	if len(os.Getenv("KUBECONFIG")) == 0 {
+		t.Skip("no kubernetes cluster")
+	}
+	config, _, apiExtClient, err := GetKubernetesClient()
+	if err != nil {
+		t.Fatalf("Error getting kubernetes client: %v", err)
+	}
+	err = EnsureFissionCRDs(apiExtClient)
+	if err != nil {
+		t.Fatalf("Error creating CRDs: %v", err)
+	}
+	crdClient, err := GetCrdClient(config)
+	if err != nil {
+		t.Fatalf("Error getting CRD client: %v", err)
+	}
+	err = waitForCRDs(crdClient)
+	if err != nil {
+		t.Fatalf("Error waiting for CRDs: %v", err)
+	}
+	return crdClient
+}
+
+// TestCRDSchemasOnCluster checks that the API server accepts the
+// schemas and enforces them, rather than only validateSchema above.
+func TestCRDSchemasOnCluster(t *testing.T) {
+	crdClient := requireCluster(t)
+	fi := MakeFunctionInterface(crdClient, metav1.NamespaceDefault)
+
+	makeFunction := func(name string, es fission.ExecutionStrategy) *Function {
+		return &Function{
+			TypeMeta: metav1.TypeMeta{Kind: "Function", APIVersion: "fission.io/v1"},
+			Metadata: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+			Spec: fission.FunctionSpec{
+				Environment: fission.EnvironmentReference{Name: "nodejs", Namespace: metav1.NamespaceDefault},
+				Package: fission.FunctionPackageRef{
+					PackageRef: fission.PackageRef{Name: "hello-pkg", Namespace: metav1.NamespaceDefault},
+				},
+				InvokeStrategy: fission.InvokeStrategy{
+					StrategyType:      fission.StrategyTypeExecution,
+					ExecutionStrategy: es,
+				},
+			},
+		}
+	}
+
+	// CPU targets are relative to the request, so above 100 is valid
+	valid := makeFunction("schema-valid", fission.ExecutionStrategy{
+		ExecutorType:     fission.ExecutorTypeNewdeploy,
+		MaxScale:         3,
+		TargetCPUPercent: 150,
+	})
+	fi.Delete(valid.Metadata.Name, nil)
+	_, err := fi.Create(valid)
+	if err != nil {
+		t.Errorf("Expected function with a 150%% CPU target to be valid, got %v", err)
+	} else {
+		fi.Delete(valid.Metadata.Name, nil)
+	}
+
+	// the API server may take a moment to start using the schema
+	invalid := makeFunction("schema-invalid", fission.ExecutionStrategy{
+		ExecutorType: fission.ExecutorTypeNewdeploy,
+		MinScale:     -1,
+	})
+	fi.Delete(invalid.Metadata.Name, nil)
+	for start := time.Now(); ; time.Sleep(time.Second) {
+		_, err = fi.Create(invalid)
+		if k8serrors.IsInvalid(err) {
+			break
+		}
+		if err == nil {
+			fi.Delete(invalid.Metadata.Name, nil)
+		}
+		if time.Since(start) > 30*time.Second {
+			t.Fatalf("Expected function with a negative scale to be rejected, got %v", err)
+		}
+	}
+}
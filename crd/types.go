@@ -128,8 +128,68 @@ type (
 
 		Items []MessageQueueTrigger `json:"items"`
 	}
+
+	// Function quotas, limiting the function capacity of a namespace
+	FunctionQuota struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        metav1.ObjectMeta         `json:"metadata"`
+		Spec            fission.FunctionQuotaSpec `json:"spec"`
+	}
+	FunctionQuotaList struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        metav1.ListMeta `json:"metadata"`
+
+		Items []FunctionQuota `json:"items"`
+	}
+
+	// Function revisions, the history of a function's specs
+	FunctionRevision struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        metav1.ObjectMeta            `json:"metadata"`
+		Spec            fission.FunctionRevisionSpec `json:"spec"`
+	}
+	FunctionRevisionList struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        metav1.ListMeta `json:"metadata"`
+
+		Items []FunctionRevision `json:"items"`
+	}
 )
 
+// Resources is a set of Fission objects of all kinds, such as the specs
+// of an app, that are validated or applied together and may refer to
+// each other.
+type Resources struct {
+	Packages                []Package                `json:"packages,omitempty"`
+	Functions               []Function               `json:"functions,omitempty"`
+	Environments            []Environment            `json:"environments,omitempty"`
+	HTTPTriggers            []HTTPTrigger            `json:"httpTriggers,omitempty"`
+	KubernetesWatchTriggers []KubernetesWatchTrigger `json:"kubernetesWatchTriggers,omitempty"`
+	TimeTriggers            []TimeTrigger            `json:"timeTriggers,omitempty"`
+	MessageQueueTriggers    []MessageQueueTrigger    `json:"messageQueueTriggers,omitempty"`
+}
+
+// ApplyRequest asks the controller to make a deployment's objects match
+// Resources. Objects are found by the deployment UID annotation; the
+// ones not in Resources are deleted if Delete is set. Resources may be
+// in several namespaces, but only the namespaces they're in are
+// searched for objects to delete.
+type ApplyRequest struct {
+	DeploymentName string    `json:"deploymentName"`
+	DeploymentUID  string    `json:"deploymentUID"`
+	Resources      Resources `json:"resources"`
+	Delete         bool      `json:"delete,omitempty"`
+}
+
+// Backup has all the Fission objects of a cluster. Archives that were
+// in the storage service are saved along with it, and referred to as
+// archive://<sha256 of the archive>.
+type Backup struct {
+	Resources         Resources          `json:"resources"`
+	FunctionQuotas    []FunctionQuota    `json:"functionQuotas,omitempty"`
+	FunctionRevisions []FunctionRevision `json:"functionRevisions,omitempty"`
+}
+
 // Each CRD type needs:
 //   GetObjectKind (to satisfy the Object interface)
 //
@@ -160,6 +220,12 @@ func (w *MessageQueueTrigger) GetObjectKind() schema.ObjectKind {
 func (w *Package) GetObjectKind() schema.ObjectKind {
 	return &w.TypeMeta
 }
+func (q *FunctionQuota) GetObjectKind() schema.ObjectKind {
+	return &q.TypeMeta
+}
+func (r *FunctionRevision) GetObjectKind() schema.ObjectKind {
+	return &r.TypeMeta
+}
 
 func (f *Function) GetObjectMeta() metav1.Object {
 	return &f.Metadata
@@ -182,6 +248,12 @@ func (w *MessageQueueTrigger) GetObjectMeta() metav1.Object {
 func (w *Package) GetObjectMeta() metav1.Object {
 	return &w.Metadata
 }
+func (q *FunctionQuota) GetObjectMeta() metav1.Object {
+	return &q.Metadata
+}
+func (r *FunctionRevision) GetObjectMeta() metav1.Object {
+	return &r.Metadata
+}
 
 func (fl *FunctionList) GetObjectKind() schema.ObjectKind {
 	return &fl.TypeMeta
@@ -204,6 +276,12 @@ func (wl *MessageQueueTriggerList) GetObjectKind() schema.ObjectKind {
 func (wl *PackageList) GetObjectKind() schema.ObjectKind {
 	return &wl.TypeMeta
 }
+func (ql *FunctionQuotaList) GetObjectKind() schema.ObjectKind {
+	return &ql.TypeMeta
+}
+func (rl *FunctionRevisionList) GetObjectKind() schema.ObjectKind {
+	return &rl.TypeMeta
+}
 
 func (fl *FunctionList) GetListMeta() metav1.List {
 	return &fl.Metadata
@@ -226,3 +304,9 @@ func (wl *MessageQueueTriggerList) GetListMeta() metav1.List {
 func (wl *PackageList) GetListMeta() metav1.List {
 	return &wl.Metadata
 }
+func (ql *FunctionQuotaList) GetListMeta() metav1.List {
+	return &ql.Metadata
+}
+func (rl *FunctionRevisionList) GetListMeta() metav1.List {
+	return &rl.Metadata
+}
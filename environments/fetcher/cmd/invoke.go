@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/executor/batchjob"
+)
+
+// invokeFunction runs a batch invocation: it specializes the runtime,
+// calls the function once with the request body in bodyFile, and
+// reports the response to the executor at resultUrl, authenticated with
+// the token in tokenFile.
+func invokeFunction(loadPayload *string, bodyFile string, contentType string, resultUrl string, tokenFile string) {
+	result := &batchjob.InvocationResult{}
+
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		log.Fatalf("Error reading result token: %v", err)
+	}
+
+	err = specializeRuntime(loadPayload)
+	if err == nil {
+		err = callFunction(bodyFile, contentType, result)
+	}
+	if err != nil {
+		log.Printf("Error invoking function: %v", err)
+		result.StatusCode = http.StatusBadGateway
+		result.Error = err.Error()
+	}
+
+	maxRetries := 10
+	for i := 0; i < maxRetries; i++ {
+		err = reportResult(resultUrl, strings.TrimSpace(string(token)), result)
+		if err == nil {
+			log.Printf("Reported invocation result (status %v)", result.StatusCode)
+			return
+		}
+		log.Printf("Error reporting invocation result, retrying: %v", err)
+		time.Sleep(time.Duration(i+1) * time.Second)
+	}
+	log.Fatalf("Failed to report invocation result: %v", err)
+}
+
+func callFunction(bodyFile string, contentType string, result *batchjob.InvocationResult) error {
+	body, err := ioutil.ReadFile(bodyFile)
+	if err != nil {
+		return err
+	}
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+
+	// No timeout here; batch functions are expected to run for a
+	// long time.
+	resp, err := http.Post("http://localhost:8888/", contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.ContentType = resp.Header.Get("Content-Type")
+	result.Body = respBody
+	return nil
+}
+
+func reportResult(resultUrl string, token string, result *batchjob.InvocationResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", resultUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fission.MakeErrorFromHTTP(resp)
+	}
+	return nil
+}
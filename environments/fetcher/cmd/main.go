@@ -42,6 +42,11 @@ func main() {
 	loadPayload := flag.String("load-request", "", "JSON payload for Load request")
 	secretDir := flag.String("secret-dir", "", "Path to shared secrets directory")
 	configDir := flag.String("cfgmap-dir", "", "Path to shared configmap directory")
+	fetchOnly := flag.Bool("fetch-only", false, "Flag to exit after fetching, e.g. when running as an init container")
+	invokeBodyFile := flag.String("invoke-body-file", "", "Path to a request body to invoke the function with once after specializing")
+	invokeContentType := flag.String("invoke-content-type", "", "Content type of the invocation request body")
+	resultUrl := flag.String("result-url", "", "URL to report the result of the invocation to")
+	resultTokenFile := flag.String("result-token-file", "", "Path to the token to report the result of the invocation with")
 
 	flag.Parse()
 	if flag.NArg() == 0 {
@@ -64,6 +69,16 @@ func main() {
 		log.Fatalf("Error making fetcher: %v", err)
 	}
 
+	if *fetchOnly {
+		fetchPackage(fetcher, fetchPayload)
+		return
+	}
+
+	if len(*invokeBodyFile) > 0 {
+		invokeFunction(loadPayload, *invokeBodyFile, *invokeContentType, *resultUrl, *resultTokenFile)
+		return
+	}
+
 	if *specializeOnStart {
 		specializePod(fetcher, fetchPayload, loadPayload)
 	}
@@ -80,11 +95,21 @@ func main() {
 }
 
 func fetcherUsage() {
-	fmt.Printf("Usage: fetcher [-specialize-on-startup] [-fetch-request <json>] [-load-request <json>] [-secret-dir <string>] [-cfgmap-dir <string>] <shared volume path> \n")
+	fmt.Printf("Usage: fetcher [-specialize-on-startup] [-fetch-only] [-fetch-request <json>] [-load-request <json>] [-secret-dir <string>] [-cfgmap-dir <string>] [-invoke-body-file <path> -result-url <url> -result-token-file <path> [-invoke-content-type <string>]] <shared volume path> \n")
 }
 
 func specializePod(f *fetcher.Fetcher, fetchPayload *string, loadPayload *string) {
-	// Fetch code
+	fetchPackage(f, fetchPayload)
+
+	err := specializeRuntime(loadPayload)
+	if err != nil {
+		log.Printf("Failed to specialize pod: %v", err)
+	}
+}
+
+// fetchPackage fetches the function's code, secrets and configmaps into
+// the shared volumes
+func fetchPackage(f *fetcher.Fetcher, fetchPayload *string) {
 	var fetchReq fetcher.FetchRequest
 	err := json.Unmarshal([]byte(*fetchPayload), &fetchReq)
 	if err != nil {
@@ -100,9 +125,11 @@ func specializePod(f *fetcher.Fetcher, fetchPayload *string, loadPayload *string
 		log.Fatalf("Error fetching secerts/configmaps: %v", err)
 		return
 	}
+}
 
-	// Specialize the pod
-
+// specializeRuntime asks the runtime container to load the fetched
+// function, retrying until the runtime is up.
+func specializeRuntime(loadPayload *string) error {
 	envVersion, err := strconv.Atoi(os.Getenv("ENV_VERSION"))
 	if err != nil {
 		log.Fatalf("Error parsing environment version %v, error: %v", os.Getenv("ENV_VERSION"), err)
@@ -137,7 +164,7 @@ func specializePod(f *fetcher.Fetcher, fetchPayload *string, loadPayload *string
 			if err != nil {
 				log.Fatalf("Error closing readiness file: %v", err)
 			}
-			break
+			return nil
 		}
 
 		// Only retry for the specific case of a connection error.
@@ -156,8 +183,7 @@ func specializePod(f *fetcher.Fetcher, fetchPayload *string, loadPayload *string
 		if err == nil {
 			err = fission.MakeErrorFromHTTP(resp)
 		}
-		log.Printf("Failed to specialize pod: %v", err)
-		return
+		return err
 	}
-
+	return nil
 }
@@ -27,10 +27,29 @@ func (err Error) Error() string {
 	return fmt.Sprintf("%v - %v", err.Description(), err.Message)
 }
 
+func (err FieldError) Error() string {
+	return fmt.Sprintf("%v: %v", err.Field, err.Message)
+}
+
+// MakeValidationError returns an invalid argument error listing the
+// problems with an object.
+func MakeValidationError(kind string, name string, errs []FieldError) Error {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return MakeError(ErrorInvalidArgument, fmt.Sprintf("Invalid %v '%v': %v", kind, name, strings.Join(msgs, "; ")))
+}
+
 func MakeError(code int, msg string) Error {
 	return Error{Code: errorCode(code), Message: msg}
 }
 
+// NoSpaceHeader marks the 503 responses of Fission's quota checks,
+// which are ErrorNoSpace errors; other 503s, such as from a pod that
+// isn't ready, are internal errors.
+const NoSpaceHeader = "X-Fission-No-Space"
+
 func MakeErrorFromHTTP(resp *http.Response) error {
 	if resp.StatusCode == 200 {
 		return nil
@@ -40,12 +59,22 @@ func MakeErrorFromHTTP(resp *http.Response) error {
 	switch resp.StatusCode {
 	case 400:
 		errCode = ErrorInvalidArgument
+	case 401:
+		errCode = ErrorNotAuthenticated
 	case 403:
 		errCode = ErrorNotAuthorized
 	case 404:
 		errCode = ErrorNotFound
 	case 409:
 		errCode = ErrorNameExists
+	case 429:
+		errCode = ErrorTooManyRequests
+	case 503:
+		if len(resp.Header.Get(NoSpaceHeader)) > 0 {
+			errCode = ErrorNoSpace
+		} else {
+			errCode = ErrorInternal
+		}
 	default:
 		errCode = ErrorInternal
 	}
@@ -65,12 +94,18 @@ func (err Error) HTTPStatus() int {
 	switch err.Code {
 	case ErrorInvalidArgument:
 		code = 400
+	case ErrorNotAuthenticated:
+		code = 401
 	case ErrorNotAuthorized:
 		code = 403
 	case ErrorNotFound:
 		code = 404
 	case ErrorNameExists:
 		code = 409
+	case ErrorTooManyRequests:
+		code = 429
+	case ErrorNoSpace:
+		code = 503
 	default:
 		code = 500
 	}
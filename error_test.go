@@ -0,0 +1,40 @@
+package fission
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMakeErrorFromHTTP(t *testing.T) {
+	// only 503s marked as Fission's quota or limit responses are
+	// ErrorNoSpace
+	tests := []struct {
+		noSpace bool
+		code    errorCode
+	}{
+		{true, ErrorNoSpace},
+		{false, ErrorInternal},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		if test.noSpace {
+			w.Header().Set(NoSpaceHeader, "true")
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+
+		err := MakeErrorFromHTTP(w.Result())
+		fe, ok := err.(Error)
+		if !ok || fe.Code != test.code {
+			t.Errorf("Expected error code %v for 503 with no space header %v, got %v", test.code, test.noSpace, err)
+		}
+	}
+
+	// concurrency limits are told apart from quotas
+	w := httptest.NewRecorder()
+	http.Error(w, "busy", MakeError(ErrorTooManyRequests, "").HTTPStatus())
+	err := MakeErrorFromHTTP(w.Result())
+	if fe, ok := err.(Error); !ok || fe.Code != ErrorTooManyRequests {
+		t.Errorf("Expected error code %v for 429, got %v", ErrorTooManyRequests, err)
+	}
+}
@@ -31,6 +31,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/batchjob"
+	"github.com/fission/fission/executor/lease"
+	"github.com/fission/fission/executor/metrics"
 )
 
 func (executor *Executor) getServiceForFunctionApi(w http.ResponseWriter, r *http.Request) {
@@ -50,9 +54,7 @@ func (executor *Executor) getServiceForFunctionApi(w http.ResponseWriter, r *htt
 
 	serviceName, err := executor.getServiceForFunction(&m)
 	if err != nil {
-		code, msg := fission.GetHTTPError(err)
-		log.Printf("Error: %v: %v", code, msg)
-		http.Error(w, msg, code)
+		executor.respondWithError(w, err)
 		return
 	}
 
@@ -80,6 +82,101 @@ func (executor *Executor) getServiceForFunction(m *metav1.ObjectMeta) (string, e
 	return resp.funcSvc.Address, resp.err
 }
 
+// acquireLease gets a service for a function, like
+// getServiceForFunction, along with a lease that counts against the
+// function's MaxConcurrency. Functions without a limit get a lease
+// with no ID, which doesn't need to be released.
+func (executor *Executor) acquireLease(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+
+	m := metav1.ObjectMeta{}
+	err = json.Unmarshal(body, &m)
+	if err != nil {
+		http.Error(w, "Failed to parse request", 400)
+		return
+	}
+
+	l, err := executor.getLease(&m)
+	if err != nil {
+		executor.respondWithError(w, err)
+		return
+	}
+	executor.respondWithJSON(w, l, http.StatusOK)
+}
+
+func (executor *Executor) getLease(m *metav1.ObjectMeta) (*lease.Lease, error) {
+	limit, err := executor.concurrencyLimit(m)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		address, err := executor.getServiceForFunction(m)
+		if err != nil {
+			return nil, err
+		}
+		return &lease.Lease{Function: *m, Address: address}, nil
+	}
+
+	// Requests over the limit are turned away before they get a
+	// service specialized or created for them.
+	l, err := executor.leases.Acquire(m, limit)
+	if err != nil {
+		return nil, err
+	}
+	address, err := executor.getServiceForFunction(m)
+	if err != nil {
+		executor.leases.Release(l.ID)
+		return nil, err
+	}
+	l.Address = address
+	return l, nil
+}
+
+// concurrencyLimit returns the MaxConcurrency of the version of a
+// function that a router asks for. Each version's limit is fetched
+// from the API once; routers that haven't seen the latest version yet
+// get the latest limit, without it being cached.
+func (executor *Executor) concurrencyLimit(m *metav1.ObjectMeta) (int, error) {
+	limit, err := executor.limits.Get(crd.CacheKey(m))
+	if err == nil {
+		return limit.(int), nil
+	}
+
+	fn, err := executor.getFunction(m)
+	if err != nil {
+		return 0, err
+	}
+	l := fn.Spec.InvokeStrategy.ExecutionStrategy.MaxConcurrency
+	executor.limits.Set(crd.CacheKey(&fn.Metadata), l)
+	return l, nil
+}
+
+func (executor *Executor) releaseLease(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := executor.leases.Release(vars["lease"])
+	if err != nil {
+		code, msg := fission.GetHTTPError(err)
+		http.Error(w, msg, code)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (executor *Executor) renewLease(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := executor.leases.Renew(vars["lease"])
+	if err != nil {
+		code, msg := fission.GetHTTPError(err)
+		http.Error(w, msg, code)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 // find funcSvc and update its atime
 func (executor *Executor) tapService(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
@@ -90,7 +187,15 @@ func (executor *Executor) tapService(w http.ResponseWriter, r *http.Request) {
 	svcName := string(body)
 	svcHost := strings.TrimPrefix(svcName, "http://")
 
-	err = executor.fsCache.TouchByAddress(svcHost)
+	// The backends share the function service cache, but a backend
+	// may keep its own state too; tap until one knows the service.
+	err = fission.MakeError(fission.ErrorNotFound, fmt.Sprintf("function service %v not found", svcHost))
+	for _, backend := range executor.uniqueBackends() {
+		err = backend.TapService(svcHost)
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		log.Printf("funcSvc tap error: %v", err)
 		http.Error(w, "Not found", 404)
@@ -99,6 +204,119 @@ func (executor *Executor) tapService(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// reportMetrics receives the request load of functions from a router,
+// and responds with the function services that are being drained.
+func (executor *Executor) reportMetrics(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+
+	var samples []metrics.Sample
+	err = json.Unmarshal(body, &samples)
+	if err != nil {
+		http.Error(w, "Failed to parse request", 400)
+		return
+	}
+
+	executor.metrics.Report(samples)
+
+	// Tell the router about function services being drained, so that
+	// it stops using them.
+	executor.respondWithJSON(w, executor.drainer.Notices(), http.StatusOK)
+}
+
+// createInvocation starts a batch job running a function once, and
+// returns the invocation without waiting for it to finish.
+func (executor *Executor) createInvocation(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+
+	var req batchjob.InvocationRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, "Failed to parse request", 400)
+		return
+	}
+
+	inv, err := executor.jobm.Invoke(&req)
+	if err != nil {
+		executor.respondWithError(w, err)
+		return
+	}
+	executor.respondWithInvocation(w, inv, http.StatusCreated)
+}
+
+func (executor *Executor) getInvocation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	inv, err := executor.jobm.GetInvocation(vars["invocation"])
+	if err != nil {
+		code, msg := fission.GetHTTPError(err)
+		http.Error(w, msg, code)
+		return
+	}
+	executor.respondWithInvocation(w, inv, http.StatusOK)
+}
+
+// completeInvocation receives the result of an invocation from the
+// invoker running in the batch job's pod. The invoker authenticates with
+// the invocation's token.
+func (executor *Executor) completeInvocation(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+
+	var result batchjob.InvocationResult
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		http.Error(w, "Failed to parse request", 400)
+		return
+	}
+
+	vars := mux.Vars(r)
+	inv, err := executor.jobm.CompleteInvocation(vars["invocation"], token, &result)
+	if err != nil {
+		executor.respondWithError(w, err)
+		return
+	}
+	executor.respondWithInvocation(w, inv, http.StatusOK)
+}
+
+// respondWithError responds with the HTTP status of err. Responses to
+// quota checks are marked, so that clients can tell them from other
+// 503s.
+func (executor *Executor) respondWithError(w http.ResponseWriter, err error) {
+	code, msg := fission.GetHTTPError(err)
+	log.Printf("Error: %v: %v", code, msg)
+	if fe, ok := err.(fission.Error); ok && fe.Code == fission.ErrorNoSpace {
+		w.Header().Set(fission.NoSpaceHeader, "true")
+	}
+	http.Error(w, msg, code)
+}
+
+func (executor *Executor) respondWithInvocation(w http.ResponseWriter, inv *batchjob.Invocation, code int) {
+	executor.respondWithJSON(w, inv, code)
+}
+
+func (executor *Executor) respondWithJSON(w http.ResponseWriter, obj interface{}, code int) {
+	resp, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(resp)
+}
+
 func (executor *Executor) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -107,11 +325,24 @@ func (executor *Executor) Serve(port int) {
 	r := mux.NewRouter()
 	r.HandleFunc("/v2/getServiceForFunction", executor.getServiceForFunctionApi).Methods("POST")
 	r.HandleFunc("/v2/tapService", executor.tapService).Methods("POST")
+	r.HandleFunc("/v2/reportMetrics", executor.reportMetrics).Methods("POST")
+	r.HandleFunc("/v2/leases", executor.acquireLease).Methods("POST")
+	r.HandleFunc("/v2/leases/{lease}", executor.renewLease).Methods("PUT")
+	r.HandleFunc("/v2/leases/{lease}", executor.releaseLease).Methods("DELETE")
+	r.HandleFunc("/v2/invocations", executor.createInvocation).Methods("POST")
+	r.HandleFunc("/v2/invocations/{invocation}", executor.getInvocation).Methods("GET")
+	r.HandleFunc("/v2/invocations/{invocation}/result", executor.completeInvocation).Methods("POST")
+	r.HandleFunc("/v2/funcsvcs", executor.listFuncSvcs).Methods("GET")
+	r.HandleFunc("/v2/pools", executor.listPools).Methods("GET")
+	r.HandleFunc("/v2/functions/{function}/status", executor.getFunctionStatus).Methods("GET")
 	r.HandleFunc("/healthz", executor.healthHandler).Methods("GET")
 	address := fmt.Sprintf(":%v", port)
 	log.Printf("starting executor at port %v", port)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	executor.ndm.Run(ctx)
+	if executor.prewarmer != nil {
+		executor.prewarmer.Run(ctx)
+	}
 	log.Fatal(http.ListenAndServe(address, handlers.LoggingHandler(os.Stdout, r)))
 }
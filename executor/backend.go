@@ -0,0 +1,83 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+// ExecutorBackend runs the function services of one or more executor
+// types. The pool manager and NewDeploy are backends; executor/fake has
+// an in-memory backend for tests.
+type ExecutorBackend interface {
+	// GetFuncSvc returns a function service for fn, creating it if
+	// there's none, and adds it to the function service cache.
+	GetFuncSvc(fn *crd.Function) (*fscache.FuncSvc, error)
+
+	// TapService marks the function service at address as used, so
+	// that it isn't reaped while it's busy.
+	TapService(address string) error
+
+	// DeleteFuncSvc deletes the Kubernetes objects of a function
+	// service. Callers drain it first, so that it isn't deleted
+	// while requests to it are in flight.
+	DeleteFuncSvc(fsvc *fscache.FuncSvc) error
+
+	// ListFuncSvcs returns the function services run by the backend.
+	ListFuncSvcs() ([]*fscache.FuncSvc, error)
+
+	// CleanupOnStart deletes objects left behind by previous executor
+	// instances. It doesn't block.
+	CleanupOnStart()
+}
+
+// RegisterBackend makes the executor use backend for functions of
+// executorType.
+func (executor *Executor) RegisterBackend(executorType fission.ExecutorType, backend ExecutorBackend) {
+	executor.backends[executorType] = backend
+}
+
+// getBackend returns the backend for a function.
+func (executor *Executor) getBackend(fn *crd.Function) (ExecutorBackend, bool) {
+	executorType := fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType
+	if len(executorType) == 0 {
+		executorType = fission.ExecutorTypePoolmgr
+	}
+	backend, ok := executor.backends[executorType]
+	return backend, ok
+}
+
+// uniqueBackends returns each registered backend once, even if it's
+// registered for several executor types.
+func (executor *Executor) uniqueBackends() []ExecutorBackend {
+	backends := make([]ExecutorBackend, 0, len(executor.backends))
+	for _, backend := range executor.backends {
+		found := false
+		for _, b := range backends {
+			if b == backend {
+				found = true
+				break
+			}
+		}
+		if !found {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
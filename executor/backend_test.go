@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fake"
+	"github.com/fission/fission/executor/fscache"
+)
+
+// makeTestExecutor returns an executor with a fake poolmgr backend,
+// serving the given functions.
+func makeTestExecutor(fns ...*crd.Function) (*Executor, *fake.Backend) {
+	fsCache := fscache.MakeFunctionServiceCache()
+	executor := MakeExecutor(nil, nil, nil, nil, fsCache, nil, nil)
+	executor.getFunction = func(m *metav1.ObjectMeta) (*crd.Function, error) {
+		for _, fn := range fns {
+			if fn.Metadata.Name == m.Name && fn.Metadata.Namespace == m.Namespace {
+				return fn, nil
+			}
+		}
+		return nil, fission.MakeError(fission.ErrorNotFound, "no such function")
+	}
+	backend := fake.MakeBackend(fsCache)
+	executor.RegisterBackend(fission.ExecutorTypePoolmgr, backend)
+	return executor, backend
+}
+
+func makeTestFunction(name string, executorType fission.ExecutorType) *crd.Function {
+	fn := &crd.Function{
+		Metadata: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       metav1.NamespaceDefault,
+			UID:             types.UID(name + "-uid"),
+			ResourceVersion: "1",
+		},
+	}
+	fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType = executorType
+	return fn
+}
+
+func TestConcurrentRequestsShareFuncSvc(t *testing.T) {
+	foo := makeTestFunction("foo", fission.ExecutorTypePoolmgr)
+	bar := makeTestFunction("bar", "")
+	executor, backend := makeTestExecutor(foo, bar)
+	backend.Delay = 100 * time.Millisecond
+
+	var wg sync.WaitGroup
+	addresses := make(chan string, 20)
+	for i := 0; i < 20; i++ {
+		fn := foo
+		if i%2 == 1 {
+			fn = bar
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			address, err := executor.getServiceForFunction(&fn.Metadata)
+			if err != nil {
+				t.Errorf("error getting service for %v: %v", fn.Metadata.Name, err)
+				return
+			}
+			addresses <- fmt.Sprintf("%v=%v", fn.Metadata.Name, address)
+		}()
+	}
+	wg.Wait()
+	close(addresses)
+
+	if n := backend.Created(foo); n != 1 {
+		t.Errorf("expected 1 service for foo, got %v", n)
+	}
+	if n := backend.Created(bar); n != 1 {
+		t.Errorf("expected 1 service for bar, got %v", n)
+	}
+	seen := make(map[string]bool)
+	for a := range addresses {
+		seen[a] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected requests to share 2 services, got %v", seen)
+	}
+
+	// once the service exists, it comes from the cache
+	_, err := executor.getServiceForFunction(&foo.Metadata)
+	if err != nil {
+		t.Errorf("error getting cached service: %v", err)
+	}
+	if n := backend.Created(foo); n != 1 {
+		t.Errorf("expected cached service to be reused, got %v services", n)
+	}
+}
+
+func TestFailedRequestIsRetried(t *testing.T) {
+	foo := makeTestFunction("foo", fission.ExecutorTypePoolmgr)
+	executor, backend := makeTestExecutor(foo)
+
+	backend.Err = errors.New("specialize failed")
+	_, err := executor.getServiceForFunction(&foo.Metadata)
+	if err == nil {
+		t.Fatalf("expected error from backend")
+	}
+
+	// the failed request doesn't block later ones
+	backend.Err = nil
+	_, err = executor.getServiceForFunction(&foo.Metadata)
+	if err != nil {
+		t.Errorf("error getting service after failure: %v", err)
+	}
+	if n := backend.Created(foo); n != 1 {
+		t.Errorf("expected 1 service, got %v", n)
+	}
+}
+
+func TestUnknownExecutorType(t *testing.T) {
+	foo := makeTestFunction("foo", fission.ExecutorTypeNewdeploy)
+	job := makeTestFunction("job", fission.ExecutorTypeJob)
+	executor, _ := makeTestExecutor(foo, job)
+
+	for _, fn := range []*crd.Function{foo, job} {
+		_, err := executor.getServiceForFunction(&fn.Metadata)
+		fe, ok := err.(fission.Error)
+		if !ok || fe.Code != fission.ErrorInvalidArgument {
+			t.Errorf("expected invalid argument error for %v, got %v", fn.Metadata.Name, err)
+		}
+	}
+}
+
+func TestLeaseLimitCheckedFirst(t *testing.T) {
+	foo := makeTestFunction("foo", fission.ExecutorTypePoolmgr)
+	foo.Spec.InvokeStrategy.ExecutionStrategy.MaxConcurrency = 1
+	executor, backend := makeTestExecutor(foo)
+	var gets int32
+	getFunction := executor.getFunction
+	executor.getFunction = func(m *metav1.ObjectMeta) (*crd.Function, error) {
+		atomic.AddInt32(&gets, 1)
+		return getFunction(m)
+	}
+
+	l, err := executor.getLease(&foo.Metadata)
+	if err != nil {
+		t.Fatalf("error getting lease: %v", err)
+	}
+	if len(l.ID) == 0 || len(l.Address) == 0 {
+		t.Errorf("expected lease with an address, got %#v", l)
+	}
+
+	// with the service reaped, a request over the limit must not
+	// create another one, nor fetch the function again
+	fsvc, err := executor.fsCache.GetByFunction(&foo.Metadata)
+	if err != nil {
+		t.Fatalf("error getting function service: %v", err)
+	}
+	executor.fsCache.DeleteOld(fsvc, 0)
+	atomic.StoreInt32(&gets, 0)
+
+	_, err = executor.getLease(&foo.Metadata)
+	if fe, ok := err.(fission.Error); !ok || fe.Code != fission.ErrorTooManyRequests {
+		t.Errorf("expected function to be at its limit, got %v", err)
+	}
+	if n := atomic.LoadInt32(&gets); n != 0 {
+		t.Errorf("expected limit to be cached, got %v function fetches", n)
+	}
+	if n := backend.Created(foo); n != 1 {
+		t.Errorf("expected 1 service, got %v", n)
+	}
+}
@@ -0,0 +1,658 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batchjob
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/satori/go.uuid"
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/environments/fetcher"
+	"github.com/fission/fission/executor/util"
+)
+
+const (
+	invocationIdLabel = "invocationId"
+
+	// set on a Job once its invocation has reported a result
+	completedAtAnnotation      = "fission.io/completed-at"
+	invocationStatusAnnotation = "fission.io/invocation-status"
+
+	// the hash of the token the invoker reports the result with
+	resultTokenAnnotation = "fission.io/result-token-sha256"
+
+	envVersion = "ENV_VERSION"
+
+	// Invocations of functions without a timeout are stopped after
+	// this long.
+	defaultTimeout = time.Hour
+)
+
+// MaxBodySize is the size limit of request and response bodies of
+// invocations. They're kept in Secrets, which can't be much larger.
+const MaxBodySize = 1000 * 1000
+
+// JobManager runs functions with the job executor type. Each
+// invocation gets its own Kubernetes Job: an init container fetches the
+// function's package, and an invoker container specializes the runtime,
+// sends it the request body, and reports the response back to the
+// executor. Jobs are stopped after the function's timeout, and deleted
+// jobTTL after they finish. Results are kept in Secrets owned by the
+// Jobs, so that they survive executor restarts.
+type JobManager struct {
+	kubernetesClient *kubernetes.Clientset
+	fissionClient    *crd.FissionClient
+	namespace        string
+	executorUrl      string        // address invokers report results to
+	jobTTL           time.Duration // finished Jobs are kept this long, e.g. to read their logs
+
+	fetcherImg             string
+	fetcherImagePullPolicy apiv1.PullPolicy
+	sharedMountPath        string
+	sharedSecretPath       string
+	sharedCfgMapPath       string
+
+	invocations *invocationStore
+}
+
+func MakeJobManager(
+	fissionClient *crd.FissionClient,
+	kubernetesClient *kubernetes.Clientset,
+	namespace string,
+	executorUrl string,
+	jobTTL time.Duration,
+) *JobManager {
+
+	log.Printf("Creating Job ExecutorType")
+
+	fetcherImg := os.Getenv("FETCHER_IMAGE")
+	if len(fetcherImg) == 0 {
+		fetcherImg = "fission/fetcher"
+	}
+
+	jm := &JobManager{
+		kubernetesClient: kubernetesClient,
+		fissionClient:    fissionClient,
+		namespace:        namespace,
+		executorUrl:      executorUrl,
+		jobTTL:           jobTTL,
+
+		fetcherImg:             fetcherImg,
+		fetcherImagePullPolicy: apiv1.PullIfNotPresent,
+		sharedMountPath:        "/userfunc",
+		sharedSecretPath:       "/secrets",
+		sharedCfgMapPath:       "/configs",
+
+		invocations: makeInvocationStore(),
+	}
+	go jm.jobReaper()
+	return jm
+}
+
+// Invoke starts a Job running the function once with the body of req,
+// and returns without waiting for it to finish.
+func (jm *JobManager) Invoke(req *InvocationRequest) (*Invocation, error) {
+	if len(req.Body) > MaxBodySize {
+		return nil, fission.MakeError(fission.ErrorInvalidArgument,
+			fmt.Sprintf("request body of %v bytes exceeds the limit of %v bytes", len(req.Body), MaxBodySize))
+	}
+
+	fn, err := jm.fissionClient.Functions(req.Function.Namespace).Get(req.Function.Name)
+	if err != nil {
+		return nil, err
+	}
+	if fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType != fission.ExecutorTypeJob {
+		return nil, fission.MakeError(fission.ErrorInvalidArgument,
+			fmt.Sprintf("function %v doesn't use the job executor", fn.Metadata.Name))
+	}
+
+	env, err := jm.fissionClient.Environments(fn.Spec.Environment.Namespace).Get(fn.Spec.Environment.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.NewV4().String()
+	jobName := "batch-" + id
+	labels := map[string]string{
+		"functionName":      fn.Metadata.Name,
+		"functionNamespace": fn.Metadata.Namespace,
+		"functionUid":       string(fn.Metadata.UID),
+		"environmentName":   env.Metadata.Name,
+		"environmentUid":    string(env.Metadata.UID),
+		"executorType":      fission.ExecutorTypeJob,
+		invocationIdLabel:   id,
+	}
+
+	// Only the invoker, which gets the token through the request body
+	// secret, may report the result.
+	token, err := makeResultToken()
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := jm.getJob(fn, env, id, jobName, labels, req.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	job.Annotations = map[string]string{
+		resultTokenAnnotation: hashToken(token),
+	}
+	job, err = jm.kubernetesClient.BatchV1().Jobs(jm.namespace).Create(job)
+	if err != nil {
+		log.Printf("Error creating job %v: %v", jobName, err)
+		return nil, err
+	}
+
+	// The request body is handed to the invoker through a secret owned
+	// by the Job, so that it's garbage collected along with it. The pod
+	// waits for the secret to show up before starting.
+	secret := &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "batch/v1",
+					Kind:       "Job",
+					Name:       job.Name,
+					UID:        job.UID,
+				},
+			},
+		},
+		Data: map[string][]byte{
+			"body":  req.Body,
+			"token": []byte(token),
+		},
+	}
+	_, err = jm.kubernetesClient.CoreV1().Secrets(jm.namespace).Create(secret)
+	if err != nil {
+		log.Printf("Error creating request body secret for job %v: %v", jobName, err)
+		jm.deleteJob(jobName)
+		return nil, err
+	}
+
+	inv := &Invocation{
+		ID:        id,
+		Function:  fn.Metadata,
+		JobName:   jobName,
+		Status:    InvocationRunning,
+		StartTime: time.Now(),
+	}
+	jm.invocations.add(inv)
+	log.Printf("Started job %v for function %v", jobName, fn.Metadata.Name)
+
+	invCopy := *inv
+	return &invCopy, nil
+}
+
+// GetInvocation returns the status of an invocation, and its result if
+// it has completed.
+func (jm *JobManager) GetInvocation(id string) (*Invocation, error) {
+	inv, err := jm.invocations.get(id)
+	if err == nil {
+		return inv, nil
+	}
+
+	// Not started by this executor instance, or already forgotten;
+	// fall back to the Job and its result secret.
+	job, err := jm.getInvocationJob(id)
+	if err != nil {
+		return nil, err
+	}
+	inv = invocationFromJob(job)
+	if inv.CompletionTime != nil {
+		inv.Result, err = jm.getResult(job)
+		if err != nil {
+			log.Printf("Error getting result of invocation %v: %v", id, err)
+		}
+	}
+	return inv, nil
+}
+
+// CompleteInvocation records the result reported by the invoker with
+// the invocation's token, and stops the Job's pod. The Job itself is
+// kept around until its TTL expires.
+func (jm *JobManager) CompleteInvocation(id string, token string, result *InvocationResult) (*Invocation, error) {
+	job, err := jm.getInvocationJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if !checkToken(job, token) {
+		return nil, fission.MakeError(fission.ErrorNotAuthenticated,
+			fmt.Sprintf("invalid result token for invocation %v", id))
+	}
+
+	if len(result.Body) > MaxBodySize {
+		result = &InvocationResult{
+			StatusCode: result.StatusCode,
+			Error: fmt.Sprintf("response body of %v bytes exceeds the limit of %v bytes",
+				len(result.Body), MaxBodySize),
+		}
+	}
+
+	inv := jm.invocations.complete(id, invocationFromJob(job), result)
+	log.Printf("Invocation %v of function %v finished: %v", id, inv.Function.Name, inv.Status)
+
+	err = jm.saveResult(job, result)
+	if err != nil {
+		log.Printf("Error saving result of invocation %v: %v", id, err)
+	}
+
+	// The runtime container keeps serving after the invocation, so the
+	// pod never completes on its own. Setting parallelism to zero makes
+	// the Job controller terminate it.
+	parallelism := int32(0)
+	job.Spec.Parallelism = &parallelism
+	if job.Annotations == nil {
+		job.Annotations = make(map[string]string)
+	}
+	job.Annotations[completedAtAnnotation] = inv.CompletionTime.Format(time.RFC3339)
+	job.Annotations[invocationStatusAnnotation] = string(inv.Status)
+	_, err = jm.kubernetesClient.BatchV1().Jobs(jm.namespace).Update(job)
+	if err != nil {
+		log.Printf("Error stopping job %v: %v", job.Name, err)
+	}
+	return inv, nil
+}
+
+// saveResult keeps the result of an invocation in a secret owned by its
+// Job, for executors that don't have it in memory.
+func (jm *JobManager) saveResult(job *batchv1.Job, result *InvocationResult) error {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	secret := &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   resultSecretName(job.Name),
+			Labels: job.Labels,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "batch/v1",
+					Kind:       "Job",
+					Name:       job.Name,
+					UID:        job.UID,
+				},
+			},
+		},
+		Data: map[string][]byte{
+			"result": b,
+		},
+	}
+	_, err = jm.kubernetesClient.CoreV1().Secrets(jm.namespace).Create(secret)
+	if k8s_err.IsAlreadyExists(err) {
+		_, err = jm.kubernetesClient.CoreV1().Secrets(jm.namespace).Update(secret)
+	}
+	return err
+}
+
+func (jm *JobManager) getResult(job *batchv1.Job) (*InvocationResult, error) {
+	secret, err := jm.kubernetesClient.CoreV1().Secrets(jm.namespace).Get(resultSecretName(job.Name), metav1.GetOptions{})
+	if err != nil {
+		if k8s_err.IsNotFound(err) {
+			// failed before reporting a result
+			return nil, nil
+		}
+		return nil, err
+	}
+	var result InvocationResult
+	err = json.Unmarshal(secret.Data["result"], &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func resultSecretName(jobName string) string {
+	return jobName + "-result"
+}
+
+func makeResultToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkToken returns true if token is the result token of a Job.
+func checkToken(job *batchv1.Job, token string) bool {
+	expected := job.Annotations[resultTokenAnnotation]
+	if len(expected) == 0 || len(token) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(expected)) == 1
+}
+
+func (jm *JobManager) getInvocationJob(id string) (*batchv1.Job, error) {
+	job, err := jm.kubernetesClient.BatchV1().Jobs(jm.namespace).Get("batch-"+id, metav1.GetOptions{})
+	if err != nil {
+		if k8s_err.IsNotFound(err) {
+			return nil, fission.MakeError(fission.ErrorNotFound, fmt.Sprintf("invocation %v not found", id))
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// invocationFromJob reconstructs an invocation from its Job. Results
+// aren't stored in the Job, so they're not available.
+func invocationFromJob(job *batchv1.Job) *Invocation {
+	// Jobs created before the namespace label was added are all of
+	// functions in the default namespace.
+	fnNamespace := job.Labels["functionNamespace"]
+	if len(fnNamespace) == 0 {
+		fnNamespace = metav1.NamespaceDefault
+	}
+	inv := &Invocation{
+		ID: job.Labels[invocationIdLabel],
+		Function: metav1.ObjectMeta{
+			Name:      job.Labels["functionName"],
+			Namespace: fnNamespace,
+			UID:       types.UID(job.Labels["functionUid"]),
+		},
+		JobName:   job.Name,
+		Status:    InvocationRunning,
+		StartTime: job.CreationTimestamp.Time,
+	}
+
+	if status, ok := job.Annotations[invocationStatusAnnotation]; ok {
+		inv.Status = InvocationStatus(status)
+		completedAt, err := time.Parse(time.RFC3339, job.Annotations[completedAtAnnotation])
+		if err == nil {
+			inv.CompletionTime = &completedAt
+		}
+		return inv
+	}
+
+	// e.g. DeadlineExceeded, when the function's timeout is up
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == apiv1.ConditionTrue {
+			inv.Status = InvocationFailed
+			inv.Error = fmt.Sprintf("job failed: %v %v", c.Reason, c.Message)
+			completedAt := c.LastTransitionTime.Time
+			inv.CompletionTime = &completedAt
+		}
+	}
+	return inv
+}
+
+func (jm *JobManager) getJob(fn *crd.Function, env *crd.Environment, id string,
+	jobName string, labels map[string]string, contentType string) (*batchv1.Job, error) {
+
+	targetFilename := "user"
+	userfunc := "userfunc"
+	secrets := "secrets"
+	configs := "configs"
+	invocation := "invocation"
+	invocationPath := "/invocation"
+
+	fetchReq := &fetcher.FetchRequest{
+		FetchType: fetcher.FETCH_DEPLOYMENT,
+		Package: metav1.ObjectMeta{
+			Namespace: fn.Spec.Package.PackageRef.Namespace,
+			Name:      fn.Spec.Package.PackageRef.Name,
+		},
+		Filename:   targetFilename,
+		Secrets:    fn.Spec.Secrets,
+		ConfigMaps: fn.Spec.ConfigMaps,
+	}
+	loadReq := fission.FunctionLoadRequest{
+		FilePath:         filepath.Join(jm.sharedMountPath, targetFilename),
+		FunctionName:     fn.Spec.Package.FunctionName,
+		FunctionMetadata: &fn.Metadata,
+	}
+
+	fetchPayload, err := json.Marshal(fetchReq)
+	if err != nil {
+		return nil, err
+	}
+	loadPayload, err := json.Marshal(loadReq)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcherResources, err := util.GetFetcherResources()
+	if err != nil {
+		log.Printf("Error while parsing fetcher resources: %v", err)
+		return nil, err
+	}
+
+	sharedMounts := []apiv1.VolumeMount{
+		{
+			Name:      userfunc,
+			MountPath: jm.sharedMountPath,
+		},
+		{
+			Name:      secrets,
+			MountPath: jm.sharedSecretPath,
+		},
+		{
+			Name:      configs,
+			MountPath: jm.sharedCfgMapPath,
+		},
+	}
+
+	// The runtime container never exits, so Jobs whose invokers don't
+	// report a result, e.g. because they crashed, only stop at their
+	// deadline.
+	parallelism := int32(1)
+	completions := int32(1)
+	deadline := int64(functionTimeout(fn).Seconds())
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: batchv1.JobSpec{
+			Parallelism:           &parallelism,
+			Completions:           &completions,
+			ActiveDeadlineSeconds: &deadline,
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: apiv1.PodSpec{
+					Volumes: []apiv1.Volume{
+						{
+							Name: userfunc,
+							VolumeSource: apiv1.VolumeSource{
+								EmptyDir: &apiv1.EmptyDirVolumeSource{},
+							},
+						},
+						{
+							Name: secrets,
+							VolumeSource: apiv1.VolumeSource{
+								EmptyDir: &apiv1.EmptyDirVolumeSource{},
+							},
+						},
+						{
+							Name: configs,
+							VolumeSource: apiv1.VolumeSource{
+								EmptyDir: &apiv1.EmptyDirVolumeSource{},
+							},
+						},
+						{
+							Name: invocation,
+							VolumeSource: apiv1.VolumeSource{
+								Secret: &apiv1.SecretVolumeSource{
+									SecretName: jobName,
+								},
+							},
+						},
+					},
+					InitContainers: []apiv1.Container{
+						{
+							Name:                   "fetcher",
+							Image:                  jm.fetcherImg,
+							ImagePullPolicy:        jm.fetcherImagePullPolicy,
+							TerminationMessagePath: "/dev/termination-log",
+							VolumeMounts:           sharedMounts,
+							Command: []string{"/fetcher", "-fetch-only",
+								"-fetch-request", string(fetchPayload),
+								"-secret-dir", jm.sharedSecretPath,
+								"-cfgmap-dir", jm.sharedCfgMapPath,
+								jm.sharedMountPath},
+							Resources: fetcherResources,
+						},
+					},
+					Containers: []apiv1.Container{
+						{
+							Name:                   fn.Metadata.Name,
+							Image:                  env.Spec.Runtime.Image,
+							ImagePullPolicy:        apiv1.PullIfNotPresent,
+							TerminationMessagePath: "/dev/termination-log",
+							VolumeMounts:           sharedMounts,
+							Resources:              env.Spec.Resources,
+						},
+						{
+							Name:                   "invoker",
+							Image:                  jm.fetcherImg,
+							ImagePullPolicy:        jm.fetcherImagePullPolicy,
+							TerminationMessagePath: "/dev/termination-log",
+							VolumeMounts: []apiv1.VolumeMount{
+								{
+									Name:      invocation,
+									MountPath: invocationPath,
+									ReadOnly:  true,
+								},
+							},
+							Command: []string{"/fetcher",
+								"-load-request", string(loadPayload),
+								"-invoke-body-file", filepath.Join(invocationPath, "body"),
+								"-invoke-content-type", contentType,
+								"-result-url", fmt.Sprintf("%v/v2/invocations/%v/result", jm.executorUrl, id),
+								"-result-token-file", filepath.Join(invocationPath, "token"),
+								jm.sharedMountPath},
+							Env: []apiv1.EnvVar{
+								{
+									Name:  envVersion,
+									Value: strconv.Itoa(env.Spec.Version),
+								},
+							},
+							Resources: fetcherResources,
+						},
+					},
+					RestartPolicy:      apiv1.RestartPolicyNever,
+					ServiceAccountName: "fission-fetcher",
+				},
+			},
+		},
+	}
+
+	fission.MergePodTemplateOverlay(&job.Spec.Template, fn.Metadata.Name, env.Spec.PodTemplate)
+	fission.MergePodTemplateOverlay(&job.Spec.Template, fn.Metadata.Name, fn.Spec.PodTemplate)
+	return job, nil
+}
+
+// functionTimeout returns how long invocations of a function may run.
+func functionTimeout(fn *crd.Function) time.Duration {
+	if fn.Spec.FunctionTimeout > 0 {
+		return time.Duration(fn.Spec.FunctionTimeout) * time.Second
+	}
+	return defaultTimeout
+}
+
+// jobFinishedAt returns when the Job's invocation finished, or nil if
+// it's still running.
+func jobFinishedAt(job *batchv1.Job) *time.Time {
+	inv := invocationFromJob(job)
+	if inv.CompletionTime != nil {
+		return inv.CompletionTime
+	}
+	if job.Status.CompletionTime != nil {
+		return &job.Status.CompletionTime.Time
+	}
+	return nil
+}
+
+// jobExpired returns true if a Job finished, completed or failed, more
+// than ttl ago. Jobs that somehow still run long after their deadline,
+// e.g. because it wasn't set, expire as well.
+func jobExpired(job *batchv1.Job, ttl time.Duration, now time.Time) bool {
+	if finishedAt := jobFinishedAt(job); finishedAt != nil {
+		return now.Sub(*finishedAt) > ttl
+	}
+	deadline := defaultTimeout
+	if job.Spec.ActiveDeadlineSeconds != nil {
+		deadline = time.Duration(*job.Spec.ActiveDeadlineSeconds) * time.Second
+	}
+	return now.Sub(job.CreationTimestamp.Time) > deadline+ttl
+}
+
+// jobReaper deletes Jobs, along with their pods, request bodies and
+// results, once they've expired.
+func (jm *JobManager) jobReaper() {
+	pollSleep := time.Duration(time.Minute)
+	for {
+		time.Sleep(pollSleep)
+
+		jm.invocations.expire(jm.jobTTL)
+
+		jobs, err := jm.kubernetesClient.BatchV1().Jobs(jm.namespace).List(metav1.ListOptions{
+			LabelSelector: "executorType=" + fission.ExecutorTypeJob,
+		})
+		if err != nil {
+			log.Printf("Error listing jobs: %v", err)
+			continue
+		}
+
+		now := time.Now()
+		for i := range jobs.Items {
+			job := &jobs.Items[i]
+			if !jobExpired(job, jm.jobTTL, now) {
+				continue
+			}
+			log.Printf("Deleting job %v, created at %v, finished at %v", job.Name, job.CreationTimestamp, jobFinishedAt(job))
+			jm.deleteJob(job.Name)
+		}
+	}
+}
+
+func (jm *JobManager) deleteJob(name string) {
+	deletePropagation := metav1.DeletePropagationForeground
+	err := jm.kubernetesClient.BatchV1().Jobs(jm.namespace).Delete(name, &metav1.DeleteOptions{
+		PropagationPolicy: &deletePropagation,
+	})
+	if err != nil {
+		log.Printf("Error deleting job %v: %v", name, err)
+	}
+}
@@ -0,0 +1,167 @@
+package batchjob
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+
+	"github.com/fission/fission"
+)
+
+func TestInvocationStore(t *testing.T) {
+	s := makeInvocationStore()
+
+	_, err := s.get("foo")
+	if err == nil {
+		t.Errorf("expected error for unknown invocation")
+	} else if fe, ok := err.(fission.Error); !ok || fe.Code != fission.ErrorNotFound {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	s.add(&Invocation{ID: "foo", Status: InvocationRunning, StartTime: time.Now()})
+	inv, err := s.get("foo")
+	if err != nil {
+		t.Fatalf("error getting invocation: %v", err)
+	}
+	if inv.Status != InvocationRunning {
+		t.Errorf("expected running invocation, got %v", inv.Status)
+	}
+
+	inv = s.complete("foo", nil, &InvocationResult{StatusCode: 200, Body: []byte("done")})
+	if inv.Status != InvocationSucceeded || inv.CompletionTime == nil {
+		t.Errorf("expected succeeded invocation, got %#v", inv)
+	}
+
+	inv = s.complete("bar", &Invocation{ID: "bar"}, &InvocationResult{StatusCode: 500})
+	if inv.Status != InvocationFailed {
+		t.Errorf("expected failed invocation, got %v", inv.Status)
+	}
+
+	s.expire(time.Hour)
+	if _, err = s.get("foo"); err != nil {
+		t.Errorf("invocation expired too early")
+	}
+	s.expire(0)
+	if _, err = s.get("foo"); err == nil {
+		t.Errorf("invocation should have expired")
+	}
+}
+
+func TestInvocationFromJob(t *testing.T) {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "batch-foo",
+			Labels: map[string]string{
+				invocationIdLabel:   "foo",
+				"functionName":      "hello",
+				"functionNamespace": "test",
+			},
+		},
+	}
+	inv := invocationFromJob(job)
+	if inv.ID != "foo" || inv.Function.Name != "hello" || inv.Function.Namespace != "test" || inv.Status != InvocationRunning {
+		t.Errorf("unexpected invocation %#v", inv)
+	}
+	if jobFinishedAt(job) != nil {
+		t.Errorf("running job shouldn't have a finish time")
+	}
+
+	job.Status.Conditions = []batchv1.JobCondition{
+		{
+			Type:               batchv1.JobFailed,
+			Status:             apiv1.ConditionTrue,
+			Reason:             "BackoffLimitExceeded",
+			LastTransitionTime: metav1.Now(),
+		},
+	}
+	inv = invocationFromJob(job)
+	if inv.Status != InvocationFailed || len(inv.Error) == 0 {
+		t.Errorf("expected failed invocation, got %#v", inv)
+	}
+
+	job.Annotations = map[string]string{
+		invocationStatusAnnotation: string(InvocationSucceeded),
+		completedAtAnnotation:      time.Now().Format(time.RFC3339),
+	}
+	inv = invocationFromJob(job)
+	if inv.Status != InvocationSucceeded || inv.CompletionTime == nil {
+		t.Errorf("expected succeeded invocation, got %#v", inv)
+	}
+}
+
+func TestJobExpired(t *testing.T) {
+	now := time.Now()
+	deadline := int64(60)
+	makeJob := func(created time.Time, finished *time.Time, failed bool) *batchv1.Job {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+			Spec:       batchv1.JobSpec{ActiveDeadlineSeconds: &deadline},
+		}
+		if finished != nil {
+			job.Annotations = map[string]string{
+				invocationStatusAnnotation: string(InvocationSucceeded),
+				completedAtAnnotation:      finished.Format(time.RFC3339),
+			}
+		}
+		if failed {
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type:               batchv1.JobFailed,
+				Status:             apiv1.ConditionTrue,
+				Reason:             "DeadlineExceeded",
+				LastTransitionTime: metav1.NewTime(created.Add(time.Minute)),
+			}}
+		}
+		return job
+	}
+	longAgo := now.Add(-2 * time.Hour)
+	recently := now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		job     *batchv1.Job
+		expired bool
+	}{
+		{"running", makeJob(recently, nil, false), false},
+		{"completed recently", makeJob(longAgo, &recently, false), false},
+		{"completed long ago", makeJob(longAgo, &longAgo, false), true},
+		{"deadline exceeded", makeJob(longAgo, nil, true), true},
+		{"running past deadline", makeJob(longAgo, nil, false), true},
+	}
+	for _, test := range tests {
+		if expired := jobExpired(test.job, time.Hour, now); expired != test.expired {
+			t.Errorf("%v: expected expired %v, got %v", test.name, test.expired, expired)
+		}
+	}
+}
+
+func TestResultToken(t *testing.T) {
+	token, err := makeResultToken()
+	if err != nil {
+		t.Fatalf("error making token: %v", err)
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{resultTokenAnnotation: hashToken(token)},
+		},
+	}
+	if !checkToken(job, token) {
+		t.Errorf("expected token to be accepted")
+	}
+	if checkToken(job, "") || checkToken(job, token+"x") {
+		t.Errorf("expected wrong token to be rejected")
+	}
+	if checkToken(&batchv1.Job{}, "") {
+		t.Errorf("expected job without token to reject empty token")
+	}
+}
+
+func TestInvokeBodyLimit(t *testing.T) {
+	jm := &JobManager{}
+	_, err := jm.Invoke(&InvocationRequest{Body: make([]byte, MaxBodySize+1)})
+	if fe, ok := err.(fission.Error); !ok || fe.Code != fission.ErrorInvalidArgument {
+		t.Errorf("expected invalid argument error, got %v", err)
+	}
+}
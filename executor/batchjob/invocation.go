@@ -0,0 +1,199 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batchjob
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+)
+
+type (
+	InvocationStatus string
+
+	// InvocationRequest asks the executor to run a function once as a
+	// Kubernetes Job, with Body as the request body.
+	InvocationRequest struct {
+		Function    metav1.ObjectMeta `json:"function"`
+		ContentType string            `json:"contentType,omitempty"`
+		Body        []byte            `json:"body,omitempty"`
+	}
+
+	// InvocationResult is the response of the function, reported by the
+	// invoker running next to the function in the Job's pod.
+	InvocationResult struct {
+		StatusCode  int    `json:"statusCode"`
+		ContentType string `json:"contentType,omitempty"`
+		Body        []byte `json:"body,omitempty"`
+		Error       string `json:"error,omitempty"`
+	}
+
+	// Invocation is a single run of a batch function.
+	Invocation struct {
+		ID             string            `json:"id"`
+		Function       metav1.ObjectMeta `json:"function"`
+		JobName        string            `json:"jobName"`
+		Status         InvocationStatus  `json:"status"`
+		StartTime      time.Time         `json:"startTime"`
+		CompletionTime *time.Time        `json:"completionTime,omitempty"`
+		Result         *InvocationResult `json:"result,omitempty"`
+		Error          string            `json:"error,omitempty"`
+	}
+)
+
+const (
+	InvocationRunning   InvocationStatus = "Running"
+	InvocationSucceeded InvocationStatus = "Succeeded"
+	InvocationFailed    InvocationStatus = "Failed"
+)
+
+type (
+	storeRequestType int
+
+	// invocationStore keeps invocations started by this executor
+	// instance, along with their results. It's a cache: results are
+	// also saved in secrets owned by the Jobs, from which the status of
+	// older invocations is derived after an executor restart.
+	invocationStore struct {
+		invocations    map[string]*Invocation
+		requestChannel chan *storeRequest
+	}
+	storeRequest struct {
+		storeRequestType
+		id              string
+		invocation      *Invocation
+		result          *InvocationResult
+		age             time.Duration
+		responseChannel chan *storeResponse
+	}
+	storeResponse struct {
+		invocation *Invocation
+		error
+	}
+)
+
+const (
+	ADD storeRequestType = iota
+	GET
+	COMPLETE
+	EXPIRE
+)
+
+func makeInvocationStore() *invocationStore {
+	s := &invocationStore{
+		invocations:    make(map[string]*Invocation),
+		requestChannel: make(chan *storeRequest),
+	}
+	go s.service()
+	return s
+}
+
+func (s *invocationStore) service() {
+	for {
+		req := <-s.requestChannel
+		resp := &storeResponse{}
+		switch req.storeRequestType {
+		case ADD:
+			s.invocations[req.invocation.ID] = req.invocation
+		case GET:
+			inv, ok := s.invocations[req.id]
+			if !ok {
+				resp.error = fission.MakeError(fission.ErrorNotFound,
+					fmt.Sprintf("invocation %v not found", req.id))
+				break
+			}
+			// return a copy, the stored invocation may still change
+			invCopy := *inv
+			resp.invocation = &invCopy
+		case COMPLETE:
+			inv, ok := s.invocations[req.id]
+			if !ok {
+				inv = req.invocation
+				s.invocations[req.id] = inv
+			}
+			now := time.Now()
+			inv.CompletionTime = &now
+			inv.Result = req.result
+			if len(req.result.Error) == 0 && req.result.StatusCode < 400 {
+				inv.Status = InvocationSucceeded
+			} else {
+				inv.Status = InvocationFailed
+				inv.Error = req.result.Error
+			}
+			invCopy := *inv
+			resp.invocation = &invCopy
+		case EXPIRE:
+			for id, inv := range s.invocations {
+				if inv.CompletionTime != nil && time.Since(*inv.CompletionTime) > req.age {
+					delete(s.invocations, id)
+				}
+			}
+		}
+		req.responseChannel <- resp
+	}
+}
+
+func (s *invocationStore) add(inv *Invocation) {
+	responseChannel := make(chan *storeResponse)
+	s.requestChannel <- &storeRequest{
+		storeRequestType: ADD,
+		invocation:       inv,
+		responseChannel:  responseChannel,
+	}
+	<-responseChannel
+}
+
+func (s *invocationStore) get(id string) (*Invocation, error) {
+	responseChannel := make(chan *storeResponse)
+	s.requestChannel <- &storeRequest{
+		storeRequestType: GET,
+		id:               id,
+		responseChannel:  responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.invocation, resp.error
+}
+
+// complete records the result of an invocation. inv is stored if the
+// invocation isn't known yet, e.g. because it was started by a previous
+// executor instance.
+func (s *invocationStore) complete(id string, inv *Invocation, result *InvocationResult) *Invocation {
+	responseChannel := make(chan *storeResponse)
+	s.requestChannel <- &storeRequest{
+		storeRequestType: COMPLETE,
+		id:               id,
+		invocation:       inv,
+		result:           result,
+		responseChannel:  responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.invocation
+}
+
+// expire forgets invocations that completed more than age ago.
+func (s *invocationStore) expire(age time.Duration) {
+	responseChannel := make(chan *storeResponse)
+	s.requestChannel <- &storeRequest{
+		storeRequestType: EXPIRE,
+		age:              age,
+		responseChannel:  responseChannel,
+	}
+	<-responseChannel
+}
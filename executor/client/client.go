@@ -19,6 +19,7 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -29,6 +30,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fission/fission"
+	"github.com/fission/fission/executor/batchjob"
+	"github.com/fission/fission/executor/drain"
+	"github.com/fission/fission/executor/lease"
+	"github.com/fission/fission/executor/metrics"
 )
 
 type Client struct {
@@ -73,6 +78,76 @@ func (c *Client) GetServiceForFunction(metadata *metav1.ObjectMeta) (string, err
 	return string(svcName), nil
 }
 
+// AcquireLease gets a service for a function along with a lease that
+// counts against the function's concurrency limit. It returns an
+// ErrorTooManyRequests error if the function is at its limit, and an
+// ErrorNoSpace error if it's out of quota. Leases must be
+// released with ReleaseLease once the request is done.
+func (c *Client) AcquireLease(metadata *metav1.ObjectMeta) (*lease.Lease, error) {
+	executorUrl := c.executorUrl + "/v2/leases"
+
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(executorUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fission.MakeErrorFromHTTP(resp)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var l lease.Lease
+	err = json.Unmarshal(respBody, &l)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ReleaseLease gives back a lease, letting another request through to
+// the function.
+func (c *Client) ReleaseLease(id string) error {
+	req, err := http.NewRequest("DELETE", c.executorUrl+"/v2/leases/"+id, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fission.MakeErrorFromHTTP(resp)
+	}
+	return nil
+}
+
+// RenewLease keeps a lease from expiring while a request to the
+// function is still in flight.
+func (c *Client) RenewLease(id string) error {
+	req, err := http.NewRequest("PUT", c.executorUrl+"/v2/leases/"+id, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fission.MakeErrorFromHTTP(resp)
+	}
+	return nil
+}
+
 func (c *Client) service() {
 	ticker := time.NewTicker(time.Second * 5)
 	for {
@@ -99,6 +174,131 @@ func (c *Client) TapService(serviceUrl *url.URL) {
 	c.requestChan <- serviceUrl.String()
 }
 
+// ReportMetrics sends the request load of functions seen by a router
+// to the executor. It returns the function services the executor is
+// draining, which the router should stop using.
+func (c *Client) ReportMetrics(samples []metrics.Sample) ([]drain.Notice, error) {
+	executorUrl := c.executorUrl + "/v2/reportMetrics"
+
+	body, err := json.Marshal(samples)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(executorUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fission.MakeErrorFromHTTP(resp)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var notices []drain.Notice
+	err = json.Unmarshal(respBody, &notices)
+	if err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
+
+// CreateInvocation starts a batch job running a function once, and
+// returns without waiting for the function to finish.
+func (c *Client) CreateInvocation(req *batchjob.InvocationRequest) (*batchjob.Invocation, error) {
+	executorUrl := c.executorUrl + "/v2/invocations"
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(executorUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 201 {
+		return nil, fission.MakeErrorFromHTTP(resp)
+	}
+	return readInvocation(resp)
+}
+
+// GetInvocation returns the status of a batch invocation, and its
+// result once it's finished.
+func (c *Client) GetInvocation(id string) (*batchjob.Invocation, error) {
+	executorUrl := c.executorUrl + "/v2/invocations/" + id
+
+	resp, err := http.Get(executorUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fission.MakeErrorFromHTTP(resp)
+	}
+	return readInvocation(resp)
+}
+
+// ListFuncSvcs returns the function services cached by the executor.
+func (c *Client) ListFuncSvcs() ([]FuncSvcStatus, error) {
+	var fsvcs []FuncSvcStatus
+	err := c.get("/v2/funcsvcs", &fsvcs)
+	return fsvcs, err
+}
+
+// ListPools returns the status of the generic pools of all environments.
+func (c *Client) ListPools() ([]PoolStatus, error) {
+	var pools []PoolStatus
+	err := c.get("/v2/pools", &pools)
+	return pools, err
+}
+
+// GetFunctionStatus returns whether a function is warm, and where it
+// runs.
+func (c *Client) GetFunctionStatus(m *metav1.ObjectMeta) (*FunctionStatus, error) {
+	var status FunctionStatus
+	err := c.get(fmt.Sprintf("/v2/functions/%v/status?namespace=%v", m.Name, m.Namespace), &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *Client) get(relativeUrl string, result interface{}) error {
+	resp, err := http.Get(c.executorUrl + relativeUrl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fission.MakeErrorFromHTTP(resp)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+func readInvocation(resp *http.Response) (*batchjob.Invocation, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var inv batchjob.Invocation
+	err = json.Unmarshal(body, &inv)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
 func (c *Client) _tapService(serviceUrlStr string) error {
 	executorUrl := c.executorUrl + "/v2/tapService"
 
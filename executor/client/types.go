@@ -0,0 +1,57 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api"
+)
+
+type (
+	// FuncSvcStatus describes a function service cached by the
+	// executor, i.e. a specialized pod or deployment serving a
+	// function.
+	FuncSvcStatus struct {
+		Function          metav1.ObjectMeta     `json:"function"`
+		Environment       *metav1.ObjectMeta    `json:"environment,omitempty"`
+		Name              string                `json:"name,omitempty"`
+		Address           string                `json:"address"`
+		Executor          string                `json:"executor"`
+		Ctime             time.Time             `json:"ctime"`
+		Atime             time.Time             `json:"atime"`
+		ReapTime          *time.Time            `json:"reapTime,omitempty"` // when the service is reaped if it stays idle; unset if it's never reaped
+		KubernetesObjects []api.ObjectReference `json:"kubernetesObjects"`
+	}
+
+	// PoolStatus counts the pods of an environment's generic pool.
+	PoolStatus struct {
+		Environment metav1.ObjectMeta `json:"environment"`
+		Ready       int               `json:"ready"`       // generic pods ready to be specialized
+		Pending     int               `json:"pending"`     // generic pods still starting up
+		Specialized int               `json:"specialized"` // pods taken from the pool and specialized for a function
+	}
+
+	// FunctionStatus describes where a function currently runs.
+	FunctionStatus struct {
+		Function     metav1.ObjectMeta `json:"function"`
+		ExecutorType string            `json:"executorType"`
+		Warm         bool              `json:"warm"`
+		Services     []FuncSvcStatus   `json:"services"`
+	}
+)
@@ -0,0 +1,152 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drain
+
+import (
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/metrics"
+)
+
+type requestType int
+
+const (
+	ADD requestType = iota
+	REMOVE
+	LIST
+)
+
+type (
+	// Notice tells routers to stop sending requests to a function
+	// service that's about to be deleted, and to drop it from their
+	// caches.
+	Notice struct {
+		Function metav1.ObjectMeta `json:"function"`
+		Address  string            `json:"address"`
+	}
+
+	// Drainer deletes function services gracefully: routers are told
+	// to stop using the service first, and the service is only deleted
+	// once requests in flight have finished, or a grace period has
+	// passed.
+	Drainer struct {
+		load           metrics.MetricsSource
+		notifyPeriod   time.Duration // time routers need to pick up notices
+		gracePeriod    time.Duration // longest time to wait for requests in flight
+		notices        map[string]*Notice
+		requestChannel chan *request
+	}
+	request struct {
+		requestType
+		notice          *Notice
+		responseChannel chan []Notice
+	}
+)
+
+// MakeDrainer creates a drainer. notifyPeriod must be longer than the
+// interval at which routers report metrics, since that's when they
+// receive notices.
+func MakeDrainer(load metrics.MetricsSource, notifyPeriod time.Duration, gracePeriod time.Duration) *Drainer {
+	d := &Drainer{
+		load:           load,
+		notifyPeriod:   notifyPeriod,
+		gracePeriod:    gracePeriod,
+		notices:        make(map[string]*Notice),
+		requestChannel: make(chan *request),
+	}
+	go d.service()
+	return d
+}
+
+func (d *Drainer) service() {
+	for {
+		req := <-d.requestChannel
+		switch req.requestType {
+		case ADD:
+			d.notices[req.notice.Address] = req.notice
+			req.responseChannel <- nil
+		case REMOVE:
+			delete(d.notices, req.notice.Address)
+			req.responseChannel <- nil
+		case LIST:
+			notices := make([]Notice, 0, len(d.notices))
+			for _, n := range d.notices {
+				notices = append(notices, *n)
+			}
+			req.responseChannel <- notices
+		}
+	}
+}
+
+func (d *Drainer) call(reqType requestType, notice *Notice) []Notice {
+	responseChannel := make(chan []Notice)
+	d.requestChannel <- &request{
+		requestType:     reqType,
+		notice:          notice,
+		responseChannel: responseChannel,
+	}
+	return <-responseChannel
+}
+
+// Notices returns the function services currently being drained.
+func (d *Drainer) Notices() []Notice {
+	return d.call(LIST, nil)
+}
+
+// Drain calls deleteFunc to delete fsvc once it's drained; it returns
+// right away. The caller must have removed fsvc from the function
+// service cache already, so that no new requests are sent to it.
+func (d *Drainer) Drain(fsvc *fscache.FuncSvc, deleteFunc func()) {
+	notice := &Notice{
+		Function: *fsvc.Function,
+		Address:  fsvc.Address,
+	}
+	go func() {
+		d.call(ADD, notice)
+		defer d.call(REMOVE, notice)
+
+		// Give routers a chance to see the notice, so that the
+		// in-flight count doesn't grow any further.
+		time.Sleep(d.notifyPeriod)
+
+		// Routers count requests in flight per function rather than
+		// per address, so if the function has already been started
+		// elsewhere we may end up waiting for the whole grace period.
+		deadline := time.Now().Add(d.gracePeriod)
+		for time.Now().Before(deadline) {
+			load, err := d.load.GetFunctionLoad(&notice.Function)
+			if err != nil {
+				log.Printf("Error getting load of function %v, not waiting for requests in flight: %v", notice.Function.Name, err)
+				break
+			}
+			if load.InFlight == 0 {
+				break
+			}
+			time.Sleep(time.Second)
+		}
+		if time.Now().After(deadline) {
+			log.Printf("Function %v at %v still has requests in flight after %v, deleting anyway",
+				notice.Function.Name, notice.Address, d.gracePeriod)
+		}
+
+		deleteFunc()
+	}()
+}
@@ -0,0 +1,73 @@
+package drain
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/metrics"
+)
+
+type fakeMetricsSource struct {
+	inFlight int64
+}
+
+func (f *fakeMetricsSource) GetFunctionLoad(m *metav1.ObjectMeta) (*metrics.FunctionLoad, error) {
+	return &metrics.FunctionLoad{InFlight: atomic.LoadInt64(&f.inFlight)}, nil
+}
+
+func TestDrain(t *testing.T) {
+	source := &fakeMetricsSource{inFlight: 2}
+	d := MakeDrainer(source, 10*time.Millisecond, time.Minute)
+
+	fsvc := &fscache.FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+		Address:  "10.0.0.1:8888",
+	}
+	deleted := make(chan bool, 1)
+	d.Drain(fsvc, func() { deleted <- true })
+
+	time.Sleep(100 * time.Millisecond)
+	notices := d.Notices()
+	if len(notices) != 1 || notices[0].Address != fsvc.Address {
+		t.Fatalf("expected a notice for %v, got %v", fsvc.Address, notices)
+	}
+	select {
+	case <-deleted:
+		t.Fatalf("deleted function service with requests in flight")
+	default:
+	}
+
+	atomic.StoreInt64(&source.inFlight, 0)
+	select {
+	case <-deleted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("function service wasn't deleted after requests finished")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if notices := d.Notices(); len(notices) != 0 {
+		t.Errorf("expected no notices after drain, got %v", notices)
+	}
+}
+
+func TestDrainGracePeriod(t *testing.T) {
+	source := &fakeMetricsSource{inFlight: 1}
+	d := MakeDrainer(source, 0, 50*time.Millisecond)
+
+	fsvc := &fscache.FuncSvc{
+		Function: &metav1.ObjectMeta{Name: "foo", UID: "1212"},
+		Address:  "10.0.0.1:8888",
+	}
+	deleted := make(chan bool, 1)
+	d.Drain(fsvc, func() { deleted <- true })
+
+	select {
+	case <-deleted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("function service wasn't deleted after the grace period")
+	}
+}
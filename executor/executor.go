@@ -17,6 +17,7 @@ limitations under the License.
 package executor
 
 import (
+	"fmt"
 	"log"
 	"runtime/debug"
 	"strings"
@@ -29,21 +30,50 @@ import (
 	"github.com/fission/fission"
 	"github.com/fission/fission/cache"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/batchjob"
+	"github.com/fission/fission/executor/drain"
 	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/lease"
+	"github.com/fission/fission/executor/metrics"
 	"github.com/fission/fission/executor/newdeploy"
 	"github.com/fission/fission/executor/poolmgr"
+	"github.com/fission/fission/executor/quota"
 )
 
+// Function services unused for idlePodReapTime are reaped; newdeploy
+// uses the same idle time for scaling deployments down to zero.
+const idlePodReapTime = 2 * time.Minute
+
+// Leases of functions with a concurrency limit expire after leaseTTL,
+// in case the router holding them goes away. Routers renew them while
+// requests are in flight.
+const leaseTTL = time.Minute
+
+// Concurrency limits of function versions unused for limitCacheTime
+// are forgotten.
+const limitCacheTime = 10 * time.Minute
+
 type (
 	Executor struct {
 		gpm           *poolmgr.GenericPoolManager
 		ndm           *newdeploy.NewDeploy
-		functionEnv   *cache.Cache
+		jobm          *batchjob.JobManager
+		backends      map[fission.ExecutorType]ExecutorBackend
 		fissionClient *crd.FissionClient
 		fsCache       *fscache.FunctionServiceCache
-
-		requestChan chan *createFuncServiceRequest
-		fsCreateWg  map[string]*sync.WaitGroup
+		metrics       *metrics.Aggregator
+		drainer       *drain.Drainer
+		leases        *lease.Manager
+		limits        *cache.Cache // function cache key -> MaxConcurrency
+		prewarmer     *prewarmer
+
+		// getFunction fetches functions from the API; tests without
+		// a cluster replace it.
+		getFunction func(m *metav1.ObjectMeta) (*crd.Function, error)
+
+		requestChan    chan *createFuncServiceRequest
+		createDoneChan chan string
+		fsCreateWg     map[string]*sync.WaitGroup
 	}
 	createFuncServiceRequest struct {
 		funcMeta *metav1.ObjectMeta
@@ -56,16 +86,39 @@ type (
 	}
 )
 
-func MakeExecutor(gpm *poolmgr.GenericPoolManager, ndm *newdeploy.NewDeploy, fissionClient *crd.FissionClient, fsCache *fscache.FunctionServiceCache) *Executor {
+func MakeExecutor(gpm *poolmgr.GenericPoolManager, ndm *newdeploy.NewDeploy, jobm *batchjob.JobManager, fissionClient *crd.FissionClient, fsCache *fscache.FunctionServiceCache, metricsAgg *metrics.Aggregator, drainer *drain.Drainer) *Executor {
 	executor := &Executor{
 		gpm:           gpm,
 		ndm:           ndm,
-		functionEnv:   cache.MakeCache(10*time.Second, 0),
+		jobm:          jobm,
+		backends:      make(map[fission.ExecutorType]ExecutorBackend),
 		fissionClient: fissionClient,
 		fsCache:       fsCache,
+		metrics:       metricsAgg,
+		drainer:       drainer,
+		leases:        lease.MakeManager(leaseTTL),
+		limits:        cache.MakeCache(0, limitCacheTime),
+
+		requestChan:    make(chan *createFuncServiceRequest),
+		createDoneChan: make(chan string),
+		fsCreateWg:     make(map[string]*sync.WaitGroup),
+	}
+	executor.getFunction = func(m *metav1.ObjectMeta) (*crd.Function, error) {
+		return executor.fissionClient.Functions(m.Namespace).Get(m.Name)
+	}
+
+	if gpm != nil {
+		executor.RegisterBackend(fission.ExecutorTypePoolmgr, gpm)
+	}
+	if ndm != nil {
+		// container functions are deployments too, running the
+		// function's own image
+		executor.RegisterBackend(fission.ExecutorTypeNewdeploy, ndm)
+		executor.RegisterBackend(fission.ExecutorTypeContainer, ndm)
+	}
 
-		requestChan: make(chan *createFuncServiceRequest),
-		fsCreateWg:  make(map[string]*sync.WaitGroup),
+	if fissionClient != nil {
+		executor.prewarmer = makePrewarmer(executor, fissionClient.GetCrdClient())
 	}
 	go executor.serveCreateFuncServices()
 	return executor
@@ -79,42 +132,51 @@ func MakeExecutor(gpm *poolmgr.GenericPoolManager, ndm *newdeploy.NewDeploy, fis
 // that request to complete.
 func (executor *Executor) serveCreateFuncServices() {
 	for {
-		req := <-executor.requestChan
-		m := req.funcMeta
-
-		// Cache miss -- is this first one to request the func?
-		wg, found := executor.fsCreateWg[crd.CacheKey(m)]
-		if !found {
-			// create a waitgroup for other requests for
-			// the same function to wait on
-			wg := &sync.WaitGroup{}
-			wg.Add(1)
-			executor.fsCreateWg[crd.CacheKey(m)] = wg
-
-			// launch a goroutine for each request, to parallelize
-			// the specialization of different functions
-			go func() {
-				fsvc, err := executor.createServiceForFunction(m)
-				req.respChan <- &createFuncServiceResponse{
-					funcSvc: fsvc,
-					err:     err,
-				}
-				delete(executor.fsCreateWg, crd.CacheKey(m))
-				wg.Done()
-			}()
-		} else {
-			// There's an existing request for this function, wait for it to finish
-			go func() {
-				log.Printf("Waiting for concurrent request for the same function: %v", m)
-				wg.Wait()
-
-				// get the function service from the cache
-				fsvc, err := executor.fsCache.GetByFunction(m)
-				req.respChan <- &createFuncServiceResponse{
-					funcSvc: fsvc,
-					err:     err,
-				}
-			}()
+		select {
+		case key := <-executor.createDoneChan:
+			// Only this goroutine touches fsCreateWg, so the
+			// creating goroutine hands its key back here when
+			// it's done, before anyone gets its result.
+			delete(executor.fsCreateWg, key)
+			continue
+		case req := <-executor.requestChan:
+			m := req.funcMeta
+			key := crd.CacheKey(m)
+
+			// Cache miss -- is this first one to request the func?
+			wg, found := executor.fsCreateWg[key]
+			if !found {
+				// create a waitgroup for other requests for
+				// the same function to wait on
+				wg := &sync.WaitGroup{}
+				wg.Add(1)
+				executor.fsCreateWg[key] = wg
+
+				// launch a goroutine for each request, to parallelize
+				// the specialization of different functions
+				go func() {
+					fsvc, err := executor.createServiceForFunction(m)
+					executor.createDoneChan <- key
+					wg.Done()
+					req.respChan <- &createFuncServiceResponse{
+						funcSvc: fsvc,
+						err:     err,
+					}
+				}()
+			} else {
+				// There's an existing request for this function, wait for it to finish
+				go func() {
+					log.Printf("Waiting for concurrent request for the same function: %v", m)
+					wg.Wait()
+
+					// get the function service from the cache
+					fsvc, err := executor.fsCache.GetByFunction(m)
+					req.respChan <- &createFuncServiceResponse{
+						funcSvc: fsvc,
+						err:     err,
+					}
+				}()
+			}
 		}
 	}
 }
@@ -122,64 +184,23 @@ func (executor *Executor) serveCreateFuncServices() {
 func (executor *Executor) createServiceForFunction(meta *metav1.ObjectMeta) (*fscache.FuncSvc, error) {
 	log.Printf("[%v] No cached function service found, creating one", meta.Name)
 
-	// from Func -> get Env
-	log.Printf("[%v] getting environment for function", meta.Name)
-	env, err := executor.getFunctionEnv(meta)
+	fn, err := executor.getFunction(meta)
 	if err != nil {
 		return nil, err
 	}
 
-	fn, err := executor.fissionClient.
-		Functions(meta.Namespace).
-		Get(meta.Name)
-	if err != nil {
-		return nil, err
+	executorType := fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType
+	if executorType == fission.ExecutorTypeJob {
+		return nil, fission.MakeError(fission.ErrorInvalidArgument,
+			fmt.Sprintf("function %v runs as a batch job and has no service, create an invocation instead", meta.Name))
 	}
 
-	switch fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType {
-	case fission.ExecutorTypeNewdeploy:
-		fs, err := executor.ndm.GetFuncSvc(meta)
-		return fs, err
-	default:
-		pool, err := executor.gpm.GetPool(env)
-		if err != nil {
-			return nil, err
-		}
-		// from GenericPool -> get one function container
-		// (this also adds to the cache)
-		log.Printf("[%v] getting function service from pool", meta.Name)
-		fsvc, err := pool.GetFuncSvc(meta)
-		return fsvc, err
+	backend, ok := executor.getBackend(fn)
+	if !ok {
+		return nil, fission.MakeError(fission.ErrorInvalidArgument,
+			fmt.Sprintf("function %v has unknown executor type %v", meta.Name, executorType))
 	}
-}
-
-func (executor *Executor) getFunctionEnv(m *metav1.ObjectMeta) (*crd.Environment, error) {
-	var env *crd.Environment
-
-	// Cached ?
-	result, err := executor.functionEnv.Get(crd.CacheKey(m))
-	if err == nil {
-		env = result.(*crd.Environment)
-		return env, nil
-	}
-
-	// Cache miss -- get func from controller
-	f, err := executor.fissionClient.Functions(m.Namespace).Get(m.Name)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get env from metadata
-	log.Printf("[%v] getting env", m)
-	env, err = executor.fissionClient.Environments(f.Spec.Environment.Namespace).Get(f.Spec.Environment.Name)
-	if err != nil {
-		return nil, err
-	}
-
-	// cache for future lookups
-	executor.functionEnv.Set(crd.CacheKey(m), env)
-
-	return env, nil
+	return backend.GetFuncSvc(fn)
 }
 
 func dumpStackTrace() {
@@ -201,18 +222,35 @@ func StartExecutor(fissionNamespace string, functionNamespace string, port int)
 
 	fsCache := fscache.MakeFunctionServiceCache()
 
+	// Routers report request load every few seconds; ignore samples
+	// from routers that have stopped reporting.
+	metricsAgg := metrics.MakeAggregator(30 * time.Second)
+
+	// Routers pick up drain notices when they report metrics, every
+	// 5 seconds.
+	drainer := drain.MakeDrainer(metricsAgg, 10*time.Second, 2*time.Minute)
+
+	quotaChecker := quota.MakeChecker(fissionClient, fsCache)
+
 	poolID := strings.ToLower(uniuri.NewLen(8))
-	cleanupObjects(kubernetesClient, functionNamespace, poolID)
-	go idleObjectReaper(kubernetesClient, fissionClient, fsCache, time.Minute*2)
 	gpm := poolmgr.MakeGenericPoolManager(
 		fissionClient, kubernetesClient, fissionNamespace,
-		functionNamespace, fsCache, poolID)
+		functionNamespace, fsCache, drainer, quotaChecker, idlePodReapTime, poolID)
 
 	ndm := newdeploy.MakeNewDeploy(
 		fissionClient, kubernetesClient, restClient,
-		functionNamespace, fsCache, poolID)
+		functionNamespace, fsCache, metricsAgg, drainer, quotaChecker, idlePodReapTime, poolID)
+
+	// Invokers in batch job pods report results back through the
+	// executor's service.
+	jobm := batchjob.MakeJobManager(
+		fissionClient, kubernetesClient, functionNamespace,
+		fmt.Sprintf("http://executor.%v", fissionNamespace), time.Hour)
 
-	api := MakeExecutor(gpm, ndm, fissionClient, fsCache)
+	api := MakeExecutor(gpm, ndm, jobm, fissionClient, fsCache, metricsAgg, drainer)
+	for _, backend := range api.uniqueBackends() {
+		backend.CleanupOnStart()
+	}
 
 	go api.Serve(port)
 
@@ -0,0 +1,120 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake has an in-memory executor backend, for testing the
+// executor and new executor types without a Kubernetes cluster.
+package fake
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+type (
+	// Backend creates function services that exist only in the
+	// function service cache. It counts the services it creates, so
+	// tests can check how often the executor asked for one.
+	Backend struct {
+		fsCache *fscache.FunctionServiceCache
+
+		// Delay is how long creating a function service takes, to
+		// simulate specializing a pod.
+		Delay time.Duration
+
+		// Err, if set, is returned instead of creating function
+		// services.
+		Err error
+
+		lock    sync.Mutex
+		created map[string]int // function cache key -> services created
+		deleted map[string]int // function cache key -> services deleted
+		nextID  int
+	}
+)
+
+func MakeBackend(fsCache *fscache.FunctionServiceCache) *Backend {
+	return &Backend{
+		fsCache: fsCache,
+		created: make(map[string]int),
+		deleted: make(map[string]int),
+	}
+}
+
+func (b *Backend) GetFuncSvc(fn *crd.Function) (*fscache.FuncSvc, error) {
+	if b.Delay > 0 {
+		time.Sleep(b.Delay)
+	}
+	if b.Err != nil {
+		return nil, b.Err
+	}
+
+	b.lock.Lock()
+	b.created[crd.CacheKey(&fn.Metadata)]++
+	b.nextID++
+	id := b.nextID
+	b.lock.Unlock()
+
+	m := fn.Metadata
+	fsvc := &fscache.FuncSvc{
+		Name:     fmt.Sprintf("fake-%v", id),
+		Function: &m,
+		Address:  fmt.Sprintf("10.0.0.%v:8888", id),
+		Ctime:    time.Now(),
+		Atime:    time.Now(),
+	}
+	_, err := b.fsCache.Add(*fsvc)
+	if err != nil {
+		return nil, err
+	}
+	return fsvc, nil
+}
+
+func (b *Backend) TapService(address string) error {
+	return b.fsCache.TouchByAddress(address)
+}
+
+func (b *Backend) DeleteFuncSvc(fsvc *fscache.FuncSvc) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.deleted[crd.CacheKey(fsvc.Function)]++
+	return nil
+}
+
+func (b *Backend) ListFuncSvcs() ([]*fscache.FuncSvc, error) {
+	return b.fsCache.List()
+}
+
+func (b *Backend) CleanupOnStart() {}
+
+// Created returns the number of function services created for a
+// function.
+func (b *Backend) Created(fn *crd.Function) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.created[crd.CacheKey(&fn.Metadata)]
+}
+
+// Deleted returns the number of function services deleted for a
+// function.
+func (b *Backend) Deleted(fn *crd.Function) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.deleted[crd.CacheKey(&fn.Metadata)]
+}
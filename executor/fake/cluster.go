@@ -0,0 +1,322 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sFake "k8s.io/client-go/kubernetes/fake"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	k8sTesting "k8s.io/client-go/testing"
+)
+
+type (
+	// Cluster runs a fake Kubernetes clientset with just enough of the
+	// cluster's controllers simulated for the executor: deployments get
+	// pods, which become ready after a delay set with SetReadyDelay,
+	// and services get cluster IPs.
+	//
+	// Pods get loopback addresses (127.0.0.1, 127.0.0.2, ...), so that
+	// a Runtime listening on all interfaces answers for all of them.
+	Cluster struct {
+		Client *k8sFake.Clientset
+
+		lock       sync.Mutex
+		readyDelay time.Duration // how long pods take to become ready
+		nextPod    int
+		nextIP     int
+		nextSvc    int
+		created    map[string]time.Time       // pod namespace/name -> creation time
+		selectors  map[string]labels.Selector // deployment namespace/name -> selector
+		stop       chan struct{}
+	}
+)
+
+// syncInterval is how often the simulated controllers run.
+const syncInterval = 20 * time.Millisecond
+
+// MakeCluster creates a simulated cluster holding objects, and starts
+// its controllers.
+func MakeCluster(objects ...runtime.Object) *Cluster {
+	c := &Cluster{
+		Client:    k8sFake.NewSimpleClientset(objects...),
+		created:   make(map[string]time.Time),
+		selectors: make(map[string]labels.Selector),
+		stop:      make(chan struct{}),
+	}
+
+	// Services are given a cluster IP as they're created, since the
+	// executor uses it right away. Reactors run with the clientset
+	// locked, so this one must not use the client.
+	c.Client.PrependReactor("create", "services", func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		svc := action.(k8sTesting.CreateAction).GetObject().(*apiv1.Service)
+		if len(svc.Spec.ClusterIP) == 0 {
+			c.lock.Lock()
+			c.nextSvc++
+			svc.Spec.ClusterIP = fmt.Sprintf("10.96.%v.%v", c.nextSvc/250, c.nextSvc%250+1)
+			c.lock.Unlock()
+		}
+		return false, nil, nil
+	})
+
+	go c.run()
+	return c
+}
+
+// SetReadyDelay sets how long pods take to become ready.
+func (c *Cluster) SetReadyDelay(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.readyDelay = d
+}
+
+// PrependReactor adds a reactor to the clientset. Unlike the
+// clientset's own PrependReactor, it's safe to call while the simulated
+// controllers are running.
+func (c *Cluster) PrependReactor(verb string, resource string, reaction k8sTesting.ReactionFunc) {
+	c.Client.Lock()
+	defer c.Client.Unlock()
+	c.Client.PrependReactor(verb, resource, reaction)
+}
+
+// Stop stops the simulated controllers.
+func (c *Cluster) Stop() {
+	close(c.stop)
+}
+
+func (c *Cluster) run() {
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(syncInterval):
+		}
+		err := c.sync()
+		if err != nil {
+			log.Printf("Error simulating cluster: %v", err)
+		}
+	}
+}
+
+// sync does what the deployment, replicaset and garbage collector
+// controllers and the kubelets would.
+func (c *Cluster) sync() error {
+	deployments, err := c.Client.ExtensionsV1beta1().Deployments(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool)
+	for i := range deployments.Items {
+		depl := &deployments.Items[i]
+		key := depl.Namespace + "/" + depl.Name
+		exists[key] = true
+		c.selectors[key] = deploymentSelector(depl)
+		err = c.syncDeployment(depl)
+		if err != nil {
+			return err
+		}
+	}
+
+	pods, err := c.Client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		// Pods are deleted along with their deployment, except for
+		// relabeled ones, which the deployment has released.
+		key := pod.Namespace + "/" + podOwner(pod)
+		if selector, ok := c.selectors[key]; ok && !exists[key] {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				err = c.Client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, nil)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		err = c.syncPod(pod)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDeployment creates or deletes pods so that the deployment has
+// the right number of them, and updates the deployment's status.
+// Relabeled pods no longer match the selector, so they're replaced.
+func (c *Cluster) syncDeployment(depl *v1beta1.Deployment) error {
+	replicas := int32(1)
+	if depl.Spec.Replicas != nil {
+		replicas = *depl.Spec.Replicas
+	}
+
+	pods, err := c.Client.CoreV1().Pods(depl.Namespace).List(metav1.ListOptions{
+		LabelSelector: deploymentSelector(depl).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var ready int32
+	for i, pod := range pods.Items {
+		if int32(i) >= replicas {
+			err = c.Client.CoreV1().Pods(depl.Namespace).Delete(pod.Name, nil)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if isPodReady(&pod) {
+			ready++
+		}
+	}
+	for i := int32(len(pods.Items)); i < replicas; i++ {
+		err = c.createPod(depl)
+		if err != nil {
+			return err
+		}
+	}
+
+	if depl.Status.Replicas == replicas && depl.Status.ReadyReplicas == ready &&
+		depl.Status.AvailableReplicas == ready {
+		return nil
+	}
+
+	// The fake clientset doesn't check resource versions, so get the
+	// latest deployment to avoid undoing a change to its spec.
+	latest, err := c.Client.ExtensionsV1beta1().Deployments(depl.Namespace).Get(depl.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	latest.Status.Replicas = replicas
+	latest.Status.ReadyReplicas = ready
+	latest.Status.AvailableReplicas = ready
+	_, err = c.Client.ExtensionsV1beta1().Deployments(depl.Namespace).Update(latest)
+	return err
+}
+
+func (c *Cluster) createPod(depl *v1beta1.Deployment) error {
+	c.lock.Lock()
+	c.nextPod++
+	n := c.nextPod
+	c.lock.Unlock()
+
+	podLabels := make(map[string]string)
+	for k, v := range depl.Spec.Template.Labels {
+		podLabels[k] = v
+	}
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v-%v", depl.Name, n),
+			Namespace: depl.Namespace,
+			Labels:    podLabels,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "extensions/v1beta1",
+					Kind:       "Deployment",
+					Name:       depl.Name,
+					UID:        depl.UID,
+				},
+			},
+		},
+		Spec: depl.Spec.Template.Spec,
+		Status: apiv1.PodStatus{
+			Phase: apiv1.PodPending,
+		},
+	}
+
+	c.lock.Lock()
+	c.created[pod.Namespace+"/"+pod.Name] = time.Now()
+	c.lock.Unlock()
+
+	_, err := c.Client.CoreV1().Pods(depl.Namespace).Create(pod)
+	return err
+}
+
+// syncPod starts pods once they've existed for the ready delay.
+func (c *Cluster) syncPod(pod *apiv1.Pod) error {
+	if pod.Status.Phase != apiv1.PodPending {
+		return nil
+	}
+
+	c.lock.Lock()
+	created, ok := c.created[pod.Namespace+"/"+pod.Name]
+	if !ok {
+		// created by the test rather than a deployment
+		created = time.Now()
+		c.created[pod.Namespace+"/"+pod.Name] = created
+	}
+	readyDelay := c.readyDelay
+	c.lock.Unlock()
+	if time.Since(created) < readyDelay {
+		return nil
+	}
+
+	c.lock.Lock()
+	n := c.nextIP
+	c.nextIP++
+	c.lock.Unlock()
+
+	pod.Status.Phase = apiv1.PodRunning
+	pod.Status.PodIP = fmt.Sprintf("127.0.%v.%v", n/250, n%250+1)
+	pod.Status.ContainerStatuses = make([]apiv1.ContainerStatus, 0, len(pod.Spec.Containers))
+	for _, container := range pod.Spec.Containers {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, apiv1.ContainerStatus{
+			Name:  container.Name,
+			Ready: true,
+		})
+	}
+	_, err := c.Client.CoreV1().Pods(pod.Namespace).Update(pod)
+	return err
+}
+
+func deploymentSelector(depl *v1beta1.Deployment) labels.Selector {
+	if depl.Spec.Selector != nil {
+		return labels.Set(depl.Spec.Selector.MatchLabels).AsSelector()
+	}
+	return labels.Set(depl.Spec.Template.Labels).AsSelector()
+}
+
+func podOwner(pod *apiv1.Pod) string {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "Deployment" {
+			return ref.Name
+		}
+	}
+	return ""
+}
+
+func isPodReady(pod *apiv1.Pod) bool {
+	if pod.Status.Phase != apiv1.PodRunning {
+		return false
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if !cs.Ready {
+			return false
+		}
+	}
+	return true
+}
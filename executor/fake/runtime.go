@@ -0,0 +1,145 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/environments/fetcher"
+)
+
+type (
+	// Runtime stands in for the fetcher and environment runtime of
+	// every pod of a Cluster. It serves the fetch and specialize calls
+	// of the executor on one port, listening on all interfaces, so
+	// both the fetcher and runtime ports of pools must be set to Port.
+	Runtime struct {
+		Port int
+
+		listener net.Listener
+		server   *http.Server
+
+		lock            sync.Mutex
+		fetches         []fetcher.FetchRequest
+		specializations map[string][]fission.FunctionLoadRequest // pod IP -> load requests
+		failures        int
+	}
+)
+
+func MakeRuntime() (*Runtime, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+	r := &Runtime{
+		Port:            listener.Addr().(*net.TCPAddr).Port,
+		listener:        listener,
+		specializations: make(map[string][]fission.FunctionLoadRequest),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", r.fetch)
+	mux.HandleFunc("/specialize", r.specialize)
+	mux.HandleFunc("/v2/specialize", r.specialize)
+	r.server = &http.Server{Handler: mux}
+	go r.server.Serve(listener)
+	return r, nil
+}
+
+func (r *Runtime) Close() {
+	r.listener.Close()
+}
+
+// FailSpecializations makes the next count specialize calls fail.
+func (r *Runtime) FailSpecializations(count int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.failures = count
+}
+
+// Fetches returns the fetch requests received so far.
+func (r *Runtime) Fetches() []fetcher.FetchRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]fetcher.FetchRequest{}, r.fetches...)
+}
+
+// Specializations returns the load requests received by the pod at
+// podIP that succeeded.
+func (r *Runtime) Specializations(podIP string) []fission.FunctionLoadRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]fission.FunctionLoadRequest{}, r.specializations[podIP]...)
+}
+
+func (r *Runtime) fetch(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+	var fr fetcher.FetchRequest
+	err = json.Unmarshal(body, &fr)
+	if err != nil {
+		http.Error(w, "Failed to parse request", 400)
+		return
+	}
+
+	r.lock.Lock()
+	r.fetches = append(r.fetches, fr)
+	r.lock.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *Runtime) specialize(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+
+	// v1 environments get an empty request
+	var lr fission.FunctionLoadRequest
+	if strings.HasPrefix(req.URL.Path, "/v2/") {
+		err = json.Unmarshal(body, &lr)
+		if err != nil {
+			http.Error(w, "Failed to parse request", 400)
+			return
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		host = req.Host
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "specialization failed", 500)
+		return
+	}
+	r.specializations[host] = append(r.specializations[host], lr)
+	w.WriteHeader(http.StatusOK)
+}
@@ -34,19 +34,35 @@ type executorType int
 const (
 	TOUCH fscRequestType = iota
 	LISTOLD
+	LISTOLDBYEXECUTOR
+	LIST
 	LOG
 )
 
 const (
 	POOLMGR executorType = iota
 	NEWDEPLOY
+	CONTAINER
 )
 
+func (e executorType) String() string {
+	switch e {
+	case POOLMGR:
+		return fission.ExecutorTypePoolmgr
+	case NEWDEPLOY:
+		return fission.ExecutorTypeNewdeploy
+	case CONTAINER:
+		return fission.ExecutorTypeContainer
+	default:
+		return "unknown"
+	}
+}
+
 type (
 	FuncSvc struct {
 		Name              string                // Name of object
 		Function          *metav1.ObjectMeta    // function this pod/service is for
-		Environment       *crd.Environment      // function's environment
+		Environment       *crd.Environment      // function's environment; nil for container functions
 		Address           string                // Host:Port or IP:Port that the function's service can be reached at.
 		KubernetesObjects []api.ObjectReference // Kubernetes Objects (within the function namespace)
 		Executor          executorType
@@ -67,6 +83,7 @@ type (
 		kubernetesObjects []api.ObjectReference
 		age               time.Duration
 		env               *metav1.ObjectMeta // used for ListOld
+		executor          executorType       // used for ListOldByExecutor
 		responseChannel   chan *fscResponse
 	}
 	fscResponse struct {
@@ -100,12 +117,33 @@ func (fsc *FunctionServiceCache) service() {
 			funcObjects := make([]*FuncSvc, 0)
 			for _, funcSvc := range fscs {
 				fsvc := funcSvc.(*FuncSvc)
-				if fsvc.Environment.Metadata.UID == req.env.UID &&
+				if fsvc.Environment != nil &&
+					fsvc.Environment.Metadata.UID == req.env.UID &&
 					time.Since(fsvc.Atime) > req.age {
 					funcObjects = append(funcObjects, fsvc)
 				}
 			}
 			resp.objects = funcObjects
+		case LISTOLDBYEXECUTOR:
+			// get svcs of an executor type idle for > req.age
+			fscs := fsc.byFunction.Copy()
+			funcObjects := make([]*FuncSvc, 0)
+			for _, funcSvc := range fscs {
+				fsvc := funcSvc.(*FuncSvc)
+				if fsvc.Executor == req.executor &&
+					time.Since(fsvc.Atime) > req.age {
+					funcObjects = append(funcObjects, fsvc)
+				}
+			}
+			resp.objects = funcObjects
+		case LIST:
+			fscs := fsc.byFunction.Copy()
+			funcObjects := make([]*FuncSvc, 0, len(fscs))
+			for _, funcSvc := range fscs {
+				fsvcCopy := *funcSvc.(*FuncSvc)
+				funcObjects = append(funcObjects, &fsvcCopy)
+			}
+			resp.objects = funcObjects
 		case LOG:
 			funcCopy := fsc.byFunction.Copy()
 			log.Printf("Cache has %v entries", len(funcCopy))
@@ -218,6 +256,31 @@ func (fsc *FunctionServiceCache) ListOld(env *metav1.ObjectMeta, age time.Durati
 	return resp.objects, resp.error
 }
 
+// ListOldByExecutor returns the function services created by the given
+// executor type that have been idle for longer than age.
+func (fsc *FunctionServiceCache) ListOldByExecutor(executor executorType, age time.Duration) ([]*FuncSvc, error) {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     LISTOLDBYEXECUTOR,
+		age:             age,
+		executor:        executor,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.objects, resp.error
+}
+
+// List returns copies of all cached function services.
+func (fsc *FunctionServiceCache) List() ([]*FuncSvc, error) {
+	responseChannel := make(chan *fscResponse)
+	fsc.requestChannel <- &fscRequest{
+		requestType:     LIST,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.objects, resp.error
+}
+
 func (fsc *FunctionServiceCache) Log() {
 	log.Printf("--- FunctionService Cache Contents")
 	responseChannel := make(chan *fscResponse)
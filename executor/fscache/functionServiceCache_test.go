@@ -83,6 +83,15 @@ func TestFunctionServiceCache(t *testing.T) {
 		log.Panicf("Failed to touch fsvc: %v", err)
 	}
 
+	fsvcs, err := fsc.List()
+	if err != nil {
+		log.Panicf("Failed to list fsvcs: %v", err)
+	}
+	if len(fsvcs) != 1 || fsvcs[0].Address != fsvc.Address {
+		fsc.Log()
+		log.Panicf("Incorrect fsvc list: %#v", fsvcs)
+	}
+
 	deleted, err := fsc.DeleteOld(fsvc, 0)
 	if err != nil {
 		fsc.Log()
@@ -0,0 +1,216 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lease
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/satori/go.uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+)
+
+type requestType int
+
+const (
+	ACQUIRE requestType = iota
+	RELEASE
+	RENEW
+	COUNT
+)
+
+type (
+	// Lease allows a router to send one request to a function. Routers
+	// renew leases within TTL while the request is in flight and release
+	// them once it's done; leases of routers that go away expire, so
+	// that they don't hold up the function forever.
+	Lease struct {
+		ID       string            `json:"id"`
+		Function metav1.ObjectMeta `json:"function"`
+		Address  string            `json:"address"`
+		Expiry   time.Time         `json:"expiry"`
+		TTL      time.Duration     `json:"ttl"`
+	}
+
+	// Manager hands out leases for functions with a concurrency limit.
+	// The executor is the only place that sees requests from all
+	// routers, so that's where the limit is enforced.
+	Manager struct {
+		ttl            time.Duration
+		leases         map[string]*Lease // lease ID -> lease
+		active         map[string]int    // function namespace/name -> number of leases
+		requestChannel chan *request
+	}
+	request struct {
+		requestType
+		function        *metav1.ObjectMeta
+		limit           int
+		id              string
+		responseChannel chan *response
+	}
+	response struct {
+		lease *Lease
+		count int
+		err   error
+	}
+)
+
+// MakeManager creates a lease manager. Leases expire after ttl unless
+// they're renewed.
+func MakeManager(ttl time.Duration) *Manager {
+	m := &Manager{
+		ttl:            ttl,
+		leases:         make(map[string]*Lease),
+		active:         make(map[string]int),
+		requestChannel: make(chan *request),
+	}
+	go m.service()
+	return m
+}
+
+func functionKey(fn *metav1.ObjectMeta) string {
+	return fmt.Sprintf("%v/%v", fn.Namespace, fn.Name)
+}
+
+func (m *Manager) service() {
+	ticker := time.NewTicker(m.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case req := <-m.requestChannel:
+			switch req.requestType {
+			case ACQUIRE:
+				key := functionKey(req.function)
+				if m.active[key] >= req.limit {
+					// Leases of routers that went away may be
+					// holding the function up.
+					m.expire(time.Now())
+				}
+				if m.active[key] >= req.limit {
+					req.responseChannel <- &response{
+						err: fission.MakeError(fission.ErrorTooManyRequests,
+							fmt.Sprintf("function %v is at its concurrency limit of %v", req.function.Name, req.limit)),
+					}
+					continue
+				}
+				lease := &Lease{
+					ID:       uuid.NewV4().String(),
+					Function: *req.function,
+					Expiry:   time.Now().Add(m.ttl),
+					TTL:      m.ttl,
+				}
+				m.leases[lease.ID] = lease
+				m.active[key]++
+				req.responseChannel <- &response{lease: lease}
+			case RELEASE:
+				lease, ok := m.leases[req.id]
+				if !ok {
+					req.responseChannel <- &response{
+						err: fission.MakeError(fission.ErrorNotFound, fmt.Sprintf("lease %v not found", req.id)),
+					}
+					continue
+				}
+				m.remove(lease)
+				req.responseChannel <- &response{}
+			case RENEW:
+				lease, ok := m.leases[req.id]
+				if !ok {
+					req.responseChannel <- &response{
+						err: fission.MakeError(fission.ErrorNotFound, fmt.Sprintf("lease %v not found", req.id)),
+					}
+					continue
+				}
+				lease.Expiry = time.Now().Add(m.ttl)
+				req.responseChannel <- &response{}
+			case COUNT:
+				req.responseChannel <- &response{count: m.active[functionKey(req.function)]}
+			}
+		case now := <-ticker.C:
+			m.expire(now)
+		}
+	}
+}
+
+func (m *Manager) remove(lease *Lease) {
+	key := functionKey(&lease.Function)
+	delete(m.leases, lease.ID)
+	m.active[key]--
+	if m.active[key] <= 0 {
+		delete(m.active, key)
+	}
+}
+
+func (m *Manager) expire(now time.Time) {
+	for _, lease := range m.leases {
+		if now.After(lease.Expiry) {
+			log.Printf("Lease %v for function %v expired", lease.ID, lease.Function.Name)
+			m.remove(lease)
+		}
+	}
+}
+
+func (m *Manager) call(req *request) *response {
+	req.responseChannel = make(chan *response)
+	m.requestChannel <- req
+	return <-req.responseChannel
+}
+
+// Acquire returns a lease for a request to a function, or an
+// ErrorTooManyRequests error if limit leases for the function are
+// already out.
+// The lease is a copy, whose Address the caller fills in.
+func (m *Manager) Acquire(function *metav1.ObjectMeta, limit int) (*Lease, error) {
+	resp := m.call(&request{
+		requestType: ACQUIRE,
+		function:    function,
+		limit:       limit,
+	})
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	lease := *resp.lease
+	return &lease, nil
+}
+
+// Release gives back a lease before it expires.
+func (m *Manager) Release(id string) error {
+	return m.call(&request{
+		requestType: RELEASE,
+		id:          id,
+	}).err
+}
+
+// Renew pushes the expiry of a lease back by the manager's TTL. It
+// returns an ErrorNotFound error if the lease has already expired or
+// been released.
+func (m *Manager) Renew(id string) error {
+	return m.call(&request{
+		requestType: RENEW,
+		id:          id,
+	}).err
+}
+
+// Count returns the number of leases out for a function.
+func (m *Manager) Count(function *metav1.ObjectMeta) int {
+	return m.call(&request{
+		requestType: COUNT,
+		function:    function,
+	}).count
+}
@@ -0,0 +1,116 @@
+package lease
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+)
+
+func TestAcquireAndRelease(t *testing.T) {
+	m := MakeManager(time.Minute)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: metav1.NamespaceDefault}
+	other := &metav1.ObjectMeta{Name: "bar", Namespace: metav1.NamespaceDefault}
+
+	first, err := m.Acquire(fn, 2)
+	if err != nil {
+		t.Fatalf("Error acquiring lease: %v", err)
+	}
+	if first.Function.Name != fn.Name {
+		t.Errorf("Expected lease for %v, got %v", fn.Name, first)
+	}
+	_, err = m.Acquire(fn, 2)
+	if err != nil {
+		t.Fatalf("Error acquiring second lease: %v", err)
+	}
+
+	_, err = m.Acquire(fn, 2)
+	if fe, ok := err.(fission.Error); !ok || fe.Code != fission.ErrorTooManyRequests {
+		t.Fatalf("Expected function to be at its limit, got %v", err)
+	}
+
+	// limits are per function
+	_, err = m.Acquire(other, 1)
+	if err != nil {
+		t.Fatalf("Error acquiring lease for another function: %v", err)
+	}
+
+	err = m.Release(first.ID)
+	if err != nil {
+		t.Fatalf("Error releasing lease: %v", err)
+	}
+	if m.Count(fn) != 1 {
+		t.Errorf("Expected 1 lease out, got %v", m.Count(fn))
+	}
+	_, err = m.Acquire(fn, 2)
+	if err != nil {
+		t.Fatalf("Error acquiring released lease: %v", err)
+	}
+
+	err = m.Release(first.ID)
+	if err == nil {
+		t.Errorf("Expected releasing a lease twice to fail")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	m := MakeManager(50 * time.Millisecond)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: metav1.NamespaceDefault}
+
+	_, err := m.Acquire(fn, 1)
+	if err != nil {
+		t.Fatalf("Error acquiring lease: %v", err)
+	}
+	_, err = m.Acquire(fn, 1)
+	if err == nil {
+		t.Fatalf("Expected function to be at its limit")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if m.Count(fn) != 0 {
+		t.Errorf("Expected lease to expire, got %v leases out", m.Count(fn))
+	}
+	_, err = m.Acquire(fn, 1)
+	if err != nil {
+		t.Fatalf("Error acquiring lease after expiry: %v", err)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	m := MakeManager(100 * time.Millisecond)
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: metav1.NamespaceDefault}
+
+	l, err := m.Acquire(fn, 1)
+	if err != nil {
+		t.Fatalf("Error acquiring lease: %v", err)
+	}
+	if l.TTL != 100*time.Millisecond {
+		t.Errorf("Expected lease TTL of 100ms, got %v", l.TTL)
+	}
+
+	// a renewed lease is held past its TTL
+	for i := 0; i < 6; i++ {
+		time.Sleep(50 * time.Millisecond)
+		err = m.Renew(l.ID)
+		if err != nil {
+			t.Fatalf("Error renewing lease after %v renewals: %v", i, err)
+		}
+	}
+	_, err = m.Acquire(fn, 1)
+	if fe, ok := err.(fission.Error); !ok || fe.Code != fission.ErrorTooManyRequests {
+		t.Fatalf("Expected renewed lease to still be held, got %v", err)
+	}
+
+	// once renewals stop, it expires
+	time.Sleep(250 * time.Millisecond)
+	err = m.Renew(l.ID)
+	if fe, ok := err.(fission.Error); !ok || fe.Code != fission.ErrorNotFound {
+		t.Errorf("Expected renewing an expired lease to fail, got %v", err)
+	}
+	_, err = m.Acquire(fn, 1)
+	if err != nil {
+		t.Fatalf("Error acquiring lease after expiry: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission/crd"
+)
+
+type requestType int
+
+const (
+	REPORT requestType = iota
+	GET
+)
+
+type (
+	// Sample is the request load a single router saw for a function
+	// during Interval. Routers periodically send these to the
+	// executor.
+	Sample struct {
+		RouterID string
+		Function metav1.ObjectMeta
+		Requests int64         // number of requests started during Interval
+		InFlight int64         // number of requests in flight when the sample was taken
+		Interval time.Duration // length of the sampling interval
+	}
+
+	// FunctionLoad is the load of a function summed across all
+	// routers.
+	FunctionLoad struct {
+		RequestsPerSecond float64
+		InFlight          int64
+	}
+
+	// MetricsSource provides the current load of functions.
+	MetricsSource interface {
+		GetFunctionLoad(m *metav1.ObjectMeta) (*FunctionLoad, error)
+	}
+
+	// Aggregator keeps the latest sample from each router for each
+	// function, and implements MetricsSource on top of them.
+	Aggregator struct {
+		samples        map[string]map[string]*receivedSample // function key -> router id -> sample
+		maxSampleAge   time.Duration
+		requestChannel chan *request
+	}
+	receivedSample struct {
+		sample   Sample
+		received time.Time
+	}
+
+	request struct {
+		requestType
+		samples         []Sample
+		function        *metav1.ObjectMeta
+		responseChannel chan *response
+	}
+	response struct {
+		load *FunctionLoad
+		error
+	}
+)
+
+// MakeAggregator creates an aggregator that ignores samples older
+// than maxSampleAge, e.g. from routers that went away.
+func MakeAggregator(maxSampleAge time.Duration) *Aggregator {
+	agg := &Aggregator{
+		samples:        make(map[string]map[string]*receivedSample),
+		maxSampleAge:   maxSampleAge,
+		requestChannel: make(chan *request),
+	}
+	go agg.service()
+	return agg
+}
+
+func (agg *Aggregator) service() {
+	for {
+		req := <-agg.requestChannel
+		resp := &response{}
+		switch req.requestType {
+		case REPORT:
+			now := time.Now()
+			for _, s := range req.samples {
+				key := crd.CacheKey(&s.Function)
+				byRouter, ok := agg.samples[key]
+				if !ok {
+					byRouter = make(map[string]*receivedSample)
+					agg.samples[key] = byRouter
+				}
+				byRouter[s.RouterID] = &receivedSample{sample: s, received: now}
+			}
+			agg.expire(now)
+		case GET:
+			load := &FunctionLoad{}
+			for _, rs := range agg.samples[crd.CacheKey(req.function)] {
+				if time.Since(rs.received) > agg.maxSampleAge {
+					continue
+				}
+				if rs.sample.Interval > 0 {
+					load.RequestsPerSecond += float64(rs.sample.Requests) / rs.sample.Interval.Seconds()
+				}
+				load.InFlight += rs.sample.InFlight
+			}
+			resp.load = load
+		}
+		req.responseChannel <- resp
+	}
+}
+
+// expire removes samples older than maxSampleAge
+func (agg *Aggregator) expire(now time.Time) {
+	for key, byRouter := range agg.samples {
+		for routerID, rs := range byRouter {
+			if now.Sub(rs.received) > agg.maxSampleAge {
+				delete(byRouter, routerID)
+			}
+		}
+		if len(byRouter) == 0 {
+			delete(agg.samples, key)
+		}
+	}
+}
+
+// Report records samples sent by a router.
+func (agg *Aggregator) Report(samples []Sample) {
+	responseChannel := make(chan *response)
+	agg.requestChannel <- &request{
+		requestType:     REPORT,
+		samples:         samples,
+		responseChannel: responseChannel,
+	}
+	<-responseChannel
+}
+
+// GetFunctionLoad returns the load of the function across all routers
+// that recently reported samples for it.
+func (agg *Aggregator) GetFunctionLoad(m *metav1.ObjectMeta) (*FunctionLoad, error) {
+	responseChannel := make(chan *response)
+	agg.requestChannel <- &request{
+		requestType:     GET,
+		function:        m,
+		responseChannel: responseChannel,
+	}
+	resp := <-responseChannel
+	return resp.load, resp.error
+}
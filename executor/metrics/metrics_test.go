@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAggregator(t *testing.T) {
+	agg := MakeAggregator(100 * time.Millisecond)
+
+	fn := metav1.ObjectMeta{
+		Name:            "foo",
+		UID:             "1212",
+		ResourceVersion: "1",
+	}
+
+	// two routers each seeing 20 requests over 2 seconds
+	agg.Report([]Sample{
+		{RouterID: "r1", Function: fn, Requests: 20, InFlight: 3, Interval: 2 * time.Second},
+	})
+	agg.Report([]Sample{
+		{RouterID: "r2", Function: fn, Requests: 20, InFlight: 1, Interval: 2 * time.Second},
+	})
+
+	load, err := agg.GetFunctionLoad(&fn)
+	if err != nil {
+		log.Panicf("Failed to get load: %v", err)
+	}
+	if load.RequestsPerSecond != 20 || load.InFlight != 4 {
+		log.Panicf("Incorrect load: %#v", load)
+	}
+
+	// a newer sample from a router replaces its older one
+	agg.Report([]Sample{
+		{RouterID: "r1", Function: fn, Requests: 0, InFlight: 0, Interval: 2 * time.Second},
+	})
+	load, err = agg.GetFunctionLoad(&fn)
+	if err != nil {
+		log.Panicf("Failed to get load: %v", err)
+	}
+	if load.RequestsPerSecond != 10 || load.InFlight != 1 {
+		log.Panicf("Incorrect load: %#v", load)
+	}
+
+	// stale samples are ignored
+	time.Sleep(150 * time.Millisecond)
+	load, err = agg.GetFunctionLoad(&fn)
+	if err != nil {
+		log.Panicf("Failed to get load: %v", err)
+	}
+	if load.RequestsPerSecond != 0 || load.InFlight != 0 {
+		log.Panicf("Expected no load, got %#v", load)
+	}
+}
@@ -19,6 +19,7 @@ package newdeploy
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"path/filepath"
 	"strconv"
@@ -30,6 +31,7 @@ import (
 	apiv1 "k8s.io/client-go/pkg/api/v1"
 	asv1 "k8s.io/client-go/pkg/apis/autoscaling/v1"
 	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/util/retry"
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
@@ -53,147 +55,289 @@ func (deploy *NewDeploy) createOrGetDeployment(fn *crd.Function, env *crd.Enviro
 	if replicas == 0 {
 		replicas = 1
 	}
-	targetFilename := "user"
-	userfunc := "userfunc"
 
 	existingDepl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(deployName, metav1.GetOptions{})
-	if err == nil && existingDepl.Status.ReadyReplicas >= replicas {
-		return existingDepl, err
+	if err == nil {
+		// The deployment may have been scaled down below what the
+		// function needs, to zero by the idle reaper or partway by the
+		// HPA; its ready replicas can then still be draining away, so
+		// check the desired count rather than the ready one.
+		if existingDepl.Spec.Replicas != nil && *existingDepl.Spec.Replicas < replicas {
+			log.Printf("Scaling deployment %v up to %v replicas", deployName, replicas)
+			var from int32
+			err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+				latest, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(deployName, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				if latest.Spec.Replicas != nil && *latest.Spec.Replicas >= replicas {
+					existingDepl = latest
+					return nil
+				}
+				if latest.Spec.Replicas != nil {
+					from = *latest.Spec.Replicas
+				}
+				latest.Spec.Replicas = &replicas
+				existingDepl, err = deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Update(latest)
+				return err
+			})
+			if err != nil {
+				log.Printf("Error scaling up deployment %v: %v", deployName, err)
+				return nil, err
+			}
+			util.RecordFunctionEvent(deploy.kubernetesClient, &fn.Metadata, apiv1.EventTypeNormal, util.EventReasonScaledUp,
+				fmt.Sprintf("Scaled deployment %v up from %v to %v replicas", deployName, from, replicas))
+		} else if existingDepl.Status.ReadyReplicas >= replicas {
+			return existingDepl, nil
+		}
+		return deploy.waitForDeploy(existingDepl, replicas)
 	}
 
 	if err != nil && k8s_err.IsNotFound(err) {
-		fetchReq := &fetcher.FetchRequest{
-			FetchType: fetcher.FETCH_DEPLOYMENT,
-			Package: metav1.ObjectMeta{
-				Namespace: fn.Spec.Package.PackageRef.Namespace,
-				Name:      fn.Spec.Package.PackageRef.Name,
-			},
-			Filename:   targetFilename,
-			Secrets:    fn.Spec.Secrets,
-			ConfigMaps: fn.Spec.ConfigMaps,
-		}
-
-		loadReq := fission.FunctionLoadRequest{
-			FilePath:         filepath.Join(deploy.sharedMountPath, targetFilename),
-			FunctionName:     fn.Spec.Package.FunctionName,
-			FunctionMetadata: &fn.Metadata,
+		var deployment *v1beta1.Deployment
+		if fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType == fission.ExecutorTypeContainer {
+			deployment = deploy.getContainerDeployment(fn, deployName, deployLabels, replicas)
+		} else {
+			deployment, err = deploy.getFetcherDeployment(fn, env, deployName, deployLabels, replicas)
+			if err != nil {
+				return nil, err
+			}
+			fission.MergePodTemplateOverlay(&deployment.Spec.Template, fn.Metadata.Name, env.Spec.PodTemplate)
 		}
+		// function level customizations take precedence over environment ones
+		fission.MergePodTemplateOverlay(&deployment.Spec.Template, fn.Metadata.Name, fn.Spec.PodTemplate)
 
-		fetchPayload, err := json.Marshal(fetchReq)
-		if err != nil {
-			return nil, err
-		}
-		loadPayload, err := json.Marshal(loadReq)
+		depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Create(deployment)
 		if err != nil {
+			log.Printf("Error while creating deployment: %v", err)
 			return nil, err
 		}
+		util.RecordFunctionEvent(deploy.kubernetesClient, &fn.Metadata, apiv1.EventTypeNormal, util.EventReasonDeployed,
+			fmt.Sprintf("Created deployment %v with %v replicas", deployName, replicas))
 
-		fetcherResources, err := util.GetFetcherResources()
-		if err != nil {
-			log.Printf("Error while parsing fetcher resources: %v", err)
-			return nil, err
-		}
+		return deploy.waitForDeploy(depl, replicas)
+	}
 
-		deployment := &v1beta1.Deployment{
-			ObjectMeta: metav1.ObjectMeta{
-				Labels: deployLabels,
-				Name:   deployName,
+	return nil, err
+
+}
+
+// getFetcherDeployment returns a deployment running the function in its
+// environment's runtime image, with a fetcher that loads the function's
+// package and specializes the runtime on startup.
+func (deploy *NewDeploy) getFetcherDeployment(fn *crd.Function, env *crd.Environment,
+	deployName string, deployLabels map[string]string, replicas int32) (*v1beta1.Deployment, error) {
+
+	targetFilename := "user"
+	userfunc := "userfunc"
+
+	fetchReq := &fetcher.FetchRequest{
+		FetchType: fetcher.FETCH_DEPLOYMENT,
+		Package: metav1.ObjectMeta{
+			Namespace: fn.Spec.Package.PackageRef.Namespace,
+			Name:      fn.Spec.Package.PackageRef.Name,
+		},
+		Filename:   targetFilename,
+		Secrets:    fn.Spec.Secrets,
+		ConfigMaps: fn.Spec.ConfigMaps,
+	}
+
+	loadReq := fission.FunctionLoadRequest{
+		FilePath:         filepath.Join(deploy.sharedMountPath, targetFilename),
+		FunctionName:     fn.Spec.Package.FunctionName,
+		FunctionMetadata: &fn.Metadata,
+	}
+
+	fetchPayload, err := json.Marshal(fetchReq)
+	if err != nil {
+		return nil, err
+	}
+	loadPayload, err := json.Marshal(loadReq)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcherResources, err := util.GetFetcherResources()
+	if err != nil {
+		log.Printf("Error while parsing fetcher resources: %v", err)
+		return nil, err
+	}
+
+	deployment := &v1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:    deployLabels,
+			Name:      deployName,
+			Namespace: deploy.namespace,
+		},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: deployLabels,
 			},
-			Spec: v1beta1.DeploymentSpec{
-				Replicas: &replicas,
-				Selector: &metav1.LabelSelector{
-					MatchLabels: deployLabels,
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: deployLabels,
 				},
-				Template: apiv1.PodTemplateSpec{
-					ObjectMeta: metav1.ObjectMeta{
-						Labels: deployLabels,
+				Spec: apiv1.PodSpec{
+					Volumes: []apiv1.Volume{
+						{
+							Name: userfunc,
+							VolumeSource: apiv1.VolumeSource{
+								EmptyDir: &apiv1.EmptyDirVolumeSource{},
+							},
+						},
 					},
-					Spec: apiv1.PodSpec{
-						Volumes: []apiv1.Volume{
-							{
-								Name: userfunc,
-								VolumeSource: apiv1.VolumeSource{
-									EmptyDir: &apiv1.EmptyDirVolumeSource{},
+					Containers: []apiv1.Container{
+						{
+							Name:                   fn.Metadata.Name,
+							Image:                  env.Spec.Runtime.Image,
+							ImagePullPolicy:        apiv1.PullIfNotPresent,
+							TerminationMessagePath: "/dev/termination-log",
+							VolumeMounts: []apiv1.VolumeMount{
+								{
+									Name:      userfunc,
+									MountPath: deploy.sharedMountPath,
 								},
 							},
+							Resources: env.Spec.Resources,
 						},
-						Containers: []apiv1.Container{
-							{
-								Name:                   fn.Metadata.Name,
-								Image:                  env.Spec.Runtime.Image,
-								ImagePullPolicy:        apiv1.PullIfNotPresent,
-								TerminationMessagePath: "/dev/termination-log",
-								VolumeMounts: []apiv1.VolumeMount{
-									{
-										Name:      userfunc,
-										MountPath: deploy.sharedMountPath,
-									},
+						{
+							Name:                   "fetcher",
+							Image:                  deploy.fetcherImg,
+							ImagePullPolicy:        deploy.fetcherImagePullPolicy,
+							TerminationMessagePath: "/dev/termination-log",
+							VolumeMounts: []apiv1.VolumeMount{
+								{
+									Name:      userfunc,
+									MountPath: deploy.sharedMountPath,
 								},
-								Resources: env.Spec.Resources,
 							},
-							{
-								Name:                   "fetcher",
-								Image:                  deploy.fetcherImg,
-								ImagePullPolicy:        deploy.fetcherImagePullPolicy,
-								TerminationMessagePath: "/dev/termination-log",
-								VolumeMounts: []apiv1.VolumeMount{
-									{
-										Name:      userfunc,
-										MountPath: deploy.sharedMountPath,
-									},
+							Command: []string{"/fetcher", "-specialize-on-startup",
+								"-fetch-request", string(fetchPayload),
+								"-load-request", string(loadPayload),
+								"-secret-dir", deploy.sharedSecretPath,
+								"-cfgmap-dir", deploy.sharedCfgMapPath,
+								deploy.sharedMountPath},
+							Env: []apiv1.EnvVar{
+								{
+									Name:  envVersion,
+									Value: strconv.Itoa(env.Spec.Version),
 								},
-								Command: []string{"/fetcher", "-specialize-on-startup",
-									"-fetch-request", string(fetchPayload),
-									"-load-request", string(loadPayload),
-									"-secret-dir", deploy.sharedSecretPath,
-									"-cfgmap-dir", deploy.sharedCfgMapPath,
-									deploy.sharedMountPath},
-								Env: []apiv1.EnvVar{
-									{
-										Name:  envVersion,
-										Value: strconv.Itoa(env.Spec.Version),
+							},
+							// TBD Use smaller default resources, for now needed to make HPA work
+							Resources: fetcherResources,
+							ReadinessProbe: &apiv1.Probe{
+								Handler: apiv1.Handler{
+									Exec: &apiv1.ExecAction{
+										Command: []string{"cat", "/tmp/ready"},
 									},
 								},
-								// TBD Use smaller default resources, for now needed to make HPA work
-								Resources: fetcherResources,
-								ReadinessProbe: &apiv1.Probe{
-									Handler: apiv1.Handler{
-										Exec: &apiv1.ExecAction{
-											Command: []string{"cat", "/tmp/ready"},
-										},
+								InitialDelaySeconds: 1,
+								PeriodSeconds:       1,
+							},
+						},
+					},
+					ServiceAccountName: "fission-fetcher",
+				},
+			},
+		},
+	}
+	return deployment, nil
+}
+
+// getContainerDeployment returns a deployment running the user supplied
+// image of a container function as is.
+func (deploy *NewDeploy) getContainerDeployment(fn *crd.Function,
+	deployName string, deployLabels map[string]string, replicas int32) *v1beta1.Deployment {
+
+	port := getContainerPort(fn)
+	return &v1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:    deployLabels,
+			Name:      deployName,
+			Namespace: deploy.namespace,
+		},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: deployLabels,
+			},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: deployLabels,
+				},
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{
+						{
+							Name:                   fn.Metadata.Name,
+							Image:                  fn.Spec.Container.Image,
+							Command:                fn.Spec.Container.Command,
+							Args:                   fn.Spec.Container.Args,
+							ImagePullPolicy:        apiv1.PullIfNotPresent,
+							TerminationMessagePath: "/dev/termination-log",
+							Ports: []apiv1.ContainerPort{
+								{
+									Name:          "http",
+									ContainerPort: port,
+								},
+							},
+							Resources: fn.Spec.Resources,
+							ReadinessProbe: &apiv1.Probe{
+								Handler: apiv1.Handler{
+									TCPSocket: &apiv1.TCPSocketAction{
+										Port: intstr.FromInt(int(port)),
 									},
-									InitialDelaySeconds: 1,
-									PeriodSeconds:       1,
 								},
+								InitialDelaySeconds: 1,
+								PeriodSeconds:       1,
 							},
 						},
-						ServiceAccountName: "fission-fetcher",
 					},
 				},
 			},
-		}
-		depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Create(deployment)
+		},
+	}
+}
+
+// getContainerPort returns the port a container function listens on
+func getContainerPort(fn *crd.Function) int32 {
+	if fn.Spec.Container != nil && fn.Spec.Container.Port > 0 {
+		return fn.Spec.Container.Port
+	}
+	return 8888
+}
+
+// waitForDeploy polls the deployment until it has the given number of
+// ready replicas.
+func (deploy *NewDeploy) waitForDeploy(depl *v1beta1.Deployment, replicas int32) (*v1beta1.Deployment, error) {
+	for i := 0; i < 120; i++ {
+		latestDepl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(depl.Name, metav1.GetOptions{})
 		if err != nil {
-			log.Printf("Error while creating deployment: %v", err)
 			return nil, err
 		}
-
-		for i := 0; i < 120; i++ {
-			latestDepl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(depl.Name, metav1.GetOptions{})
-			if err != nil {
-				return nil, err
-			}
-			//TODO check for imagePullerror
-			if latestDepl.Status.ReadyReplicas == replicas {
-				return latestDepl, err
-			}
-			time.Sleep(time.Second)
+		//TODO check for imagePullerror
+		if latestDepl.Status.ReadyReplicas >= replicas {
+			return latestDepl, err
 		}
-		return nil, errors.New("failed to create deployment within timeout window")
+		time.Sleep(time.Second)
 	}
+	return nil, errors.New("failed to create deployment within timeout window")
+}
 
-	return nil, err
-
+// scaleDeployment sets the number of replicas of the deployment. The
+// HPA doesn't act on a deployment that has been scaled to zero, so
+// scaling down to zero also effectively suspends autoscaling until
+// the deployment is scaled up again.
+func (deploy *NewDeploy) scaleDeployment(ns string, name string, replicas int32) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		depl.Spec.Replicas = &replicas
+		_, err = deploy.kubernetesClient.ExtensionsV1beta1().Deployments(ns).Update(depl)
+		return err
+	})
 }
 
 func (deploy *NewDeploy) deleteDeployment(ns string, name string) error {
@@ -207,7 +351,8 @@ func (deploy *NewDeploy) deleteDeployment(ns string, name string) error {
 	return nil
 }
 
-func (deploy *NewDeploy) createOrGetHpa(hpaName string, execStrategy *fission.ExecutionStrategy, depl *v1beta1.Deployment) (*asv1.HorizontalPodAutoscaler, error) {
+func (deploy *NewDeploy) createOrGetHpa(fn *crd.Function, hpaName string, depl *v1beta1.Deployment) (*asv1.HorizontalPodAutoscaler, error) {
+	execStrategy := &fn.Spec.InvokeStrategy.ExecutionStrategy
 
 	minRepl := int32(execStrategy.MinScale)
 	if minRepl == 0 {
@@ -248,6 +393,8 @@ func (deploy *NewDeploy) createOrGetHpa(hpaName string, execStrategy *fission.Ex
 		if err != nil {
 			return nil, err
 		}
+		util.RecordFunctionEvent(deploy.kubernetesClient, &fn.Metadata, apiv1.EventTypeNormal, util.EventReasonHPACreated,
+			fmt.Sprintf("Created HPA %v scaling between %v and %v replicas at %v%% cpu", hpaName, minRepl, maxRepl, targetCPU))
 		return cHpa, nil
 	}
 
@@ -260,7 +407,21 @@ func (deploy NewDeploy) deleteHpa(ns string, name string) error {
 	return err
 }
 
-func (deploy *NewDeploy) createOrGetSvc(deployLabels map[string]string, svcName string) (*apiv1.Service, error) {
+// removeHpa deletes the HPA of a function, if it has one.
+func (deploy *NewDeploy) removeHpa(fn *crd.Function, hpaName string) error {
+	err := deploy.deleteHpa(deploy.namespace, hpaName)
+	if k8s_err.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	util.RecordFunctionEvent(deploy.kubernetesClient, &fn.Metadata, apiv1.EventTypeNormal, util.EventReasonHPADeleted,
+		fmt.Sprintf("Deleted HPA %v, the function scales on requests", hpaName))
+	return nil
+}
+
+func (deploy *NewDeploy) createOrGetSvc(fn *crd.Function, deployLabels map[string]string, svcName string) (*apiv1.Service, error) {
 
 	existingSvc, err := deploy.kubernetesClient.CoreV1().Services(deploy.namespace).Get(svcName, metav1.GetOptions{})
 	if err == nil {
@@ -268,24 +429,37 @@ func (deploy *NewDeploy) createOrGetSvc(deployLabels map[string]string, svcName
 	}
 
 	if err != nil && k8s_err.IsNotFound(err) {
+		ports := []apiv1.ServicePort{
+			{
+				Name:       "runtime-env-port",
+				Port:       int32(80),
+				TargetPort: intstr.FromInt(8888),
+			},
+			{
+				Name:       "fetcher-port",
+				Port:       int32(8000),
+				TargetPort: intstr.FromInt(8000),
+			},
+		}
+		// container functions have no fetcher, and listen on their own port
+		if fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType == fission.ExecutorTypeContainer {
+			ports = []apiv1.ServicePort{
+				{
+					Name:       "container-port",
+					Port:       int32(80),
+					TargetPort: intstr.FromInt(int(getContainerPort(fn))),
+				},
+			}
+		}
+
 		service := &apiv1.Service{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:   svcName,
-				Labels: deployLabels,
+				Name:      svcName,
+				Namespace: deploy.namespace,
+				Labels:    deployLabels,
 			},
 			Spec: apiv1.ServiceSpec{
-				Ports: []apiv1.ServicePort{
-					{
-						Name:       "runtime-env-port",
-						Port:       int32(80),
-						TargetPort: intstr.FromInt(8888),
-					},
-					{
-						Name:       "fetcher-port",
-						Port:       int32(8000),
-						TargetPort: intstr.FromInt(8000),
-					},
-				},
+				Ports:    ports,
 				Selector: deployLabels,
 				Type:     apiv1.ServiceTypeClusterIP,
 			},
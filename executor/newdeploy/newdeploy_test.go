@@ -0,0 +1,301 @@
+package newdeploy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	k8sTesting "k8s.io/client-go/testing"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+	"github.com/fission/fission/executor/drain"
+	"github.com/fission/fission/executor/fake"
+	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/metrics"
+	"github.com/fission/fission/executor/quota"
+)
+
+const testNamespace = "fission-function"
+
+// setup makes a NewDeploy on a simulated cluster, with a newdeploy
+// function.
+func setup(t *testing.T) (*NewDeploy, *fake.Cluster, *crd.Function) {
+	cluster := fake.MakeCluster()
+	fissionClient := crdFake.MakeFissionClient()
+	fsCache := fscache.MakeFunctionServiceCache()
+
+	_, err := fissionClient.Environments(metav1.NamespaceDefault).Create(&crd.Environment{
+		Metadata: metav1.ObjectMeta{
+			Name: "nodejs",
+		},
+		Spec: fission.EnvironmentSpec{
+			Version: 2,
+			Runtime: fission.Runtime{
+				Image: "fission/node-env",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating environment: %v", err)
+	}
+
+	fn, err := fissionClient.Functions(metav1.NamespaceDefault).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{
+			Name: "hello",
+		},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{
+				Namespace: metav1.NamespaceDefault,
+				Name:      "nodejs",
+			},
+			Package: fission.FunctionPackageRef{
+				PackageRef: fission.PackageRef{
+					Namespace: metav1.NamespaceDefault,
+					Name:      "hello-pkg",
+				},
+				FunctionName: "main",
+			},
+			InvokeStrategy: fission.InvokeStrategy{
+				ExecutionStrategy: fission.ExecutionStrategy{
+					ExecutorType:     fission.ExecutorTypeNewdeploy,
+					MaxScale:         3,
+					TargetCPUPercent: 80,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+
+	load := metrics.MakeAggregator(time.Minute)
+	deploy := MakeNewDeploy(fissionClient, cluster.Client, nil, testNamespace, fsCache, load,
+		drain.MakeDrainer(load, 10*time.Millisecond, time.Second),
+		quota.MakeChecker(fissionClient, fsCache), 2*time.Minute, "test")
+	return deploy, cluster, fn
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for start := time.Now(); time.Since(start) < 10*time.Second; {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %v", what)
+}
+
+func TestCreateAndDelete(t *testing.T) {
+	deploy, cluster, fn := setup(t)
+	defer cluster.Stop()
+	client := cluster.Client
+	objName := deploy.getObjName(fn)
+
+	fsvc, err := deploy.GetFuncSvc(fn)
+	if err != nil {
+		t.Fatalf("Error creating function service: %v", err)
+	}
+
+	depl, err := client.ExtensionsV1beta1().Deployments(testNamespace).Get(objName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting deployment: %v", err)
+	}
+	if depl.Status.ReadyReplicas != 1 {
+		t.Errorf("Expected 1 ready replica, got %v", depl.Status.ReadyReplicas)
+	}
+	svc, err := client.CoreV1().Services(testNamespace).Get(objName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting service: %v", err)
+	}
+	if fsvc.Address != svc.Spec.ClusterIP || len(fsvc.Address) == 0 {
+		t.Errorf("Expected address %v, got %v", svc.Spec.ClusterIP, fsvc.Address)
+	}
+	hpa, err := client.AutoscalingV1().HorizontalPodAutoscalers(testNamespace).Get(objName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting HPA: %v", err)
+	}
+	if hpa.Spec.MaxReplicas != 3 {
+		t.Errorf("Expected HPA to scale up to 3 replicas, got %v", hpa.Spec.MaxReplicas)
+	}
+	if len(fsvc.KubernetesObjects) != 3 {
+		t.Errorf("Expected deployment, service and HPA in function service, got %v", fsvc.KubernetesObjects)
+	}
+
+	// cached services are reused
+	again, err := deploy.GetFuncSvc(fn)
+	if err != nil || again.Address != fsvc.Address {
+		t.Errorf("Expected cached function service at %v, got %v, %v", fsvc.Address, again, err)
+	}
+
+	err = deploy.DeleteFuncSvc(fsvc)
+	if err != nil {
+		t.Fatalf("Error deleting function service: %v", err)
+	}
+	_, err = deploy.fsCache.GetByFunction(&fn.Metadata)
+	if err == nil {
+		t.Errorf("Expected function service to be removed from the cache")
+	}
+	_, err = client.ExtensionsV1beta1().Deployments(testNamespace).Get(objName, metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("Expected deployment to be deleted")
+	}
+	_, err = client.CoreV1().Services(testNamespace).Get(objName, metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("Expected service to be deleted")
+	}
+	_, err = client.AutoscalingV1().HorizontalPodAutoscalers(testNamespace).Get(objName, metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("Expected HPA to be deleted")
+	}
+	waitFor(t, "pods to be deleted", func() bool {
+		pods, err := client.CoreV1().Pods(testNamespace).List(metav1.ListOptions{})
+		return err == nil && len(pods.Items) == 0
+	})
+}
+
+func TestReapAndScaleUp(t *testing.T) {
+	deploy, cluster, fn := setup(t)
+	defer cluster.Stop()
+	client := cluster.Client
+	objName := deploy.getObjName(fn)
+
+	_, err := deploy.GetFuncSvc(fn)
+	if err != nil {
+		t.Fatalf("Error creating function service: %v", err)
+	}
+
+	deploy.idlePodReapTime = 0
+	deploy.reapIdleFunctions()
+	_, err = deploy.fsCache.GetByFunction(&fn.Metadata)
+	if err == nil {
+		t.Errorf("Expected idle function to be removed from the cache")
+	}
+	waitFor(t, "deployment to be scaled down", func() bool {
+		depl, err := client.ExtensionsV1beta1().Deployments(testNamespace).Get(objName, metav1.GetOptions{})
+		return err == nil && depl.Spec.Replicas != nil && *depl.Spec.Replicas == 0 && depl.Status.ReadyReplicas == 0
+	})
+
+	// the next request scales it back up
+	_, err = deploy.GetFuncSvc(fn)
+	if err != nil {
+		t.Fatalf("Error scaling function service up: %v", err)
+	}
+	depl, err := client.ExtensionsV1beta1().Deployments(testNamespace).Get(objName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting deployment: %v", err)
+	}
+	if *depl.Spec.Replicas != 1 || depl.Status.ReadyReplicas != 1 {
+		t.Errorf("Expected deployment to be scaled up to 1 ready replica, got %v, %v ready",
+			*depl.Spec.Replicas, depl.Status.ReadyReplicas)
+	}
+}
+
+func TestScaleUpWhileScalingDown(t *testing.T) {
+	deploy, cluster, fn := setup(t)
+	defer cluster.Stop()
+	client := cluster.Client
+	objName := deploy.getObjName(fn)
+
+	_, err := deploy.GetFuncSvc(fn)
+	if err != nil {
+		t.Fatalf("Error creating function service: %v", err)
+	}
+
+	// the reaper scales the deployment down while its pod is still
+	// ready; a request arriving now must scale it back up
+	err = deploy.scaleDeployment(testNamespace, objName, 0)
+	if err != nil {
+		t.Fatalf("Error scaling deployment down: %v", err)
+	}
+	_, err = deploy.createOrGetDeployment(fn, nil, objName, nil)
+	if err != nil {
+		t.Fatalf("Error getting deployment: %v", err)
+	}
+	depl, err := client.ExtensionsV1beta1().Deployments(testNamespace).Get(objName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting deployment: %v", err)
+	}
+	if *depl.Spec.Replicas != 1 || depl.Status.ReadyReplicas != 1 {
+		t.Errorf("Expected deployment to be scaled back up to 1 ready replica, got %v, %v ready",
+			*depl.Spec.Replicas, depl.Status.ReadyReplicas)
+	}
+}
+
+func TestScaleDeploymentConflict(t *testing.T) {
+	deploy, cluster, fn := setup(t)
+	defer cluster.Stop()
+	client := cluster.Client
+	objName := deploy.getObjName(fn)
+
+	_, err := deploy.GetFuncSvc(fn)
+	if err != nil {
+		t.Fatalf("Error creating function service: %v", err)
+	}
+
+	// the first update scaling to 2 loses a race with another writer
+	conflicts := 1
+	cluster.PrependReactor("update", "deployments", func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		depl := action.(k8sTesting.UpdateAction).GetObject().(*v1beta1.Deployment)
+		if conflicts == 0 || depl.Spec.Replicas == nil || *depl.Spec.Replicas != 2 {
+			return false, nil, nil
+		}
+		conflicts--
+		return true, nil, k8s_err.NewConflict(schema.GroupResource{Group: "extensions", Resource: "deployments"},
+			objName, errors.New("the object has been modified"))
+	})
+
+	err = deploy.scaleDeployment(testNamespace, objName, 2)
+	if err != nil {
+		t.Fatalf("Expected scaling to be retried on conflict, got %v", err)
+	}
+	depl, err := client.ExtensionsV1beta1().Deployments(testNamespace).Get(objName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting deployment: %v", err)
+	}
+	if *depl.Spec.Replicas != 2 {
+		t.Errorf("Expected deployment to be scaled to 2 replicas, got %v", *depl.Spec.Replicas)
+	}
+}
+
+func TestSwitchToRequestScaling(t *testing.T) {
+	deploy, cluster, fn := setup(t)
+	defer cluster.Stop()
+	client := cluster.Client
+	objName := deploy.getObjName(fn)
+	hpaExists := func() bool {
+		_, err := client.AutoscalingV1().HorizontalPodAutoscalers(testNamespace).Get(objName, metav1.GetOptions{})
+		return err == nil
+	}
+
+	_, err := deploy.GetFuncSvc(fn)
+	if err != nil {
+		t.Fatalf("Error creating function service: %v", err)
+	}
+	if !hpaExists() {
+		t.Fatalf("Expected function scaled on CPU to have an HPA")
+	}
+
+	updated := *fn
+	updated.Metadata.ResourceVersion = fn.Metadata.ResourceVersion + "1"
+	updated.Spec.InvokeStrategy.ExecutionStrategy.TargetRequestsPerSecond = 10
+	deploy.updateFunction(fn, &updated)
+	if hpaExists() {
+		t.Errorf("Expected HPA to be deleted when switching to request scaling")
+	}
+
+	// the HPA stays gone for the new version's service
+	fsvc, err := deploy.GetFuncSvc(&updated)
+	if err != nil {
+		t.Fatalf("Error creating function service: %v", err)
+	}
+	if hpaExists() || len(fsvc.KubernetesObjects) != 2 {
+		t.Errorf("Expected no HPA for function scaled on requests, got %v", fsvc.KubernetesObjects)
+	}
+}
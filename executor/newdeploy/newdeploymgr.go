@@ -25,7 +25,12 @@ import (
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/drain"
 	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/metrics"
+	"github.com/fission/fission/executor/quota"
+	"github.com/fission/fission/executor/util"
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
@@ -39,8 +44,8 @@ type (
 	requestType int
 
 	NewDeploy struct {
-		kubernetesClient *kubernetes.Clientset
-		fissionClient    *crd.FissionClient
+		kubernetesClient kubernetes.Interface
+		fissionClient    crd.FissionClientInterface
 		crdClient        *rest.RESTClient
 		instanceID       string
 
@@ -50,8 +55,12 @@ type (
 		sharedMountPath        string
 		sharedSecretPath       string
 		sharedCfgMapPath       string
+		idlePodReapTime        time.Duration // deployments of functions unused for idlePodReapTime are scaled to zero
 
 		fsCache        *fscache.FunctionServiceCache // cache funcSvc's by function, address and podname
+		metricsSource  metrics.MetricsSource         // request load of functions, used for request based autoscaling
+		drainer        *drain.Drainer                // drains idle functions before they're scaled down
+		quota          *quota.Checker                // enforces namespace function quotas
 		requestChannel chan *fnRequest
 
 		functions      []crd.Function
@@ -62,6 +71,8 @@ type (
 	fnRequest struct {
 		reqType         requestType
 		fn              *crd.Function
+		fsvc            *fscache.FuncSvc // used for FnScaleDown and FnScale
+		replicas        int32            // used for FnScale
 		responseChannel chan *fnResponse
 	}
 
@@ -75,14 +86,20 @@ const (
 	FnCreate requestType = iota
 	FnDelete
 	FnUpdate
+	FnScaleDown
+	FnScale
 )
 
 func MakeNewDeploy(
-	fissionClient *crd.FissionClient,
-	kubernetesClient *kubernetes.Clientset,
+	fissionClient crd.FissionClientInterface,
+	kubernetesClient kubernetes.Interface,
 	crdClient *rest.RESTClient,
 	namespace string,
 	fsCache *fscache.FunctionServiceCache,
+	metricsSource metrics.MetricsSource,
+	drainer *drain.Drainer,
+	quota *quota.Checker,
+	idlePodReapTime time.Duration,
 	instanceID string,
 ) *NewDeploy {
 
@@ -103,14 +120,18 @@ func MakeNewDeploy(
 		crdClient:        crdClient,
 		instanceID:       instanceID,
 
-		namespace: namespace,
-		fsCache:   fsCache,
+		namespace:     namespace,
+		fsCache:       fsCache,
+		metricsSource: metricsSource,
+		drainer:       drainer,
+		quota:         quota,
 
 		fetcherImg:             fetcherImg,
 		fetcherImagePullPolicy: apiv1.PullIfNotPresent,
 		sharedMountPath:        "/userfunc",
 		sharedSecretPath:       "/secrets",
 		sharedCfgMapPath:       "/configs",
+		idlePodReapTime:        idlePodReapTime,
 
 		requestChannel: make(chan *fnRequest),
 	}
@@ -121,6 +142,8 @@ func MakeNewDeploy(
 		nd.funcController = fnController
 	}
 	go nd.service()
+	go nd.idleObjectReaper()
+	go nd.requestScaler()
 	return nd
 }
 
@@ -138,10 +161,15 @@ func (deploy *NewDeploy) initFuncController() (k8sCache.Store, k8sCache.Controll
 		},
 		DeleteFunc: func(obj interface{}) {
 			fn := obj.(*crd.Function)
-			deploy.deleteFunction(fn)
+			err := deploy.deleteFunction(fn)
+			if err != nil {
+				log.Printf("Error deleting function %v: %v", fn.Metadata.Name, err)
+			}
 		},
-		UpdateFunc: func(newObj interface{}, oldObj interface{}) {
-			//TBD
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			oldFn := oldObj.(*crd.Function)
+			newFn := newObj.(*crd.Function)
+			deploy.updateFunction(oldFn, newFn)
 		},
 	})
 	return store, controller
@@ -167,16 +195,28 @@ func (deploy *NewDeploy) service() {
 				fSvc:  nil,
 			}
 			continue
+		case FnScaleDown:
+			err := deploy.fnScaleDown(req.fsvc)
+			req.responseChannel <- &fnResponse{
+				error: err,
+				fSvc:  nil,
+			}
+			continue
+		case FnScale:
+			err := deploy.fnScale(req.fsvc, req.replicas)
+			req.responseChannel <- &fnResponse{
+				error: err,
+				fSvc:  nil,
+			}
+			continue
 		}
 	}
 }
 
-func (deploy *NewDeploy) GetFuncSvc(metadata *metav1.ObjectMeta) (*fscache.FuncSvc, error) {
+// GetFuncSvc creates the function's deployment, or scales it back up,
+// and adds it to the function service cache.
+func (deploy *NewDeploy) GetFuncSvc(fn *crd.Function) (*fscache.FuncSvc, error) {
 	c := make(chan *fnResponse)
-	fn, err := deploy.fissionClient.Functions(metadata.Namespace).Get(metadata.Name)
-	if err != nil {
-		return nil, err
-	}
 	deploy.requestChannel <- &fnRequest{
 		fn:              fn,
 		reqType:         FnCreate,
@@ -189,8 +229,66 @@ func (deploy *NewDeploy) GetFuncSvc(metadata *metav1.ObjectMeta) (*fscache.FuncS
 	return resp.fSvc, nil
 }
 
+// TapService marks the function service at address as used.
+func (deploy *NewDeploy) TapService(address string) error {
+	return deploy.fsCache.TouchByAddress(address)
+}
+
+// DeleteFuncSvc removes a function service from the cache, if it's
+// still there, and deletes its deployment, service and HPA.
+func (deploy *NewDeploy) DeleteFuncSvc(fsvc *fscache.FuncSvc) error {
+	c := make(chan *fnResponse)
+	deploy.requestChannel <- &fnRequest{
+		fn:              &crd.Function{Metadata: *fsvc.Function},
+		reqType:         FnDelete,
+		responseChannel: c,
+	}
+	resp := <-c
+	return resp.error
+}
+
+// ListFuncSvcs returns the cached function services of newdeploy and
+// container functions.
+func (deploy *NewDeploy) ListFuncSvcs() ([]*fscache.FuncSvc, error) {
+	fsvcs, err := deploy.fsCache.List()
+	if err != nil {
+		return nil, err
+	}
+	deployFsvcs := make([]*fscache.FuncSvc, 0)
+	for _, fsvc := range fsvcs {
+		if fsvc.Executor == fscache.NEWDEPLOY || fsvc.Executor == fscache.CONTAINER {
+			deployFsvcs = append(deployFsvcs, fsvc)
+		}
+	}
+	return deployFsvcs, nil
+}
+
+// CleanupOnStart deletes the objects of functions created by previous
+// executor instances, in the background.
+func (deploy *NewDeploy) CleanupOnStart() {
+	util.CleanupObjects(deploy.kubernetesClient, deploy.namespace, deploy.instanceID,
+		func(labels map[string]string) bool {
+			switch labels["executorType"] {
+			case fission.ExecutorTypeNewdeploy, fission.ExecutorTypeContainer:
+				return true
+			}
+			return false
+		})
+}
+
+// isNewDeployFunction returns true for functions whose objects are managed
+// by NewDeploy: newdeploy functions, and container functions, which only
+// differ in the pods they run.
+func isNewDeployFunction(fn *crd.Function) bool {
+	switch fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType {
+	case fission.ExecutorTypeNewdeploy, fission.ExecutorTypeContainer:
+		return true
+	}
+	return false
+}
+
 func (deploy *NewDeploy) createFunction(fn *crd.Function) {
-	if fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType != fission.ExecutorTypeNewdeploy {
+	if !isNewDeployFunction(fn) {
 		return
 	}
 	if fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale <= 0 {
@@ -210,43 +308,77 @@ func (deploy *NewDeploy) createFunction(fn *crd.Function) {
 	}
 }
 
-func (deploy *NewDeploy) deleteFunction(fn *crd.Function) {
-	if fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType == fission.ExecutorTypeNewdeploy {
-		c := make(chan *fnResponse)
-		deploy.requestChannel <- &fnRequest{
-			fn:              fn,
-			reqType:         FnDelete,
-			responseChannel: c,
-		}
-		resp := <-c
-		if resp.error != nil {
-			log.Printf("Error deleing the function: %v", resp.error)
-		}
+// updateFunction removes the HPA of functions that switch to request
+// based autoscaling, so that it doesn't fight the request scaler over
+// the deployment's replicas. Functions switching back to CPU based
+// autoscaling get an HPA again when their service is next created.
+func (deploy *NewDeploy) updateFunction(oldFn *crd.Function, newFn *crd.Function) {
+	if !isNewDeployFunction(newFn) {
+		return
+	}
+	if usesRequestScaling(&oldFn.Spec.InvokeStrategy.ExecutionStrategy) ||
+		!usesRequestScaling(&newFn.Spec.InvokeStrategy.ExecutionStrategy) {
+		return
+	}
+	err := deploy.removeHpa(newFn, deploy.getObjName(newFn))
+	if err != nil {
+		log.Printf("Error deleting the HPA of function %v: %v", newFn.Metadata.Name, err)
 	}
 }
 
+// deleteFunction deletes the deployment, service and HPA of a deleted
+// function, whether or not it's cached.
+func (deploy *NewDeploy) deleteFunction(fn *crd.Function) error {
+	if !isNewDeployFunction(fn) {
+		return nil
+	}
+	return deploy.DeleteFuncSvc(&fscache.FuncSvc{Function: &fn.Metadata})
+}
+
 func (deploy *NewDeploy) fnCreate(fn *crd.Function) (*fscache.FuncSvc, error) {
 	fsvc, err := deploy.fsCache.GetByFunction(&fn.Metadata)
 	if err == nil {
 		return fsvc, err
 	}
 
-	env, err := deploy.fissionClient.
-		Environments(fn.Spec.Environment.Namespace).
-		Get(fn.Spec.Environment.Name)
-	if err != nil {
-		return fsvc, err
-	}
-
 	objName := deploy.getObjName(fn)
 
+	executorType := fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType
 	deployLabels := map[string]string{
-		"environmentName":                 env.Metadata.Name,
-		"environmentUid":                  string(env.Metadata.UID),
 		"functionName":                    fn.Metadata.Name,
 		"functionUid":                     string(fn.Metadata.UID),
 		fission.EXECUTOR_INSTANCEID_LABEL: deploy.instanceID,
-		"executorType":                    fission.ExecutorTypeNewdeploy,
+		"executorType":                    string(executorType),
+	}
+
+	// Container functions run their own image and have no environment
+	var env *crd.Environment
+	fsvcExecutor := fscache.CONTAINER
+	if executorType != fission.ExecutorTypeContainer {
+		env, err = deploy.fissionClient.
+			Environments(fn.Spec.Environment.Namespace).
+			Get(fn.Spec.Environment.Name)
+		if err != nil {
+			return fsvc, err
+		}
+		deployLabels["environmentName"] = env.Metadata.Name
+		deployLabels["environmentUid"] = string(env.Metadata.UID)
+		fsvcExecutor = fscache.NEWDEPLOY
+	} else if fn.Spec.Container == nil || len(fn.Spec.Container.Image) == 0 {
+		return fsvc, fission.MakeError(fission.ErrorInvalidArgument, "container functions need an image")
+	}
+
+	// The function isn't cached, so its deployment is new or scaled
+	// down to zero; either way it's about to take up capacity.
+	podResources := fn.Spec.Resources
+	if env != nil {
+		podResources = env.Spec.Resources
+	}
+	err = deploy.quota.Check(fn, quota.DeploymentPods(fn), podResources)
+	if err != nil {
+		util.RecordFunctionEvent(deploy.kubernetesClient, &fn.Metadata, apiv1.EventTypeWarning,
+			util.EventReasonQuotaExceeded, err.Error())
+		return nil, err
 	}
 
 	depl, err := deploy.createOrGetDeployment(fn, env, objName, deployLabels)
@@ -255,19 +387,13 @@ func (deploy *NewDeploy) fnCreate(fn *crd.Function) (*fscache.FuncSvc, error) {
 		return fsvc, err
 	}
 
-	svc, err := deploy.createOrGetSvc(deployLabels, objName)
+	svc, err := deploy.createOrGetSvc(fn, deployLabels, objName)
 	if err != nil {
 		log.Printf("Error creating the service %v: %v", objName, err)
 		return fsvc, err
 	}
 	svcAddress := svc.Spec.ClusterIP
 
-	hpa, err := deploy.createOrGetHpa(objName, &fn.Spec.InvokeStrategy.ExecutionStrategy, depl)
-	if err != nil {
-		log.Printf("Error creating the HPA %v: %v", objName, err)
-		return fsvc, err
-	}
-
 	kubeObjRefs := []api.ObjectReference{
 		{
 			//obj.TypeMeta.Kind does not work hence this, needs investigationa and a fix
@@ -286,14 +412,31 @@ func (deploy *NewDeploy) fnCreate(fn *crd.Function) (*fscache.FuncSvc, error) {
 			ResourceVersion: svc.ObjectMeta.ResourceVersion,
 			UID:             svc.ObjectMeta.UID,
 		},
-		{
+	}
+
+	// Functions scaled on request load are scaled by requestScaler
+	// rather than by a CPU based HPA; one left from before the
+	// function switched is removed.
+	if usesRequestScaling(&fn.Spec.InvokeStrategy.ExecutionStrategy) {
+		err = deploy.removeHpa(fn, objName)
+		if err != nil {
+			log.Printf("Error deleting the HPA %v: %v", objName, err)
+			return fsvc, err
+		}
+	} else {
+		hpa, err := deploy.createOrGetHpa(fn, objName, depl)
+		if err != nil {
+			log.Printf("Error creating the HPA %v: %v", objName, err)
+			return fsvc, err
+		}
+		kubeObjRefs = append(kubeObjRefs, api.ObjectReference{
 			Kind:            "horizontalpodautoscaler",
 			Name:            hpa.ObjectMeta.Name,
 			APIVersion:      hpa.TypeMeta.APIVersion,
 			Namespace:       hpa.ObjectMeta.Namespace,
 			ResourceVersion: hpa.ObjectMeta.ResourceVersion,
 			UID:             hpa.ObjectMeta.UID,
-		},
+		})
 	}
 
 	fsvc = &fscache.FuncSvc{
@@ -302,7 +445,7 @@ func (deploy *NewDeploy) fnCreate(fn *crd.Function) (*fscache.FuncSvc, error) {
 		Environment:       env,
 		Address:           svcAddress,
 		KubernetesObjects: kubeObjRefs,
-		Executor:          fscache.NEWDEPLOY,
+		Executor:          fsvcExecutor,
 	}
 
 	_, err = deploy.fsCache.Add(*fsvc)
@@ -317,10 +460,11 @@ func (deploy *NewDeploy) fnDelete(fn *crd.Function) (*fscache.FuncSvc, error) {
 
 	var delError error
 
+	// The function isn't in the cache if its deployment has been
+	// scaled down to zero, but its objects still need to be deleted.
 	fsvc, err := deploy.fsCache.GetByFunction(&fn.Metadata)
 	if err != nil {
 		log.Printf("fsvc not fonud in cache: %v", fn.Metadata)
-		delError = err
 	} else {
 		_, err = deploy.fsCache.DeleteOld(fsvc, time.Second*0)
 		if err != nil {
@@ -328,7 +472,7 @@ func (deploy *NewDeploy) fnDelete(fn *crd.Function) (*fscache.FuncSvc, error) {
 			delError = err
 		}
 	}
-	objName := fsvc.Name
+	objName := deploy.getObjName(fn)
 
 	err = deploy.deleteDeployment(deploy.namespace, objName)
 	if err != nil {
@@ -342,8 +486,9 @@ func (deploy *NewDeploy) fnDelete(fn *crd.Function) (*fscache.FuncSvc, error) {
 		delError = err
 	}
 
+	// There's no HPA for functions using request based autoscaling
 	err = deploy.deleteHpa(deploy.namespace, objName)
-	if err != nil {
+	if err != nil && !k8s_err.IsNotFound(err) {
 		log.Printf("Error deleting the HPA: %v", objName)
 		delError = err
 	}
@@ -354,6 +499,101 @@ func (deploy *NewDeploy) fnDelete(fn *crd.Function) (*fscache.FuncSvc, error) {
 	return nil, nil
 }
 
+// fnScaleDown removes an idle function from the cache and scales its
+// deployment down to zero. The service and HPA are left in place, so
+// the next request for the function only has to scale it back up.
+func (deploy *NewDeploy) fnScaleDown(fsvc *fscache.FuncSvc) error {
+	// The function may have been invoked again while it was being
+	// drained, in which case the deployment is back in the cache.
+	if _, err := deploy.fsCache.GetByFunction(fsvc.Function); err == nil {
+		log.Printf("Function %v was used while draining, not scaling it down", fsvc.Function.Name)
+		return nil
+	}
+
+	log.Printf("Scaling idle function %v down to zero", fsvc.Function.Name)
+	err := deploy.scaleDeployment(deploy.namespace, fsvc.Name, 0)
+	if err != nil {
+		return err
+	}
+	util.RecordFunctionEvent(deploy.kubernetesClient, fsvc.Function, apiv1.EventTypeNormal, util.EventReasonScaledDown,
+		fmt.Sprintf("Scaled idle deployment %v down to zero", fsvc.Name))
+	return nil
+}
+
+// idleObjectReaper periodically scales down the deployments of
+// functions with MinScale 0 that haven't been used for idlePodReapTime.
+func (deploy *NewDeploy) idleObjectReaper() {
+	pollSleep := time.Duration(2 * time.Minute)
+	for {
+		time.Sleep(pollSleep)
+		deploy.reapIdleFunctions()
+	}
+}
+
+// reapIdleFunctions drains the functions with MinScale 0 that have been
+// idle for longer than idlePodReapTime, and scales them down to zero.
+func (deploy *NewDeploy) reapIdleFunctions() {
+	funcSvcs, err := deploy.listOld(deploy.idlePodReapTime)
+	if err != nil {
+		log.Printf("Error listing idle functions: %v", err)
+		return
+	}
+
+	for _, fsvc := range funcSvcs {
+		fn, err := deploy.fissionClient.Functions(fsvc.Function.Namespace).Get(fsvc.Function.Name)
+		if err != nil {
+			log.Printf("Error getting function: %v", fsvc.Function.Name)
+			continue
+		}
+
+		// Functions with MinScale > 0 are kept running
+		if fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale > 0 {
+			continue
+		}
+
+		// The function may have been invoked since it was listed
+		// as idle; DeleteOld checks the access time again before
+		// removing it.
+		deleted, err := deploy.fsCache.DeleteOld(fsvc, deploy.idlePodReapTime)
+		if err != nil {
+			log.Printf("Error removing idle function %v from cache: %v", fsvc.Function.Name, err)
+			continue
+		}
+		if !deleted {
+			continue
+		}
+
+		fsvc := fsvc
+		deploy.drainer.Drain(fsvc, func() {
+			c := make(chan *fnResponse)
+			deploy.requestChannel <- &fnRequest{
+				fn:              fn,
+				fsvc:            fsvc,
+				reqType:         FnScaleDown,
+				responseChannel: c,
+			}
+			resp := <-c
+			if resp.error != nil {
+				log.Printf("Error scaling down function %v: %v", fsvc.Function.Name, resp.error)
+			}
+		})
+	}
+}
+
+// listOld returns the cached function services managed by NewDeploy that
+// have been idle for longer than age.
+func (deploy *NewDeploy) listOld(age time.Duration) ([]*fscache.FuncSvc, error) {
+	funcSvcs, err := deploy.fsCache.ListOldByExecutor(fscache.NEWDEPLOY, age)
+	if err != nil {
+		return nil, err
+	}
+	containerSvcs, err := deploy.fsCache.ListOldByExecutor(fscache.CONTAINER, age)
+	if err != nil {
+		return nil, err
+	}
+	return append(funcSvcs, containerSvcs...), nil
+}
+
 func (deploy *NewDeploy) getObjName(fn *crd.Function) string {
 	return fmt.Sprintf("%v-%v",
 		fn.Metadata.Name,
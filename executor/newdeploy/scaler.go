@@ -0,0 +1,145 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package newdeploy
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/metrics"
+	"github.com/fission/fission/executor/util"
+)
+
+// usesRequestScaling returns true if the function autoscales on its
+// request load rather than with a CPU based HPA.
+func usesRequestScaling(execStrategy *fission.ExecutionStrategy) bool {
+	return execStrategy.TargetRequestsPerSecond > 0 || execStrategy.TargetInFlightRequests > 0
+}
+
+// getDesiredReplicas computes the number of replicas needed to keep the
+// per-pod request rate and in-flight requests of a function at or below
+// its targets, bounded by MinScale and MaxScale.
+func getDesiredReplicas(source metrics.MetricsSource, m *metav1.ObjectMeta, execStrategy *fission.ExecutionStrategy) (int32, error) {
+	load, err := source.GetFunctionLoad(m)
+	if err != nil {
+		return 0, err
+	}
+
+	var desired float64
+	if execStrategy.TargetRequestsPerSecond > 0 {
+		desired = math.Max(desired, load.RequestsPerSecond/float64(execStrategy.TargetRequestsPerSecond))
+	}
+	if execStrategy.TargetInFlightRequests > 0 {
+		desired = math.Max(desired, float64(load.InFlight)/float64(execStrategy.TargetInFlightRequests))
+	}
+	replicas := int32(math.Ceil(desired))
+
+	minRepl := int32(execStrategy.MinScale)
+	if minRepl == 0 {
+		minRepl = 1
+	}
+	maxRepl := int32(execStrategy.MaxScale)
+	if replicas < minRepl {
+		replicas = minRepl
+	}
+	if maxRepl >= minRepl && replicas > maxRepl {
+		replicas = maxRepl
+	}
+	return replicas, nil
+}
+
+// requestScaler periodically adjusts the replicas of running functions
+// that use request based autoscaling, using the load reported by the
+// routers. Deployments scaled down to zero by the idle reaper are left
+// alone; they're scaled up again on the next request.
+func (deploy *NewDeploy) requestScaler() {
+	pollSleep := time.Duration(10 * time.Second)
+	for {
+		time.Sleep(pollSleep)
+
+		funcSvcs, err := deploy.listOld(0)
+		if err != nil {
+			log.Printf("Error listing functions to scale: %v", err)
+			continue
+		}
+
+		for _, fsvc := range funcSvcs {
+			fn, err := deploy.fissionClient.Functions(fsvc.Function.Namespace).Get(fsvc.Function.Name)
+			if err != nil {
+				log.Printf("Error getting function: %v", fsvc.Function.Name)
+				continue
+			}
+
+			execStrategy := fn.Spec.InvokeStrategy.ExecutionStrategy
+			if !usesRequestScaling(&execStrategy) {
+				continue
+			}
+
+			replicas, err := getDesiredReplicas(deploy.metricsSource, fsvc.Function, &execStrategy)
+			if err != nil {
+				log.Printf("Error getting load of function %v: %v", fsvc.Function.Name, err)
+				continue
+			}
+
+			c := make(chan *fnResponse)
+			deploy.requestChannel <- &fnRequest{
+				fn:              fn,
+				fsvc:            fsvc,
+				replicas:        replicas,
+				reqType:         FnScale,
+				responseChannel: c,
+			}
+			resp := <-c
+			if resp.error != nil {
+				log.Printf("Error scaling function %v: %v", fsvc.Function.Name, resp.error)
+			}
+		}
+	}
+}
+
+// fnScale sets the replicas of a function's deployment, unless the
+// deployment has been scaled down to zero.
+func (deploy *NewDeploy) fnScale(fsvc *fscache.FuncSvc, replicas int32) error {
+	depl, err := deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Get(fsvc.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if depl.Spec.Replicas == nil || *depl.Spec.Replicas == 0 || *depl.Spec.Replicas == replicas {
+		return nil
+	}
+
+	log.Printf("Scaling function %v from %v to %v replicas", fsvc.Function.Name, *depl.Spec.Replicas, replicas)
+	reason := util.EventReasonScaledUp
+	if replicas < *depl.Spec.Replicas {
+		reason = util.EventReasonScaledDown
+	}
+	msg := fmt.Sprintf("Scaled deployment %v from %v to %v replicas on request load", fsvc.Name, *depl.Spec.Replicas, replicas)
+	depl.Spec.Replicas = &replicas
+	_, err = deploy.kubernetesClient.ExtensionsV1beta1().Deployments(deploy.namespace).Update(depl)
+	if err != nil {
+		return err
+	}
+	util.RecordFunctionEvent(deploy.kubernetesClient, fsvc.Function, apiv1.EventTypeNormal, reason, msg)
+	return nil
+}
@@ -0,0 +1,80 @@
+package newdeploy
+
+import (
+	"log"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/executor/metrics"
+)
+
+// fakeMetricsSource returns a fixed load for every function
+type fakeMetricsSource struct {
+	load metrics.FunctionLoad
+}
+
+func (f *fakeMetricsSource) GetFunctionLoad(m *metav1.ObjectMeta) (*metrics.FunctionLoad, error) {
+	load := f.load
+	return &load, nil
+}
+
+func TestGetDesiredReplicas(t *testing.T) {
+	fn := &metav1.ObjectMeta{Name: "foo", UID: "1212"}
+
+	tests := []struct {
+		name     string
+		strategy fission.ExecutionStrategy
+		load     metrics.FunctionLoad
+		expected int32
+	}{
+		{
+			name:     "request rate",
+			strategy: fission.ExecutionStrategy{MinScale: 1, MaxScale: 10, TargetRequestsPerSecond: 10},
+			load:     metrics.FunctionLoad{RequestsPerSecond: 35},
+			expected: 4,
+		},
+		{
+			name:     "in-flight requests",
+			strategy: fission.ExecutionStrategy{MinScale: 1, MaxScale: 10, TargetInFlightRequests: 2},
+			load:     metrics.FunctionLoad{InFlight: 7},
+			expected: 4,
+		},
+		{
+			name:     "largest of both targets",
+			strategy: fission.ExecutionStrategy{MinScale: 1, MaxScale: 10, TargetRequestsPerSecond: 10, TargetInFlightRequests: 1},
+			load:     metrics.FunctionLoad{RequestsPerSecond: 15, InFlight: 5},
+			expected: 5,
+		},
+		{
+			name:     "bounded by MaxScale",
+			strategy: fission.ExecutionStrategy{MinScale: 1, MaxScale: 3, TargetRequestsPerSecond: 10},
+			load:     metrics.FunctionLoad{RequestsPerSecond: 100},
+			expected: 3,
+		},
+		{
+			name:     "bounded by MinScale",
+			strategy: fission.ExecutionStrategy{MinScale: 2, MaxScale: 5, TargetRequestsPerSecond: 10},
+			load:     metrics.FunctionLoad{},
+			expected: 2,
+		},
+		{
+			name:     "at least one replica with MinScale 0",
+			strategy: fission.ExecutionStrategy{MinScale: 0, MaxScale: 5, TargetInFlightRequests: 10},
+			load:     metrics.FunctionLoad{},
+			expected: 1,
+		},
+	}
+
+	for _, test := range tests {
+		source := &fakeMetricsSource{load: test.load}
+		replicas, err := getDesiredReplicas(source, fn, &test.strategy)
+		if err != nil {
+			log.Panicf("%v: failed to get desired replicas: %v", test.name, err)
+		}
+		if replicas != test.expected {
+			log.Panicf("%v: expected %v replicas, got %v", test.name, test.expected, replicas)
+		}
+	}
+}
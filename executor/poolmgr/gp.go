@@ -50,6 +50,13 @@ import (
 
 const POD_PHASE_RUNNING string = "Running"
 
+// Pods that failed to specialize are labeled and annotated, with the
+// error, before they're deleted.
+const (
+	QUARANTINED_LABEL            string = "quarantined"
+	QUARANTINE_REASON_ANNOTATION string = "fission.io/quarantine-reason"
+)
+
 type (
 	GenericPool struct {
 		env                    *crd.Environment
@@ -57,21 +64,23 @@ type (
 		deployment             *v1beta1.Deployment           // kubernetes deployment
 		namespace              string                        // namespace to keep our resources
 		podReadyTimeout        time.Duration                 // timeout for generic pods to become ready
-		idlePodReapTime        time.Duration                 // pods unused for idlePodReapTime are deleted
+		maxSpecializeAttempts  int                           // pods tried before giving up on specializing a function
 		fsCache                *fscache.FunctionServiceCache // cache funcSvc's by function, address and podname
 		useSvc                 bool                          // create k8s service for specialized pods
 		poolInstanceId         string                        // small random string to uniquify pod names
 		fetcherImage           string
 		fetcherImagePullPolicy apiv1.PullPolicy
 		runtimeImagePullPolicy apiv1.PullPolicy // pull policy for generic pool to created env deployment
-		kubernetesClient       *kubernetes.Clientset
-		fissionClient          *crd.FissionClient
+		kubernetesClient       kubernetes.Interface
+		fissionClient          crd.FissionClientInterface
 		instanceId             string // poolmgr instance id
 		labelsForPool          map[string]string
 		requestChannel         chan *choosePodRequest
 		sharedMountPath        string // used by generic pool when creating env deployment to specify the share volume path for fetcher & env
 		sharedSecretPath       string
 		sharedCfgMapPath       string
+		fetcherPort            int // ports pods of the pool listen on; changed by tests
+		runtimePort            int
 	}
 
 	// serialize the choosing of pods so that choices don't conflict
@@ -97,8 +106,8 @@ func getImagePullPolicy(policy string) apiv1.PullPolicy {
 }
 
 func MakeGenericPool(
-	fissionClient *crd.FissionClient,
-	kubernetesClient *kubernetes.Clientset,
+	fissionClient crd.FissionClientInterface,
+	kubernetesClient kubernetes.Interface,
 	env *crd.Environment,
 	initialReplicas int32,
 	namespace string,
@@ -123,22 +132,24 @@ func MakeGenericPool(
 	// TODO: in general we need to provide the user a way to configure pools.  Initial
 	// replicas, autoscaling params, various timeouts, etc.
 	gp := &GenericPool{
-		env:              env,
-		replicas:         initialReplicas, // TODO make this an env param instead?
-		requestChannel:   make(chan *choosePodRequest),
-		fissionClient:    fissionClient,
-		kubernetesClient: kubernetesClient,
-		namespace:        namespace,
-		podReadyTimeout:  5 * time.Minute, // TODO make this an env param?
-		idlePodReapTime:  3 * time.Minute, // TODO make this configurable
-		fsCache:          fsCache,
-		poolInstanceId:   uniuri.NewLen(8),
-		instanceId:       instanceId,
-		fetcherImage:     fetcherImage,
-		useSvc:           false,       // defaults off -- svc takes a second or more to become routable, slowing cold start
-		sharedMountPath:  "/userfunc", // change this may break v1 compatibility, since most of the v1 environments have hard-coded "/userfunc" in loading path
-		sharedSecretPath: "/secrets",
-		sharedCfgMapPath: "/configs",
+		env:                   env,
+		replicas:              initialReplicas, // TODO make this an env param instead?
+		requestChannel:        make(chan *choosePodRequest),
+		fissionClient:         fissionClient,
+		kubernetesClient:      kubernetesClient,
+		namespace:             namespace,
+		podReadyTimeout:       5 * time.Minute, // TODO make this an env param?
+		maxSpecializeAttempts: 3,
+		fsCache:               fsCache,
+		poolInstanceId:        uniuri.NewLen(8),
+		instanceId:            instanceId,
+		fetcherImage:          fetcherImage,
+		useSvc:                false,       // defaults off -- svc takes a second or more to become routable, slowing cold start
+		sharedMountPath:       "/userfunc", // change this may break v1 compatibility, since most of the v1 environments have hard-coded "/userfunc" in loading path
+		sharedSecretPath:      "/secrets",
+		sharedCfgMapPath:      "/configs",
+		fetcherPort:           8000,
+		runtimePort:           8888,
 	}
 
 	gp.runtimeImagePullPolicy = getImagePullPolicy(runtimeImagePullPolicy)
@@ -238,6 +249,8 @@ func (gp *GenericPool) _choosePod(newLabels map[string]string) (*apiv1.Pod, erro
 		if len(readyPods) == 0 {
 			err = gp.waitForReadyPod()
 			if err != nil {
+				util.RecordEnvironmentEvent(gp.kubernetesClient, &gp.env.Metadata, apiv1.EventTypeWarning,
+					util.EventReasonNoReadyPods, fmt.Sprintf("No ready pods in pool of %v total: %v", len(podList.Items), err))
 				return nil, err
 			}
 			continue
@@ -257,6 +270,8 @@ func (gp *GenericPool) _choosePod(newLabels map[string]string) (*apiv1.Pod, erro
 			_, err = gp.kubernetesClient.CoreV1().Pods(gp.namespace).Update(chosenPod)
 			if err != nil {
 				log.Printf("failed to relabel pod [%v]: %v", chosenPod.ObjectMeta.Name, err)
+				util.RecordEnvironmentEvent(gp.kubernetesClient, &gp.env.Metadata, apiv1.EventTypeNormal,
+					util.EventReasonRelabelConflict, fmt.Sprintf("Failed to relabel pod %v: %v", chosenPod.ObjectMeta.Name, err))
 				continue
 			}
 		}
@@ -265,6 +280,42 @@ func (gp *GenericPool) _choosePod(newLabels map[string]string) (*apiv1.Pod, erro
 	}
 }
 
+// GetReplicas returns the number of generic pods the pool keeps ready.
+func (gp *GenericPool) GetReplicas() int32 {
+	return gp.replicas
+}
+
+// GetEnvironment returns the environment the pool runs.
+func (gp *GenericPool) GetEnvironment() *crd.Environment {
+	return gp.env
+}
+
+// CountPods returns the number of ready and pending generic pods in the
+// pool. Specialized pods are relabeled out of the pool's deployment, so
+// they aren't counted.
+func (gp *GenericPool) CountPods() (ready int, pending int, err error) {
+	podList, err := gp.kubernetesClient.CoreV1().Pods(gp.namespace).List(
+		metav1.ListOptions{
+			LabelSelector: labels.Set(
+				gp.deployment.Spec.Selector.MatchLabels).AsSelector().String(),
+		})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, pod := range podList.Items {
+		podReady := len(pod.Status.PodIP) > 0 && string(pod.Status.Phase) == POD_PHASE_RUNNING
+		for _, cs := range pod.Status.ContainerStatuses {
+			podReady = podReady && cs.Ready
+		}
+		if podReady {
+			ready++
+		} else {
+			pending++
+		}
+	}
+	return ready, pending, nil
+}
+
 func (gp *GenericPool) labelsForFunction(metadata *metav1.ObjectMeta) map[string]string {
 	return map[string]string{
 		"functionName":                    metadata.Name,
@@ -274,6 +325,31 @@ func (gp *GenericPool) labelsForFunction(metadata *metav1.ObjectMeta) map[string
 	}
 }
 
+// quarantinePod takes a pod that failed to specialize out of service.
+// Its function UID label is dropped so that no function service
+// selects it, and it's marked as quarantined, with the error, for
+// debugging. It's deleted after a while like other failed pods.
+func (gp *GenericPool) quarantinePod(name string, reason error) {
+	pod, err := gp.kubernetesClient.CoreV1().Pods(gp.namespace).Get(name, metav1.GetOptions{})
+	if err == nil {
+		pod.ObjectMeta.Labels = map[string]string{
+			"functionName":                    pod.ObjectMeta.Labels["functionName"],
+			"unmanaged":                       "true",
+			QUARANTINED_LABEL:                 "true",
+			fission.EXECUTOR_INSTANCEID_LABEL: gp.instanceId,
+		}
+		if pod.ObjectMeta.Annotations == nil {
+			pod.ObjectMeta.Annotations = make(map[string]string)
+		}
+		pod.ObjectMeta.Annotations[QUARANTINE_REASON_ANNOTATION] = reason.Error()
+		_, err = gp.kubernetesClient.CoreV1().Pods(gp.namespace).Update(pod)
+	}
+	if err != nil {
+		log.Printf("Error quarantining pod %v: %v", name, err)
+	}
+	gp.scheduleDeletePod(name)
+}
+
 func (gp *GenericPool) scheduleDeletePod(name string) {
 	go func() {
 		// The sleep allows debugging or collecting logs from the pod before it's
@@ -302,9 +378,9 @@ func (gp *GenericPool) getFetcherUrl(podIP string) string {
 	isv6 := IsIPv6(podIP)
 	var baseUrl string
 	if isv6 == false {
-		baseUrl = fmt.Sprintf("http://%v:8000/", podIP)
+		baseUrl = fmt.Sprintf("http://%v:%v/", podIP, gp.fetcherPort)
 	} else if isv6 == true { // We use bracket if the IP is in IPv6.
-		baseUrl = fmt.Sprintf("http://[%v]:8000/", podIP)
+		baseUrl = fmt.Sprintf("http://[%v]:%v/", podIP, gp.fetcherPort)
 	}
 	return baseUrl
 
@@ -318,9 +394,9 @@ func (gp *GenericPool) getSpecializeUrl(podIP string, version int) string {
 		return u
 	}
 	if isv6 == false {
-		baseUrl = fmt.Sprintf("http://%v:8888", podIP)
+		baseUrl = fmt.Sprintf("http://%v:%v", podIP, gp.runtimePort)
 	} else if isv6 == true { // We use bracket if the IP is in IPv6.
-		baseUrl = fmt.Sprintf("http://[%v]:8888", podIP)
+		baseUrl = fmt.Sprintf("http://[%v]:%v", podIP, gp.runtimePort)
 	}
 
 	if version == 1 {
@@ -441,8 +517,9 @@ func (gp *GenericPool) createPool() error {
 
 	deployment := &v1beta1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   poolDeploymentName,
-			Labels: gp.labelsForPool,
+			Name:      poolDeploymentName,
+			Namespace: gp.namespace,
+			Labels:    gp.labelsForPool,
 		},
 		Spec: v1beta1.DeploymentSpec{
 			Replicas: &gp.replicas,
@@ -560,6 +637,8 @@ func (gp *GenericPool) createPool() error {
 			},
 		},
 	}
+	fission.MergePodTemplateOverlay(&deployment.Spec.Template, gp.env.Metadata.Name, gp.env.Spec.PodTemplate)
+
 	depl, err := gp.kubernetesClient.ExtensionsV1beta1().Deployments(gp.namespace).Create(deployment)
 	if err != nil {
 		log.Printf("Error creating deployment for %s in kubernetes, err: %v", deployment.Name, err)
@@ -594,7 +673,8 @@ func (gp *GenericPool) waitForReadyPod() error {
 func (gp *GenericPool) createSvc(name string, labels map[string]string) (*apiv1.Service, error) {
 	service := apiv1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
+			Name:      name,
+			Namespace: gp.namespace,
 		},
 		Spec: apiv1.ServiceSpec{
 			Type: apiv1.ServiceTypeClusterIP,
@@ -614,19 +694,39 @@ func (gp *GenericPool) createSvc(name string, labels map[string]string) (*apiv1.
 
 func (gp *GenericPool) GetFuncSvc(m *metav1.ObjectMeta) (*fscache.FuncSvc, error) {
 
-	log.Printf("[%v] Choosing pod from pool", m.Name)
-	newLabels := gp.labelsForFunction(m)
-	pod, err := gp.choosePod(newLabels)
-	if err != nil {
-		return nil, err
-	}
+	startTime := time.Now()
 
-	err = gp.specializePod(pod, m)
-	if err != nil {
-		gp.scheduleDeletePod(pod.ObjectMeta.Name)
-		return nil, err
+	// A pod failing to specialize may be broken rather than the
+	// function, so retry on other pods before giving up.
+	var pod *apiv1.Pod
+	var err error
+	for attempt := 1; ; attempt++ {
+		log.Printf("[%v] Choosing pod from pool", m.Name)
+		newLabels := gp.labelsForFunction(m)
+		pod, err = gp.choosePod(newLabels)
+		if err != nil {
+			return nil, err
+		}
+
+		err = gp.specializePod(pod, m)
+		if err == nil {
+			break
+		}
+
+		log.Printf("[%v] Failed to specialize pod %v (attempt %v of %v): %v",
+			m.Name, pod.ObjectMeta.Name, attempt, gp.maxSpecializeAttempts, err)
+		gp.quarantinePod(pod.ObjectMeta.Name, err)
+		util.RecordFunctionEvent(gp.kubernetesClient, m, apiv1.EventTypeWarning, util.EventReasonSpecializeFailed,
+			fmt.Sprintf("Failed to specialize pod %v: %v", pod.ObjectMeta.Name, err))
+
+		if attempt >= gp.maxSpecializeAttempts {
+			return nil, fmt.Errorf("failed to specialize function %v after %v attempts: %v", m.Name, attempt, err)
+		}
 	}
 	log.Printf("Specialized pod: %v", pod.ObjectMeta.Name)
+	util.RecordFunctionEvent(gp.kubernetesClient, m, apiv1.EventTypeNormal, util.EventReasonSpecialized,
+		fmt.Sprintf("Specialized pod %v of environment %v in %v",
+			pod.ObjectMeta.Name, gp.env.Metadata.Name, time.Since(startTime)))
 
 	var svcHost string
 	if gp.useSvc {
@@ -651,7 +751,7 @@ func (gp *GenericPool) GetFuncSvc(m *metav1.ObjectMeta) (*fscache.FuncSvc, error
 		svcHost = fmt.Sprintf("%v.%v", svcName, gp.namespace)
 	} else {
 		log.Printf("Using pod IP for specialized pod")
-		svcHost = fmt.Sprintf("%v:8888", pod.Status.PodIP)
+		svcHost = fmt.Sprintf("%v:%v", pod.Status.PodIP, gp.runtimePort)
 	}
 
 	kubeObjRefs := []api.ObjectReference{
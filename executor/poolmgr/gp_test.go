@@ -0,0 +1,256 @@
+package poolmgr
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+	"github.com/fission/fission/executor/drain"
+	"github.com/fission/fission/executor/fake"
+	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/metrics"
+)
+
+const testNamespace = "fission-function"
+
+type testCluster struct {
+	cluster       *fake.Cluster
+	runtime       *fake.Runtime
+	fissionClient *crdFake.FissionClient
+	fsCache       *fscache.FunctionServiceCache
+	env           *crd.Environment
+	fn            *crd.Function
+}
+
+// setup makes a simulated cluster with an environment and a function.
+// Pods get loopback addresses other than 127.0.0.1, so the test is
+// skipped where those aren't routable.
+func setup(t *testing.T) *testCluster {
+	runtime, err := fake.MakeRuntime()
+	if err != nil {
+		t.Fatalf("Error starting fake runtime: %v", err)
+	}
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.2:%v", runtime.Port), time.Second)
+	if err != nil {
+		runtime.Close()
+		t.Skipf("Loopback addresses other than 127.0.0.1 aren't routable: %v", err)
+	}
+	conn.Close()
+
+	tc := &testCluster{
+		cluster:       fake.MakeCluster(),
+		runtime:       runtime,
+		fissionClient: crdFake.MakeFissionClient(),
+		fsCache:       fscache.MakeFunctionServiceCache(),
+	}
+
+	tc.env, err = tc.fissionClient.Environments(metav1.NamespaceDefault).Create(&crd.Environment{
+		Metadata: metav1.ObjectMeta{
+			Name: "nodejs",
+		},
+		Spec: fission.EnvironmentSpec{
+			Version: 2,
+			Runtime: fission.Runtime{
+				Image: "fission/node-env",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating environment: %v", err)
+	}
+
+	tc.fn, err = tc.fissionClient.Functions(metav1.NamespaceDefault).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{
+			Name: "hello",
+		},
+		Spec: fission.FunctionSpec{
+			Environment: fission.EnvironmentReference{
+				Namespace: metav1.NamespaceDefault,
+				Name:      "nodejs",
+			},
+			Package: fission.FunctionPackageRef{
+				PackageRef: fission.PackageRef{
+					Namespace: metav1.NamespaceDefault,
+					Name:      "hello-pkg",
+				},
+				FunctionName: "main",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+	return tc
+}
+
+func (tc *testCluster) stop() {
+	tc.cluster.Stop()
+	tc.runtime.Close()
+}
+
+func (tc *testCluster) makePool(t *testing.T, replicas int32) *GenericPool {
+	gp, err := MakeGenericPool(tc.fissionClient, tc.cluster.Client, tc.env, replicas, testNamespace, tc.fsCache, "test")
+	if err != nil {
+		t.Fatalf("Error creating pool: %v", err)
+	}
+	gp.fetcherPort = tc.runtime.Port
+	gp.runtimePort = tc.runtime.Port
+	gp.podReadyTimeout = 10 * time.Second
+	return gp
+}
+
+// countPods returns the number of pods with the given labels.
+func (tc *testCluster) countPods(t *testing.T, podLabels map[string]string) int {
+	pods, err := tc.cluster.Client.CoreV1().Pods(testNamespace).List(metav1.ListOptions{
+		LabelSelector: labels.Set(podLabels).AsSelector().String(),
+	})
+	if err != nil {
+		t.Fatalf("Error listing pods: %v", err)
+	}
+	return len(pods.Items)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for start := time.Now(); time.Since(start) < 10*time.Second; {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %v", what)
+}
+
+func TestChoosePodAndSpecialize(t *testing.T) {
+	tc := setup(t)
+	defer tc.stop()
+	tc.cluster.SetReadyDelay(200 * time.Millisecond)
+
+	gp := tc.makePool(t, 2)
+
+	// waits for a pod to become ready
+	fsvc, err := gp.GetFuncSvc(&tc.fn.Metadata)
+	if err != nil {
+		t.Fatalf("Error specializing function: %v", err)
+	}
+
+	pod, err := tc.cluster.Client.CoreV1().Pods(testNamespace).Get(fsvc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting specialized pod: %v", err)
+	}
+	if pod.Labels["functionName"] != tc.fn.Metadata.Name {
+		t.Errorf("Expected pod to be relabeled for function %v, got labels %v", tc.fn.Metadata.Name, pod.Labels)
+	}
+	expectedAddress := fmt.Sprintf("%v:%v", pod.Status.PodIP, tc.runtime.Port)
+	if fsvc.Address != expectedAddress {
+		t.Errorf("Expected address %v, got %v", expectedAddress, fsvc.Address)
+	}
+
+	fetches := tc.runtime.Fetches()
+	if len(fetches) != 1 || fetches[0].Package.Name != "hello-pkg" {
+		t.Errorf("Expected one fetch of package hello-pkg, got %v", fetches)
+	}
+	loads := tc.runtime.Specializations(pod.Status.PodIP)
+	if len(loads) != 1 || loads[0].FunctionName != "main" {
+		t.Errorf("Expected pod to be specialized once for main, got %v", loads)
+	}
+
+	cached, err := tc.fsCache.GetByFunction(&tc.fn.Metadata)
+	if err != nil || cached.Address != fsvc.Address {
+		t.Errorf("Expected function service at %v to be cached, got %v, %v", fsvc.Address, cached, err)
+	}
+
+	// the pool replaces the pod taken out of it
+	waitFor(t, "pool to be refilled", func() bool {
+		ready, _, err := gp.CountPods()
+		return err == nil && ready == 2
+	})
+}
+
+func TestSpecializeRetriesOnAnotherPod(t *testing.T) {
+	tc := setup(t)
+	defer tc.stop()
+
+	gp := tc.makePool(t, 2)
+	tc.runtime.FailSpecializations(1)
+
+	fsvc, err := gp.GetFuncSvc(&tc.fn.Metadata)
+	if err != nil {
+		t.Fatalf("Error specializing function: %v", err)
+	}
+	if tc.countPods(t, map[string]string{QUARANTINED_LABEL: "true"}) != 1 {
+		t.Errorf("Expected the pod that failed to specialize to be quarantined")
+	}
+	pod, err := tc.cluster.Client.CoreV1().Pods(testNamespace).Get(fsvc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting specialized pod: %v", err)
+	}
+	if _, ok := pod.Labels[QUARANTINED_LABEL]; ok {
+		t.Errorf("Expected the specialized pod not to be quarantined")
+	}
+}
+
+func TestSpecializeFailure(t *testing.T) {
+	tc := setup(t)
+	defer tc.stop()
+
+	gp := tc.makePool(t, 1)
+	tc.runtime.FailSpecializations(100)
+
+	_, err := gp.GetFuncSvc(&tc.fn.Metadata)
+	if err == nil {
+		t.Fatalf("Expected specialization to fail")
+	}
+	quarantined := tc.countPods(t, map[string]string{QUARANTINED_LABEL: "true"})
+	if quarantined != gp.maxSpecializeAttempts {
+		t.Errorf("Expected %v quarantined pods, got %v", gp.maxSpecializeAttempts, quarantined)
+	}
+	_, err = tc.fsCache.GetByFunction(&tc.fn.Metadata)
+	if err == nil {
+		t.Errorf("Expected no function service to be cached")
+	}
+}
+
+func TestReapIdlePods(t *testing.T) {
+	tc := setup(t)
+	defer tc.stop()
+
+	gp := tc.makePool(t, 1)
+	fsvc, err := gp.GetFuncSvc(&tc.fn.Metadata)
+	if err != nil {
+		t.Fatalf("Error specializing function: %v", err)
+	}
+
+	gpm := &GenericPoolManager{
+		kubernetesClient: tc.cluster.Client,
+		namespace:        testNamespace,
+		fissionClient:    tc.fissionClient,
+		fsCache:          tc.fsCache,
+		drainer:          drain.MakeDrainer(metrics.MakeAggregator(time.Minute), 10*time.Millisecond, time.Second),
+		idlePodReapTime:  time.Hour,
+	}
+
+	// recently used pods are kept
+	gpm.reapIdlePods()
+	_, err = tc.fsCache.GetByFunction(&tc.fn.Metadata)
+	if err != nil {
+		t.Fatalf("Expected function service to be kept: %v", err)
+	}
+
+	gpm.idlePodReapTime = 0
+	gpm.reapIdlePods()
+	_, err = tc.fsCache.GetByFunction(&tc.fn.Metadata)
+	if err == nil {
+		t.Errorf("Expected idle function service to be removed from the cache")
+	}
+	waitFor(t, "idle pod to be deleted", func() bool {
+		_, err := tc.cluster.Client.CoreV1().Pods(testNamespace).Get(fsvc.Name, metav1.GetOptions{})
+		return err != nil
+	})
+}
@@ -17,15 +17,21 @@ limitations under the License.
 package poolmgr
 
 import (
+	"fmt"
 	"log"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
 
 	"github.com/fission/fission"
+	"github.com/fission/fission/cache"
 	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/drain"
 	"github.com/fission/fission/executor/fscache"
+	"github.com/fission/fission/executor/quota"
+	"github.com/fission/fission/executor/util"
 )
 
 type requestType int
@@ -33,18 +39,23 @@ type requestType int
 const (
 	GET_POOL requestType = iota
 	CLEANUP_POOLS
+	LIST_POOLS
 )
 
 type (
 	GenericPoolManager struct {
 		pools            map[string]*GenericPool
-		kubernetesClient *kubernetes.Clientset
+		kubernetesClient kubernetes.Interface
 		namespace        string
 
-		fissionClient  *crd.FissionClient
-		fsCache        *fscache.FunctionServiceCache
-		instanceId     string
-		requestChannel chan *request
+		fissionClient   crd.FissionClientInterface
+		fsCache         *fscache.FunctionServiceCache
+		functionEnv     *cache.Cache   // function cache key -> environment
+		drainer         *drain.Drainer // drains idle function pods before they're deleted
+		quota           *quota.Checker // enforces namespace function quotas
+		idlePodReapTime time.Duration  // specialized pods unused for idlePodReapTime are deleted
+		instanceId      string
+		requestChannel  chan *request
 	}
 	request struct {
 		requestType
@@ -54,16 +65,20 @@ type (
 	}
 	response struct {
 		error
-		pool *GenericPool
+		pool  *GenericPool
+		pools []*GenericPool
 	}
 )
 
 func MakeGenericPoolManager(
-	fissionClient *crd.FissionClient,
-	kubernetesClient *kubernetes.Clientset,
+	fissionClient crd.FissionClientInterface,
+	kubernetesClient kubernetes.Interface,
 	fissionNamespace string,
 	functionNamespace string,
 	fsCache *fscache.FunctionServiceCache,
+	drainer *drain.Drainer,
+	quota *quota.Checker,
+	idlePodReapTime time.Duration,
 	instanceId string) *GenericPoolManager {
 
 	gpm := &GenericPoolManager{
@@ -72,11 +87,16 @@ func MakeGenericPoolManager(
 		namespace:        functionNamespace,
 		fissionClient:    fissionClient,
 		fsCache:          fsCache,
+		functionEnv:      cache.MakeCache(10*time.Second, 0),
+		drainer:          drainer,
+		quota:            quota,
+		idlePodReapTime:  idlePodReapTime,
 		instanceId:       instanceId,
 		requestChannel:   make(chan *request),
 	}
 	go gpm.service()
 	go gpm.eagerPoolCreator()
+	go gpm.idleObjectReaper()
 
 	return gpm
 }
@@ -126,6 +146,12 @@ func (gpm *GenericPoolManager) service() {
 				}
 			}
 			// no response, caller doesn't wait
+		case LIST_POOLS:
+			pools := make([]*GenericPool, 0, len(gpm.pools))
+			for _, pool := range gpm.pools {
+				pools = append(pools, pool)
+			}
+			req.responseChannel <- &response{pools: pools}
 		}
 	}
 }
@@ -141,6 +167,17 @@ func (gpm *GenericPoolManager) GetPool(env *crd.Environment) (*GenericPool, erro
 	return resp.pool, resp.error
 }
 
+// ListPools returns the pools of all environments.
+func (gpm *GenericPoolManager) ListPools() []*GenericPool {
+	c := make(chan *response)
+	gpm.requestChannel <- &request{
+		requestType:     LIST_POOLS,
+		responseChannel: c,
+	}
+	resp := <-c
+	return resp.pools
+}
+
 func (gpm *GenericPoolManager) CleanupPools(envs []crd.Environment) {
 	gpm.requestChannel <- &request{
 		requestType: CLEANUP_POOLS,
@@ -188,3 +225,159 @@ func (gpm *GenericPoolManager) getEnvPoolsize(env *crd.Environment) int32 {
 	}
 	return poolsize
 }
+
+// GetFunctionPool returns the pool of the function's environment.
+func (gpm *GenericPoolManager) GetFunctionPool(fn *crd.Function) (*GenericPool, error) {
+	env, err := gpm.getFunctionEnv(fn)
+	if err != nil {
+		return nil, err
+	}
+	return gpm.GetPool(env)
+}
+
+func (gpm *GenericPoolManager) getFunctionEnv(fn *crd.Function) (*crd.Environment, error) {
+	// Cached ?
+	result, err := gpm.functionEnv.Get(crd.CacheKey(&fn.Metadata))
+	if err == nil {
+		return result.(*crd.Environment), nil
+	}
+
+	// Cache miss -- get env from the function's reference
+	log.Printf("[%v] getting env", fn.Metadata.Name)
+	env, err := gpm.fissionClient.Environments(fn.Spec.Environment.Namespace).Get(fn.Spec.Environment.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// cache for future lookups
+	gpm.functionEnv.Set(crd.CacheKey(&fn.Metadata), env)
+
+	return env, nil
+}
+
+// GetFuncSvc specializes a pod from the pool of the function's
+// environment, and adds it to the function service cache.
+func (gpm *GenericPoolManager) GetFuncSvc(fn *crd.Function) (*fscache.FuncSvc, error) {
+	pool, err := gpm.GetFunctionPool(fn)
+	if err != nil {
+		return nil, err
+	}
+	err = gpm.quota.Check(fn, 1, pool.GetEnvironment().Spec.Resources)
+	if err != nil {
+		util.RecordFunctionEvent(gpm.kubernetesClient, &fn.Metadata, apiv1.EventTypeWarning,
+			util.EventReasonQuotaExceeded, err.Error())
+		return nil, err
+	}
+	// from GenericPool -> get one function container
+	// (this also adds to the cache)
+	log.Printf("[%v] getting function service from pool", fn.Metadata.Name)
+	return pool.GetFuncSvc(&fn.Metadata)
+}
+
+// TapService marks the function service at address as used.
+func (gpm *GenericPoolManager) TapService(address string) error {
+	return gpm.fsCache.TouchByAddress(address)
+}
+
+// DeleteFuncSvc deletes the pod of a function service.
+func (gpm *GenericPoolManager) DeleteFuncSvc(fsvc *fscache.FuncSvc) error {
+	for _, kubeobj := range fsvc.KubernetesObjects {
+		util.DeleteKubeObject(gpm.kubernetesClient, &kubeobj)
+	}
+	return nil
+}
+
+// ListFuncSvcs returns the cached function services of specialized
+// pool pods.
+func (gpm *GenericPoolManager) ListFuncSvcs() ([]*fscache.FuncSvc, error) {
+	fsvcs, err := gpm.fsCache.List()
+	if err != nil {
+		return nil, err
+	}
+	poolFsvcs := make([]*fscache.FuncSvc, 0)
+	for _, fsvc := range fsvcs {
+		if fsvc.Executor == fscache.POOLMGR {
+			poolFsvcs = append(poolFsvcs, fsvc)
+		}
+	}
+	return poolFsvcs, nil
+}
+
+// CleanupOnStart deletes pools and specialized pods of previous
+// executor instances, in the background.
+func (gpm *GenericPoolManager) CleanupOnStart() {
+	util.CleanupObjects(gpm.kubernetesClient, gpm.namespace, gpm.instanceId,
+		func(labels map[string]string) bool {
+			// specialized pods and objects of older releases have
+			// no executor type label
+			executorType, ok := labels["executorType"]
+			return !ok || executorType == fission.ExecutorTypePoolmgr
+		})
+}
+
+// idleObjectReaper deletes specialized pods that haven't been used for
+// idlePodReapTime.
+func (gpm *GenericPoolManager) idleObjectReaper() {
+	pollSleep := time.Duration(2 * time.Minute)
+	for {
+		time.Sleep(pollSleep)
+		gpm.reapIdlePods()
+	}
+}
+
+// reapIdlePods drains and deletes the specialized pods that have been
+// idle for longer than idlePodReapTime.
+func (gpm *GenericPoolManager) reapIdlePods() {
+	envs, err := gpm.fissionClient.Environments(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		log.Fatalf("Failed to get environment list: %v", err)
+	}
+
+	for i := range envs.Items {
+		env := envs.Items[i]
+		if env.Spec.AllowedFunctionsPerContainer == fission.AllowedFunctionsPerContainerInfinite {
+			continue
+		}
+		funcSvcs, err := gpm.fsCache.ListOld(&env.Metadata, gpm.idlePodReapTime)
+		if err != nil {
+			log.Printf("Error reaping idle pods: %v", err)
+			continue
+		}
+
+		for _, fsvc := range funcSvcs {
+
+			// NewDeploy scales its own idle deployments down to zero
+			// instead of deleting them.
+			if fsvc.Executor != fscache.POOLMGR {
+				continue
+			}
+
+			deleted, err := gpm.fsCache.DeleteOld(fsvc, gpm.idlePodReapTime)
+
+			if err != nil {
+				log.Printf("Error deleting Kubernetes objects for fsvc '%v': %v", fsvc, err)
+				log.Printf("Object Name| Object Kind | Object Namespace")
+				for _, kubeobj := range fsvc.KubernetesObjects {
+					log.Printf("%v | %v | %v", kubeobj.Name, kubeobj.Kind, kubeobj.Namespace)
+				}
+			}
+
+			if !deleted {
+				continue
+			}
+
+			// The function service is out of the cache now, so
+			// new requests won't be sent to it; wait for routers
+			// to finish the ones in flight before deleting it.
+			fsvc := fsvc
+			util.RecordFunctionEvent(gpm.kubernetesClient, fsvc.Function, apiv1.EventTypeNormal, util.EventReasonReaped,
+				fmt.Sprintf("Reaped function service %v, idle for more than %v", fsvc.Address, gpm.idlePodReapTime))
+			gpm.drainer.Drain(fsvc, func() {
+				err := gpm.DeleteFuncSvc(fsvc)
+				if err != nil {
+					log.Printf("Error deleting function service %v: %v", fsvc.Name, err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,287 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/rest"
+	k8sCache "k8s.io/client-go/tools/cache"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+type (
+	// prewarmer specializes pods for poolmgr functions with Prewarm set
+	// as soon as they're created or their package build succeeds, so
+	// that the first invocation doesn't pay for fetching and
+	// specializing.
+	//
+	// Functions are pre-warmed one at a time, and only a few functions
+	// per environment may hold pre-warmed pods, so pre-warming can't
+	// drain the pool that serves cold requests.
+	//
+	// Pre-warmed pods are reaped when idle like any other, so functions
+	// whose pods were reaped are pre-warmed again every
+	// prewarmCheckInterval.
+	prewarmer struct {
+		executor *Executor
+
+		// functions pre-warmed by this executor, by environment and
+		// function cache key. Only the service goroutine uses it.
+		prewarmed      map[string]map[string]bool
+		requestChannel chan *crd.Function
+
+		funcStore      k8sCache.Store
+		funcController k8sCache.Controller
+		pkgController  k8sCache.Controller
+	}
+)
+
+// Pre-warm requests queued beyond this are dropped; the function is
+// specialized on its first invocation instead.
+const prewarmQueueLength = 1000
+
+// How often functions are checked for having lost their pre-warmed
+// pods.
+const prewarmCheckInterval = time.Minute
+
+func makePrewarmer(executor *Executor, crdClient *rest.RESTClient) *prewarmer {
+	p := &prewarmer{
+		executor:       executor,
+		prewarmed:      make(map[string]map[string]bool),
+		requestChannel: make(chan *crd.Function, prewarmQueueLength),
+	}
+	p.funcStore, p.funcController = p.initFuncController(crdClient)
+	p.pkgController = p.initPkgController(crdClient)
+	go p.service()
+	return p
+}
+
+func (p *prewarmer) Run(ctx context.Context) {
+	go p.funcController.Run(ctx.Done())
+	go p.pkgController.Run(ctx.Done())
+	go p.rewarmLoop(ctx)
+}
+
+func (p *prewarmer) rewarmLoop(ctx context.Context) {
+	ticker := time.NewTicker(prewarmCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.rewarm()
+		}
+	}
+}
+
+// rewarm asks for functions that should be pre-warmed but have no
+// function service, e.g. because it was reaped, to be pre-warmed
+// again. Informer resyncs don't, since the functions haven't changed.
+func (p *prewarmer) rewarm() {
+	for _, obj := range p.funcStore.List() {
+		fn := obj.(*crd.Function)
+		if !isPrewarmed(fn) {
+			continue
+		}
+		if _, err := p.executor.fsCache.GetByFunction(&fn.Metadata); err == nil {
+			continue
+		}
+		p.enqueue(fn)
+	}
+}
+
+func (p *prewarmer) initFuncController(crdClient *rest.RESTClient) (k8sCache.Store, k8sCache.Controller) {
+	resyncPeriod := 30 * time.Second
+	listWatch := k8sCache.NewListWatchFromClient(crdClient, "functions", metav1.NamespaceAll, fields.Everything())
+	store, controller := k8sCache.NewInformer(listWatch, &crd.Function{}, resyncPeriod, k8sCache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			fn := obj.(*crd.Function)
+			p.enqueue(fn)
+		},
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			oldFn := oldObj.(*crd.Function)
+			newFn := newObj.(*crd.Function)
+			// resyncs deliver unchanged functions, which are
+			// already warm or were skipped
+			if oldFn.Metadata.ResourceVersion != newFn.Metadata.ResourceVersion {
+				p.enqueue(newFn)
+			}
+		},
+	})
+	return store, controller
+}
+
+func (p *prewarmer) initPkgController(crdClient *rest.RESTClient) k8sCache.Controller {
+	resyncPeriod := 30 * time.Second
+	listWatch := k8sCache.NewListWatchFromClient(crdClient, "packages", metav1.NamespaceAll, fields.Everything())
+	_, controller := k8sCache.NewInformer(listWatch, &crd.Package{}, resyncPeriod, k8sCache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			oldPkg := oldObj.(*crd.Package)
+			newPkg := newObj.(*crd.Package)
+			if oldPkg.Status.BuildStatus == fission.BuildStatusSucceeded ||
+				newPkg.Status.BuildStatus != fission.BuildStatusSucceeded {
+				return
+			}
+			for _, obj := range p.funcStore.List() {
+				fn := obj.(*crd.Function)
+				ref := fn.Spec.Package.PackageRef
+				if ref.Name == newPkg.Metadata.Name && ref.Namespace == newPkg.Metadata.Namespace {
+					p.enqueue(fn)
+				}
+			}
+		},
+	})
+	return controller
+}
+
+// isPrewarmed returns true for functions that ask to be pre-warmed
+// and run on the pool manager.
+func isPrewarmed(fn *crd.Function) bool {
+	strategy := fn.Spec.InvokeStrategy.ExecutionStrategy
+	if !strategy.Prewarm {
+		return false
+	}
+	switch strategy.ExecutorType {
+	case "", fission.ExecutorTypePoolmgr:
+		return true
+	}
+	return false
+}
+
+// enqueue asks for fn to be pre-warmed, without blocking the informer.
+func (p *prewarmer) enqueue(fn *crd.Function) {
+	if !isPrewarmed(fn) {
+		return
+	}
+	select {
+	case p.requestChannel <- fn:
+	default:
+		log.Printf("[%v] Too many functions waiting to be pre-warmed, skipping", fn.Metadata.Name)
+	}
+}
+
+func (p *prewarmer) service() {
+	for fn := range p.requestChannel {
+		err := p.prewarm(fn)
+		if err != nil {
+			log.Printf("[%v] Error pre-warming function: %v", fn.Metadata.Name, err)
+		}
+	}
+}
+
+func (p *prewarmer) prewarm(fn *crd.Function) error {
+	m := &fn.Metadata
+
+	_, err := p.executor.fsCache.GetByFunction(m)
+	if err == nil {
+		// already warm
+		return nil
+	}
+
+	// Functions with a package that's still being built are
+	// pre-warmed once the build succeeds.
+	ref := fn.Spec.Package.PackageRef
+	if len(ref.Name) > 0 {
+		pkg, err := p.executor.fissionClient.Packages(ref.Namespace).Get(ref.Name)
+		if err != nil {
+			return err
+		}
+		switch pkg.Status.BuildStatus {
+		case "", fission.BuildStatusSucceeded, fission.BuildStatusNone:
+		default:
+			log.Printf("[%v] Package %v is %v, not pre-warming yet", m.Name, ref.Name, pkg.Status.BuildStatus)
+			return nil
+		}
+	}
+
+	pool, err := p.executor.gpm.GetFunctionPool(fn)
+	if err != nil {
+		return err
+	}
+	env := pool.GetEnvironment()
+
+	envKey := crd.CacheKey(&env.Metadata)
+	maxPrewarmed := prewarmLimit(pool.GetReplicas())
+	count, err := p.countPrewarmed(envKey)
+	if err != nil {
+		return err
+	}
+	if count >= maxPrewarmed {
+		log.Printf("[%v] Environment %v already has %v pre-warmed functions, not pre-warming",
+			m.Name, env.Metadata.Name, count)
+		return nil
+	}
+
+	log.Printf("[%v] Pre-warming function", m.Name)
+	_, err = p.executor.getServiceForFunction(m)
+	if err != nil {
+		return err
+	}
+	if _, ok := p.prewarmed[envKey]; !ok {
+		p.prewarmed[envKey] = make(map[string]bool)
+	}
+	p.prewarmed[envKey][crd.CacheKey(m)] = true
+	return nil
+}
+
+// countPrewarmed returns the number of functions of an environment
+// that still have a pod pre-warmed by this executor, forgetting those
+// whose pods have been reaped since.
+func (p *prewarmer) countPrewarmed(envKey string) (int, error) {
+	fns, ok := p.prewarmed[envKey]
+	if !ok {
+		return 0, nil
+	}
+
+	fsvcs, err := p.executor.fsCache.List()
+	if err != nil {
+		return 0, err
+	}
+	warm := make(map[string]bool)
+	for _, fsvc := range fsvcs {
+		warm[crd.CacheKey(fsvc.Function)] = true
+	}
+
+	for key := range fns {
+		if !warm[key] {
+			delete(fns, key)
+		}
+	}
+	return len(fns), nil
+}
+
+// prewarmLimit returns how many functions of an environment may hold
+// pre-warmed pods: half of its pool, so that the rest of the pool is
+// left for cold requests of other functions.
+func prewarmLimit(poolsize int32) int {
+	if poolsize <= 0 {
+		return 0
+	}
+	limit := int(poolsize) / 2
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
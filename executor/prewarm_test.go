@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"testing"
+
+	k8sCache "k8s.io/client-go/tools/cache"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+)
+
+func TestPrewarmLimit(t *testing.T) {
+	tests := map[int32]int{0: 0, 1: 1, 2: 1, 3: 1, 10: 5}
+	for poolsize, expected := range tests {
+		if limit := prewarmLimit(poolsize); limit != expected {
+			t.Errorf("poolsize %v: expected limit %v, got %v", poolsize, expected, limit)
+		}
+	}
+}
+
+func TestIsPrewarmed(t *testing.T) {
+	fn := &crd.Function{}
+	if isPrewarmed(fn) {
+		t.Errorf("function without prewarm shouldn't be pre-warmed")
+	}
+
+	fn.Spec.InvokeStrategy.ExecutionStrategy.Prewarm = true
+	if !isPrewarmed(fn) {
+		t.Errorf("function with default executor should be pre-warmed")
+	}
+
+	fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType = fission.ExecutorTypeNewdeploy
+	if isPrewarmed(fn) {
+		t.Errorf("newdeploy function shouldn't be pre-warmed")
+	}
+}
+
+func TestRewarmAfterReap(t *testing.T) {
+	fn := makeTestFunction("foo", fission.ExecutorTypePoolmgr)
+	fn.Spec.InvokeStrategy.ExecutionStrategy.Prewarm = true
+	cold := makeTestFunction("bar", fission.ExecutorTypePoolmgr)
+	executor, _ := makeTestExecutor(fn, cold)
+
+	p := &prewarmer{
+		executor:       executor,
+		prewarmed:      make(map[string]map[string]bool),
+		requestChannel: make(chan *crd.Function, 10),
+		funcStore:      k8sCache.NewStore(k8sCache.MetaNamespaceKeyFunc),
+	}
+	p.funcStore.Add(fn)
+	p.funcStore.Add(cold)
+
+	_, err := executor.getServiceForFunction(&fn.Metadata)
+	if err != nil {
+		t.Fatalf("error getting service for %v: %v", fn.Metadata.Name, err)
+	}
+	p.rewarm()
+	if n := len(p.requestChannel); n != 0 {
+		t.Errorf("expected warm function not to be re-warmed, got %v requests", n)
+	}
+
+	// the idle reaper deletes the pre-warmed function service
+	fsvc, err := executor.fsCache.GetByFunction(&fn.Metadata)
+	if err != nil {
+		t.Fatalf("error getting function service: %v", err)
+	}
+	_, err = executor.fsCache.DeleteOld(fsvc, 0)
+	if err != nil {
+		t.Fatalf("error reaping function service: %v", err)
+	}
+
+	p.rewarm()
+	if n := len(p.requestChannel); n != 1 {
+		t.Fatalf("expected 1 re-warm request, got %v", n)
+	}
+	if req := <-p.requestChannel; req.Metadata.Name != fn.Metadata.Name {
+		t.Errorf("expected %v to be re-warmed, got %v", fn.Metadata.Name, req.Metadata.Name)
+	}
+}
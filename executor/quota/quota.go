@@ -0,0 +1,214 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/cache"
+	"github.com/fission/fission/crd"
+	"github.com/fission/fission/executor/fscache"
+)
+
+type (
+	// Checker enforces the FunctionQuotas of namespaces. Usage is
+	// computed from the function service cache, so it only covers
+	// functions run by this executor.
+	Checker struct {
+		fissionClient crd.FissionClientInterface
+		fsCache       *fscache.FunctionServiceCache
+		functions     *cache.Cache // function cache key -> *crd.Function
+	}
+
+	// usage is the function capacity that a namespace uses, or that
+	// a function asks for.
+	usage struct {
+		pods     int
+		milliCPU int64
+		memory   int64
+	}
+)
+
+// functionCacheTime is how long functions looked up for usage are kept
+// after they were last used.
+const functionCacheTime = 10 * time.Minute
+
+func MakeChecker(fissionClient crd.FissionClientInterface, fsCache *fscache.FunctionServiceCache) *Checker {
+	return &Checker{
+		fissionClient: fissionClient,
+		fsCache:       fsCache,
+		functions:     cache.MakeCache(0, functionCacheTime),
+	}
+}
+
+// DeploymentPods returns the number of pods a newdeploy or container
+// function counts as: the most it may scale to.
+func DeploymentPods(fn *crd.Function) int {
+	strategy := fn.Spec.InvokeStrategy.ExecutionStrategy
+	pods := strategy.MaxScale
+	if strategy.MinScale > pods {
+		pods = strategy.MinScale
+	}
+	if pods < 1 {
+		pods = 1
+	}
+	return pods
+}
+
+// Check returns an ErrorNoSpace error if running pods more pods for fn,
+// each with the given resources, would exceed a quota of fn's
+// namespace.
+//
+// Concurrent checks may each see the usage from before the others, so
+// quotas can be overrun by the number of functions specialized at the
+// same time.
+func (c *Checker) Check(fn *crd.Function, pods int, resources apiv1.ResourceRequirements) error {
+	quotas, err := c.fissionClient.FunctionQuotas(fn.Metadata.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+
+	used, err := c.getUsage(fn.Metadata.Namespace)
+	if err != nil {
+		return err
+	}
+	requested := &usage{}
+	requested.add(pods, resources)
+
+	for i := range quotas.Items {
+		err = checkQuota(&quotas.Items[i], fn, used, requested)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getUsage adds up the function services of a namespace's functions.
+func (c *Checker) getUsage(namespace string) (*usage, error) {
+	fsvcs, err := c.fsCache.List()
+	if err != nil {
+		return nil, err
+	}
+
+	used := &usage{}
+	for _, fsvc := range fsvcs {
+		if fsvc.Function.Namespace != namespace {
+			continue
+		}
+		switch fsvc.Executor {
+		case fscache.POOLMGR:
+			var resources apiv1.ResourceRequirements
+			if fsvc.Environment != nil {
+				resources = fsvc.Environment.Spec.Resources
+			}
+			used.add(1, resources)
+		default:
+			fn, err := c.getFunction(fsvc.Function)
+			if err != nil {
+				// deleted functions are cleaned up soon
+				log.Printf("Error getting function %v for quota usage: %v", fsvc.Function.Name, err)
+				continue
+			}
+			resources := fn.Spec.Resources
+			if fsvc.Executor == fscache.NEWDEPLOY && fsvc.Environment != nil {
+				resources = fsvc.Environment.Spec.Resources
+			}
+			used.add(DeploymentPods(fn), resources)
+		}
+	}
+	return used, nil
+}
+
+// getFunction gets the function a service runs. Function services
+// are for one version of a function, so they're cached by version.
+func (c *Checker) getFunction(m *metav1.ObjectMeta) (*crd.Function, error) {
+	key := crd.CacheKey(m)
+	if item, err := c.functions.Get(key); err == nil {
+		return item.(*crd.Function), nil
+	}
+	fn, err := c.fissionClient.Functions(m.Namespace).Get(m.Name)
+	if err != nil {
+		return nil, err
+	}
+	c.functions.Set(key, fn)
+	return fn, nil
+}
+
+// checkQuota checks a request for more pods against one quota.
+func checkQuota(q *crd.FunctionQuota, fn *crd.Function, used *usage, requested *usage) error {
+	noSpace := func(format string, args ...interface{}) error {
+		msg := fmt.Sprintf("function %v exceeds quota %v: %v", fn.Metadata.Name, q.Metadata.Name,
+			fmt.Sprintf(format, args...))
+		return fission.MakeError(fission.ErrorNoSpace, msg)
+	}
+
+	strategy := fn.Spec.InvokeStrategy.ExecutionStrategy
+	switch strategy.ExecutorType {
+	case fission.ExecutorTypeNewdeploy, fission.ExecutorTypeContainer:
+		if q.Spec.MaxScale > 0 && strategy.MaxScale > q.Spec.MaxScale {
+			return noSpace("maxscale %v is above %v", strategy.MaxScale, q.Spec.MaxScale)
+		}
+	}
+
+	if q.Spec.MaxPods > 0 && used.pods+requested.pods > q.Spec.MaxPods {
+		return noSpace("%v pods in use, %v more requested, limit is %v",
+			used.pods, requested.pods, q.Spec.MaxPods)
+	}
+
+	if limit, ok := q.Spec.MaxResources[apiv1.ResourceCPU]; ok && !limit.IsZero() {
+		total := used.milliCPU + requested.milliCPU
+		if total > limit.MilliValue() {
+			return noSpace("%vm cpu in use and requested, limit is %v", total, limit.String())
+		}
+	}
+	if limit, ok := q.Spec.MaxResources[apiv1.ResourceMemory]; ok && !limit.IsZero() {
+		total := used.memory + requested.memory
+		if total > limit.Value() {
+			return noSpace("%v bytes of memory in use and requested, limit is %v", total, limit.String())
+		}
+	}
+	return nil
+}
+
+// add counts pods pods, each with resources.
+func (u *usage) add(pods int, resources apiv1.ResourceRequirements) {
+	cpu := podResource(resources, apiv1.ResourceCPU)
+	memory := podResource(resources, apiv1.ResourceMemory)
+	u.pods += pods
+	u.milliCPU += int64(pods) * cpu.MilliValue()
+	u.memory += int64(pods) * memory.Value()
+}
+
+// podResource returns the limit of a resource, or its request if it
+// has no limit.
+func podResource(resources apiv1.ResourceRequirements, name apiv1.ResourceName) resource.Quantity {
+	if q, ok := resources.Limits[name]; ok {
+		return q
+	}
+	return resources.Requests[name]
+}
@@ -0,0 +1,137 @@
+package quota
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiv1 "k8s.io/client-go/pkg/api/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/crd"
+	crdFake "github.com/fission/fission/crd/fake"
+	"github.com/fission/fission/executor/fscache"
+)
+
+func makeResources(cpu string, memory string) apiv1.ResourceRequirements {
+	return apiv1.ResourceRequirements{
+		Limits: apiv1.ResourceList{
+			apiv1.ResourceCPU:    resource.MustParse(cpu),
+			apiv1.ResourceMemory: resource.MustParse(memory),
+		},
+	}
+}
+
+func expectNoSpace(t *testing.T, err error, msg string) {
+	fe, ok := err.(fission.Error)
+	if !ok || fe.Code != fission.ErrorNoSpace {
+		t.Errorf("%v: expected no space error, got %v", msg, err)
+	}
+}
+
+func TestDeploymentPods(t *testing.T) {
+	fn := &crd.Function{}
+	if n := DeploymentPods(fn); n != 1 {
+		t.Errorf("expected 1 pod for unscaled function, got %v", n)
+	}
+	fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale = 2
+	fn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale = 5
+	if n := DeploymentPods(fn); n != 5 {
+		t.Errorf("expected maxscale pods, got %v", n)
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	q := &crd.FunctionQuota{
+		Metadata: metav1.ObjectMeta{Name: "team"},
+		Spec: fission.FunctionQuotaSpec{
+			MaxPods: 4,
+			MaxResources: apiv1.ResourceList{
+				apiv1.ResourceCPU: resource.MustParse("1"),
+			},
+			MaxScale: 3,
+		},
+	}
+	fn := &crd.Function{Metadata: metav1.ObjectMeta{Name: "foo"}}
+
+	used := &usage{}
+	used.add(2, makeResources("200m", "128Mi"))
+	if used.milliCPU != 400 || used.memory != 2*128*1024*1024 {
+		t.Errorf("wrong usage %#v", used)
+	}
+
+	requested := &usage{}
+	requested.add(1, makeResources("200m", "128Mi"))
+	if err := checkQuota(q, fn, used, requested); err != nil {
+		t.Errorf("unexpected error within quota: %v", err)
+	}
+
+	requested = &usage{}
+	requested.add(3, makeResources("100m", "128Mi"))
+	expectNoSpace(t, checkQuota(q, fn, used, requested), "too many pods")
+
+	requested = &usage{}
+	requested.add(1, makeResources("700m", "128Mi"))
+	expectNoSpace(t, checkQuota(q, fn, used, requested), "too much cpu")
+
+	// memory isn't limited by this quota
+	requested = &usage{}
+	requested.add(1, makeResources("100m", "64Gi"))
+	if err := checkQuota(q, fn, used, requested); err != nil {
+		t.Errorf("unexpected error for unlimited resource: %v", err)
+	}
+
+	// maxscale only applies to deployments
+	fn.Spec.InvokeStrategy.ExecutionStrategy.MaxScale = 10
+	if err := checkQuota(q, fn, &usage{}, &usage{}); err != nil {
+		t.Errorf("unexpected maxscale error for poolmgr function: %v", err)
+	}
+	fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType = fission.ExecutorTypeNewdeploy
+	expectNoSpace(t, checkQuota(q, fn, &usage{}, &usage{}), "maxscale above quota")
+}
+
+func TestUsageCachesFunctions(t *testing.T) {
+	fissionClient := crdFake.MakeFissionClient()
+	fsCache := fscache.MakeFunctionServiceCache()
+	c := MakeChecker(fissionClient, fsCache)
+
+	fn, err := fissionClient.Functions(metav1.NamespaceDefault).Create(&crd.Function{
+		Metadata: metav1.ObjectMeta{Name: "hello", Namespace: metav1.NamespaceDefault},
+		Spec: fission.FunctionSpec{
+			InvokeStrategy: fission.InvokeStrategy{
+				ExecutionStrategy: fission.ExecutionStrategy{
+					ExecutorType: fission.ExecutorTypeContainer,
+					MaxScale:     3,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating function: %v", err)
+	}
+	_, err = fsCache.Add(fscache.FuncSvc{
+		Name:     "hello",
+		Function: &fn.Metadata,
+		Address:  "10.0.0.1",
+		Executor: fscache.CONTAINER,
+	})
+	if err != nil {
+		t.Fatalf("Error adding function service: %v", err)
+	}
+
+	used, err := c.getUsage(metav1.NamespaceDefault)
+	if err != nil || used.pods != 3 {
+		t.Fatalf("Expected 3 pods in use, got %v, %v", used, err)
+	}
+
+	// the function service's version of the function is cached, so
+	// it's counted without getting it again
+	err = fissionClient.Functions(metav1.NamespaceDefault).Delete(fn.Metadata.Name, nil)
+	if err != nil {
+		t.Fatalf("Error deleting function: %v", err)
+	}
+	used, err = c.getUsage(metav1.NamespaceDefault)
+	if err != nil || used.pods != 3 {
+		t.Errorf("Expected cached function to count 3 pods, got %v, %v", used, err)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	k8s_err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	executorClient "github.com/fission/fission/executor/client"
+	"github.com/fission/fission/executor/fscache"
+)
+
+// listFuncSvcs returns the function services of all backends
+func (executor *Executor) listFuncSvcs(w http.ResponseWriter, r *http.Request) {
+	fns, err := executor.fissionClient.Functions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		code, msg := fission.GetHTTPError(err)
+		http.Error(w, msg, code)
+		return
+	}
+	minScales := make(map[string]int)
+	for _, fn := range fns.Items {
+		minScales[functionKey(&fn.Metadata)] = fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale
+	}
+
+	statuses := make([]executorClient.FuncSvcStatus, 0)
+	for _, backend := range executor.uniqueBackends() {
+		fsvcs, err := backend.ListFuncSvcs()
+		if err != nil {
+			code, msg := fission.GetHTTPError(err)
+			http.Error(w, msg, code)
+			return
+		}
+		for _, fsvc := range fsvcs {
+			statuses = append(statuses, getFuncSvcStatus(fsvc, minScales))
+		}
+	}
+	executor.respondWithJSON(w, statuses, http.StatusOK)
+}
+
+// listPools returns the number of generic and specialized pods of each
+// environment's pool
+func (executor *Executor) listPools(w http.ResponseWriter, r *http.Request) {
+	fsvcs, err := executor.fsCache.List()
+	if err != nil {
+		code, msg := fission.GetHTTPError(err)
+		http.Error(w, msg, code)
+		return
+	}
+	specialized := make(map[string]int)
+	for _, fsvc := range fsvcs {
+		if fsvc.Executor == fscache.POOLMGR && fsvc.Environment != nil {
+			specialized[string(fsvc.Environment.Metadata.UID)]++
+		}
+	}
+
+	pools := executor.gpm.ListPools()
+	statuses := make([]executorClient.PoolStatus, 0, len(pools))
+	for _, pool := range pools {
+		env := pool.GetEnvironment()
+		ready, pending, err := pool.CountPods()
+		if err != nil {
+			log.Printf("Error counting pods of pool for environment %v: %v", env.Metadata.Name, err)
+			code, msg := fission.GetHTTPError(err)
+			http.Error(w, msg, code)
+			return
+		}
+		statuses = append(statuses, executorClient.PoolStatus{
+			Environment: env.Metadata,
+			Ready:       ready,
+			Pending:     pending,
+			Specialized: specialized[string(env.Metadata.UID)],
+		})
+	}
+	executor.respondWithJSON(w, statuses, http.StatusOK)
+}
+
+// getFunctionStatus returns whether a function is warm, and which
+// function services it runs on
+func (executor *Executor) getFunctionStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ns := r.FormValue("namespace")
+	if len(ns) == 0 {
+		ns = metav1.NamespaceDefault
+	}
+
+	fn, err := executor.fissionClient.Functions(ns).Get(vars["function"])
+	if err != nil {
+		if k8s_err.IsNotFound(err) {
+			err = fission.MakeError(fission.ErrorNotFound, fmt.Sprintf("function %v not found", vars["function"]))
+		}
+		code, msg := fission.GetHTTPError(err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	fsvcs, err := executor.fsCache.List()
+	if err != nil {
+		code, msg := fission.GetHTTPError(err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	status := executorClient.FunctionStatus{
+		Function:     fn.Metadata,
+		ExecutorType: string(fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType),
+		Services:     make([]executorClient.FuncSvcStatus, 0),
+	}
+	if len(status.ExecutorType) == 0 {
+		status.ExecutorType = fission.ExecutorTypePoolmgr
+	}
+	minScales := map[string]int{
+		functionKey(&fn.Metadata): fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale,
+	}
+	for _, fsvc := range fsvcs {
+		// match by name rather than cache key, so that services of
+		// older versions of the function show up too
+		if fsvc.Function.Name == fn.Metadata.Name && fsvc.Function.Namespace == fn.Metadata.Namespace {
+			status.Services = append(status.Services, getFuncSvcStatus(fsvc, minScales))
+		}
+	}
+	status.Warm = len(status.Services) > 0
+	executor.respondWithJSON(w, status, http.StatusOK)
+}
+
+// functionKey returns the namespace/name of a function, whichever
+// version of it the metadata is of.
+func functionKey(m *metav1.ObjectMeta) string {
+	return m.Namespace + "/" + m.Name
+}
+
+// getFuncSvcStatus returns the status of a function service. minScales
+// has the MinScale of functions by functionKey, fetched once per
+// request rather than for each function service.
+func getFuncSvcStatus(fsvc *fscache.FuncSvc, minScales map[string]int) executorClient.FuncSvcStatus {
+	status := executorClient.FuncSvcStatus{
+		Function:          *fsvc.Function,
+		Name:              fsvc.Name,
+		Address:           fsvc.Address,
+		Executor:          fsvc.Executor.String(),
+		Ctime:             fsvc.Ctime,
+		Atime:             fsvc.Atime,
+		KubernetesObjects: fsvc.KubernetesObjects,
+	}
+	if fsvc.Environment != nil {
+		status.Environment = &fsvc.Environment.Metadata
+	}
+	if isReapedWhenIdle(fsvc, minScales) {
+		reapTime := fsvc.Atime.Add(idlePodReapTime)
+		status.ReapTime = &reapTime
+	}
+	return status
+}
+
+// isReapedWhenIdle tells whether the idle reapers clean up the function
+// service once it's been unused for idlePodReapTime.
+func isReapedWhenIdle(fsvc *fscache.FuncSvc, minScales map[string]int) bool {
+	switch fsvc.Executor {
+	case fscache.POOLMGR:
+		return fsvc.Environment == nil ||
+			fsvc.Environment.Spec.AllowedFunctionsPerContainer != fission.AllowedFunctionsPerContainerInfinite
+	default:
+		// newdeploy keeps MinScale replicas running; services of
+		// deleted functions are cleaned up
+		return minScales[functionKey(fsvc.Function)] == 0
+	}
+}
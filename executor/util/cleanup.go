@@ -14,7 +14,7 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package executor
+package util
 
 import (
 	"fmt"
@@ -22,22 +22,26 @@ import (
 	"strings"
 	"time"
 
-	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/pkg/api"
 
 	"github.com/fission/fission"
-	"github.com/fission/fission/crd"
-	"github.com/fission/fission/executor/fscache"
 )
 
-// cleanupObjects cleans up resources created by old executortype instances
-func cleanupObjects(kubernetesClient *kubernetes.Clientset,
+// LabelFilter tells whether an object with the given labels belongs to
+// the caller, so that executor types only clean up their own objects.
+type LabelFilter func(labels map[string]string) bool
+
+// CleanupObjects cleans up resources created by old executor instances
+// in the background. Only objects accepted by owns are deleted.
+func CleanupObjects(kubernetesClient kubernetes.Interface,
 	namespace string,
-	instanceId string) {
+	instanceId string,
+	owns LabelFilter) {
 	go func() {
-		err := cleanup(kubernetesClient, namespace, instanceId)
+		err := cleanup(kubernetesClient, namespace, instanceId, owns)
 		if err != nil {
 			// TODO retry cleanup; logged and ignored for now
 			log.Printf("Failed to cleanup: %v", err)
@@ -45,14 +49,14 @@ func cleanupObjects(kubernetesClient *kubernetes.Clientset,
 	}()
 }
 
-func cleanup(client *kubernetes.Clientset, namespace string, instanceId string) error {
+func cleanup(client kubernetes.Interface, namespace string, instanceId string, owns LabelFilter) error {
 
-	err := cleanupServices(client, namespace, instanceId)
+	err := cleanupServices(client, namespace, instanceId, owns)
 	if err != nil {
 		return err
 	}
 
-	err = cleanupHpa(client, namespace, instanceId)
+	err = cleanupHpa(client, namespace, instanceId, owns)
 	if err != nil {
 		return err
 	}
@@ -60,7 +64,7 @@ func cleanup(client *kubernetes.Clientset, namespace string, instanceId string)
 	// Deployments are used for idle pools and can be cleaned up
 	// immediately.  (We should "adopt" these instead of creating
 	// a new pool.)
-	err = cleanupDeployments(client, namespace, instanceId)
+	err = cleanupDeployments(client, namespace, instanceId, owns)
 	if err != nil {
 		return err
 	}
@@ -68,7 +72,7 @@ func cleanup(client *kubernetes.Clientset, namespace string, instanceId string)
 	// through the API doesn't cause the associated ReplicaSet to
 	// be deleted.  (Fixed recently, but we may be running a
 	// version before the fix.)
-	err = cleanupReplicaSets(client, namespace, instanceId)
+	err = cleanupReplicaSets(client, namespace, instanceId, owns)
 	if err != nil {
 		return err
 	}
@@ -80,7 +84,7 @@ func cleanup(client *kubernetes.Clientset, namespace string, instanceId string)
 	// time.
 	time.Sleep(6 * time.Minute)
 
-	err = cleanupPods(client, namespace, instanceId)
+	err = cleanupPods(client, namespace, instanceId, owns)
 	if err != nil {
 		return err
 	}
@@ -88,66 +92,21 @@ func cleanup(client *kubernetes.Clientset, namespace string, instanceId string)
 	return nil
 }
 
-// idleObjectReaper reaps objects after certain idle time
-func idleObjectReaper(kubeClient *kubernetes.Clientset,
-	fissionClient *crd.FissionClient,
-	fsCache *fscache.FunctionServiceCache,
-	idlePodReapTime time.Duration) {
-
-	pollSleep := time.Duration(2 * time.Minute)
-	for {
-		time.Sleep(pollSleep)
-
-		envs, err := fissionClient.Environments(meta_v1.NamespaceAll).List(meta_v1.ListOptions{})
-		if err != nil {
-			log.Fatalf("Failed to get environment list: %v", err)
-		}
-
-		for i := range envs.Items {
-			env := envs.Items[i]
-			if env.Spec.AllowedFunctionsPerContainer == fission.AllowedFunctionsPerContainerInfinite {
-				continue
-			}
-			funcSvcs, err := fsCache.ListOld(&env.Metadata, idlePodReapTime)
-			if err != nil {
-				log.Printf("Error reaping idle pods: %v", err)
-				continue
-			}
-
-			for _, fsvc := range funcSvcs {
-
-				fn, err := fissionClient.Functions(fsvc.Function.Namespace).Get(fsvc.Function.Name)
-				if err != nil {
-					log.Printf("Error getting function: %v", fsvc.Function.Name)
-					continue
-				}
-
-				// Ignore functions of NewDeploy ExecutorType with MinScale > 0
-				if fn.Spec.InvokeStrategy.ExecutionStrategy.MinScale > 0 && fn.Spec.InvokeStrategy.ExecutionStrategy.ExecutorType == fission.ExecutorTypeNewdeploy {
-					continue
-				}
-				deleted, err := fsCache.DeleteOld(fsvc, idlePodReapTime)
-
-				if err != nil {
-					log.Printf("Error deleting Kubernetes objects for fsvc '%v': %v", fsvc, err)
-					log.Printf("Object Name| Object Kind | Object Namespace")
-					for _, kubeobj := range fsvc.KubernetesObjects {
-						log.Printf("%v | %v | %v", kubeobj.Name, kubeobj.Kind, kubeobj.Namespace)
-					}
-				}
-
-				if !deleted {
-					continue
-				}
-				for _, kubeobj := range fsvc.KubernetesObjects {
-					deleteKubeobject(kubeClient, &kubeobj)
-				}
-			}
-		}
+// isOldObject tells whether an object was created by an executor
+// instance other than instanceId.
+func isOldObject(objLabels map[string]string, instanceId string) bool {
+	id, ok := objLabels[fission.EXECUTOR_INSTANCEID_LABEL]
+	if ok && id != instanceId {
+		return true
 	}
+	// Backward compatibility with older label name
+	pid, pok := objLabels[fission.POOLMGR_INSTANCEID_LABEL]
+	return pok && pid != instanceId
 }
 
-func deleteKubeobject(kubeClient *kubernetes.Clientset, kubeobj *api.ObjectReference) {
+// DeleteKubeObject deletes an object created for a function service.
+// Errors are logged and ignored.
+func DeleteKubeObject(kubeClient kubernetes.Interface, kubeobj *api.ObjectReference) {
 	switch strings.ToLower(kubeobj.Kind) {
 	case "pod":
 		err := kubeClient.CoreV1().Pods(kubeobj.Namespace).Delete(kubeobj.Name, nil)
@@ -158,7 +117,7 @@ func deleteKubeobject(kubeClient *kubernetes.Clientset, kubeobj *api.ObjectRefer
 		logErr(fmt.Sprintf("cleaning up service %v ", kubeobj.Name), err)
 
 	case "deployment":
-		depl, err := kubeClient.ExtensionsV1beta1().Deployments(kubeobj.Namespace).Get(kubeobj.Name, meta_v1.GetOptions{})
+		depl, err := kubeClient.ExtensionsV1beta1().Deployments(kubeobj.Namespace).Get(kubeobj.Name, metav1.GetOptions{})
 		err = kubeClient.ExtensionsV1beta1().Deployments(kubeobj.Namespace).Delete(kubeobj.Name, nil)
 		logErr(fmt.Sprintf("cleaning up deployment %v ", kubeobj.Name), err)
 		cleanupDeploymentObjects(kubeClient, kubeobj.Namespace, depl.Labels)
@@ -173,15 +132,15 @@ func deleteKubeobject(kubeClient *kubernetes.Clientset, kubeobj *api.ObjectRefer
 	}
 }
 
-func cleanupDeploymentObjects(kubeClient *kubernetes.Clientset, namespace string, sel map[string]string) {
-	rsList, err := kubeClient.ExtensionsV1beta1().ReplicaSets(namespace).List(meta_v1.ListOptions{LabelSelector: labels.Set(sel).AsSelector().String()})
+func cleanupDeploymentObjects(kubeClient kubernetes.Interface, namespace string, sel map[string]string) {
+	rsList, err := kubeClient.ExtensionsV1beta1().ReplicaSets(namespace).List(metav1.ListOptions{LabelSelector: labels.Set(sel).AsSelector().String()})
 	logErr("Getting replicaset for deployment ", err)
 	for _, rs := range rsList.Items {
 		err = kubeClient.ExtensionsV1beta1().ReplicaSets(namespace).Delete(rs.Name, nil)
 		logErr(fmt.Sprintf("Cleaning replicaset %v for deployment", rs.Name), err)
 	}
 
-	podList, err := kubeClient.CoreV1().Pods(namespace).List(meta_v1.ListOptions{LabelSelector: labels.Set(sel).AsSelector().String()})
+	podList, err := kubeClient.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: labels.Set(sel).AsSelector().String()})
 	logErr("Getting pods for deployment ", err)
 	for _, pod := range podList.Items {
 		err = kubeClient.CoreV1().Pods(namespace).Delete(pod.Name, nil)
@@ -189,22 +148,13 @@ func cleanupDeploymentObjects(kubeClient *kubernetes.Clientset, namespace string
 	}
 }
 
-func cleanupDeployments(client *kubernetes.Clientset, namespace string, instanceId string) error {
-	deploymentList, err := client.ExtensionsV1beta1().Deployments(namespace).List(meta_v1.ListOptions{})
+func cleanupDeployments(client kubernetes.Interface, namespace string, instanceId string, owns LabelFilter) error {
+	deploymentList, err := client.ExtensionsV1beta1().Deployments(namespace).List(metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 	for _, dep := range deploymentList.Items {
-		id, ok := dep.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
-		if ok && id != instanceId {
-			log.Printf("Cleaning up deployment %v", dep.ObjectMeta.Name)
-			err := client.ExtensionsV1beta1().Deployments(namespace).Delete(dep.ObjectMeta.Name, nil)
-			logErr("cleaning up deployment", err)
-			// ignore err
-		}
-		// Backward compatibility with older label name
-		pid, pok := dep.ObjectMeta.Labels[fission.POOLMGR_INSTANCEID_LABEL]
-		if pok && pid != instanceId {
+		if isOldObject(dep.ObjectMeta.Labels, instanceId) && owns(dep.ObjectMeta.Labels) {
 			log.Printf("Cleaning up deployment %v", dep.ObjectMeta.Name)
 			err := client.ExtensionsV1beta1().Deployments(namespace).Delete(dep.ObjectMeta.Name, nil)
 			logErr("cleaning up deployment", err)
@@ -214,21 +164,13 @@ func cleanupDeployments(client *kubernetes.Clientset, namespace string, instance
 	return nil
 }
 
-func cleanupReplicaSets(client *kubernetes.Clientset, namespace string, instanceId string) error {
-	rsList, err := client.ExtensionsV1beta1().ReplicaSets(namespace).List(meta_v1.ListOptions{})
+func cleanupReplicaSets(client kubernetes.Interface, namespace string, instanceId string, owns LabelFilter) error {
+	rsList, err := client.ExtensionsV1beta1().ReplicaSets(namespace).List(metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 	for _, rs := range rsList.Items {
-		id, ok := rs.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
-		if ok && id != instanceId {
-			log.Printf("Cleaning up replicaset %v", rs.ObjectMeta.Name)
-			err := client.ExtensionsV1beta1().ReplicaSets(namespace).Delete(rs.ObjectMeta.Name, nil)
-			logErr("cleaning up replicaset", err)
-		}
-		// Backward compatibility with older label name
-		pid, pok := rs.ObjectMeta.Labels[fission.POOLMGR_INSTANCEID_LABEL]
-		if pok && pid != instanceId {
+		if isOldObject(rs.ObjectMeta.Labels, instanceId) && owns(rs.ObjectMeta.Labels) {
 			log.Printf("Cleaning up replicaset %v", rs.ObjectMeta.Name)
 			err := client.ExtensionsV1beta1().ReplicaSets(namespace).Delete(rs.ObjectMeta.Name, nil)
 			logErr("cleaning up replicaset", err)
@@ -237,40 +179,30 @@ func cleanupReplicaSets(client *kubernetes.Clientset, namespace string, instance
 	return nil
 }
 
-func cleanupPods(client *kubernetes.Clientset, namespace string, instanceId string) error {
-	podList, err := client.CoreV1().Pods(namespace).List(meta_v1.ListOptions{})
+func cleanupPods(client kubernetes.Interface, namespace string, instanceId string, owns LabelFilter) error {
+	podList, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 	for _, pod := range podList.Items {
-		id, ok := pod.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
-		if ok && id != instanceId {
+		if isOldObject(pod.ObjectMeta.Labels, instanceId) && owns(pod.ObjectMeta.Labels) {
 			log.Printf("Cleaning up pod %v", pod.ObjectMeta.Name)
 			err := client.CoreV1().Pods(namespace).Delete(pod.ObjectMeta.Name, nil)
 			logErr("cleaning up pod", err)
 			// ignore err
 		}
-		// Backward compatibility with older label name
-		pid, pok := pod.ObjectMeta.Labels[fission.POOLMGR_INSTANCEID_LABEL]
-		if pok && pid != instanceId {
-			log.Printf("Cleaning up pod %v", pod.ObjectMeta.Name)
-			err := client.CoreV1().Pods(namespace).Delete(pod.ObjectMeta.Name, nil)
-			logErr("cleaning up pod", err)
-			// ignore err
-		}
-
 	}
 	return nil
 }
 
-func cleanupServices(client *kubernetes.Clientset, namespace string, instanceId string) error {
-	svcList, err := client.CoreV1().Services(namespace).List(meta_v1.ListOptions{})
+func cleanupServices(client kubernetes.Interface, namespace string, instanceId string, owns LabelFilter) error {
+	svcList, err := client.CoreV1().Services(namespace).List(metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 	for _, svc := range svcList.Items {
 		id, ok := svc.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
-		if ok && id != instanceId {
+		if ok && id != instanceId && owns(svc.ObjectMeta.Labels) {
 			log.Printf("Cleaning up svc %v", svc.ObjectMeta.Name)
 			err := client.CoreV1().Services(namespace).Delete(svc.ObjectMeta.Name, nil)
 			logErr("cleaning up service", err)
@@ -280,15 +212,15 @@ func cleanupServices(client *kubernetes.Clientset, namespace string, instanceId
 	return nil
 }
 
-func cleanupHpa(client *kubernetes.Clientset, namespace string, instanceId string) error {
-	hpaList, err := client.AutoscalingV1().HorizontalPodAutoscalers(namespace).List(meta_v1.ListOptions{})
+func cleanupHpa(client kubernetes.Interface, namespace string, instanceId string, owns LabelFilter) error {
+	hpaList, err := client.AutoscalingV1().HorizontalPodAutoscalers(namespace).List(metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
 	for _, hpa := range hpaList.Items {
 		id, ok := hpa.ObjectMeta.Labels[fission.EXECUTOR_INSTANCEID_LABEL]
-		if ok && id != instanceId {
+		if ok && id != instanceId && owns(hpa.ObjectMeta.Labels) {
 			log.Printf("Cleaning up HPA %v", hpa.ObjectMeta.Name)
 			err := client.AutoscalingV1().HorizontalPodAutoscalers(namespace).Delete(hpa.ObjectMeta.Name, nil)
 			logErr("cleaning up HPA", err)
@@ -0,0 +1,105 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// Reasons of the events recorded by the executor. Pool events are
+// recorded on environments, the others on functions.
+const (
+	EventReasonSpecialized      = "Specialized"
+	EventReasonSpecializeFailed = "SpecializeFailed"
+	EventReasonRelabelConflict  = "RelabelConflict"
+	EventReasonNoReadyPods      = "NoReadyPods"
+	EventReasonReaped           = "Reaped"
+	EventReasonDeployed         = "Deployed"
+	EventReasonScaledUp         = "ScaledUp"
+	EventReasonScaledDown       = "ScaledDown"
+	EventReasonHPACreated       = "HPACreated"
+	EventReasonHPADeleted       = "HPADeleted"
+	EventReasonQuotaExceeded    = "QuotaExceeded"
+)
+
+// maxEventMessageLength keeps long fetcher or runtime errors from
+// making events unreadable
+const maxEventMessageLength = 1024
+
+// RecordFunctionEvent records a Kubernetes Event about a function, so
+// that executor problems can be seen with `kubectl get events` or
+// `fission function events` without digging through executor logs.
+// The event is created in the background, so that it doesn't slow down
+// cold starts; errors are logged and ignored.
+func RecordFunctionEvent(kubernetesClient kubernetes.Interface, fn *metav1.ObjectMeta,
+	eventType string, reason string, message string) {
+	go recordEvent(kubernetesClient, "Function", fn, eventType, reason, message)
+}
+
+// RecordEnvironmentEvent records a Kubernetes Event about an
+// environment's pool, in the background like RecordFunctionEvent.
+func RecordEnvironmentEvent(kubernetesClient kubernetes.Interface, env *metav1.ObjectMeta,
+	eventType string, reason string, message string) {
+	go recordEvent(kubernetesClient, "Environment", env, eventType, reason, message)
+}
+
+func recordEvent(kubernetesClient kubernetes.Interface, kind string, obj *metav1.ObjectMeta,
+	eventType string, reason string, message string) {
+
+	ns := obj.Namespace
+	if len(ns) == 0 {
+		ns = metav1.NamespaceDefault
+	}
+	if len(message) > maxEventMessageLength {
+		message = message[:maxEventMessageLength]
+	}
+
+	now := metav1.Now()
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", obj.Name, now.UnixNano()),
+			Namespace: ns,
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:            kind,
+			APIVersion:      "fission.io/v1",
+			Name:            obj.Name,
+			Namespace:       ns,
+			UID:             obj.UID,
+			ResourceVersion: obj.ResourceVersion,
+		},
+		Reason:  reason,
+		Message: message,
+		Type:    eventType,
+		Source: v1.EventSource{
+			Component: "fission-executor",
+		},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	_, err := kubernetesClient.CoreV1().Events(ns).Create(event)
+	if err != nil {
+		log.Printf("Error recording %v event for %v %v: %v", reason, kind, obj.Name, err)
+	}
+}
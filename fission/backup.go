@@ -0,0 +1,84 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+)
+
+const defaultBackupFile = "fission-backup.tar.gz"
+
+// backupCreate saves all Fission objects of the cluster, and the
+// archives they use, to a file.
+func backupCreate(c *cli.Context) error {
+	client := getClient(c.GlobalString("server"))
+
+	filename := c.String("file")
+	if len(filename) == 0 {
+		filename = defaultBackupFile
+	}
+
+	// write to a temporary file first, so that a failed backup doesn't
+	// replace a good one
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".fission-backup-")
+	checkErr(err, "create backup file")
+	defer os.Remove(tmp.Name())
+
+	err = client.BackupCreate(tmp)
+	tmp.Close()
+	checkErr(err, "back up fission state")
+
+	err = os.Rename(tmp.Name(), filename)
+	checkErr(err, fmt.Sprintf("write backup file %v", filename))
+
+	fmt.Printf("Saved backup to %v\n", filename)
+	return nil
+}
+
+// backupRestore creates the objects in a backup that don't exist on the
+// cluster yet, along with the archives they use.
+func backupRestore(c *cli.Context) error {
+	client := getClient(c.GlobalString("server"))
+
+	filename := c.String("file")
+	if len(filename) == 0 {
+		filename = defaultBackupFile
+	}
+
+	f, err := os.Open(filename)
+	checkErr(err, fmt.Sprintf("open backup file %v", filename))
+	defer f.Close()
+
+	result, err := client.BackupRestore(f)
+	checkErr(err, "restore backup")
+
+	applyStatus := make(map[string]resourceApplyStatus)
+	for typ, as := range result.Status {
+		var ras resourceApplyStatus
+		for i := range as.Created {
+			ras.created = append(ras.created, &as.Created[i])
+		}
+		applyStatus[typ] = ras
+	}
+	printApplyStatus(applyStatus)
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/controller/client"
@@ -57,63 +58,93 @@ func (w *packageBuildWatcher) addPackages(pkgMeta map[string]metav1.ObjectMeta)
 }
 
 func (w *packageBuildWatcher) watch(ctx context.Context) {
-	for {
-		// non-blocking check if we're cancelled
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
+	// packages in the app spec whose builds are pending or running
+	building := make(map[string]bool)
 
-		// poll list of packages (TODO: convert to watch)
+	for {
+		// list packages to find out where their builds are, then watch
+		// them for changes. The controller ends watches now and then,
+		// so both are done again until the builds are done.
 		pkgs, err := w.fclient.PackageList()
 		checkErr(err, "Getting list of packages")
-
-		// find packages that (a) are in the app spec and (b) have an interesting
-		// build status (either succeeded or failed; not "none")
-		keepWaiting := false
-		buildpkgs := make([]crd.Package, 0)
-		for _, pkg := range pkgs {
-			_, ok := w.pkgMeta[mapKey(&pkg.Metadata)]
-			if !ok {
-				continue
-			}
-			if pkg.Status.BuildStatus == fission.BuildStatusNone {
-				continue
-			}
-			if pkg.Status.BuildStatus == fission.BuildStatusPending ||
-				pkg.Status.BuildStatus == fission.BuildStatusRunning {
-				keepWaiting = true
-			}
-			buildpkgs = append(buildpkgs, pkg)
+		for i := range pkgs {
+			w.update(&pkgs[i], building)
+		}
+		if len(building) == 0 {
+			return
 		}
 
-		// print package status, and error logs if any
-		for _, pkg := range buildpkgs {
-			k := pkgKey(&pkg)
-			if _, printed := w.finished[k]; printed {
-				continue
-			}
-			if pkg.Status.BuildStatus == fission.BuildStatusFailed {
-				w.finished[k] = true
-				fmt.Printf("--- Build FAILED: ---\n%v\n------\n", pkg.Status.BuildLog)
-			} else if pkg.Status.BuildStatus == fission.BuildStatusSucceeded {
-				w.finished[k] = true
-				fmt.Printf("--- Build SUCCEEDED ---\n")
-				if len(pkg.Status.BuildLog) > 0 {
-					fmt.Printf("%v\n------\n", pkg.Status.BuildLog)
+		// controllers that can't watch make this poll instead
+		pw, err := w.fclient.PackageWatch(nil)
+		if err == nil {
+			stopped := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					pw.Stop()
+				case <-stopped:
+				}
+			}()
+			for len(building) > 0 {
+				eventType, pkg, err := pw.Next()
+				if err != nil {
+					break
 				}
+				if eventType == watch.Deleted {
+					delete(building, mapKey(&pkg.Metadata))
+					continue
+				}
+				w.update(pkg, building)
 			}
+			close(stopped)
+			pw.Stop()
 		}
 
-		// if there are no builds running, we can stop polling
-		if !keepWaiting {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		if len(building) == 0 {
 			return
 		}
 		time.Sleep(time.Second)
 	}
 }
 
+// update prints the build status of pkg once it's done, if pkg is in
+// the app spec, and keeps track of whether it's still building.
+func (w *packageBuildWatcher) update(pkg *crd.Package, building map[string]bool) {
+	mk := mapKey(&pkg.Metadata)
+	if _, ok := w.pkgMeta[mk]; !ok {
+		return
+	}
+
+	switch pkg.Status.BuildStatus {
+	case fission.BuildStatusPending, fission.BuildStatusRunning:
+		building[mk] = true
+		return
+	default:
+		delete(building, mk)
+	}
+
+	// print package status, and error logs if any
+	k := pkgKey(pkg)
+	if _, printed := w.finished[k]; printed {
+		return
+	}
+	if pkg.Status.BuildStatus == fission.BuildStatusFailed {
+		w.finished[k] = true
+		fmt.Printf("--- Build FAILED: ---\n%v\n------\n", pkg.Status.BuildLog)
+	} else if pkg.Status.BuildStatus == fission.BuildStatusSucceeded {
+		w.finished[k] = true
+		fmt.Printf("--- Build SUCCEEDED ---\n")
+		if len(pkg.Status.BuildLog) > 0 {
+			fmt.Printf("%v\n------\n", pkg.Status.BuildLog)
+		}
+	}
+}
+
 func pkgKey(pkg *crd.Package) string {
 	// packages are mutable so we want to keep track of them by resource version
 	return fmt.Sprintf("%v:%v:%v", pkg.Metadata.Name, pkg.Metadata.Namespace, pkg.Metadata.ResourceVersion)
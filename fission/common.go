@@ -32,7 +32,9 @@ import (
 
 	"github.com/dchest/uniuri"
 	uuid "github.com/satori/go.uuid"
+	"github.com/urfave/cli"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/fission/fission"
 	"github.com/fission/fission/controller/client"
@@ -62,7 +64,32 @@ func getClient(serverUrl string) *client.Client {
 		serverUrl = "http://" + serverUrl
 	}
 
-	return client.MakeClient(serverUrl)
+	c := client.MakeClient(serverUrl)
+	c.Token = getToken()
+	return c
+}
+
+// controllerHTTPClient returns an HTTP client for requests to the
+// controller that don't go through the controller client, such as
+// archives and logs through its proxies. They need the token too.
+func controllerHTTPClient(client *client.Client) *http.Client {
+	return &http.Client{Transport: client.Transport(http.DefaultTransport)}
+}
+
+// getToken returns the token to authenticate to the controller with:
+// $FISSION_TOKEN, or else the bearer token of the current kubeconfig
+// context, if there is one.
+func getToken() string {
+	token := os.Getenv("FISSION_TOKEN")
+	if len(token) > 0 {
+		return token
+	}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return ""
+	}
+	return config.BearerToken
 }
 
 func checkErr(err error, msg string) {
@@ -71,6 +98,27 @@ func checkErr(err error, msg string) {
 	}
 }
 
+// getListOptions returns the filters and page of a list command.
+func getListOptions(c *cli.Context) *client.ListOptions {
+	if c.Int("limit") < 0 {
+		fatal("--limit must not be negative")
+	}
+	return &client.ListOptions{
+		Namespace:     c.String("namespace"),
+		LabelSelector: c.String("label"),
+		Limit:         c.Int("limit"),
+		Continue:      c.String("continue"),
+	}
+}
+
+// printContinue tells the user how to get the rest of a list that was
+// cut short by --limit.
+func printContinue(next string) {
+	if len(next) > 0 {
+		warn(fmt.Sprintf("More objects are available, use --continue %v to list them", next))
+	}
+}
+
 func httpRequest(method, url, body string, headers []string) *http.Response {
 	if method == "" {
 		method = "GET"
@@ -173,6 +221,7 @@ func createArchive(client *client.Client, fileName string, specFile string) *fis
 
 		u := strings.TrimSuffix(client.Url, "/") + "/proxy/storage"
 		ssClient := storageSvcClient.MakeClient(u)
+		ssClient.Transport = client.Transport(http.DefaultTransport)
 
 		// TODO add a progress bar
 		id, err := ssClient.Upload(fileName, nil)
@@ -194,7 +243,9 @@ func createArchive(client *client.Client, fileName string, specFile string) *fis
 	return &archive
 }
 
-func createPackage(client *client.Client, envName, srcArchiveName, deployArchiveName, buildcmd string, specFile string) *metav1.ObjectMeta {
+// createPackage creates a package from archives. Packages created for a
+// function (fnName isn't empty) are deleted along with it.
+func createPackage(client *client.Client, fnName, envName, srcArchiveName, deployArchiveName, buildcmd string, specFile string) *metav1.ObjectMeta {
 	pkgSpec := fission.PackageSpec{
 		Environment: fission.EnvironmentReference{
 			Namespace: metav1.NamespaceDefault,
@@ -235,6 +286,11 @@ func createPackage(client *client.Client, envName, srcArchiveName, deployArchive
 			BuildStatus: pkgStatus,
 		},
 	}
+	if len(fnName) > 0 {
+		pkg.Metadata.Annotations = map[string]string{
+			fission.PACKAGE_FOR_FUNCTION_ANNOTATION: fnName,
+		}
+	}
 
 	if len(specFile) > 0 {
 		err := specSave(*pkg, specFile)
@@ -294,7 +350,7 @@ func writeArchiveToFile(fileName string, reader io.Reader) error {
 // downloadToTempFile fetches archive file from arbitrary url
 // and write it to temp file for further usage
 func downloadToTempFile(fileUrl string) string {
-	reader, err := downloadURL(fileUrl)
+	reader, err := downloadURL(http.DefaultClient, fileUrl)
 	defer reader.Close()
 	checkErr(err, fmt.Sprintf("download from url: %v", fileUrl))
 
@@ -313,8 +369,8 @@ func downloadToTempFile(fileUrl string) string {
 }
 
 // downloadURL downloads file from given url
-func downloadURL(fileUrl string) (io.ReadCloser, error) {
-	resp, err := http.Get(fileUrl)
+func downloadURL(httpClient *http.Client, fileUrl string) (io.ReadCloser, error) {
+	resp, err := httpClient.Get(fileUrl)
 	if err != nil {
 		return nil, err
 	}
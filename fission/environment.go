@@ -28,6 +28,7 @@ import (
 	"k8s.io/client-go/pkg/api/v1"
 
 	"github.com/fission/fission"
+	"github.com/fission/fission/controller/client"
 	"github.com/fission/fission/crd"
 )
 
@@ -168,7 +169,7 @@ func envUpdate(c *cli.Context) error {
 }
 
 func envDelete(c *cli.Context) error {
-	client := getClient(c.GlobalString("server"))
+	fclient := getClient(c.GlobalString("server"))
 
 	envName := c.String("name")
 	if len(envName) == 0 {
@@ -179,7 +180,7 @@ func envDelete(c *cli.Context) error {
 		Name:      envName,
 		Namespace: metav1.NamespaceDefault,
 	}
-	err := client.EnvironmentDelete(m)
+	err := fclient.EnvironmentDeleteWithOptions(m, &client.DeleteOptions{Force: c.Bool("force")})
 	checkErr(err, "delete environment")
 
 	fmt.Printf("environment '%v' deleted\n", envName)
@@ -189,7 +190,7 @@ func envDelete(c *cli.Context) error {
 func envList(c *cli.Context) error {
 	client := getClient(c.GlobalString("server"))
 
-	envs, err := client.EnvironmentList()
+	envs, next, err := client.EnvironmentListWithOptions(getListOptions(c))
 	checkErr(err, "list environments")
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -201,6 +202,7 @@ func envList(c *cli.Context) error {
 			env.Spec.Resources.Requests.Memory(), env.Spec.Resources.Limits.Memory())
 	}
 	w.Flush()
+	printContinue(next)
 
 	return nil
 }
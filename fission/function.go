@@ -25,6 +25,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -33,6 +34,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fission/fission"
+	"github.com/fission/fission/controller/client"
 	"github.com/fission/fission/crd"
 	"github.com/fission/fission/fission/logdb"
 )
@@ -43,15 +45,16 @@ func printPodLogs(c *cli.Context) error {
 		fatal("Need --name argument.")
 	}
 
-	queryURL, err := url.Parse(c.GlobalString("server"))
+	client := getClient(c.GlobalString("server"))
+
+	queryURL, err := url.Parse(client.Url)
 	checkErr(err, "parse the base URL")
 	queryURL.Path = fmt.Sprintf("/proxy/logs/%s", fnName)
 
 	req, err := http.NewRequest("POST", queryURL.String(), nil)
 	checkErr(err, "create logs request")
 
-	httpClient := http.Client{}
-	resp, err := httpClient.Do(req)
+	resp, err := controllerHTTPClient(client).Do(req)
 	checkErr(err, "execute get logs request")
 
 	defer resp.Body.Close()
@@ -83,8 +86,12 @@ func getInvokeStrategy(minScale int, maxScale int, executorType string, targetcp
 		fnExecutor = fission.ExecutorTypePoolmgr
 	case fission.ExecutorTypeNewdeploy:
 		fnExecutor = fission.ExecutorTypeNewdeploy
+	case fission.ExecutorTypeContainer:
+		fnExecutor = fission.ExecutorTypeContainer
+	case fission.ExecutorTypeJob:
+		fnExecutor = fission.ExecutorTypeJob
 	default:
-		fatal("Executor type must be one of 'poolmgr' or 'newdeploy', defaults to 'poolmgr'")
+		fatal("Executor type must be one of 'poolmgr', 'newdeploy', 'container' or 'job', defaults to 'poolmgr'")
 	}
 
 	// Right now a simple single case strategy implementation
@@ -149,7 +156,19 @@ func fnCreate(c *cli.Context) error {
 		cfgMapNameSpace = metav1.NamespaceDefault
 	}
 
-	if len(pkgName) > 0 {
+	var container *fission.FunctionContainer
+	if c.String("executortype") == fission.ExecutorTypeContainer {
+		// container functions run an image as is, without an
+		// environment or a package
+		image := c.String("image")
+		if len(image) == 0 {
+			fatal("Need --image argument for executor type 'container'.")
+		}
+		container = &fission.FunctionContainer{
+			Image: image,
+			Port:  int32(c.Int("port")),
+		}
+	} else if len(pkgName) > 0 {
 		// use existing package
 		pkg, err := client.PackageGet(&metav1.ObjectMeta{
 			Namespace: metav1.NamespaceDefault,
@@ -191,7 +210,7 @@ func fnCreate(c *cli.Context) error {
 		buildcmd := c.String("buildcmd")
 
 		// create new package
-		pkgMetadata = createPackage(client, envName, srcArchiveName, deployArchiveName, buildcmd, specFile)
+		pkgMetadata = createPackage(client, fnName, envName, srcArchiveName, deployArchiveName, buildcmd, specFile)
 	}
 
 	//TODO Warn user about resources at fn level overriding the env resources
@@ -209,6 +228,38 @@ func fnCreate(c *cli.Context) error {
 
 	invokeStrategy := getInvokeStrategy(c.Int("minscale"), c.Int("maxscale"), c.String("executortype"), targetCPU)
 
+	// Request based autoscaling is done by the newdeploy executor
+	targetRPS := c.Int("targetrps")
+	targetInFlight := c.Int("targetinflight")
+	if targetRPS < 0 || targetInFlight < 0 {
+		fatal("Target requests per second and target in-flight requests must not be negative")
+	}
+	if (targetRPS > 0 || targetInFlight > 0) &&
+		invokeStrategy.ExecutionStrategy.ExecutorType != fission.ExecutorTypeNewdeploy &&
+		invokeStrategy.ExecutionStrategy.ExecutorType != fission.ExecutorTypeContainer {
+		fatal("--targetrps and --targetinflight need executor type 'newdeploy' or 'container'")
+	}
+	invokeStrategy.ExecutionStrategy.TargetRequestsPerSecond = targetRPS
+	invokeStrategy.ExecutionStrategy.TargetInFlightRequests = targetInFlight
+
+	if c.Bool("prewarm") {
+		if invokeStrategy.ExecutionStrategy.ExecutorType != fission.ExecutorTypePoolmgr {
+			fatal("--prewarm needs executor type 'poolmgr'")
+		}
+		invokeStrategy.ExecutionStrategy.Prewarm = true
+	}
+
+	maxConcurrency := c.Int("maxconcurrency")
+	if maxConcurrency < 0 {
+		fatal("Max concurrency must not be negative")
+	}
+	invokeStrategy.ExecutionStrategy.MaxConcurrency = maxConcurrency
+
+	timeout := c.Int("timeout")
+	if timeout < 0 {
+		fatal("Timeout must not be negative")
+	}
+
 	function := &crd.Function{
 		Metadata: metav1.ObjectMeta{
 			Name:      fnName,
@@ -219,21 +270,26 @@ func fnCreate(c *cli.Context) error {
 				Name:      envName,
 				Namespace: metav1.NamespaceDefault,
 			},
-			Package: fission.FunctionPackageRef{
-				FunctionName: entrypoint,
-				PackageRef: fission.PackageRef{
-					Namespace:       pkgMetadata.Namespace,
-					Name:            pkgMetadata.Name,
-					ResourceVersion: pkgMetadata.ResourceVersion,
-				},
-			},
-			Secrets:        []fission.SecretReference{},
-			ConfigMaps:     []fission.ConfigMapReference{},
-			Resources:      resourceReq,
-			InvokeStrategy: invokeStrategy,
+			Secrets:         []fission.SecretReference{},
+			ConfigMaps:      []fission.ConfigMapReference{},
+			Resources:       resourceReq,
+			InvokeStrategy:  invokeStrategy,
+			Container:       container,
+			FunctionTimeout: timeout,
 		},
 	}
 
+	if pkgMetadata != nil {
+		function.Spec.Package = fission.FunctionPackageRef{
+			FunctionName: entrypoint,
+			PackageRef: fission.PackageRef{
+				Namespace:       pkgMetadata.Namespace,
+				Name:            pkgMetadata.Name,
+				ResourceVersion: pkgMetadata.ResourceVersion,
+			},
+		}
+	}
+
 	if len(secretName) > 0 {
 		newSecret := fission.SecretReference{
 			Name:      secretName,
@@ -308,6 +364,10 @@ func fnGet(c *cli.Context) error {
 	fn, err := client.FunctionGet(m)
 	checkErr(err, "get function")
 
+	if fn.Spec.Container != nil {
+		fatal(fmt.Sprintf("Function '%v' runs image %v and has no source code", fnName, fn.Spec.Container.Image))
+	}
+
 	pkg, err := client.PackageGet(&metav1.ObjectMeta{
 		Name:      fn.Spec.Package.PackageRef.Name,
 		Namespace: fn.Spec.Package.PackageRef.Namespace,
@@ -376,8 +436,42 @@ func fnUpdate(c *cli.Context) error {
 	force := c.Bool("force")
 
 	if len(envName) == 0 && len(deployArchiveName) == 0 && len(srcArchiveName) == 0 && len(pkgName) == 0 &&
-		len(entrypoint) == 0 && len(buildcmd) == 0 {
-		fatal("Need --env or --deploy or --src or --pkg or --entrypoint or --buildcmd argument.")
+		len(entrypoint) == 0 && len(buildcmd) == 0 && !c.IsSet("maxconcurrency") && !c.IsSet("timeout") &&
+		!c.IsSet("targetrps") && !c.IsSet("targetinflight") {
+		fatal("Need --env or --deploy or --src or --pkg or --entrypoint or --buildcmd or --maxconcurrency or --timeout or --targetrps or --targetinflight argument.")
+	}
+
+	// Setting either target switches the function to request based
+	// autoscaling; setting both to 0 switches it back to CPU based
+	// autoscaling. The executor replaces the HPA accordingly.
+	strategy := &function.Spec.InvokeStrategy.ExecutionStrategy
+	if c.IsSet("targetrps") {
+		strategy.TargetRequestsPerSecond = c.Int("targetrps")
+	}
+	if c.IsSet("targetinflight") {
+		strategy.TargetInFlightRequests = c.Int("targetinflight")
+	}
+	if strategy.TargetRequestsPerSecond < 0 || strategy.TargetInFlightRequests < 0 {
+		fatal("Target requests per second and target in-flight requests must not be negative")
+	}
+	if (strategy.TargetRequestsPerSecond > 0 || strategy.TargetInFlightRequests > 0) &&
+		strategy.ExecutorType != fission.ExecutorTypeNewdeploy &&
+		strategy.ExecutorType != fission.ExecutorTypeContainer {
+		fatal("--targetrps and --targetinflight need executor type 'newdeploy' or 'container'")
+	}
+
+	if c.IsSet("timeout") {
+		if c.Int("timeout") < 0 {
+			fatal("Timeout must not be negative")
+		}
+		function.Spec.FunctionTimeout = c.Int("timeout")
+	}
+
+	if c.IsSet("maxconcurrency") {
+		if c.Int("maxconcurrency") < 0 {
+			fatal("Max concurrency must not be negative")
+		}
+		function.Spec.InvokeStrategy.ExecutionStrategy.MaxConcurrency = c.Int("maxconcurrency")
 	}
 
 	if len(envName) > 0 {
@@ -438,7 +532,7 @@ func fnUpdate(c *cli.Context) error {
 }
 
 func fnDelete(c *cli.Context) error {
-	client := getClient(c.GlobalString("server"))
+	fclient := getClient(c.GlobalString("server"))
 
 	fnName := c.String("name")
 	if len(fnName) == 0 {
@@ -450,7 +544,7 @@ func fnDelete(c *cli.Context) error {
 		Namespace: metav1.NamespaceDefault,
 	}
 
-	err := client.FunctionDelete(m)
+	err := fclient.FunctionDeleteWithOptions(m, &client.DeleteOptions{Cascade: c.Bool("cascade")})
 	checkErr(err, fmt.Sprintf("delete function '%v'", fnName))
 
 	fmt.Printf("function '%v' deleted\n", fnName)
@@ -460,7 +554,7 @@ func fnDelete(c *cli.Context) error {
 func fnList(c *cli.Context) error {
 	client := getClient(c.GlobalString("server"))
 
-	fns, err := client.FunctionList()
+	fns, next, err := client.FunctionListWithOptions(getListOptions(c))
 	checkErr(err, "list functions")
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -475,10 +569,134 @@ func fnList(c *cli.Context) error {
 			f.Spec.InvokeStrategy.ExecutionStrategy.TargetCPUPercent)
 	}
 	w.Flush()
+	printContinue(next)
 
 	return err
 }
 
+func fnStatus(c *cli.Context) error {
+	client := getClient(c.GlobalString("server"))
+
+	fnName := c.String("name")
+	if len(fnName) == 0 {
+		fatal("Need name of function, use --name")
+	}
+
+	m := &metav1.ObjectMeta{
+		Name:      fnName,
+		Namespace: metav1.NamespaceDefault,
+	}
+	status, err := client.FunctionStatus(m)
+	checkErr(err, "get function status")
+
+	if !status.Warm {
+		fmt.Printf("Function %v (executor type %v) is cold, it has no running instances\n", fnName, status.ExecutorType)
+		return nil
+	}
+	fmt.Printf("Function %v (executor type %v) is warm\n\n", fnName, status.ExecutorType)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", "ADDRESS", "EXECUTOR", "OBJECTS", "CREATED", "LASTUSED", "REAPAT")
+	for _, fsvc := range status.Services {
+		objs := make([]string, 0, len(fsvc.KubernetesObjects))
+		for _, obj := range fsvc.KubernetesObjects {
+			objs = append(objs, fmt.Sprintf("%v/%v", strings.ToLower(obj.Kind), obj.Name))
+		}
+		reapAt := "never"
+		if fsvc.ReapTime != nil {
+			reapAt = fsvc.ReapTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			fsvc.Address, fsvc.Executor, strings.Join(objs, ","),
+			fsvc.Ctime.Format(time.RFC3339), fsvc.Atime.Format(time.RFC3339), reapAt)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func fnEvents(c *cli.Context) error {
+	client := getClient(c.GlobalString("server"))
+
+	fnName := c.String("name")
+	if len(fnName) == 0 {
+		fatal("Need name of function, use --name")
+	}
+
+	m := &metav1.ObjectMeta{
+		Name:      fnName,
+		Namespace: metav1.NamespaceDefault,
+	}
+	events, err := client.FunctionEvents(m)
+	checkErr(err, "get function events")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", "LASTSEEN", "COUNT", "TYPE", "REASON", "MESSAGE")
+	for _, e := range events {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n",
+			e.LastTimestamp.Format(time.RFC3339), e.Count, e.Type, e.Reason, e.Message)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func fnHistory(c *cli.Context) error {
+	client := getClient(c.GlobalString("server"))
+
+	fnName := c.String("name")
+	if len(fnName) == 0 {
+		fatal("Need name of function, use --name")
+	}
+
+	revs, err := client.FunctionRevisionList(&metav1.ObjectMeta{
+		Name:      fnName,
+		Namespace: metav1.NamespaceDefault,
+	})
+	checkErr(err, "get function history")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", "REVISION", "CREATED", "ENV", "PACKAGE", "BUILD", "CHANGE")
+	for i, rev := range revs {
+		change := ""
+		if rev.Spec.RollbackOf > 0 {
+			change = fmt.Sprintf("rollback to %v", rev.Spec.RollbackOf)
+		}
+		if i == len(revs)-1 {
+			change = strings.TrimSpace(change + " (current)")
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			rev.Spec.Revision, rev.Metadata.CreationTimestamp.Format(time.RFC3339),
+			rev.Spec.Function.Environment.Name, rev.Spec.Function.Package.PackageRef.Name,
+			rev.Spec.PackageStatus.BuildStatus, change)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func fnRollback(c *cli.Context) error {
+	client := getClient(c.GlobalString("server"))
+
+	fnName := c.String("name")
+	if len(fnName) == 0 {
+		fatal("Need name of function, use --name")
+	}
+	revision := c.Int("to")
+	if revision < 1 {
+		fatal("Need the revision to roll back to, use --to")
+	}
+
+	_, err := client.FunctionRollback(&metav1.ObjectMeta{
+		Name:      fnName,
+		Namespace: metav1.NamespaceDefault,
+	}, revision)
+	checkErr(err, fmt.Sprintf("roll back function %v", fnName))
+
+	fmt.Printf("function '%v' rolled back to revision %v\n", fnName, revision)
+	return nil
+}
+
 func fnLogs(c *cli.Context) error {
 
 	client := getClient(c.GlobalString("server"))
@@ -508,7 +726,7 @@ func fnLogs(c *cli.Context) error {
 	checkErr(err, "get function")
 
 	// request the controller to establish a proxy server to the database.
-	logDB, err := logdb.GetLogDB(dbType, c.GlobalString("server"))
+	logDB, err := logdb.GetLogDB(dbType, client.Url, client.Transport(http.DefaultTransport))
 	if err != nil {
 		fatal("failed to connect log database")
 	}
@@ -580,7 +798,7 @@ func fnPods(c *cli.Context) error {
 
 	// client first sends db query to the controller, then the controller
 	// will establish a proxy server that bridges the client and the database.
-	logDB, err := logdb.GetLogDB(dbType, c.GlobalString("server"))
+	logDB, err := logdb.GetLogDB(dbType, client.Url, client.Transport(http.DefaultTransport))
 	if err != nil {
 		fatal("failed to connect log database")
 	}
@@ -152,7 +152,7 @@ func htDelete(c *cli.Context) error {
 func htList(c *cli.Context) error {
 	client := getClient(c.GlobalString("server"))
 
-	hts, err := client.HTTPTriggerList()
+	hts, next, err := client.HTTPTriggerListWithOptions(getListOptions(c))
 	checkErr(err, "list HTTP triggers")
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -163,6 +163,7 @@ func htList(c *cli.Context) error {
 			ht.Metadata.Name, ht.Spec.Method, ht.Spec.Host, ht.Spec.RelativeURL, ht.Spec.FunctionReference.Name)
 	}
 	w.Flush()
+	printContinue(next)
 
 	return nil
 }
@@ -37,12 +37,13 @@ const (
 	INFLUXDB_URL      = "http://influxdb:8086/query"
 )
 
-func NewInfluxDB(serverURL string) (InfluxDB, error) {
-	return InfluxDB{endpoint: serverURL}, nil
+func NewInfluxDB(serverURL string, transport http.RoundTripper) (InfluxDB, error) {
+	return InfluxDB{endpoint: serverURL, transport: transport}, nil
 }
 
 type InfluxDB struct {
-	endpoint string
+	endpoint  string
+	transport http.RoundTripper
 }
 
 func (influx InfluxDB) GetPods(filter LogFilter) ([]string, error) {
@@ -177,7 +178,7 @@ func (influx InfluxDB) query(query influxdbClient.Query) (*influxdbClient.Respon
 	params.Set("params", string(parametersBytes))
 	req.URL.RawQuery = params.Encode()
 
-	httpClient := http.Client{Timeout: 5 * time.Second}
+	httpClient := http.Client{Timeout: 5 * time.Second, Transport: influx.transport}
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
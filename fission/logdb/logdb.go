@@ -17,6 +17,7 @@ limitations under the License.
 package logdb
 
 import (
+	"net/http"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -51,10 +52,13 @@ type LogEntry struct {
 	Pod       string
 }
 
-func GetLogDB(dbType string, serverURL string) (LogDatabase, error) {
+// GetLogDB returns a client of a log database behind the controller at
+// serverURL. Transport is used for requests to the controller; it may
+// be nil.
+func GetLogDB(dbType string, serverURL string, transport http.RoundTripper) (LogDatabase, error) {
 	switch dbType {
 	case INFLUXDB:
-		return NewInfluxDB(serverURL)
+		return NewInfluxDB(serverURL, transport)
 	}
 	log.Fatalf("Log database type is incorrect, now only support %s", INFLUXDB)
 	return nil, nil
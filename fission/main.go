@@ -60,6 +60,18 @@ func main() {
 	minScale := cli.StringFlag{Name: "minscale", Usage: "Minmum number of pods (Uses resource inputs to configure HPA)"}
 	maxScale := cli.StringFlag{Name: "maxscale", Usage: "Maximum number of pods (Uses resource inputs to configure HPA)"}
 	targetcpu := cli.StringFlag{Name: "targetcpu", Usage: "Target average CPU across pods for scaling (In percentage, defaults to 80)"}
+	targetrps := cli.IntFlag{Name: "targetrps", Usage: "Target requests per second per pod for scaling (newdeploy only, replaces CPU based scaling)"}
+	targetinflight := cli.IntFlag{Name: "targetinflight", Usage: "Target concurrent requests per pod for scaling (newdeploy only, replaces CPU based scaling)"}
+	maxconcurrency := cli.IntFlag{Name: "maxconcurrency", Usage: "Maximum number of requests the function serves at once, across all routers (0 means no limit)"}
+	fnTimeoutFlag := cli.IntFlag{Name: "timeout", Usage: "Seconds an invocation may run before it's stopped (job only, defaults to an hour)"}
+	prewarm := cli.BoolFlag{Name: "prewarm", Usage: "Specialize a pod for the function as soon as it's created or its package is built (poolmgr only)"}
+
+	// Filters and pagination (used in list CLIs)
+	listNamespaceFlag := cli.StringFlag{Name: "namespace", Usage: "Only list objects in this namespace (defaults to all namespaces)"}
+	listLabelFlag := cli.StringFlag{Name: "label", Usage: "Only list objects with these labels, as a selector of the form a=b,c!=d"}
+	listLimitFlag := cli.IntFlag{Name: "limit", Usage: "List at most this many objects"}
+	listContinueFlag := cli.StringFlag{Name: "continue", Usage: "Continue a list cut short by --limit"}
+	listFlags := []cli.Flag{listNamespaceFlag, listLabelFlag, listLimitFlag, listContinueFlag}
 
 	// functions
 	fnNameFlag := cli.StringFlag{Name: "name", Usage: "function name"}
@@ -83,17 +95,26 @@ func main() {
 	fnCfgMapnsFlag := cli.StringFlag{Name: "configmapNamespace", Usage: "namespace of configmap"}
 	fnLogCountFlag := cli.StringFlag{Name: "recordcount", Usage: "the n most recent log records"}
 	fnForceFlag := cli.BoolFlag{Name: "force", Usage: "Force update a package even if it is used by one or more functions"}
-	fnExecutorTypeFlag := cli.StringFlag{Name: "executortype", Usage: "Executor type for execution; one of 'poolmgr', 'newdeploy' defaults to 'poolmgr'"}
+	fnCascadeFlag := cli.BoolFlag{Name: "cascade", Usage: "Also delete the triggers of the function, and the package created for it"}
+	fnExecutorTypeFlag := cli.StringFlag{Name: "executortype", Usage: "Executor type for execution; one of 'poolmgr', 'newdeploy', 'container', 'job' defaults to 'poolmgr'"}
+	fnImageFlag := cli.StringFlag{Name: "image", Usage: "Container image to run as the function (executor type 'container' only)"}
+	fnPortFlag := cli.IntFlag{Name: "port", Usage: "Port the container image listens on (executor type 'container' only, defaults to 8888)"}
 	fnSpecSaveFlag := cli.BoolFlag{Name: "spec", Usage: "Save function to the spec directory instead of creating it"}
 
+	fnRollbackToFlag := cli.IntFlag{Name: "to", Usage: "Revision to roll the function back to, see 'fission fn history'"}
+
 	fnSubcommands := []cli.Command{
-		{Name: "create", Usage: "Create new function (and optionally, an HTTP route to it)", Flags: []cli.Flag{fnNameFlag, fnEnvNameFlag, fnSpecSaveFlag, fnCodeFlag, fnPackageFlag, fnSrcArchiveFlag, fnDeployArchiveFlag, fnEntryPointFlag, fnBuildCmdFlag, fnPkgNameFlag, htUrlFlag, htMethodFlag, minCpu, maxCpu, minMem, maxMem, minScale, maxScale, fnExecutorTypeFlag, targetcpu, fnCfgMapFlag, fnSecretFlag, fnSecretnsFlag, fnCfgMapnsFlag}, Action: fnCreate},
+		{Name: "create", Usage: "Create new function (and optionally, an HTTP route to it)", Flags: []cli.Flag{fnNameFlag, fnEnvNameFlag, fnSpecSaveFlag, fnCodeFlag, fnPackageFlag, fnSrcArchiveFlag, fnDeployArchiveFlag, fnEntryPointFlag, fnBuildCmdFlag, fnPkgNameFlag, htUrlFlag, htMethodFlag, minCpu, maxCpu, minMem, maxMem, minScale, maxScale, fnExecutorTypeFlag, fnImageFlag, fnPortFlag, targetcpu, targetrps, targetinflight, prewarm, maxconcurrency, fnTimeoutFlag, fnCfgMapFlag, fnSecretFlag, fnSecretnsFlag, fnCfgMapnsFlag}, Action: fnCreate},
 		{Name: "get", Usage: "Get function source code", Flags: []cli.Flag{fnNameFlag}, Action: fnGet},
 		{Name: "getmeta", Usage: "Get function metadata", Flags: []cli.Flag{fnNameFlag}, Action: fnGetMeta},
-		{Name: "update", Usage: "Update function source code", Flags: []cli.Flag{fnNameFlag, fnEnvNameFlag, fnCodeFlag, fnPackageFlag, fnSrcArchiveFlag, fnDeployArchiveFlag, fnEntryPointFlag, fnPkgNameFlag, fnBuildCmdFlag, fnForceFlag, minCpu, maxCpu, minMem, maxMem, minScale, maxScale, fnExecutorTypeFlag, targetcpu}, Action: fnUpdate},
-		{Name: "delete", Usage: "Delete function", Flags: []cli.Flag{fnNameFlag}, Action: fnDelete},
-		{Name: "list", Usage: "List all functions", Flags: []cli.Flag{}, Action: fnList},
+		{Name: "update", Usage: "Update function source code", Flags: []cli.Flag{fnNameFlag, fnEnvNameFlag, fnCodeFlag, fnPackageFlag, fnSrcArchiveFlag, fnDeployArchiveFlag, fnEntryPointFlag, fnPkgNameFlag, fnBuildCmdFlag, fnForceFlag, minCpu, maxCpu, minMem, maxMem, minScale, maxScale, fnExecutorTypeFlag, targetcpu, targetrps, targetinflight, maxconcurrency, fnTimeoutFlag}, Action: fnUpdate},
+		{Name: "delete", Usage: "Delete function", Flags: []cli.Flag{fnNameFlag, fnCascadeFlag}, Action: fnDelete},
+		{Name: "list", Usage: "List all functions", Flags: listFlags, Action: fnList},
 		{Name: "logs", Usage: "Display function logs", Flags: []cli.Flag{fnNameFlag, fnPodFlag, fnFollowFlag, fnDetailFlag, fnLogDBTypeFlag, fnLogCountFlag}, Action: fnLogs},
+		{Name: "status", Usage: "Show whether a function is warm, where it runs and when it will be reaped", Flags: []cli.Flag{fnNameFlag}, Action: fnStatus},
+		{Name: "events", Usage: "Show events recorded by the executor for a function, such as specializations and scaling", Flags: []cli.Flag{fnNameFlag}, Action: fnEvents},
+		{Name: "history", Usage: "Show the revisions of a function", Flags: []cli.Flag{fnNameFlag}, Action: fnHistory},
+		{Name: "rollback", Usage: "Roll a function and its package back to an earlier revision", Flags: []cli.Flag{fnNameFlag, fnRollbackToFlag}, Action: fnRollback},
 		{Name: "pods", Usage: "Display function pods", Flags: []cli.Flag{fnNameFlag, fnLogDBTypeFlag}, Action: fnPods},
 		{Name: "test", Usage: "Test a function", Flags: []cli.Flag{fnNameFlag, fnEnvNameFlag, fnCodeFlag, fnPackageFlag, fnSrcArchiveFlag, htMethodFlag, fnBodyFlag, fnHeaderFlag}, Action: fnTest},
 	}
@@ -106,7 +127,7 @@ func main() {
 		{Name: "get", Usage: "Get HTTP trigger", Flags: []cli.Flag{htMethodFlag, htUrlFlag}, Action: htGet},
 		{Name: "update", Usage: "Update HTTP trigger", Flags: []cli.Flag{htNameFlag, htFnNameFlag}, Action: htUpdate},
 		{Name: "delete", Usage: "Delete HTTP trigger", Flags: []cli.Flag{htNameFlag}, Action: htDelete},
-		{Name: "list", Usage: "List HTTP triggers", Flags: []cli.Flag{}, Action: htList},
+		{Name: "list", Usage: "List HTTP triggers", Flags: listFlags, Action: htList},
 	}
 
 	// timetriggers
@@ -118,7 +139,7 @@ func main() {
 		{Name: "get", Usage: "Get Time trigger", Flags: []cli.Flag{}, Action: ttGet},
 		{Name: "update", Usage: "Update Time trigger", Flags: []cli.Flag{ttNameFlag, ttCronFlag, ttFnNameFlag}, Action: ttUpdate},
 		{Name: "delete", Usage: "Delete Time trigger", Flags: []cli.Flag{ttNameFlag}, Action: ttDelete},
-		{Name: "list", Usage: "List Time triggers", Flags: []cli.Flag{}, Action: ttList},
+		{Name: "list", Usage: "List Time triggers", Flags: listFlags, Action: ttList},
 	}
 
 	// Message queue trigger
@@ -133,7 +154,7 @@ func main() {
 		{Name: "get", Usage: "Get message queue trigger", Flags: []cli.Flag{}, Action: mqtGet},
 		{Name: "update", Usage: "Update message queue trigger", Flags: []cli.Flag{mqtNameFlag, mqtTopicFlag, mqtRespTopicFlag, mqtFnNameFlag, mqtMsgContentType}, Action: mqtUpdate},
 		{Name: "delete", Usage: "Delete message queue trigger", Flags: []cli.Flag{mqtNameFlag}, Action: mqtDelete},
-		{Name: "list", Usage: "List message queue triggers", Flags: []cli.Flag{mqtMQTypeFlag}, Action: mqtList},
+		{Name: "list", Usage: "List message queue triggers", Flags: append([]cli.Flag{mqtMQTypeFlag}, listFlags...), Action: mqtList},
 	}
 
 	// environments
@@ -144,12 +165,13 @@ func main() {
 	envBuildCmdFlag := cli.StringFlag{Name: "buildcmd", Usage: "Build command for environment builder to build source package (optional)"}
 
 	envVersionFlag := cli.IntFlag{Name: "version", Usage: "Environment API version: defaults to 1 (means v1 interface)"}
+	envForceFlag := cli.BoolFlag{Name: "force, f", Usage: "Force delete an environment even if it is used by one or more functions"}
 	envSubcommands := []cli.Command{
 		{Name: "create", Aliases: []string{"add"}, Usage: "Add an environment", Flags: []cli.Flag{envNameFlag, envPoolsizeFlag, envImageFlag, envBuilderImageFlag, envBuildCmdFlag, minCpu, maxCpu, minMem, maxMem, envVersionFlag}, Action: envCreate},
 		{Name: "get", Usage: "Get environment details", Flags: []cli.Flag{envNameFlag}, Action: envGet},
 		{Name: "update", Usage: "Update environment", Flags: []cli.Flag{envNameFlag, envPoolsizeFlag, envImageFlag, envBuilderImageFlag, envBuildCmdFlag, minCpu, maxCpu, minMem, maxMem}, Action: envUpdate},
-		{Name: "delete", Usage: "Delete environment", Flags: []cli.Flag{envNameFlag}, Action: envDelete},
-		{Name: "list", Usage: "List all environments", Flags: []cli.Flag{}, Action: envList},
+		{Name: "delete", Usage: "Delete environment", Flags: []cli.Flag{envNameFlag, envForceFlag}, Action: envDelete},
+		{Name: "list", Usage: "List all environments", Flags: listFlags, Action: envList},
 	}
 
 	// watches
@@ -163,12 +185,13 @@ func main() {
 		{Name: "get", Usage: "Get details about a watch", Flags: []cli.Flag{wNameFlag}, Action: wGet},
 		// TODO add update flag when supported
 		{Name: "delete", Usage: "Delete watch", Flags: []cli.Flag{wNameFlag}, Action: wDelete},
-		{Name: "list", Usage: "List all watches", Flags: []cli.Flag{}, Action: wList},
+		{Name: "list", Usage: "List all watches", Flags: listFlags, Action: wList},
 	}
 
 	// packages
 	pkgNameFlag := cli.StringFlag{Name: "name", Usage: "Package name"}
 	pkgForceFlag := cli.BoolFlag{Name: "force, f", Usage: "Force update a package even if it is used by one or more functions"}
+	pkgDeleteForceFlag := cli.BoolFlag{Name: "force, f", Usage: "Force delete a package even if it is used by one or more functions; the controller refuses otherwise"}
 	pkgEnvironmentFlag := cli.StringFlag{Name: "env", Usage: "Environment name"}
 	pkgSrcArchiveFlag := cli.StringFlag{Name: "sourcearchive, src", Usage: "Local path or URL for source archive"}
 	pkgDeployArchiveFlag := cli.StringFlag{Name: "deployarchive, deploy", Usage: "Local path or URL for binary archive"}
@@ -181,8 +204,8 @@ func main() {
 		{Name: "getsrc", Usage: "Get source archive content", Flags: []cli.Flag{pkgNameFlag, pkgOutputFlag}, Action: pkgSourceGet},
 		{Name: "getdeploy", Usage: "Get deployment archive content", Flags: []cli.Flag{pkgNameFlag, pkgOutputFlag}, Action: pkgDeployGet},
 		{Name: "info", Usage: "Show package information", Flags: []cli.Flag{pkgNameFlag}, Action: pkgInfo},
-		{Name: "list", Usage: "List all packages", Flags: []cli.Flag{pkgOrphanFlag}, Action: pkgList},
-		{Name: "delete", Usage: "Delete package", Flags: []cli.Flag{pkgNameFlag, pkgForceFlag, pkgOrphanFlag}, Action: pkgDelete},
+		{Name: "list", Usage: "List all packages", Flags: append([]cli.Flag{pkgOrphanFlag}, listFlags...), Action: pkgList},
+		{Name: "delete", Usage: "Delete package; packages used by functions are only deleted with --force", Flags: []cli.Flag{pkgNameFlag, pkgDeleteForceFlag, pkgOrphanFlag}, Action: pkgDelete},
 	}
 
 	// upgrades, data migrations
@@ -199,16 +222,24 @@ func main() {
 		{Name: "restore", Usage: "Restore state dumped from a pre-0.4 Fission cluster. Requires Fission 0.4, which uses Kubernetes CustomResources.", Flags: []cli.Flag{migrateFileFlag}, Action: migrateRestoreCRD},
 	}
 
+	// backups
+	backupFileFlag := cli.StringFlag{Name: "file", Usage: "Backup file, defaults to fission-backup.tar.gz"}
+	backupSubCommands := []cli.Command{
+		{Name: "create", Usage: "Save all Fission objects of all namespaces, and the archives they use, to a file", Flags: []cli.Flag{backupFileFlag}, Action: backupCreate},
+		{Name: "restore", Usage: "Recreate the objects in a backup that don't exist yet, uploading their archives again", Flags: []cli.Flag{backupFileFlag}, Action: backupRestore},
+	}
+
 	// specs
 	specDirFlag := cli.StringFlag{Name: "specdir", Usage: "Directory to store specs, defaults to ./specs"}
 	specNameFlag := cli.StringFlag{Name: "name", Usage: "(optional) Name for the app, applied to resources as a Kubernetes annotation"}
 	specWaitFlag := cli.BoolFlag{Name: "wait", Usage: "Wait for package builds"}
 	specWatchFlag := cli.BoolFlag{Name: "watch", Usage: "Watch local files for change, and re-apply specs as necessary"}
 	specDeleteFlag := cli.BoolFlag{Name: "delete", Usage: "Allow apply to delete resources that no longer exist in the specification"}
+	specDryRunFlag := cli.BoolFlag{Name: "dry-run", Usage: "Validate changes on the server and show what apply would do, without changing anything"}
 	specSubCommands := []cli.Command{
 		{Name: "init", Usage: "Create an initial declarative app specification", Flags: []cli.Flag{specDirFlag, specNameFlag}, Action: specInit},
 		{Name: "validate", Usage: "Validate Fission app specification", Flags: []cli.Flag{specDirFlag}, Action: specValidate},
-		{Name: "apply", Usage: "Create, update, or delete Fission resources from app specification", Flags: []cli.Flag{specDirFlag, specDeleteFlag, specWaitFlag, specWatchFlag}, Action: specApply},
+		{Name: "apply", Usage: "Create, update, or delete Fission resources from app specification", Flags: []cli.Flag{specDirFlag, specDeleteFlag, specWaitFlag, specWatchFlag, specDryRunFlag}, Action: specApply},
 		{Name: "destroy", Usage: "Delete all Fission resources in the app specification", Flags: []cli.Flag{specDirFlag}, Action: specDestroy},
 		{Name: "helm", Usage: "Create a helm chart from the app specification", Flags: []cli.Flag{specDirFlag}, Action: specHelm},
 	}
@@ -222,6 +253,7 @@ func main() {
 		{Name: "watch", Aliases: []string{"w"}, Usage: "Manage watches", Subcommands: wSubCommands},
 		{Name: "package", Aliases: []string{"pkg"}, Usage: "Manage packages", Subcommands: pkgSubCommands},
 		{Name: "spec", Aliases: []string{"specs"}, Usage: "Manage a declarative app specification", Subcommands: specSubCommands},
+		{Name: "backup", Aliases: []string{}, Usage: "Back up and restore all Fission state", Subcommands: backupSubCommands},
 		{Name: "upgrade", Aliases: []string{}, Usage: "Upgrade tool from fission v0.1", Subcommands: upgradeSubCommands},
 		{Name: "tpr2crd", Aliases: []string{}, Usage: "Migrate tool for TPR to CRD", Subcommands: migrateSubCommands},
 	}
@@ -97,7 +97,7 @@ func migrateDeleteTPR(c *cli.Context) error {
 	req, err := http.NewRequest("DELETE", relativeUrl, nil)
 	checkErr(err, "connect to fission server")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := controllerHTTPClient(getClient(server)).Do(req)
 	checkErr(err, "delete tpr resources")
 	defer resp.Body.Close()
 
@@ -170,7 +170,7 @@ func mqtDelete(c *cli.Context) error {
 func mqtList(c *cli.Context) error {
 	client := getClient(c.GlobalString("server"))
 
-	mqts, err := client.MessageQueueTriggerList(c.String("mqtype"))
+	mqts, next, err := client.MessageQueueTriggerListWithOptions(c.String("mqtype"), getListOptions(c))
 	checkErr(err, "list message queue triggers")
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -182,6 +182,7 @@ func mqtList(c *cli.Context) error {
 			mqt.Metadata.Name, mqt.Spec.FunctionReference.Name, mqt.Spec.MessageQueueType, mqt.Spec.Topic, mqt.Spec.ResponseTopic, mqt.Spec.ContentType)
 	}
 	w.Flush()
+	printContinue(next)
 
 	return nil
 }
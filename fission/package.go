@@ -56,7 +56,7 @@ func downloadStoragesvcURL(client *client.Client, fileUrl string) io.ReadCloser
 
 	// replace in-cluster storage service host with controller server url
 	fileDownloadUrl := strings.TrimSuffix(client.Url, "/") + "/proxy/storage/" + u.RequestURI()
-	reader, err := downloadURL(fileDownloadUrl)
+	reader, err := downloadURL(controllerHTTPClient(client), fileDownloadUrl)
 
 	checkErr(err, fmt.Sprintf("download from storage service url: %v", fileUrl))
 	return reader
@@ -78,7 +78,7 @@ func pkgCreate(c *cli.Context) error {
 		fatal("Need --src to specify source archive, or use --deploy to specify deployment archive.")
 	}
 
-	meta := createPackage(client, envName, srcArchiveName, deployArchiveName, buildcmd, "")
+	meta := createPackage(client, "", envName, srcArchiveName, deployArchiveName, buildcmd, "")
 	fmt.Printf("Package '%v' created\n", meta.GetName())
 
 	return nil
@@ -276,7 +276,7 @@ func pkgList(c *cli.Context) error {
 	// option for the user to list all orphan packages (not referenced by any function)
 	listOrphans := c.Bool("orphan")
 
-	pkgList, err := client.PackageList()
+	pkgList, next, err := client.PackageListWithOptions(getListOptions(c))
 	if err != nil {
 		return err
 	}
@@ -299,6 +299,7 @@ func pkgList(c *cli.Context) error {
 	}
 
 	w.Flush()
+	printContinue(next)
 
 	return nil
 }
@@ -314,7 +315,7 @@ func deleteOrphanPkgs(client *client.Client) error {
 		fnList, err := getFunctionsByPackage(client, pkg.Metadata.Name)
 		checkErr(err, fmt.Sprintf("get functions sharing package %s", pkg.Metadata.Name))
 		if len(fnList) == 0 {
-			err = deletePackage(client, pkg.Metadata.Name)
+			err = deletePackage(client, pkg.Metadata.Name, false)
 			if err != nil {
 				return err
 			}
@@ -323,11 +324,11 @@ func deleteOrphanPkgs(client *client.Client) error {
 	return nil
 }
 
-func deletePackage(client *client.Client, pkgName string) error {
-	return client.PackageDelete(&metav1.ObjectMeta{
+func deletePackage(fclient *client.Client, pkgName string, force bool) error {
+	return fclient.PackageDeleteWithOptions(&metav1.ObjectMeta{
 		Namespace: metav1.NamespaceDefault,
 		Name:      pkgName,
-	})
+	}, &client.DeleteOptions{Force: force})
 }
 
 func pkgDelete(c *cli.Context) error {
@@ -360,7 +361,7 @@ func pkgDelete(c *cli.Context) error {
 			fatal("Package is used by at least one function, use -f to force delete")
 		}
 
-		err = deletePackage(client, pkgName)
+		err = deletePackage(client, pkgName, force)
 		if err != nil {
 			return err
 		}
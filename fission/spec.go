@@ -190,21 +190,84 @@ func specInit(c *cli.Context) error {
 	return nil
 }
 
-// specValidate parses a set of specs and checks for references to
-// resources that don't exist.
+// specValidate parses a set of specs, checks the references between
+// them, and has the controller validate them.
 func specValidate(c *cli.Context) error {
-	//specDir := getSpecDir(c)
+	specDir := getSpecDir(c)
+
+	fr, err := readSpecs(specDir)
+	checkErr(err, "read specs")
+
+	// archive references, which are resolved by the CLI
+	archives := make(map[string]bool)
+	for _, aus := range fr.archiveUploadSpecs {
+		archives[fmt.Sprintf("%v%v", ARCHIVE_URL_PREFIX, aus.Name)] = true
+	}
+	failed := false
+	for _, pkg := range fr.packages {
+		for _, ar := range []fission.Archive{pkg.Spec.Source, pkg.Spec.Deployment} {
+			if strings.HasPrefix(ar.URL, ARCHIVE_URL_PREFIX) && !archives[ar.URL] {
+				fmt.Printf("Package %v: no ArchiveUploadSpec for %v\n", pkg.Metadata.Name, ar.URL)
+				failed = true
+			}
+		}
+	}
 
-	// parse all specs
-	// verify references:
-	//   functions from triggers
-	//   packages from functions
+	// Triggers may refer to functions that aren't in the specs; that's
+	// allowed, but most likely a typo.
+	functions := make(map[string]bool)
+	for _, f := range fr.functions {
+		functions[f.Metadata.Name] = true
+	}
+	checkFunctionRef := func(kind string, name string, ref fission.FunctionReference) {
+		if !functions[ref.Name] {
+			warn(fmt.Sprintf("%v %v refers to function %v, which isn't in the specs", kind, name, ref.Name))
+		}
+	}
+	for _, t := range fr.httpTriggers {
+		checkFunctionRef("HTTPTrigger", t.Metadata.Name, t.Spec.FunctionReference)
+	}
+	for _, t := range fr.kubernetesWatchTriggers {
+		checkFunctionRef("KubernetesWatchTrigger", t.Metadata.Name, t.Spec.FunctionReference)
+	}
+	for _, t := range fr.timeTriggers {
+		checkFunctionRef("TimeTrigger", t.Metadata.Name, t.Spec.FunctionReference)
+	}
+	for _, t := range fr.messageQueueTriggers {
+		checkFunctionRef("MessageQueueTrigger", t.Metadata.Name, t.Spec.FunctionReference)
+	}
 
-	// find unreferenced uploads
+	// everything else is checked by the controller
+	fclient := getClient(c.GlobalString("server"))
+	results, err := fclient.Validate(fr.resources())
+	checkErr(err, "validate specs")
+	for _, r := range results {
+		for _, e := range r.Errors {
+			fmt.Printf("%v %v: %v\n", r.Kind, r.Name, e.Error())
+		}
+		failed = true
+	}
 
+	if failed {
+		fatal("Specs are invalid")
+	}
+	fmt.Println("Specs are valid")
 	return nil
 }
 
+// resources returns the Fission objects of the specs.
+func (fr *FissionResources) resources() *crd.Resources {
+	return &crd.Resources{
+		Packages:                fr.packages,
+		Functions:               fr.functions,
+		Environments:            fr.environments,
+		HTTPTriggers:            fr.httpTriggers,
+		KubernetesWatchTriggers: fr.kubernetesWatchTriggers,
+		TimeTriggers:            fr.timeTriggers,
+		MessageQueueTriggers:    fr.messageQueueTriggers,
+	}
+}
+
 // parseYaml takes one yaml document, figures out its type, parses it, and puts it in
 // the right list in the given fission resources set.
 func parseYaml(path string, b []byte, fr *FissionResources) error {
@@ -386,6 +449,12 @@ func specApply(c *cli.Context) error {
 	watchResources := c.Bool("watch")
 	waitForBuild := c.Bool("wait")
 
+	// dry runs change nothing, so there's nothing to watch or wait for
+	fclient.DryRun = c.Bool("dry-run")
+	if fclient.DryRun && (watchResources || waitForBuild) {
+		fatal("--dry-run can't be used with --watch or --wait")
+	}
+
 	var watcher *fsnotify.Watcher
 	var pbw *packageBuildWatcher
 
@@ -420,9 +489,13 @@ func specApply(c *cli.Context) error {
 		checkErr(err, "read specs")
 
 		// make changes to the cluster based on the specs
-		pkgMetas, as, err := applyResources(fclient, specDir, fr, deleteResources)
+		pkgMetas, as, err := applySpecs(fclient, specDir, fr, deleteResources)
 		checkErr(err, "apply specs")
 		printApplyStatus(as)
+		if fclient.DryRun {
+			fmt.Println("Dry run, nothing was changed")
+			break
+		}
 
 		if watchResources || waitForBuild {
 			// watch package builds
@@ -582,6 +655,13 @@ func applyArchives(fclient *client.Client, specDir string, fr *FissionResources)
 			fmt.Printf("archive %v exists, not uploading\n", name)
 			a := archiveFiles[name]
 			a.URL = url
+		} else if fclient.DryRun {
+			// packages keep the archive:// URL, which the
+			// controller accepts
+			fmt.Printf("archive %v would be uploaded\n", name)
+			a := archiveFiles[name]
+			a.URL = name
+			archiveFiles[name] = a
 		} else {
 			// doesn't exist, upload
 			fmt.Printf("uploading archive %v\n", name)
@@ -608,6 +688,111 @@ func applyArchives(fclient *client.Client, specDir string, fr *FissionResources)
 	return nil
 }
 
+// applySpecs has the controller apply the given set of fission
+// resources, which it does all at once: if anything fails, nothing is
+// changed. Controllers too old to do that get the changes one by one.
+func applySpecs(fclient *client.Client, specDir string, fr *FissionResources, delete bool) (map[string]metav1.ObjectMeta, map[string]resourceApplyStatus, error) {
+	// Archives that are literals or already uploaded are put in the
+	// packages right away; the rest are sent along with the specs.
+	uploads, err := resolveArchives(fclient, specDir, fr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := fclient.Apply(&crd.ApplyRequest{
+		DeploymentName: fr.deploymentConfig.Name,
+		DeploymentUID:  fr.deploymentConfig.UID,
+		Resources:      *fr.resources(),
+		Delete:         delete,
+	}, uploads)
+	if isMissingApi(err) {
+		warn("The controller can't apply specs all at once; applying them one by one")
+		return applyResources(fclient, specDir, fr, delete)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applyStatus := make(map[string]resourceApplyStatus)
+	for typ, as := range result.Status {
+		var ras resourceApplyStatus
+		for i := range as.Created {
+			ras.created = append(ras.created, &as.Created[i])
+		}
+		for i := range as.Updated {
+			ras.updated = append(ras.updated, &as.Updated[i])
+		}
+		for i := range as.Deleted {
+			ras.deleted = append(ras.deleted, &as.Deleted[i])
+		}
+		applyStatus[typ] = ras
+	}
+	pkgMetas := make(map[string]metav1.ObjectMeta)
+	for _, m := range result.Packages {
+		pkgMetas[mapKey(&m)] = m
+	}
+	return pkgMetas, applyStatus, nil
+}
+
+// isMissingApi returns true if err is the controller not knowing a
+// request's URL, rather than a missing object.
+func isMissingApi(err error) bool {
+	fe, ok := err.(fission.Error)
+	return ok && fe.Code == fission.ErrorNotFound && strings.HasPrefix(fe.Message, "404 page not found")
+}
+
+// resolveArchives creates the archives of the specs locally, and puts
+// the literal ones, and the ones already uploaded, in the packages
+// that refer to them. It returns the local files of the others, keyed
+// by archive name; packages keep referring to them as archive://<name>.
+func resolveArchives(fclient *client.Client, specDir string, fr *FissionResources) (map[string]string, error) {
+	archiveFiles := make(map[string]fission.Archive)
+	for _, aus := range fr.archiveUploadSpecs {
+		ar, err := localArchiveFromSpec(specDir, &aus)
+		if err != nil {
+			return nil, err
+		}
+		archiveFiles[ARCHIVE_URL_PREFIX+aus.Name] = *ar
+	}
+
+	availableArchives := make(map[string]string) // (sha256 -> url)
+	pkgs, err := fclient.PackageList()
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		for _, ar := range []fission.Archive{pkg.Spec.Source, pkg.Spec.Deployment} {
+			if ar.Type == fission.ArchiveTypeUrl && len(ar.URL) > 0 {
+				availableArchives[ar.Checksum.Sum] = ar.URL
+			}
+		}
+	}
+
+	uploads := make(map[string]string)
+	for i := range fr.packages {
+		for _, ar := range []*fission.Archive{&fr.packages[i].Spec.Source, &fr.packages[i].Spec.Deployment} {
+			if !strings.HasPrefix(ar.URL, ARCHIVE_URL_PREFIX) {
+				continue
+			}
+			localAr, ok := archiveFiles[ar.URL]
+			if !ok {
+				return nil, fmt.Errorf("Unknown archive name %v", strings.TrimPrefix(ar.URL, ARCHIVE_URL_PREFIX))
+			}
+			if localAr.Type == fission.ArchiveTypeLiteral {
+				*ar = localAr
+			} else if url, ok := availableArchives[localAr.Checksum.Sum]; ok {
+				*ar = localAr
+				ar.URL = url
+			} else {
+				// the controller checks uploads against the checksum
+				uploads[strings.TrimPrefix(ar.URL, ARCHIVE_URL_PREFIX)] = localAr.URL
+				ar.Checksum = localAr.Checksum
+			}
+		}
+	}
+	return uploads, nil
+}
+
 // applyResources applies the given set of fission resources.
 func applyResources(fclient *client.Client, specDir string, fr *FissionResources, delete bool) (map[string]metav1.ObjectMeta, map[string]resourceApplyStatus, error) {
 
@@ -868,7 +1053,8 @@ func applyPackages(fclient *client.Client, fr *FissionResources, delete bool) (m
 		for _, o := range objs {
 			_, wanted := desired[mapKey(&o.Metadata)]
 			if !wanted {
-				err := fclient.PackageDelete(&o.Metadata)
+				// functions using the package are deleted along with it
+				err := fclient.PackageDeleteWithOptions(&o.Metadata, &client.DeleteOptions{Force: true})
 				if err != nil {
 					return nil, nil, err
 				}
@@ -1034,7 +1220,8 @@ func applyEnvironments(fclient *client.Client, fr *FissionResources, delete bool
 		for _, o := range objs {
 			_, wanted := desired[mapKey(&o.Metadata)]
 			if !wanted {
-				err := fclient.EnvironmentDelete(&o.Metadata)
+				// the functions using it are deleted with it
+				err := fclient.EnvironmentDeleteWithOptions(&o.Metadata, &client.DeleteOptions{Force: true})
 				if err != nil {
 					return nil, nil, err
 				}
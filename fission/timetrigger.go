@@ -126,7 +126,7 @@ func ttDelete(c *cli.Context) error {
 func ttList(c *cli.Context) error {
 	client := getClient(c.GlobalString("server"))
 
-	tts, err := client.TimeTriggerList()
+	tts, next, err := client.TimeTriggerListWithOptions(getListOptions(c))
 	checkErr(err, "list Time triggers")
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -137,6 +137,7 @@ func ttList(c *cli.Context) error {
 			tt.Metadata.Name, tt.Spec.Cron, tt.Spec.FunctionReference.Name)
 	}
 	w.Flush()
+	printContinue(next)
 
 	return nil
 }
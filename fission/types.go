@@ -16,9 +16,13 @@ limitations under the License.
 
 package main
 
+import (
+	"github.com/fission/fission"
+)
+
 const (
-	FISSION_DEPLOYMENT_NAME_KEY = "fission-name"
-	FISSION_DEPLOYMENT_UID_KEY  = "fission-uid"
+	FISSION_DEPLOYMENT_NAME_KEY = fission.DEPLOYMENT_NAME_ANNOTATION
+	FISSION_DEPLOYMENT_UID_KEY  = fission.DEPLOYMENT_UID_ANNOTATION
 )
 
 // CLI spec types
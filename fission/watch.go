@@ -117,7 +117,7 @@ func wDelete(c *cli.Context) error {
 func wList(c *cli.Context) error {
 	client := getClient(c.GlobalString("server"))
 
-	ws, err := client.WatchList()
+	ws, next, err := client.WatchListWithOptions(getListOptions(c))
 	checkErr(err, "list watches")
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -129,6 +129,7 @@ func wList(c *cli.Context) error {
 			wa.Metadata.Name, wa.Spec.Namespace, wa.Spec.Type, wa.Spec.LabelSelector, wa.Spec.FunctionReference.Name)
 	}
 	w.Flush()
+	printContinue(next)
 
 	return nil
 }
@@ -0,0 +1,204 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fission
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// MergePodTemplateOverlay merges overlay into a pod template generated by
+// Fission. Container level settings are applied to the container named
+// containerName. A nil overlay leaves the template unchanged.
+func MergePodTemplateOverlay(tmpl *v1.PodTemplateSpec, containerName string, overlay *PodTemplateOverlay) {
+	if overlay == nil {
+		return
+	}
+
+	tmpl.ObjectMeta.Annotations = mergeStringMap(tmpl.ObjectMeta.Annotations, overlay.Annotations)
+
+	spec := &tmpl.Spec
+	spec.NodeSelector = mergeStringMap(spec.NodeSelector, overlay.NodeSelector)
+	if overlay.Tolerations != nil {
+		spec.Tolerations = overlay.Tolerations
+	}
+	if overlay.Affinity != nil {
+		spec.Affinity = overlay.Affinity
+	}
+	if len(overlay.ServiceAccountName) > 0 {
+		spec.ServiceAccountName = overlay.ServiceAccountName
+	}
+	if overlay.SecurityContext != nil {
+		spec.SecurityContext = overlay.SecurityContext
+	}
+	for _, vol := range overlay.Volumes {
+		spec.Volumes = mergeVolume(spec.Volumes, vol)
+	}
+
+	for i := range spec.Containers {
+		c := &spec.Containers[i]
+		if c.Name != containerName {
+			continue
+		}
+		for _, env := range overlay.Env {
+			c.Env = mergeEnvVar(c.Env, env)
+		}
+		for _, mount := range overlay.VolumeMounts {
+			c.VolumeMounts = mergeVolumeMount(c.VolumeMounts, mount)
+		}
+		if overlay.ContainerSecurityContext != nil {
+			c.SecurityContext = overlay.ContainerSecurityContext
+		}
+	}
+}
+
+func mergeStringMap(m map[string]string, overlay map[string]string) map[string]string {
+	if len(overlay) == 0 {
+		return m
+	}
+	if m == nil {
+		m = make(map[string]string)
+	}
+	for k, v := range overlay {
+		m[k] = v
+	}
+	return m
+}
+
+func mergeVolume(vols []v1.Volume, vol v1.Volume) []v1.Volume {
+	for i := range vols {
+		if vols[i].Name == vol.Name {
+			vols[i] = vol
+			return vols
+		}
+	}
+	return append(vols, vol)
+}
+
+func mergeEnvVar(envs []v1.EnvVar, env v1.EnvVar) []v1.EnvVar {
+	for i := range envs {
+		if envs[i].Name == env.Name {
+			envs[i] = env
+			return envs
+		}
+	}
+	return append(envs, env)
+}
+
+func mergeVolumeMount(mounts []v1.VolumeMount, mount v1.VolumeMount) []v1.VolumeMount {
+	for i := range mounts {
+		if mounts[i].MountPath == mount.MountPath {
+			mounts[i] = mount
+			return mounts
+		}
+	}
+	return append(mounts, mount)
+}
+
+// reservedVolumeNames are the names of the volumes Fission generates in
+// runtime, fetcher, builder and batch job pods. Overlay volumes with
+// these names would replace them.
+var reservedVolumeNames = map[string]bool{
+	"userfunc":   true,
+	"secrets":    true,
+	"config":     true,
+	"configs":    true,
+	"package":    true,
+	"invocation": true,
+}
+
+// Validate checks that the overlay only contains values Kubernetes will
+// accept in a pod, so that errors show up when the environment or
+// function is created rather than when its pods are.
+func (overlay *PodTemplateOverlay) Validate() error {
+	errs := overlay.FieldErrors("")
+	if len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		return MakeError(ErrorInvalidArgument, fmt.Sprintf("Invalid pod template: %v", strings.Join(msgs, "; ")))
+	}
+	return nil
+}
+
+// FieldErrors returns the problems that Validate finds, with the paths
+// of their fields under path, e.g. "spec.podtemplate".
+func (overlay *PodTemplateOverlay) FieldErrors(path string) []FieldError {
+	if overlay == nil {
+		return nil
+	}
+
+	errs := make([]FieldError, 0)
+	addErrs := func(field string, msgs []string) {
+		if len(path) > 0 {
+			field = path + "." + field
+		}
+		for _, msg := range msgs {
+			errs = append(errs, FieldError{Field: field, Message: msg})
+		}
+	}
+
+	for k := range overlay.Annotations {
+		addErrs(fmt.Sprintf("annotations[%v]", k), validation.IsQualifiedName(k))
+	}
+	for k, v := range overlay.NodeSelector {
+		addErrs(fmt.Sprintf("nodeSelector[%v]", k), validation.IsQualifiedName(k))
+		addErrs(fmt.Sprintf("nodeSelector[%v]", k), validation.IsValidLabelValue(v))
+	}
+	for i, t := range overlay.Tolerations {
+		switch t.Operator {
+		case "", v1.TolerationOpEqual, v1.TolerationOpExists:
+		default:
+			addErrs(fmt.Sprintf("tolerations[%v].operator", i), []string{fmt.Sprintf("unsupported operator %q", t.Operator)})
+		}
+		if len(t.Key) > 0 {
+			addErrs(fmt.Sprintf("tolerations[%v].key", i), validation.IsQualifiedName(t.Key))
+		}
+	}
+	if len(overlay.ServiceAccountName) > 0 {
+		addErrs("serviceAccountName", validation.IsDNS1123Subdomain(overlay.ServiceAccountName))
+	}
+
+	volumes := make(map[string]bool)
+	for i, vol := range overlay.Volumes {
+		addErrs(fmt.Sprintf("volumes[%v].name", i), validation.IsDNS1123Label(vol.Name))
+		if reservedVolumeNames[vol.Name] {
+			addErrs(fmt.Sprintf("volumes[%v].name", i), []string{fmt.Sprintf("%q is reserved for a volume Fission generates", vol.Name)})
+		}
+		volumes[vol.Name] = true
+	}
+	for i, env := range overlay.Env {
+		addErrs(fmt.Sprintf("env[%v].name", i), validation.IsCIdentifier(env.Name))
+	}
+	for i, mount := range overlay.VolumeMounts {
+		// Mounts can only refer to volumes added by the overlay, the
+		// names of the generated volumes are an implementation detail.
+		if !volumes[mount.Name] {
+			addErrs(fmt.Sprintf("volumeMounts[%v].name", i), []string{fmt.Sprintf("volume %q is not defined in volumes", mount.Name)})
+		}
+		if !filepath.IsAbs(mount.MountPath) {
+			addErrs(fmt.Sprintf("volumeMounts[%v].mountPath", i), []string{"must be an absolute path"})
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,103 @@
+package fission
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestMergePodTemplateOverlay(t *testing.T) {
+	tmpl := &v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{{Name: "userfunc"}},
+			Containers: []v1.Container{
+				{
+					Name: "runtime",
+					Env:  []v1.EnvVar{{Name: "ENV_VERSION", Value: "2"}},
+				},
+				{
+					Name: "fetcher",
+				},
+			},
+			ServiceAccountName: "fission-fetcher",
+		},
+	}
+
+	overlay := &PodTemplateOverlay{
+		Annotations:  map[string]string{"sidecar.istio.io/inject": "false"},
+		NodeSelector: map[string]string{"disktype": "ssd"},
+		Volumes:      []v1.Volume{{Name: "cache"}},
+		Env: []v1.EnvVar{
+			{Name: "ENV_VERSION", Value: "3"},
+			{Name: "DEBUG", Value: "true"},
+		},
+		VolumeMounts: []v1.VolumeMount{{Name: "cache", MountPath: "/cache"}},
+	}
+	MergePodTemplateOverlay(tmpl, "runtime", overlay)
+
+	if tmpl.ObjectMeta.Annotations["sidecar.istio.io/inject"] != "false" {
+		t.Errorf("annotation not merged: %v", tmpl.ObjectMeta.Annotations)
+	}
+	if tmpl.Spec.NodeSelector["disktype"] != "ssd" {
+		t.Errorf("node selector not merged: %v", tmpl.Spec.NodeSelector)
+	}
+	if tmpl.Spec.ServiceAccountName != "fission-fetcher" {
+		t.Errorf("service account changed to %v", tmpl.Spec.ServiceAccountName)
+	}
+	if len(tmpl.Spec.Volumes) != 2 {
+		t.Errorf("expected 2 volumes, got %v", tmpl.Spec.Volumes)
+	}
+
+	runtime := tmpl.Spec.Containers[0]
+	if len(runtime.Env) != 2 || runtime.Env[0].Value != "3" {
+		t.Errorf("env not merged by name: %v", runtime.Env)
+	}
+	if len(runtime.VolumeMounts) != 1 {
+		t.Errorf("expected 1 volume mount, got %v", runtime.VolumeMounts)
+	}
+
+	fetcher := tmpl.Spec.Containers[1]
+	if len(fetcher.Env) != 0 || len(fetcher.VolumeMounts) != 0 {
+		t.Errorf("fetcher container should be unchanged: %v", fetcher)
+	}
+}
+
+func TestPodTemplateOverlayValidate(t *testing.T) {
+	var overlay *PodTemplateOverlay
+	if err := overlay.Validate(); err != nil {
+		t.Errorf("nil overlay should be valid: %v", err)
+	}
+
+	overlay = &PodTemplateOverlay{
+		Volumes:      []v1.Volume{{Name: "cache"}},
+		VolumeMounts: []v1.VolumeMount{{Name: "cache", MountPath: "/cache"}},
+		Env:          []v1.EnvVar{{Name: "DEBUG"}},
+	}
+	if err := overlay.Validate(); err != nil {
+		t.Errorf("expected valid overlay: %v", err)
+	}
+
+	invalid := []*PodTemplateOverlay{
+		{VolumeMounts: []v1.VolumeMount{{Name: "userfunc", MountPath: "/userfunc"}}},
+		{Volumes: []v1.Volume{{Name: "Cache_1"}}},
+		{
+			Volumes:      []v1.Volume{{Name: "userfunc"}},
+			VolumeMounts: []v1.VolumeMount{{Name: "userfunc", MountPath: "/data"}},
+		},
+		{Volumes: []v1.Volume{{Name: "secrets"}}},
+		{Env: []v1.EnvVar{{Name: "1-BAD"}}},
+		{NodeSelector: map[string]string{"disktype": "not a label value"}},
+		{Tolerations: []v1.Toleration{{Key: "dedicated", Operator: "Maybe"}}},
+	}
+	for _, o := range invalid {
+		err := o.Validate()
+		if err == nil {
+			t.Errorf("expected error for overlay %#v", o)
+			continue
+		}
+		fe, ok := err.(Error)
+		if !ok || fe.Code != ErrorInvalidArgument {
+			t.Errorf("expected invalid argument error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	"github.com/fission/fission/executor/batchjob"
+	executorClient "github.com/fission/fission/executor/client"
+)
+
+// batchHandler handles requests to functions using the job executor.
+// Instead of proxying the request, it starts a batch invocation and
+// responds right away with 202 Accepted; the invocation's status and
+// result can then be polled at the URL in the Location header.
+type batchHandler struct {
+	executor *executorClient.Client
+	function *metav1.ObjectMeta
+}
+
+func (bh *batchHandler) handler(w http.ResponseWriter, r *http.Request) {
+	// Don't read more than the executor accepts.
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, batchjob.MaxBodySize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request", 500)
+		return
+	}
+	if len(body) > batchjob.MaxBodySize {
+		http.Error(w, fmt.Sprintf("Request body exceeds the limit of %v bytes", batchjob.MaxBodySize),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	inv, err := bh.executor.CreateInvocation(&batchjob.InvocationRequest{
+		Function:    *bh.function,
+		ContentType: r.Header.Get("Content-Type"),
+		Body:        body,
+	})
+	if err != nil {
+		log.Printf("Failed to create invocation of function %v: %v", bh.function.Name, err)
+		code, msg := fission.GetHTTPError(err)
+		http.Error(w, msg, code)
+		return
+	}
+
+	w.Header().Set("Location", urlForInvocation(inv.ID))
+	writeInvocation(w, inv, http.StatusAccepted)
+}
+
+func urlForInvocation(id string) string {
+	return "/fission-invocation/" + id
+}
+
+// invocationStatusHandler returns the status and result of a batch
+// invocation.
+func invocationStatusHandler(executor *executorClient.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		inv, err := executor.GetInvocation(vars["invocation"])
+		if err != nil {
+			code, msg := fission.GetHTTPError(err)
+			http.Error(w, msg, code)
+			return
+		}
+		writeInvocation(w, inv, http.StatusOK)
+	}
+}
+
+func writeInvocation(w http.ResponseWriter, inv *batchjob.Invocation, code int) {
+	resp, err := json.Marshal(inv)
+	if err != nil {
+		http.Error(w, "Failed to encode invocation", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(resp)
+}
@@ -28,15 +28,30 @@ import (
 	"github.com/gorilla/mux"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/fission/fission"
 	executorClient "github.com/fission/fission/executor/client"
+	"github.com/fission/fission/executor/lease"
 )
 
 type functionHandler struct {
 	fmap     *functionServiceMap
 	executor *executorClient.Client
+	metrics  *functionMetrics
 	function *metav1.ObjectMeta
+
+	// maxConcurrency limits the number of requests to the function
+	// across all routers; each request then needs a lease from the
+	// executor. Zero means no limit.
+	maxConcurrency int
+	// leaseTimeout is how long requests are queued for a lease
+	// before being rejected.
+	leaseTimeout time.Duration
 }
 
+// Requests to functions at their concurrency limit wait this long
+// for a lease by default.
+const defaultLeaseTimeout = 10 * time.Second
+
 func (fh *functionHandler) getServiceForFunction() (*url.URL, error) {
 	// call executor, get a url for a function
 	svcName, err := fh.executor.GetServiceForFunction(fh.function)
@@ -50,6 +65,59 @@ func (fh *functionHandler) getServiceForFunction() (*url.URL, error) {
 	return svcUrl, nil
 }
 
+// acquireLease gets a lease for a request to a function with a
+// concurrency limit, retrying while the function is at its limit.
+func (fh *functionHandler) acquireLease() (*lease.Lease, error) {
+	deadline := time.Now().Add(fh.leaseTimeout)
+	backoff := 20 * time.Millisecond
+	for {
+		l, err := fh.executor.AcquireLease(fh.function)
+		if err == nil {
+			return l, nil
+		}
+		fe, ok := err.(fission.Error)
+		if !ok || fe.Code != fission.ErrorTooManyRequests || time.Now().Add(backoff).After(deadline) {
+			return nil, err
+		}
+		time.Sleep(backoff)
+		if backoff < time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// renewLease keeps a lease from expiring until done is closed, so that
+// requests taking longer than the lease TTL hold on to it.
+func (fh *functionHandler) renewLease(l *lease.Lease, done chan bool) {
+	if len(l.ID) == 0 || l.TTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(l.TTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := fh.executor.RenewLease(l.ID)
+			if err != nil {
+				log.Printf("Error renewing lease %v for function %v: %v", l.ID, fh.function.Name, err)
+			}
+		}
+	}
+}
+
+func (fh *functionHandler) releaseLease(l *lease.Lease) {
+	if len(l.ID) == 0 {
+		// the function has no limit (any more)
+		return
+	}
+	err := fh.executor.ReleaseLease(l.ID)
+	if err != nil {
+		log.Printf("Error releasing lease %v for function %v: %v", l.ID, fh.function.Name, err)
+	}
+}
+
 // A layer on top of http.DefaultTransport, with retries.
 type RetryingRoundTripper struct {
 	maxRetries    int
@@ -101,28 +169,45 @@ func (fh *functionHandler) handler(responseWriter http.ResponseWriter, request *
 	// System Params
 	MetadataToHeaders(HEADERS_FISSION_FUNCTION_PREFIX, fh.function, request)
 
-	// cache lookup
-	serviceUrl, err := fh.fmap.lookup(fh.function)
-	if err != nil {
-		// Cache miss: request the Pool Manager to make a new service.
-		log.Printf("Not cached, getting new service for %v", fh.function)
-
-		var poolErr error
-		serviceUrl, poolErr = fh.getServiceForFunction()
-		if poolErr != nil {
-			log.Printf("Failed to get service for function %v: %v", fh.function.Name, poolErr)
-			// We might want a specific error code or header for fission
-			// failures as opposed to user function bugs.
+	var serviceUrl *url.URL
+	if fh.maxConcurrency > 0 {
+		// The executor hands out the service along with the lease,
+		// so the cache isn't used for these functions.
+		l, err := fh.acquireLease()
+		if err != nil {
+			log.Printf("Failed to get lease for function %v: %v", fh.function.Name, err)
+			if fe, ok := err.(fission.Error); ok {
+				switch fe.Code {
+				case fission.ErrorTooManyRequests:
+					http.Error(responseWriter, fe.Message, http.StatusTooManyRequests)
+					return
+				case fission.ErrorNoSpace:
+					// the function's namespace is out of quota
+					http.Error(responseWriter, fe.Message, http.StatusServiceUnavailable)
+					return
+				}
+			}
 			http.Error(responseWriter, "Internal server error (fission)", 500)
 			return
 		}
+		done := make(chan bool)
+		go fh.renewLease(l, done)
+		defer func() {
+			close(done)
+			fh.releaseLease(l)
+		}()
 
-		// add it to the map
-		fh.fmap.assign(fh.function, serviceUrl)
+		serviceUrl, err = url.Parse(fmt.Sprintf("http://%v", l.Address))
+		if err != nil {
+			http.Error(responseWriter, "Internal server error (fission)", 500)
+			return
+		}
 	} else {
-		// if we're using our cache, asynchronously tell
-		// executor we're using this service
-		go fh.tapService(serviceUrl)
+		var ok bool
+		serviceUrl, ok = fh.getCachedService(responseWriter)
+		if !ok {
+			return
+		}
 	}
 
 	// Proxy off our request to the serviceUrl, and send the response back.
@@ -167,5 +252,45 @@ func (fh *functionHandler) handler(responseWriter http.ResponseWriter, request *
 	if delay > 100*time.Millisecond {
 		log.Printf("Request delay for %v: %v", serviceUrl, delay)
 	}
+	if fh.metrics != nil {
+		fh.metrics.requestStarted(fh.function)
+		defer fh.metrics.requestFinished(fh.function)
+	}
+
 	proxy.ServeHTTP(responseWriter, request)
 }
+
+// getCachedService returns the service of the function from the
+// cache, or gets a new one from the executor. It responds with an
+// error and returns false if there's none.
+func (fh *functionHandler) getCachedService(responseWriter http.ResponseWriter) (*url.URL, bool) {
+	// cache lookup
+	serviceUrl, err := fh.fmap.lookup(fh.function)
+	if err != nil {
+		// Cache miss: request the Pool Manager to make a new service.
+		log.Printf("Not cached, getting new service for %v", fh.function)
+
+		var poolErr error
+		serviceUrl, poolErr = fh.getServiceForFunction()
+		if poolErr != nil {
+			log.Printf("Failed to get service for function %v: %v", fh.function.Name, poolErr)
+			// The function's namespace is out of function capacity
+			if fe, ok := poolErr.(fission.Error); ok && fe.Code == fission.ErrorNoSpace {
+				http.Error(responseWriter, fe.Message, http.StatusServiceUnavailable)
+				return nil, false
+			}
+			// We might want a specific error code or header for fission
+			// failures as opposed to user function bugs.
+			http.Error(responseWriter, "Internal server error (fission)", 500)
+			return nil, false
+		}
+
+		// add it to the map
+		fh.fmap.assign(fh.function, serviceUrl)
+	} else {
+		// if we're using our cache, asynchronously tell
+		// executor we're using this service
+		go fh.tapService(serviceUrl)
+	}
+	return serviceUrl, true
+}
@@ -17,13 +17,19 @@ limitations under the License.
 package router
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fission/fission"
+	executorClient "github.com/fission/fission/executor/client"
 )
 
 func createBackendService(testResponseString string) *url.URL {
@@ -60,3 +66,149 @@ func TestFunctionProxying(t *testing.T) {
 
 	testRequest(fhURL, testResponseString)
 }
+
+// A fake executor that hands out one lease at a time.
+type fakeLeaseExecutor struct {
+	address  string
+	lock     sync.Mutex
+	held     bool
+	noSpace  bool
+	attempts int
+	acquired int
+	renewed  int
+	released int
+}
+
+func (e *fakeLeaseExecutor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	switch r.Method {
+	case "POST":
+		e.attempts++
+		if e.noSpace {
+			w.Header().Set(fission.NoSpaceHeader, "true")
+			http.Error(w, "namespace is out of quota", 503)
+			return
+		}
+		if e.held {
+			http.Error(w, "function is at its concurrency limit", 429)
+			return
+		}
+		e.held = true
+		e.acquired++
+		fmt.Fprintf(w, `{"id": "lease-%v", "address": "%v", "ttl": %v}`, e.acquired, e.address, int64(100*time.Millisecond))
+	case "PUT":
+		e.renewed++
+	case "DELETE":
+		e.held = false
+		e.released++
+	}
+}
+
+func TestFunctionConcurrencyLimit(t *testing.T) {
+	// The backend holds the first request until the second one has
+	// had to queue for the lease.
+	release := make(chan bool)
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") == "true" {
+			<-release
+		}
+		w.Write([]byte("hi"))
+	}))
+	defer backendServer.Close()
+	backendURL, _ := url.Parse(backendServer.URL)
+
+	executor := &fakeLeaseExecutor{address: backendURL.Host}
+	executorServer := httptest.NewServer(executor)
+	defer executorServer.Close()
+
+	fn := &metav1.ObjectMeta{Name: "foo", Namespace: metav1.NamespaceDefault}
+	fh := &functionHandler{
+		fmap:           makeFunctionServiceMap(0),
+		executor:       executorClient.MakeClient(executorServer.URL),
+		function:       fn,
+		maxConcurrency: 1,
+		leaseTimeout:   5 * time.Second,
+	}
+	fhServer := httptest.NewServer(http.HandlerFunc(fh.handler))
+	defer fhServer.Close()
+
+	first := make(chan error)
+	go func() {
+		resp, err := http.Get(fhServer.URL + "?wait=true")
+		if err == nil {
+			resp.Body.Close()
+		}
+		first <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	second := make(chan int)
+	go func() {
+		resp, err := http.Get(fhServer.URL)
+		if err != nil {
+			second <- 0
+			return
+		}
+		resp.Body.Close()
+		second <- resp.StatusCode
+	}()
+	select {
+	case code := <-second:
+		t.Fatalf("Expected second request to wait for a lease, got %v", code)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("Error making first request: %v", err)
+	}
+	if code := <-second; code != 200 {
+		t.Fatalf("Expected second request to succeed once the lease was released, got %v", code)
+	}
+
+	// leases are released after the response is sent
+	time.Sleep(100 * time.Millisecond)
+	executor.lock.Lock()
+	if executor.acquired != 2 || executor.released != 2 {
+		t.Errorf("Expected 2 leases acquired and released, got %v and %v", executor.acquired, executor.released)
+	}
+	// the first request held its lease past the TTL, renewing it
+	if executor.renewed == 0 {
+		t.Errorf("Expected the lease held past its TTL to be renewed")
+	}
+	// requests are rejected once they've waited for leaseTimeout
+	executor.held = true
+	executor.lock.Unlock()
+
+	fh.leaseTimeout = 100 * time.Millisecond
+	resp, err := http.Get(fhServer.URL)
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected request to be rejected with 429, got %v", resp.StatusCode)
+	}
+
+	// quota exhaustion isn't retried, and is passed through as a 503
+	executor.lock.Lock()
+	executor.noSpace = true
+	executor.attempts = 0
+	executor.lock.Unlock()
+
+	fh.leaseTimeout = 5 * time.Second
+	resp, err = http.Get(fhServer.URL)
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected request to be rejected with 503, got %v", resp.StatusCode)
+	}
+	executor.lock.Lock()
+	if executor.attempts != 1 {
+		t.Errorf("Expected 1 lease attempt for a quota error, got %v", executor.attempts)
+	}
+	executor.lock.Unlock()
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2016 The Fission Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	executorClient "github.com/fission/fission/executor/client"
+	"github.com/fission/fission/executor/metrics"
+)
+
+type (
+	// functionMetrics counts the requests this router sends to each
+	// function, so that the executor can autoscale functions on their
+	// request load.
+	functionMetrics struct {
+		lock     sync.Mutex
+		counters map[metadataKey]*functionCounter
+	}
+	functionCounter struct {
+		function metav1.ObjectMeta
+		requests int64
+		inFlight int64
+	}
+)
+
+func makeFunctionMetrics() *functionMetrics {
+	return &functionMetrics{
+		counters: make(map[metadataKey]*functionCounter),
+	}
+}
+
+func (fm *functionMetrics) getCounter(m *metav1.ObjectMeta) *functionCounter {
+	mk := *keyFromMetadata(m)
+	c, ok := fm.counters[mk]
+	if !ok {
+		c = &functionCounter{function: *m}
+		fm.counters[mk] = c
+	}
+	return c
+}
+
+// requestStarted records a request to the function
+func (fm *functionMetrics) requestStarted(m *metav1.ObjectMeta) {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	c := fm.getCounter(m)
+	c.requests++
+	c.inFlight++
+}
+
+// requestFinished records the end of a request to the function
+func (fm *functionMetrics) requestFinished(m *metav1.ObjectMeta) {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	c := fm.getCounter(m)
+	c.inFlight--
+}
+
+// takeSamples returns a sample for each function and resets the
+// request counts. Counters of functions with no requests in flight
+// are dropped once they've been sampled.
+func (fm *functionMetrics) takeSamples(routerID string, interval time.Duration) []metrics.Sample {
+	fm.lock.Lock()
+	defer fm.lock.Unlock()
+	samples := make([]metrics.Sample, 0, len(fm.counters))
+	for mk, c := range fm.counters {
+		samples = append(samples, metrics.Sample{
+			RouterID: routerID,
+			Function: c.function,
+			Requests: c.requests,
+			InFlight: c.inFlight,
+			Interval: interval,
+		})
+		c.requests = 0
+		if c.inFlight == 0 {
+			delete(fm.counters, mk)
+		}
+	}
+	return samples
+}
+
+// reportToExecutor periodically sends the request counts to the
+// executor, and drops function services the executor is draining from
+// fmap. It reports even when there are no samples, to keep receiving
+// drain notices.
+func (fm *functionMetrics) reportToExecutor(executor *executorClient.Client, fmap *functionServiceMap, interval time.Duration) {
+	routerID, err := os.Hostname()
+	if err != nil {
+		log.Printf("Error getting hostname, function metrics won't be reported: %v", err)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		samples := fm.takeSamples(routerID, interval)
+		notices, err := executor.ReportMetrics(samples)
+		if err != nil {
+			log.Printf("Error reporting function metrics: %v", err)
+			continue
+		}
+		for _, n := range notices {
+			fmap.removeAddress(&n.Function, n.Address)
+		}
+	}
+}
@@ -75,3 +75,15 @@ func (fmap *functionServiceMap) assign(f *metav1.ObjectMeta, serviceUrl *url.URL
 		// ignore error
 	}
 }
+
+// removeAddress drops the cached service of a function, if it's still
+// the service at address.
+func (fmap *functionServiceMap) removeAddress(f *metav1.ObjectMeta, address string) {
+	u, err := fmap.lookup(f)
+	if err != nil || u.Host != address {
+		return
+	}
+	log.Printf("Dropping service %v of function %v, it's being drained", address, f.Name)
+	mk := keyFromMetadata(f)
+	fmap.cache.Delete(*mk)
+}
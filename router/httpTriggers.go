@@ -39,6 +39,7 @@ type HTTPTriggerSet struct {
 
 	fissionClient     *crd.FissionClient
 	executor          *executorClient.Client
+	functionMetrics   *functionMetrics
 	resolver          *functionReferenceResolver
 	crdClient         *rest.RESTClient
 	triggers          []crd.HTTPTrigger
@@ -56,6 +57,7 @@ func makeHTTPTriggerSet(fmap *functionServiceMap, fissionClient *crd.FissionClie
 		triggers:           []crd.HTTPTrigger{},
 		fissionClient:      fissionClient,
 		executor:           executor,
+		functionMetrics:    makeFunctionMetrics(),
 		crdClient:          crdClient,
 	}
 	var tStore, fnStore k8sCache.Store
@@ -96,6 +98,14 @@ func routerHealthHandler(w http.ResponseWriter, r *http.Request) {
 func (ts *HTTPTriggerSet) getRouter() *mux.Router {
 	muxRouter := mux.NewRouter()
 
+	// Functions using the job executor are started as batch jobs
+	// instead of being proxied to, and functions with a concurrency
+	// limit need leases.
+	strategies := make(map[string]fission.ExecutionStrategy)
+	for _, function := range ts.functions {
+		strategies[function.Metadata.Name] = function.Spec.InvokeStrategy.ExecutionStrategy
+	}
+
 	// HTTP triggers setup by the user
 	homeHandled := false
 	for _, trigger := range ts.triggers {
@@ -116,13 +126,9 @@ func (ts *HTTPTriggerSet) getRouter() *mux.Router {
 			log.Panicf("resolve result type not implemented (%v)", rr.resolveResultType)
 		}
 
-		fh := &functionHandler{
-			fmap:     ts.functionServiceMap,
-			function: rr.functionMetadata,
-			executor: ts.executor,
-		}
+		fh := ts.getFunctionHandler(rr.functionMetadata, strategies)
 
-		ht := muxRouter.HandleFunc(trigger.Spec.RelativeURL, fh.handler)
+		ht := muxRouter.HandleFunc(trigger.Spec.RelativeURL, fh)
 		ht.Methods(trigger.Spec.Method)
 		if trigger.Spec.Host != "" {
 			ht.Host(trigger.Spec.Host)
@@ -146,20 +152,40 @@ func (ts *HTTPTriggerSet) getRouter() *mux.Router {
 	// triggers route into these.
 	for _, function := range ts.functions {
 		m := function.Metadata
-		fh := &functionHandler{
-			fmap:     ts.functionServiceMap,
-			function: &m,
-			executor: ts.executor,
-		}
-		muxRouter.HandleFunc(fission.UrlForFunction(function.Metadata.Name), fh.handler)
+		fh := ts.getFunctionHandler(&m, strategies)
+		muxRouter.HandleFunc(fission.UrlForFunction(function.Metadata.Name), fh)
 	}
 
+	// Status and results of batch invocations
+	muxRouter.HandleFunc(urlForInvocation("{invocation}"), invocationStatusHandler(ts.executor)).Methods("GET")
+
 	// Healthz endpoint for the router.
 	muxRouter.HandleFunc("/router-healthz", routerHealthHandler).Methods("GET")
 
 	return muxRouter
 }
 
+func (ts *HTTPTriggerSet) getFunctionHandler(m *metav1.ObjectMeta, strategies map[string]fission.ExecutionStrategy) http.HandlerFunc {
+	strategy := strategies[m.Name]
+	if strategy.ExecutorType == fission.ExecutorTypeJob {
+		bh := &batchHandler{
+			executor: ts.executor,
+			function: m,
+		}
+		return bh.handler
+	}
+	fh := &functionHandler{
+		fmap:     ts.functionServiceMap,
+		function: m,
+		executor: ts.executor,
+		metrics:  ts.functionMetrics,
+
+		maxConcurrency: strategy.MaxConcurrency,
+		leaseTimeout:   defaultLeaseTimeout,
+	}
+	return fh.handler
+}
+
 func (ts *HTTPTriggerSet) updateTriggerStatusFailed(ht *crd.HTTPTrigger, err error) {
 	// TODO
 }
@@ -89,6 +89,10 @@ func Start(port int, executorUrl string) {
 	triggers, _, fnStore := makeHTTPTriggerSet(fmap, fissionClient, executor, restClient)
 	resolver := makeFunctionReferenceResolver(fnStore)
 
+	// report request load to the executor, for autoscaling, and
+	// learn about function services being drained
+	go triggers.functionMetrics.reportToExecutor(executor, fmap, 5*time.Second)
+
 	log.Printf("Starting router at port %v\n", port)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -22,6 +22,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/fission/fission"
 	"github.com/fission/fission/crd"
 )
 
@@ -84,18 +85,33 @@ func (pruner *ArchivePruner) getOrphanArchives() error {
 		return err
 	}
 
-	// extract archives referenced by these pkgs
+	// function revisions keep copies of package specs, so that functions
+	// can be rolled back to them; their archives are in use too
+	revList, err := pruner.crdClient.FunctionRevisions(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		log.WithError(err).Error("Error getting function revision list from kubernetes")
+		return err
+	}
+	pkgSpecs := make([]fission.PackageSpec, 0, len(pkgList.Items)+len(revList.Items))
 	for _, pkg := range pkgList.Items {
-		if pkg.Spec.Deployment.URL != "" {
-			archiveID, err = getQueryParamValue(pkg.Spec.Deployment.URL, "id")
+		pkgSpecs = append(pkgSpecs, pkg.Spec)
+	}
+	for _, rev := range revList.Items {
+		pkgSpecs = append(pkgSpecs, rev.Spec.Package)
+	}
+
+	// extract archives referenced by these pkgs
+	for _, spec := range pkgSpecs {
+		if spec.Deployment.URL != "" {
+			archiveID, err = getQueryParamValue(spec.Deployment.URL, "id")
 			if err != nil {
 				log.WithError(err).Error("Error extracting value of archiveID from url")
 				return err
 			}
 			archivesRefByPkgs = append(archivesRefByPkgs, archiveID)
 		}
-		if pkg.Spec.Source.URL != "" {
-			archiveID, err = getQueryParamValue(pkg.Spec.Source.URL, "id")
+		if spec.Source.URL != "" {
+			archiveID, err = getQueryParamValue(spec.Source.URL, "id")
 			if err != nil {
 				log.WithError(err).Error("Error extracting value of archiveID from url")
 				return err
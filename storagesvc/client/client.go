@@ -35,6 +35,10 @@ import (
 type (
 	Client struct {
 		url string
+
+		// Transport of requests to the storage service; optional,
+		// defaults to http.DefaultTransport.
+		Transport http.RoundTripper
 	}
 )
 
@@ -45,6 +49,10 @@ func MakeClient(url string) *Client {
 	}
 }
 
+func (c *Client) httpClient() *http.Client {
+	return &http.Client{Transport: c.Transport}
+}
+
 // Upload sends the local file pointed to by filePath to the storage
 // service, along with the metadata.  It returns a file ID that can be
 // used to retrieve the file.
@@ -82,9 +90,7 @@ func (c *Client) Upload(filePath string, metadata *map[string]string) (string, e
 	req.Header["X-File-Size"] = []string{fmt.Sprintf("%v", fileSize)}
 	req.Header["Content-Type"] = []string{contentType}
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -132,7 +138,7 @@ func (c *Client) Download(id string, filePath string) error {
 	defer f.Close()
 
 	// make request
-	resp, err := http.Get(url)
+	resp, err := c.httpClient().Get(url)
 	if err != nil {
 		fmt.Println(err)
 		os.Remove(filePath)
@@ -162,9 +168,7 @@ func (c *Client) Delete(id string) error {
 		return err
 	}
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return err
 	}
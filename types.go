@@ -129,14 +129,84 @@ type (
 		// Reference to a package containing deployment and optionally the source
 		Package FunctionPackageRef `json:"package"`
 
-		Secrets    []SecretReference    `json:"secrets"`
-		ConfigMaps []ConfigMapReference `json:"configmaps"`
+		Secrets    []SecretReference    `json:"secrets,omitempty"`
+		ConfigMaps []ConfigMapReference `json:"configmaps,omitempty"`
 
 		// cpu and memory resources as per K8S standards
 		Resources v1.ResourceRequirements `json:"resources"`
 
 		// InvokeStrategy is a set of controls which affect how function executes
 		InvokeStrategy InvokeStrategy
+
+		// Container is the image to run for functions of executor type
+		// "container". Such functions don't use the Environment or
+		// Package; required for that executor type and ignored otherwise.
+		Container *FunctionContainer `json:"container,omitempty"`
+
+		// Optional. Customizes the pods running this function. Only
+		// applies to executors that run dedicated pods per function
+		// (newdeploy and container); it's merged on top of the
+		// environment's PodTemplate.
+		PodTemplate *PodTemplateOverlay `json:"podtemplate,omitempty"`
+
+		// Optional. The number of seconds an invocation of a function of
+		// executor type "job" may run before it's stopped; default one
+		// hour. Ignored by other executor types.
+		FunctionTimeout int `json:"functionTimeout,omitempty"`
+	}
+
+	// FunctionContainer is a user supplied image serving HTTP requests.
+	// It's run as is: there's no fetcher, package or specialization.
+	FunctionContainer struct {
+		// Image of the container; required.
+		Image string `json:"image"`
+
+		// Port defines the port on which the container listens for
+		// function requests. Optional; default 8888.
+		Port int32 `json:"port,omitempty"`
+
+		// Optional. Override the image's entrypoint and arguments.
+		Command []string `json:"command,omitempty"`
+		Args    []string `json:"args,omitempty"`
+	}
+
+	// PodTemplateOverlay customizes the pods that Fission creates for
+	// environments and functions. It is merged into the generated pod
+	// template with strategic merge semantics: maps are merged, Volumes
+	// and Env are merged by name, VolumeMounts by mount path, and any
+	// other field that is set replaces the generated value.
+	//
+	// Env, VolumeMounts and ContainerSecurityContext apply to the
+	// runtime (or builder) container only, not to the fetcher. Volumes
+	// can't use the names of the volumes Fission generates, such as
+	// "userfunc".
+	//
+	// Overlays are applied when the pods' deployment or job is created,
+	// not to existing ones: a changed environment overlay applies to
+	// the pool and builder the environment gets on update, but a
+	// changed function overlay only applies once the function's
+	// deployment is re-created, e.g. by deleting and re-creating the
+	// function.
+	PodTemplateOverlay struct {
+		// Annotations added to the pods, e.g. for service meshes
+		Annotations map[string]string `json:"annotations,omitempty"`
+
+		NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+		Tolerations  []v1.Toleration   `json:"tolerations,omitempty"`
+		Affinity     *v1.Affinity      `json:"affinity,omitempty"`
+
+		// ServiceAccountName replaces the default service account.
+		// The fetcher uses this account too, so it needs the same
+		// permissions as the default one (fission-fetcher or
+		// fission-builder).
+		ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+		SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+		Volumes         []v1.Volume            `json:"volumes,omitempty"`
+
+		Env                      []v1.EnvVar         `json:"env,omitempty"`
+		VolumeMounts             []v1.VolumeMount    `json:"volumeMounts,omitempty"`
+		ContainerSecurityContext *v1.SecurityContext `json:"containerSecurityContext,omitempty"`
 	}
 
 	/*InvokeStrategy is a set of controls over how the function executes.
@@ -158,18 +228,40 @@ type (
 
 	MinScale affects the cold start behaviour for a function. If MinScale is 0 then the
 	deployment is created on first invocation of function and is good for requests of
-	asynchronous nature. If MinScale is greater than 0 then MinScale number of pods are
+	asynchronous nature. Once such a function has been idle for a while, its deployment
+	is scaled back down to zero and scaled up again on the next invocation. If MinScale is greater than 0 then MinScale number of pods are
 	created at the time of creation of function. This ensures faster response during first
 	invocation at the cost of consuming resources.
 
 	MaxScale is the maximum number of pods that function will scale to based on TargetCPUPercent
 	and resources allocated to the function pod.
+
+	TargetRequestsPerSecond and TargetInFlightRequests scale the function on its request load
+	instead of CPU usage, which suits IO-bound functions. They are the per-pod targets for the
+	request rate and the number of concurrent requests, as reported by the routers. If either is
+	set, the executor scales the function itself and TargetCPUPercent is ignored.
+
+	Functions with ExecutorType job run once per invocation in a Kubernetes Job, which suits
+	long running batch work. The scale settings don't apply to them.
+
+	Prewarm makes the executor specialize a pod for a poolmgr function as soon as the function
+	is created or its package is built, instead of on the first invocation. Only a few functions
+	per environment are pre-warmed, so that the pool is still available for other functions.
+
+	MaxConcurrency limits the number of requests a function serves at once, across all routers,
+	for functions calling services that can only take a few concurrent calls, or runtimes that
+	handle one request at a time. Routers get a lease from the executor for each request; excess
+	requests are queued for a while and then rejected with 429 Too Many Requests. 0 means no limit.
 	*/
 	ExecutionStrategy struct {
-		ExecutorType     ExecutorType
-		MinScale         int
-		MaxScale         int
-		TargetCPUPercent int
+		ExecutorType            ExecutorType
+		MinScale                int
+		MaxScale                int
+		TargetCPUPercent        int
+		TargetRequestsPerSecond int
+		TargetInFlightRequests  int
+		Prewarm                 bool
+		MaxConcurrency          int
 	}
 
 	FunctionReferenceType string
@@ -238,6 +330,10 @@ type (
 
 		// The initial pool size for environment
 		Poolsize int `json:"poolsize,omitempty"`
+
+		// Optional. Customizes the runtime and builder pods of this
+		// environment.
+		PodTemplate *PodTemplateOverlay `json:"podtemplate,omitempty"`
 	}
 
 	AllowedFunctionsPerContainer string
@@ -256,7 +352,7 @@ type (
 	KubernetesWatchTriggerSpec struct {
 		Namespace         string            `json:"namespace"`
 		Type              string            `json:"type"`
-		LabelSelector     map[string]string `json:"labelselector"`
+		LabelSelector     map[string]string `json:"labelselector,omitempty"`
 		FunctionReference FunctionReference `json:"functionref"`
 	}
 
@@ -277,12 +373,124 @@ type (
 		FunctionReference `json:"functionref"`
 	}
 
+	//
+	// Quotas
+	//
+
+	// FunctionQuotaSpec limits the function capacity used by the
+	// functions of a namespace. Limits that are zero or missing aren't
+	// enforced.
+	FunctionQuotaSpec struct {
+		// MaxPods is the maximum number of pods running the
+		// namespace's functions. Specialized pool pods count as one
+		// pod; newdeploy and container functions count as MaxScale
+		// pods.
+		MaxPods int `json:"maxpods,omitempty"`
+
+		// MaxResources caps the total cpu and memory of those pods,
+		// using the limits of their function containers, or their
+		// requests if they have no limits.
+		MaxResources v1.ResourceList `json:"maxresources,omitempty"`
+
+		// MaxScale caps the MaxScale of each newdeploy and container
+		// function.
+		MaxScale int `json:"maxscale,omitempty"`
+	}
+
+	//
+	// Revisions
+	//
+
+	// FunctionRevisionSpec is a snapshot of a function, recorded by the
+	// controller each time the function is created or changed. Revisions
+	// are never updated; the oldest ones are deleted once a function has
+	// more than the controller's revision limit.
+	FunctionRevisionSpec struct {
+		// FunctionName is the name of the function in the revision's
+		// namespace.
+		FunctionName string `json:"functionName"`
+
+		// Revision numbers start at 1 for each function, and go up by
+		// one with every change.
+		Revision int `json:"revision"`
+
+		// Function is the function's spec as of this revision.
+		Function FunctionSpec `json:"function"`
+
+		// Package and PackageStatus are the function's package as of
+		// this revision. Packages are often updated in place, so
+		// rolling back restores them from here rather than relying on
+		// the package reference alone.
+		Package       PackageSpec   `json:"package"`
+		PackageStatus PackageStatus `json:"packageStatus"`
+
+		// RollbackOf is the revision that this one rolled the function
+		// back to, if it was recorded by a rollback.
+		RollbackOf int `json:"rollbackOf,omitempty"`
+	}
+
 	// Errors returned by the Fission API.
 	Error struct {
 		Code    errorCode `json:"code"`
 		Message string    `json:"message"`
 	}
 
+	// FieldError is a problem with one field of an object, such as
+	// "spec.method", found by validation.
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+
+	// ValidationResult lists the problems with one object of a set of
+	// objects validated by the controller.
+	ValidationResult struct {
+		Kind      string       `json:"kind"`
+		Namespace string       `json:"namespace"`
+		Name      string       `json:"name"`
+		Errors    []FieldError `json:"errors"`
+	}
+
+	// ApplyStatus lists the objects of one kind that applying a set of
+	// specs created, updated and deleted.
+	ApplyStatus struct {
+		Created []metav1.ObjectMeta `json:"created"`
+		Updated []metav1.ObjectMeta `json:"updated"`
+		Deleted []metav1.ObjectMeta `json:"deleted"`
+	}
+
+	// ApplyResult is the outcome of applying a set of specs on the
+	// controller. Status is keyed by kind; Packages are all the
+	// packages of the deployment, changed or not, so that their builds
+	// can be watched.
+	ApplyResult struct {
+		Status   map[string]ApplyStatus `json:"status"`
+		Packages []metav1.ObjectMeta    `json:"packages"`
+	}
+
+	// AuditEvent records a change made through the controller API.
+	// User is empty if authentication is disabled.
+	AuditEvent struct {
+		Time      metav1.Time  `json:"time"`
+		User      string       `json:"user,omitempty"`
+		ClientIP  string       `json:"clientIP"`
+		Verb      string       `json:"verb"`     // create, update or delete
+		Resource  string       `json:"resource"` // such as "functions"
+		Namespace string       `json:"namespace"`
+		Name      string       `json:"name"`
+		Changes   []SpecChange `json:"changes,omitempty"`
+	}
+
+	// SpecChange is a spec field that a change set, changed or
+	// removed. Field is a JSON path, such as "spec.environment.name";
+	// Old or New is missing if the field isn't set before or after
+	// the change.
+	SpecChange struct {
+		Field string      `json:"field"`
+		Old   interface{} `json:"old,omitempty"`
+		New   interface{} `json:"new,omitempty"`
+	}
+
 	errorCode int
 )
 
@@ -316,6 +524,18 @@ type (
 const EXECUTOR_INSTANCEID_LABEL string = "executorInstanceId"
 const POOLMGR_INSTANCEID_LABEL string = "poolmgrInstanceId"
 
+// Objects created from specs are annotated with the name and UID of
+// their deployment, so that they can be found when the specs change.
+const (
+	DEPLOYMENT_NAME_ANNOTATION = "fission-name"
+	DEPLOYMENT_UID_ANNOTATION  = "fission-uid"
+)
+
+// Packages that the CLI creates for a function are annotated with the
+// function's name. The controller makes such packages owned by their
+// function, so that they're deleted along with it.
+const PACKAGE_FOR_FUNCTION_ANNOTATION = "fission-function"
+
 const (
 	ChecksumTypeSHA256 ChecksumType = "sha256"
 )
@@ -345,6 +565,8 @@ const (
 const (
 	ExecutorTypePoolmgr   = "poolmgr"
 	ExecutorTypeNewdeploy = "newdeploy"
+	ExecutorTypeContainer = "container"
+	ExecutorTypeJob       = "job"
 )
 
 const (
@@ -373,6 +595,8 @@ const (
 	ErrorNotImplmented
 	ErrorChecksumFail
 	ErrorSizeLimitExceeded
+	ErrorNotAuthenticated
+	ErrorTooManyRequests
 )
 
 // must match order and len of the above const
@@ -386,6 +610,8 @@ var errorDescriptions = []string{
 	"Not implemented",
 	"Checksum verification failed",
 	"Size limit exceeded",
+	"Not authenticated",
+	"Too many requests",
 }
 
 const (